@@ -0,0 +1,9 @@
+// Package simplefsd 内置在可执行文件中的默认数据文件与邮件模板
+package simplefsd
+
+import "embed"
+
+// Assets 离线环境下使用的默认文件, 路径与仓库中的路径一致
+//
+//go:embed data/airport.json template/*.template
+var Assets embed.FS
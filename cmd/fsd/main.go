@@ -4,12 +4,15 @@ import (
 	"flag"
 	"fmt"
 	"github.com/half-nothing/simple-fsd/internal/base"
+	"github.com/half-nothing/simple-fsd/internal/cli"
 	"github.com/half-nothing/simple-fsd/internal/database"
 	"github.com/half-nothing/simple-fsd/internal/fsd_server"
 	"github.com/half-nothing/simple-fsd/internal/http_server"
 	"github.com/half-nothing/simple-fsd/internal/interfaces"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/global"
+	"github.com/half-nothing/simple-fsd/internal/metrics"
+	"os"
 )
 
 func recoverFromError() {
@@ -21,6 +24,11 @@ func recoverFromError() {
 func main() {
 	flag.Parse()
 
+	// 带有子命令时执行管理命令而不是启动服务器
+	if flag.NArg() > 0 {
+		os.Exit(cli.Run(flag.Args()))
+	}
+
 	defer recoverFromError()
 
 	logger := base.NewLogger()
@@ -34,6 +42,7 @@ func main() {
 
 	configManager := base.NewManager(logger)
 	config := configManager.Config()
+	configManager.WatchSignal()
 
 	if err := fsd.SyncRatingConfig(config); err != nil {
 		logger.FatalF("Error occurred while handle rating base, details: %v", err)
@@ -54,6 +63,14 @@ func main() {
 		go http_server.StartHttpServer(applicationContent)
 	}
 
+	if metricsConfig := config.Server.MetricsServer; metricsConfig.Enabled {
+		if metricsConfig.Standalone() {
+			go metrics.StartMetricsServer(applicationContent)
+		} else if !config.Server.HttpServer.Enabled {
+			logger.Warn("Metrics server port is 0 but http server is disabled, metrics endpoint will not be available")
+		}
+	}
+
 	//if config.Server.GRPCServer.Enabled {
 	//	go grpc_server.StartGRPCServer(applicationContent)
 	//}
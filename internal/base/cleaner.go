@@ -42,6 +42,11 @@ func (c *Cleaner) Add(callable Callable) {
 
 func (c *Cleaner) Clean() {
 	c.mu.Lock()
+	if c.cleaning {
+		// 清理已经在进行中(例如信号与排空模式同时触发), 等待其完成后退出进程
+		c.mu.Unlock()
+		select {}
+	}
 	c.cleaning = true // 标记为清理中，阻止后续Add操作
 	cleanersCopy := make([]Callable, len(c.cleaners))
 	copy(cleanersCopy, c.cleaners)
@@ -1,16 +1,23 @@
 package base
 
 import (
-	"encoding/json"
 	"errors"
+	"fmt"
 	. "github.com/half-nothing/simple-fsd/internal/interfaces/config"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/global"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
 	"github.com/half-nothing/simple-fsd/internal/utils"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
 )
 
-func readConfig(logger log.LoggerInterface) (*Config, *ValidResult) {
+// LoadConfig 按照 默认值 -> 配置文件 -> SIMPLEFSD_* 环境变量 -> *_FILE 密钥文件 的顺序加载配置, 不进行校验
+// withEnv 为false时只加载默认值与配置文件
+func LoadConfig(logger log.LoggerInterface, withEnv bool) (*Config, *ValidResult) {
 	config := DefaultConfig()
 
 	// 读取配置文件
@@ -20,19 +27,38 @@ func readConfig(logger log.LoggerInterface) (*Config, *ValidResult) {
 			return nil, ValidFailWith(errors.New("fail to save configuration file while creating configuration file"), err)
 		}
 		return nil, ValidFail(errors.New("the configuration file does not exist and has been created. Please try again after editing the configuration file"))
-	} else if err := json.Unmarshal(bytes, config); err != nil {
-		// 解析JSON配置
-		return nil, ValidFailWith(errors.New("the configuration file does not contain valid JSON"), err)
-	} else if result := config.CheckValid(logger); result.IsFail() {
+	} else if err := decodeConfigFile(*global.ConfigFilePath, bytes, config); err != nil {
+		// 解析配置文件
+		return nil, ValidFailWith(errors.New("the configuration file is not valid"), err)
+	}
+
+	if !withEnv {
+		return config, ValidPass()
+	}
+	if applied, err := applyEnvOverrides(config); err != nil {
+		return nil, ValidFailWith(errors.New("fail to apply environment variables to configuration"), err)
+	} else if len(applied) > 0 {
+		logger.InfoF("Configuration overridden by environment variables: %s", strings.Join(applied, ", "))
+	}
+	return config, ValidPass()
+}
+
+// ReadConfig 读取并校验配置文件
+func ReadConfig(logger log.LoggerInterface) (*Config, *ValidResult) {
+	config, result := LoadConfig(logger, true)
+	if result.IsFail() {
+		return nil, result
+	}
+	if result := config.CheckValid(logger); result.IsFail() {
 		return nil, result
 	}
 	return config, ValidPass()
 }
 
 func saveConfig(config *Config) error {
-	if writer, err := os.OpenFile(*global.ConfigFilePath, os.O_WRONLY|os.O_CREATE, global.DefaultFilePermissions); err != nil {
+	if data, err := encodeConfigFile(*global.ConfigFilePath, config); err != nil {
 		return err
-	} else if data, err := json.MarshalIndent(config, "", "\t"); err != nil {
+	} else if writer, err := os.OpenFile(*global.ConfigFilePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, global.DefaultFilePermissions); err != nil {
 		return err
 	} else if _, err = writer.Write(data); err != nil {
 		return err
@@ -43,8 +69,10 @@ func saveConfig(config *Config) error {
 }
 
 type Manager struct {
-	config *utils.CachedValue[Config]
-	logger log.LoggerInterface
+	config    *utils.CachedValue[Config]
+	logger    log.LoggerInterface
+	reloadMu  sync.Mutex
+	listeners []func(config *Config)
 }
 
 func NewManager(logger log.LoggerInterface) *Manager {
@@ -56,7 +84,7 @@ func NewManager(logger log.LoggerInterface) *Manager {
 }
 
 func (manager *Manager) getConfig() *Config {
-	if config, result := readConfig(manager.logger); result.IsFail() {
+	if config, result := ReadConfig(manager.logger); result.IsFail() {
 		manager.logger.Fatal(result.Error().Error())
 		panic(result.OriginErr())
 	} else {
@@ -71,3 +99,55 @@ func (manager *Manager) Config() *Config {
 func (manager *Manager) SaveConfig() error {
 	return saveConfig(manager.Config())
 }
+
+func (manager *Manager) OnReload(listener func(config *Config)) {
+	manager.reloadMu.Lock()
+	defer manager.reloadMu.Unlock()
+	manager.listeners = append(manager.listeners, listener)
+}
+
+// Reload 重新读取配置文件, 校验通过后替换可热重载的配置项, 其余变化的配置项需要重启才能生效
+func (manager *Manager) Reload() (*ReloadResult, error) {
+	manager.reloadMu.Lock()
+	defer manager.reloadMu.Unlock()
+
+	newConfig, result := ReadConfig(manager.logger)
+	if result.IsFail() {
+		if result.OriginErr() != nil {
+			return nil, fmt.Errorf("%v: %v", result.Error(), result.OriginErr())
+		}
+		return nil, result.Error()
+	}
+	if err := fsd.SyncRatingConfig(newConfig); err != nil {
+		return nil, err
+	}
+
+	config := manager.Config()
+	reloadResult, err := config.ApplyHotReload(newConfig)
+	if err != nil {
+		return nil, err
+	}
+	for _, listener := range manager.listeners {
+		listener(config)
+	}
+
+	manager.logger.InfoF("Configuration reloaded, applied: [%s]", strings.Join(reloadResult.Applied, ", "))
+	if len(reloadResult.RestartRequired) > 0 {
+		manager.logger.WarnF("Configuration fields changed but require restart: [%s]", strings.Join(reloadResult.RestartRequired, ", "))
+	}
+	return reloadResult, nil
+}
+
+// WatchSignal 收到SIGHUP信号时重载配置
+func (manager *Manager) WatchSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for range signals {
+			manager.logger.Info("Received SIGHUP, reloading configuration")
+			if _, err := manager.Reload(); err != nil {
+				manager.logger.ErrorF("Fail to reload configuration, keep using current configuration: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,276 @@
+package base
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/config"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// EnvPrefix 环境变量前缀, 例如 SIMPLEFSD_DATABASE_PASSWORD 对应 database.password
+	EnvPrefix = "SIMPLEFSD_"
+	// EnvFileSuffix 密钥文件后缀, 例如 SIMPLEFSD_DATABASE_PASSWORD_FILE=/run/secrets/db_password
+	EnvFileSuffix = "_FILE"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported configuration file format")
+
+type configFormat int
+
+const (
+	formatJson configFormat = iota
+	formatYaml
+	formatToml
+)
+
+func formatOf(path string) (configFormat, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", "":
+		return formatJson, nil
+	case ".yaml", ".yml":
+		return formatYaml, nil
+	case ".toml":
+		return formatToml, nil
+	default:
+		return formatJson, fmt.Errorf("%w: %s", ErrUnsupportedFormat, filepath.Ext(path))
+	}
+}
+
+// decodeConfigFile 按照文件扩展名解析配置文件并覆盖到config上, 所有格式都使用json标签作为字段名
+func decodeConfigFile(path string, data []byte, config *Config) error {
+	format, err := formatOf(path)
+	if err != nil {
+		return err
+	}
+	if format == formatJson {
+		return json.Unmarshal(data, config)
+	}
+	tree := make(map[string]interface{})
+	switch format {
+	case formatYaml:
+		err = yaml.Unmarshal(data, &tree)
+	case formatToml:
+		err = toml.Unmarshal(data, &tree)
+	}
+	if err != nil {
+		return err
+	}
+	jsonData, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(jsonData, config)
+}
+
+// encodeConfigFile 按照文件扩展名序列化配置
+func encodeConfigFile(path string, config *Config) ([]byte, error) {
+	format, err := formatOf(path)
+	if err != nil {
+		return nil, err
+	}
+	if format == formatJson {
+		return json.MarshalIndent(config, "", "\t")
+	}
+	tree, err := ConfigTree(config)
+	if err != nil {
+		return nil, err
+	}
+	normalizeNumbers(tree)
+	if format == formatYaml {
+		return yaml.Marshal(tree)
+	}
+	buffer := &bytes.Buffer{}
+	err = toml.NewEncoder(buffer).Encode(tree)
+	return buffer.Bytes(), err
+}
+
+// ConfigTree 将配置转换为以json标签为键的通用树
+func ConfigTree(config *Config) (map[string]interface{}, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	tree := make(map[string]interface{})
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err = decoder.Decode(&tree)
+	return tree, err
+}
+
+// normalizeNumbers 将 json.Number 还原为整数或浮点数, 避免YAML与TOML将整数输出为字符串或浮点数
+func normalizeNumbers(tree map[string]interface{}) {
+	for key, value := range tree {
+		tree[key] = normalizeNumber(value)
+	}
+}
+
+func normalizeNumber(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		normalizeNumbers(v)
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = normalizeNumber(v[i])
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// envName 配置路径对应的环境变量名
+func envName(path []string) string {
+	return EnvPrefix + strings.ToUpper(strings.Join(path, "_"))
+}
+
+// lookupEnv 读取环境变量, 若存在 *_FILE 变量则读取其指向的文件内容
+func lookupEnv(name string) (string, bool, error) {
+	value, ok := os.LookupEnv(name)
+	file, fileOk := os.LookupEnv(name + EnvFileSuffix)
+	if ok && fileOk {
+		return "", false, fmt.Errorf("both %s and %s%s are set", name, name, EnvFileSuffix)
+	}
+	if fileOk {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", false, fmt.Errorf("fail to read secret file %s from %s%s: %v", file, name, EnvFileSuffix, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), true, nil
+	}
+	return value, ok, nil
+}
+
+// parseEnvValue 按照配置项当前值的类型解析环境变量
+func parseEnvValue(name, raw string, current interface{}) (interface{}, error) {
+	switch current.(type) {
+	case string:
+		return raw, nil
+	case bool:
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bool value for %s: %v", name, err)
+		}
+		return value, nil
+	case json.Number:
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return nil, fmt.Errorf("invalid number value for %s: %v", name, err)
+		}
+		return json.Number(raw), nil
+	default:
+		var value interface{}
+		if err := json.Unmarshal([]byte(raw), &value); err != nil {
+			return nil, fmt.Errorf("invalid json value for %s: %v", name, err)
+		}
+		return value, nil
+	}
+}
+
+// collectEnvOverrides 遍历配置树, 收集所有被环境变量覆盖的配置项, 父节点先于子节点
+func collectEnvOverrides(path []string, node interface{}, overrides map[string]interface{}, applied *[]string) error {
+	if len(path) > 0 {
+		name := envName(path)
+		raw, ok, err := lookupEnv(name)
+		if err != nil {
+			return err
+		}
+		if ok {
+			value, err := parseEnvValue(name, raw, node)
+			if err != nil {
+				return err
+			}
+			setTreeValue(overrides, path, value)
+			*applied = append(*applied, name)
+		}
+	}
+	children, ok := node.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	keys := make([]string, 0, len(children))
+	for key := range children {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := collectEnvOverrides(append(path[:len(path):len(path)], key), children[key], overrides, applied); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setTreeValue(tree map[string]interface{}, path []string, value interface{}) {
+	for _, key := range path[:len(path)-1] {
+		child, ok := tree[key].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			tree[key] = child
+		}
+		tree = child
+	}
+	tree[path[len(path)-1]] = value
+}
+
+// applyEnvOverrides 将 SIMPLEFSD_* 环境变量与 *_FILE 密钥文件覆盖到配置上, 返回生效的环境变量名
+func applyEnvOverrides(config *Config) ([]string, error) {
+	tree, err := ConfigTree(config)
+	if err != nil {
+		return nil, err
+	}
+	overrides := make(map[string]interface{})
+	applied := make([]string, 0)
+	if err := collectEnvOverrides(nil, tree, overrides, &applied); err != nil {
+		return nil, err
+	}
+	if len(applied) == 0 {
+		return applied, nil
+	}
+	data, err := json.Marshal(overrides)
+	if err != nil {
+		return nil, err
+	}
+	return applied, json.Unmarshal(data, config)
+}
+
+// secretSuffixes 输出配置时需要隐藏的字段名后缀, 如password, client_secret, token, access_key
+var secretSuffixes = []string{"password", "secret", "token", "key"}
+
+func isSecretField(key string) bool {
+	for _, suffix := range secretSuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactSecrets 隐藏配置树中的密钥字段
+func RedactSecrets(tree map[string]interface{}) {
+	for key, value := range tree {
+		if child, ok := value.(map[string]interface{}); ok {
+			RedactSecrets(child)
+			continue
+		}
+		if isSecretField(key) {
+			if str, ok := value.(string); ok && str != "" {
+				tree[key] = "******"
+			}
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package base
+
+import (
+	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyEnvOverrides(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "jwt_secret")
+	if err := os.WriteFile(secretFile, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SIMPLEFSD_DATABASE_PASSWORD", "db-password")
+	t.Setenv("SIMPLEFSD_SERVER_FSD_SERVER_PORT", "7000")
+	t.Setenv("SIMPLEFSD_SERVER_HTTP_SERVER_ENABLED", "true")
+	t.Setenv("SIMPLEFSD_SERVER_FSD_SERVER_MOTD", `["line1","line2"]`)
+	t.Setenv("SIMPLEFSD_SERVER_HTTP_SERVER_JWT_SECRET_FILE", secretFile)
+
+	cfg := config.DefaultConfig()
+	applied, err := applyEnvOverrides(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 5 {
+		t.Errorf("expected 5 applied env vars, got %v", applied)
+	}
+	if cfg.Database.Password != "db-password" {
+		t.Errorf("database password not overridden: %q", cfg.Database.Password)
+	}
+	if cfg.Server.FSDServer.Port != 7000 {
+		t.Errorf("fsd port not overridden: %d", cfg.Server.FSDServer.Port)
+	}
+	if !cfg.Server.HttpServer.Enabled {
+		t.Error("http server enabled not overridden")
+	}
+	if len(cfg.Server.FSDServer.Motd) != 2 || cfg.Server.FSDServer.Motd[1] != "line2" {
+		t.Errorf("motd not overridden: %v", cfg.Server.FSDServer.Motd)
+	}
+	if cfg.Server.HttpServer.JWT.Secret != "from-file" {
+		t.Errorf("jwt secret not loaded from file: %q", cfg.Server.HttpServer.JWT.Secret)
+	}
+	// 未被覆盖的字段保持默认值
+	if cfg.Server.HttpServer.Port != 6810 {
+		t.Errorf("http port changed unexpectedly: %d", cfg.Server.HttpServer.Port)
+	}
+}
+
+func TestApplyEnvOverridesInvalid(t *testing.T) {
+	t.Setenv("SIMPLEFSD_SERVER_FSD_SERVER_PORT", "not-a-number")
+	if _, err := applyEnvOverrides(config.DefaultConfig()); err == nil {
+		t.Error("expected error for invalid number")
+	}
+}
+
+func TestApplyEnvOverridesConflict(t *testing.T) {
+	t.Setenv("SIMPLEFSD_DATABASE_PASSWORD", "a")
+	t.Setenv("SIMPLEFSD_DATABASE_PASSWORD_FILE", "/nonexistent")
+	if _, err := applyEnvOverrides(config.DefaultConfig()); err == nil {
+		t.Error("expected error when both value and file are set")
+	}
+}
+
+func TestDecodeConfigFileFormats(t *testing.T) {
+	cases := map[string]string{
+		"config.yaml": "server:\n  fsd_server:\n    port: 7001\n",
+		"config.toml": "[server.fsd_server]\nport = 7001\n",
+		"config.json": `{"server":{"fsd_server":{"port":7001}}}`,
+	}
+	for name, content := range cases {
+		cfg := config.DefaultConfig()
+		if err := decodeConfigFile(name, []byte(content), cfg); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if cfg.Server.FSDServer.Port != 7001 {
+			t.Errorf("%s: port not decoded: %d", name, cfg.Server.FSDServer.Port)
+		}
+		if cfg.Server.HttpServer.Port != 6810 {
+			t.Errorf("%s: default value lost: %d", name, cfg.Server.HttpServer.Port)
+		}
+	}
+	if err := decodeConfigFile("config.ini", nil, config.DefaultConfig()); err == nil {
+		t.Error("expected error for unsupported format")
+	}
+}
+
+func TestRedactSecrets(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Database.Password = "db-password"
+	cfg.Server.MetricsServer.Token = "metrics-token"
+	cfg.Server.HttpServer.OAuth.SigningKeyFile = "oauth_signing_key.pem"
+	tree, err := ConfigTree(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	RedactSecrets(tree)
+	database := tree["database"].(map[string]interface{})
+	if database["password"] != "******" {
+		t.Errorf("password not redacted: %v", database["password"])
+	}
+	server := tree["server"].(map[string]interface{})
+	if token := server["metrics_server"].(map[string]interface{})["token"]; token != "******" {
+		t.Errorf("metrics token not redacted: %v", token)
+	}
+	oauth := server["http_server"].(map[string]interface{})["oauth"].(map[string]interface{})
+	if oauth["signing_key_file"] != "oauth_signing_key.pem" {
+		t.Errorf("signing key file path redacted: %v", oauth["signing_key_file"])
+	}
+}
@@ -0,0 +1,123 @@
+// Package cli fsd 可执行文件的管理子命令
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/half-nothing/simple-fsd/internal/base"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/global"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+var ErrUsage = errors.New("invalid usage")
+
+// Context 子命令运行上下文, 配置文件按需加载
+type Context struct {
+	Logger log.LoggerInterface
+	Stdout io.Writer
+	Stdin  io.Reader
+	config *config.Config
+}
+
+// Config 读取并校验配置文件
+func (ctx *Context) Config() (*config.Config, error) {
+	if ctx.config != nil {
+		return ctx.config, nil
+	}
+	cfg, result := base.ReadConfig(ctx.Logger)
+	if result.IsFail() {
+		if result.OriginErr() != nil {
+			return nil, fmt.Errorf("%v: %v", result.Error(), result.OriginErr())
+		}
+		return nil, result.Error()
+	}
+	ctx.config = cfg
+	return cfg, nil
+}
+
+func (ctx *Context) Printf(format string, v ...interface{}) {
+	_, _ = fmt.Fprintf(ctx.Stdout, format, v...)
+}
+
+type command struct {
+	usage       string
+	description string
+	run         func(ctx *Context, args []string) error
+}
+
+type commandGroup map[string]*command
+
+var commands = map[string]commandGroup{
+	"user":    userCommands,
+	"config":  configCommands,
+	"db":      databaseCommands,
+	"clients": clientCommands,
+}
+
+// Run 执行子命令, 返回进程退出码
+func Run(args []string) int {
+	logger := base.NewLogger()
+	logger.Init(*global.DebugMode)
+	defer func() { _ = logger.ShutdownCallback().Invoke(context.Background()) }()
+
+	ctx := &Context{Logger: logger, Stdout: os.Stdout, Stdin: os.Stdin}
+
+	if len(args) < 2 {
+		printUsage(ctx, args)
+		return 2
+	}
+	group, ok := commands[args[0]]
+	if !ok {
+		printUsage(ctx, nil)
+		return 2
+	}
+	cmd, ok := group[args[1]]
+	if !ok {
+		printUsage(ctx, args[:1])
+		return 2
+	}
+	if err := cmd.run(ctx, args[2:]); err != nil {
+		if errors.Is(err, ErrUsage) {
+			_, _ = fmt.Fprintf(os.Stderr, "usage: fsd %s %s %s\n", args[0], args[1], cmd.usage)
+			return 2
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func printUsage(ctx *Context, args []string) {
+	groups := make([]string, 0, len(commands))
+	for name := range commands {
+		if len(args) > 0 && args[0] != name {
+			continue
+		}
+		groups = append(groups, name)
+	}
+	if len(groups) == 0 {
+		for name := range commands {
+			groups = append(groups, name)
+		}
+	}
+	sort.Strings(groups)
+	ctx.Printf("usage: fsd [global flags] <command> <subcommand> [args]\n\n")
+	for _, groupName := range groups {
+		group := commands[groupName]
+		names := make([]string, 0, len(group))
+		for name := range group {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			cmd := group[name]
+			ctx.Printf("  %-48s %s\n", strings.TrimSpace(fmt.Sprintf("%s %s %s", groupName, name, cmd.usage)), cmd.description)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/global"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/service"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+var clientCommands = commandGroup{
+	"list": {
+		usage:       "[-server url]",
+		description: "列出运行中服务器的在线客户端",
+		run:         clientsList,
+	},
+	"kick": {
+		usage:       "[-server url] [-token jwt | -username name [-totp code]] [-reason text] <callsign>",
+		description: "通过HTTP API踢出客户端",
+		run:         clientsKick,
+	},
+}
+
+// apiClient 调用运行中服务器HTTP API的客户端
+type apiClient struct {
+	server string
+	token  string
+	client *http.Client
+}
+
+// serverAddress 返回服务器地址, 未指定时使用配置文件中的 server_address
+func serverAddress(ctx *Context, server string) string {
+	if server != "" {
+		return strings.TrimRight(server, "/")
+	}
+	// 配置文件不存在时不创建默认配置
+	if _, err := os.Stat(*global.ConfigFilePath); err != nil {
+		return "http://127.0.0.1:6810"
+	}
+	if cfg, err := ctx.Config(); err == nil && cfg.Server.HttpServer.ServerAddress != "" {
+		return strings.TrimRight(cfg.Server.HttpServer.ServerAddress, "/")
+	}
+	return "http://127.0.0.1:6810"
+}
+
+func newApiClient(server string) *apiClient {
+	return &apiClient{server: server, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// call 发送请求并将响应中的data字段解析到result
+func (c *apiClient) call(method, path string, body interface{}, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.server+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	response := &service.ApiResponse[json.RawMessage]{}
+	if err := json.NewDecoder(res.Body).Decode(response); err != nil {
+		return fmt.Errorf("unexpected response from %s %s: %s", method, path, res.Status)
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", response.Code, response.Message)
+	}
+	if result != nil && response.Data != nil {
+		return json.Unmarshal(*response.Data, result)
+	}
+	return nil
+}
+
+func clientsList(ctx *Context, args []string) error {
+	flags := flag.NewFlagSet("clients list", flag.ContinueOnError)
+	server := flags.String("server", "", "HTTP server address")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		return ErrUsage
+	}
+
+	api := newApiClient(serverAddress(ctx, *server))
+	// 在线列表接口直接返回JSON而不是ApiResponse
+	res, err := api.client.Get(api.server + "/api/clients")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response: %s", res.Status)
+	}
+	clients := &service.OnlineClients{}
+	if err := json.NewDecoder(res.Body).Decode(clients); err != nil {
+		return err
+	}
+
+	ctx.Printf("%-12s %-8s %-4s %s\n", "CALLSIGN", "CID", "TYPE", "INFO")
+	for _, controller := range clients.Controllers {
+		ctx.Printf("%-12s %-8d %-4s %.3f %s\n", controller.Callsign, controller.Cid, "ATC",
+			float64(controller.Frequency)/1000, controller.RealName)
+	}
+	for _, pilot := range clients.Pilots {
+		ctx.Printf("%-12s %-8d %-4s %s FL%03d %dkt\n", pilot.Callsign, pilot.Cid, "PLT",
+			pilot.RealName, pilot.Altitude/100, pilot.GroundSpeed)
+	}
+	ctx.Printf("%d pilots, %d controllers online\n", len(clients.Pilots), len(clients.Controllers))
+	return nil
+}
+
+func clientsKick(ctx *Context, args []string) error {
+	flags := flag.NewFlagSet("clients kick", flag.ContinueOnError)
+	server := flags.String("server", "", "HTTP server address")
+	token := flags.String("token", os.Getenv("SIMPLEFSD_TOKEN"), "JWT token")
+	username := flags.String("username", "", "username used to login when no token given")
+	totpCode := flags.String("totp", "", "two-factor code or recovery code used to login")
+	reason := flags.String("reason", "", "kick reason")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return ErrUsage
+	}
+
+	api := newApiClient(serverAddress(ctx, *server))
+	api.token = *token
+	if api.token == "" {
+		if *username == "" {
+			return fmt.Errorf("either -token or -username must be given")
+		}
+		password, err := readPassword(ctx)
+		if err != nil {
+			return err
+		}
+		login := &service.ResponseUserLogin{}
+		if err := api.call(http.MethodPost, "/api/sessions",
+			&service.RequestUserLogin{Username: *username, Password: password, TotpCode: *totpCode}, login); err != nil {
+			return err
+		}
+		api.token = login.Token
+	}
+
+	callsign := strings.ToUpper(flags.Arg(0))
+	if err := api.call(http.MethodDelete, "/api/clients/"+url.PathEscape(callsign),
+		map[string]string{"reason": *reason}, nil); err != nil {
+		return err
+	}
+	ctx.Printf("client %s kicked\n", callsign)
+	return nil
+}
@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"github.com/half-nothing/simple-fsd/internal/base"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/global"
+)
+
+var configCommands = commandGroup{
+	"validate": {
+		usage:       "",
+		description: "校验配置文件",
+		run:         configValidate,
+	},
+	"print": {
+		usage:       "[-effective]",
+		description: "输出配置文件, -effective 输出叠加环境变量并校验后的实际配置, 密钥字段会被隐藏",
+		run:         configPrint,
+	},
+	"print-default": {
+		usage:       "",
+		description: "输出默认配置文件",
+		run:         configPrintDefault,
+	},
+}
+
+func configValidate(ctx *Context, args []string) error {
+	if len(args) != 0 {
+		return ErrUsage
+	}
+	if _, err := ctx.Config(); err != nil {
+		return err
+	}
+	ctx.Printf("configuration file %s is valid\n", *global.ConfigFilePath)
+	return nil
+}
+
+func configPrintDefault(ctx *Context, args []string) error {
+	if len(args) != 0 {
+		return ErrUsage
+	}
+	data, err := json.MarshalIndent(config.DefaultConfig(), "", "\t")
+	if err != nil {
+		return err
+	}
+	ctx.Printf("%s\n", data)
+	return nil
+}
+
+func configPrint(ctx *Context, args []string) error {
+	flags := flag.NewFlagSet("config print", flag.ContinueOnError)
+	effective := flags.Bool("effective", false, "print the effective configuration")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		return ErrUsage
+	}
+	var cfg *config.Config
+	if *effective {
+		c, err := ctx.Config()
+		if err != nil {
+			return err
+		}
+		cfg = c
+	} else {
+		c, result := base.LoadConfig(ctx.Logger, false)
+		if result.IsFail() {
+			return result.Error()
+		}
+		cfg = c
+	}
+	tree, err := base.ConfigTree(cfg)
+	if err != nil {
+		return err
+	}
+	base.RedactSecrets(tree)
+	data, err := json.MarshalIndent(tree, "", "\t")
+	if err != nil {
+		return err
+	}
+	ctx.Printf("%s\n", data)
+	return nil
+}
@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"github.com/half-nothing/simple-fsd/internal/database"
+	"os"
+)
+
+var databaseCommands = commandGroup{
+	"migrate": {
+		usage:       "",
+		description: "执行数据库迁移",
+		run:         databaseMigrate,
+	},
+	"export": {
+		usage:       "<file>",
+		description: "导出数据库为JSON",
+		run:         databaseExport,
+	},
+	"import": {
+		usage:       "<file>",
+		description: "从JSON导入数据库, 需要目标库为空",
+		run:         databaseImport,
+	},
+}
+
+func databaseMigrate(ctx *Context, args []string) error {
+	if len(args) != 0 {
+		return ErrUsage
+	}
+	cfg, err := ctx.Config()
+	if err != nil {
+		return err
+	}
+	if err := database.MigrateDatabase(ctx.Logger, cfg); err != nil {
+		return err
+	}
+	ctx.Printf("database migrated\n")
+	return nil
+}
+
+func databaseExport(ctx *Context, args []string) error {
+	if len(args) != 1 {
+		return ErrUsage
+	}
+	cfg, err := ctx.Config()
+	if err != nil {
+		return err
+	}
+	// 导出文件包含密码哈希与两步验证密钥, 只允许所有者读写
+	file, err := os.OpenFile(args[0], os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := database.ExportDatabase(ctx.Logger, cfg, file); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	ctx.Printf("database exported to %s\n", args[0])
+	return nil
+}
+
+func databaseImport(ctx *Context, args []string) error {
+	if len(args) != 1 {
+		return ErrUsage
+	}
+	cfg, err := ctx.Config()
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	if err := database.ImportDatabase(ctx.Logger, cfg, file); err != nil {
+		return err
+	}
+	ctx.Printf("database imported from %s\n", args[0])
+	return nil
+}
@@ -0,0 +1,267 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/half-nothing/simple-fsd/internal/database"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"github.com/half-nothing/simple-fsd/internal/utils"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var userCommands = commandGroup{
+	"create": {
+		usage:       "<username> <email> <cid>",
+		description: "创建用户, 密码从标准输入读取",
+		run:         userCreate,
+	},
+	"passwd": {
+		usage:       "<cid|username|email>",
+		description: "重置用户密码, 密码从标准输入读取",
+		run:         userPasswd,
+	},
+	"grant": {
+		usage:       "<cid|username|email> <permission|-permission|all>...",
+		description: "授予(前缀-为撤销)用户权限",
+		run:         userGrant,
+	},
+	"rating": {
+		usage:       "<cid|username|email> <rating>",
+		description: "设置用户管制权限, 可使用数字或简称(如 S2)",
+		run:         userRating,
+	},
+	"unban": {
+		usage:       "<cid|username|email>",
+		description: "解除用户的临时封禁",
+		run:         userUnban,
+	},
+}
+
+// databaseOperations 连接数据库并返回数据库操作
+func databaseOperations(ctx *Context) (*operation.DatabaseOperations, error) {
+	cfg, err := ctx.Config()
+	if err != nil {
+		return nil, err
+	}
+	if err := fsd.SyncRatingConfig(cfg); err != nil {
+		return nil, err
+	}
+	_, operations, err := database.ConnectDatabase(ctx.Logger, cfg, false)
+	return operations, err
+}
+
+// userOperation 连接数据库并返回用户操作接口
+func userOperation(ctx *Context) (operation.UserOperationInterface, error) {
+	operations, err := databaseOperations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return operations.UserOperation(), nil
+}
+
+// revokeSessions 注销用户的所有网页会话, 用于密码、权限与管制权限变更后强制重新登录
+func revokeSessions(ctx *Context, operations *operation.DatabaseOperations, user *operation.User) {
+	if err := operations.UserSessionOperation().RevokeUserSessions(user.Cid, ""); err != nil {
+		ctx.Printf("warning: fail to revoke sessions of user %s(%04d), %v\n", user.Username, user.Cid, err)
+	}
+}
+
+// readPassword 从标准输入读取密码, 不接受命令行参数以免密码留在shell历史与进程列表中
+func readPassword(ctx *Context) (string, error) {
+	ctx.Printf("password: ")
+	line, err := bufio.NewReader(ctx.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("password can not be empty")
+	}
+	return password, nil
+}
+
+func userCreate(ctx *Context, args []string) error {
+	if len(args) != 3 {
+		return ErrUsage
+	}
+	cid := utils.StrToInt(args[2], -1)
+	if cid <= 0 {
+		return fmt.Errorf("invalid cid %q", args[2])
+	}
+	password, err := readPassword(ctx)
+	if err != nil {
+		return err
+	}
+	userOp, err := userOperation(ctx)
+	if err != nil {
+		return err
+	}
+	user, err := userOp.NewUser(args[0], strings.ToLower(args[1]), cid, password)
+	if err != nil {
+		return err
+	}
+	if err := userOp.AddUser(user); err != nil {
+		return err
+	}
+	ctx.Printf("user %s(%04d) created\n", user.Username, user.Cid)
+	return nil
+}
+
+func userPasswd(ctx *Context, args []string) error {
+	if len(args) != 1 {
+		return ErrUsage
+	}
+	password, err := readPassword(ctx)
+	if err != nil {
+		return err
+	}
+	operations, err := databaseOperations(ctx)
+	if err != nil {
+		return err
+	}
+	userOp := operations.UserOperation()
+	user, err := operation.GetUserId(args[0]).GetUser(userOp)
+	if err != nil {
+		return err
+	}
+	encodePassword, err := userOp.UpdateUserPassword(user, "", password, true)
+	if err != nil {
+		return err
+	}
+	if err := userOp.UpdateUserInfo(user, map[string]interface{}{"password": encodePassword}); err != nil {
+		return err
+	}
+	revokeSessions(ctx, operations, user)
+	ctx.Printf("password of user %s(%04d) updated\n", user.Username, user.Cid)
+	return nil
+}
+
+// permissionNames 返回排序后的权限名称列表
+func permissionNames() []string {
+	names := make([]string, 0, len(operation.PermissionMap))
+	for name := range operation.PermissionMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func userGrant(ctx *Context, args []string) error {
+	if len(args) < 2 {
+		return ErrUsage
+	}
+	var grants, revokes operation.PermissionSet
+	for _, arg := range args[1:] {
+		name, revoke := strings.CutPrefix(arg, "-")
+		var perm operation.PermissionSet
+		if strings.EqualFold(name, "all") {
+			perm = operation.AllPermissions()
+		} else if p, ok := operation.PermissionMap[name]; ok {
+			perm.Grant(p)
+		} else {
+			return fmt.Errorf("unknown permission %q, available: %s", name, strings.Join(permissionNames(), ", "))
+		}
+		if revoke {
+			revokes.Merge(perm)
+		} else {
+			grants.Merge(perm)
+		}
+	}
+
+	operations, err := databaseOperations(ctx)
+	if err != nil {
+		return err
+	}
+	userOp := operations.UserOperation()
+	user, err := operation.GetUserId(args[0]).GetUser(userOp)
+	if err != nil {
+		return err
+	}
+	// 只修改直接授予的权限, 角色授予的权限不受影响
+	permission := operation.NewPermissionSet(user.Permission)
+	permission.Merge(grants)
+	for _, name := range revokes.Nodes() {
+		permission.Revoke(operation.PermissionMap[name])
+	}
+	if err := userOp.UpdateUserPermission(user, permission); err != nil {
+		return err
+	}
+	revokeSessions(ctx, operations, user)
+
+	ctx.Printf("permission of user %s(%04d) is now %d [%s]\n", user.Username, user.Cid, user.Permission, strings.Join(permission.Nodes(), ", "))
+	return nil
+}
+
+// parseRating 解析数字或简称形式的管制权限
+func parseRating(value string) (fsd.Rating, error) {
+	if rating, err := strconv.Atoi(value); err == nil {
+		if rating < fsd.Ban.Index() || rating > fsd.Administrator.Index() {
+			return fsd.Ban, fmt.Errorf("rating %d out of range", rating)
+		}
+		return fsd.Rating(rating), nil
+	}
+	for _, model := range fsd.Ratings {
+		if strings.EqualFold(model.ShortName, value) {
+			return fsd.Rating(model.Id), nil
+		}
+	}
+	return fsd.Ban, fmt.Errorf("unknown rating %q", value)
+}
+
+func userRating(ctx *Context, args []string) error {
+	if len(args) != 2 {
+		return ErrUsage
+	}
+	rating, err := parseRating(args[1])
+	if err != nil {
+		return err
+	}
+	operations, err := databaseOperations(ctx)
+	if err != nil {
+		return err
+	}
+	userOp := operations.UserOperation()
+	user, err := operation.GetUserId(args[0]).GetUser(userOp)
+	if err != nil {
+		return err
+	}
+	oldRating := fsd.Rating(user.Rating)
+	if err := userOp.UpdateUserRating(user, rating.Index()); err != nil {
+		return err
+	}
+	revokeSessions(ctx, operations, user)
+	if rating == fsd.Ban {
+		if err := operations.TrainingOperation().CancelUserTrainingRequests(user.Cid); err != nil {
+			ctx.Printf("warning: fail to cancel training requests of user %s(%04d), %v\n", user.Username, user.Cid, err)
+		}
+	}
+	ctx.Printf("rating of user %s(%04d) changed from %s to %s\n", user.Username, user.Cid, oldRating, rating)
+	return nil
+}
+
+func userUnban(ctx *Context, args []string) error {
+	if len(args) != 1 {
+		return ErrUsage
+	}
+	userOp, err := userOperation(ctx)
+	if err != nil {
+		return err
+	}
+	user, err := operation.GetUserId(args[0]).GetUser(userOp)
+	if err != nil {
+		return err
+	}
+	if !user.Banned() {
+		ctx.Printf("user %s(%04d) is not banned\n", user.Username, user.Cid)
+		return nil
+	}
+	if err := userOp.UnbanUser(user); err != nil {
+		return err
+	}
+	ctx.Printf("user %s(%04d) unbanned\n", user.Username, user.Cid)
+	return nil
+}
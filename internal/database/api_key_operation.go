@@ -0,0 +1,111 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"gorm.io/gorm"
+	"time"
+)
+
+// apiKeyPrefix 密钥明文前缀, 便于在代码仓库等位置识别泄露的密钥
+const apiKeyPrefix = "sfsd_"
+
+type ApiKeyOperation struct {
+	logger       log.LoggerInterface
+	db           *gorm.DB
+	queryTimeout time.Duration
+}
+
+func NewApiKeyOperation(logger log.LoggerInterface, db *gorm.DB, queryTimeout time.Duration) *ApiKeyOperation {
+	return &ApiKeyOperation{logger: logger, db: db, queryTimeout: queryTimeout}
+}
+
+// hashApiKey 密钥是高熵随机串, 直接使用SHA256摘要存储
+func hashApiKey(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+func (keyOperation *ApiKeyOperation) NewApiKey(user *User, name string, permission PermissionSet, rateLimit int, expiresAt *time.Time) (key *ApiKey, plain string) {
+	plain = apiKeyPrefix + randomHex(32)
+	key = &ApiKey{
+		Cid:         user.Cid,
+		Name:        name,
+		Prefix:      plain[:len(apiKeyPrefix)+8],
+		KeyHash:     hashApiKey(plain),
+		Permissions: permission.String(),
+		RateLimit:   rateLimit,
+		ExpiresAt:   expiresAt,
+	}
+	return
+}
+
+func (keyOperation *ApiKeyOperation) AddApiKey(key *ApiKey) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), keyOperation.queryTimeout)
+	defer cancel()
+	return keyOperation.db.WithContext(ctx).Create(key).Error
+}
+
+func (keyOperation *ApiKeyOperation) GetApiKeyById(id uint) (key *ApiKey, err error) {
+	key = &ApiKey{}
+	ctx, cancel := context.WithTimeout(context.Background(), keyOperation.queryTimeout)
+	defer cancel()
+	err = keyOperation.db.WithContext(ctx).Where("id = ?", id).First(key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = ErrApiKeyNotFound
+	}
+	return
+}
+
+func (keyOperation *ApiKeyOperation) GetApiKeyByPlain(plain string) (key *ApiKey, err error) {
+	key = &ApiKey{}
+	ctx, cancel := context.WithTimeout(context.Background(), keyOperation.queryTimeout)
+	defer cancel()
+	err = keyOperation.db.WithContext(ctx).Where("key_hash = ?", hashApiKey(plain)).First(key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = ErrApiKeyNotFound
+	}
+	return
+}
+
+func (keyOperation *ApiKeyOperation) GetApiKeys(cid int) (keys []*ApiKey, err error) {
+	keys = make([]*ApiKey, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), keyOperation.queryTimeout)
+	defer cancel()
+	query := keyOperation.db.WithContext(ctx)
+	if cid != 0 {
+		query = query.Where("cid = ?", cid)
+	}
+	err = query.Order("id").Find(&keys).Error
+	return
+}
+
+func (keyOperation *ApiKeyOperation) CountActiveApiKeys(cid int) (count int64, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), keyOperation.queryTimeout)
+	defer cancel()
+	err = keyOperation.db.WithContext(ctx).Model(&ApiKey{}).
+		Where("cid = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", cid, time.Now()).
+		Count(&count).Error
+	return
+}
+
+func (keyOperation *ApiKeyOperation) TouchApiKey(key *ApiKey, ip string) (err error) {
+	now := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), keyOperation.queryTimeout)
+	defer cancel()
+	return keyOperation.db.WithContext(ctx).Model(key).Updates(map[string]interface{}{
+		"last_used_at": now,
+		"last_used_ip": ip,
+	}).Error
+}
+
+func (keyOperation *ApiKeyOperation) RevokeApiKey(key *ApiKey) (err error) {
+	now := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), keyOperation.queryTimeout)
+	defer cancel()
+	return keyOperation.db.WithContext(ctx).Model(key).Update("revoked_at", now).Error
+}
@@ -0,0 +1,112 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type AtcBookingOperation struct {
+	logger       log.LoggerInterface
+	db           *gorm.DB
+	queryTimeout time.Duration
+}
+
+func NewAtcBookingOperation(logger log.LoggerInterface, db *gorm.DB, queryTimeout time.Duration) *AtcBookingOperation {
+	return &AtcBookingOperation{logger: logger, db: db, queryTimeout: queryTimeout}
+}
+
+func (bookingOperation *AtcBookingOperation) NewAtcBooking(user *User, callsign string, startTime, endTime time.Time) (booking *AtcBooking) {
+	return &AtcBooking{
+		Cid:       user.Cid,
+		Callsign:  callsign,
+		StartTime: startTime,
+		EndTime:   endTime,
+	}
+}
+
+func (bookingOperation *AtcBookingOperation) AddAtcBooking(booking *AtcBooking) (err error) {
+	if !booking.StartTime.Before(booking.EndTime) {
+		return ErrAtcBookingTimeInvalid
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), bookingOperation.queryTimeout)
+	defer cancel()
+	return bookingOperation.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 依次锁定席位与用户, 同一席位或同一用户的并发预约在此处排队, 固定的加锁顺序避免死锁
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&AtcBookingLock{Callsign: booking.Callsign}).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("callsign = ?", booking.Callsign).First(&AtcBookingLock{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("cid = ?", booking.Cid).First(&User{}).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+		exist := &AtcBooking{}
+		// 两个时间段重叠当且仅当 a.start < b.end 且 a.end > b.start
+		err := tx.Select("id", "cid", "callsign").
+			Where("(callsign = ? or cid = ?) and start_time < ? and end_time > ?", booking.Callsign, booking.Cid, booking.EndTime, booking.StartTime).
+			First(exist).
+			Error
+		if err == nil {
+			if exist.Callsign == booking.Callsign {
+				return ErrAtcBookingOverlap
+			}
+			return ErrAtcBookingUserOverlap
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		return tx.Create(booking).Error
+	})
+}
+
+func (bookingOperation *AtcBookingOperation) GetAtcBookingById(id uint) (booking *AtcBooking, err error) {
+	booking = &AtcBooking{}
+	ctx, cancel := context.WithTimeout(context.Background(), bookingOperation.queryTimeout)
+	defer cancel()
+	err = bookingOperation.db.WithContext(ctx).Where("id = ?", id).First(booking).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = ErrAtcBookingNotFound
+	}
+	return
+}
+
+func (bookingOperation *AtcBookingOperation) GetAtcBookings(startTime, endTime time.Time) (bookings []*AtcBooking, err error) {
+	bookings = make([]*AtcBooking, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), bookingOperation.queryTimeout)
+	defer cancel()
+	err = bookingOperation.db.WithContext(ctx).
+		Where("start_time < ? and end_time > ?", endTime, startTime).
+		Order("start_time").
+		Find(&bookings).
+		Error
+	return
+}
+
+func (bookingOperation *AtcBookingOperation) GetActiveAtcBooking(callsign string, moment time.Time) (booking *AtcBooking, err error) {
+	booking = &AtcBooking{}
+	ctx, cancel := context.WithTimeout(context.Background(), bookingOperation.queryTimeout)
+	defer cancel()
+	err = bookingOperation.db.WithContext(ctx).
+		Where("callsign = ? and start_time <= ? and end_time > ?", callsign, moment, moment).
+		First(booking).
+		Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = ErrAtcBookingNotFound
+	}
+	return
+}
+
+func (bookingOperation *AtcBookingOperation) DeleteAtcBooking(booking *AtcBooking) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), bookingOperation.queryTimeout)
+	defer cancel()
+	return bookingOperation.db.WithContext(ctx).Delete(booking).Error
+}
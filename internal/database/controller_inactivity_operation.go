@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type ControllerInactivityOperation struct {
+	logger       log.LoggerInterface
+	db           *gorm.DB
+	queryTimeout time.Duration
+}
+
+func NewControllerInactivityOperation(logger log.LoggerInterface, db *gorm.DB, queryTimeout time.Duration) *ControllerInactivityOperation {
+	return &ControllerInactivityOperation{logger: logger, db: db, queryTimeout: queryTimeout}
+}
+
+func (inactivityOperation *ControllerInactivityOperation) NewControllerInactivity(user *User, periodStart, periodEnd time.Time, atcTime int, graceUntil time.Time) (inactivity *ControllerInactivity) {
+	return &ControllerInactivity{
+		Cid:         user.Cid,
+		PeriodStart: periodStart,
+		PeriodEnd:   periodEnd,
+		AtcTime:     atcTime,
+		Status:      InactivityStatusWarned,
+		GraceUntil:  graceUntil,
+		OldRating:   user.Rating,
+	}
+}
+
+func (inactivityOperation *ControllerInactivityOperation) AddControllerInactivity(inactivity *ControllerInactivity) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), inactivityOperation.queryTimeout)
+	defer cancel()
+	result := inactivityOperation.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(inactivity)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrControllerInactivityExists
+	}
+	return nil
+}
+
+func (inactivityOperation *ControllerInactivityOperation) GetControllerInactivities(periodStart time.Time) (inactivities []*ControllerInactivity, err error) {
+	inactivities = make([]*ControllerInactivity, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), inactivityOperation.queryTimeout)
+	defer cancel()
+	err = inactivityOperation.db.WithContext(ctx).Where("period_start = ?", periodStart).Order("cid").Find(&inactivities).Error
+	return
+}
+
+func (inactivityOperation *ControllerInactivityOperation) GetWarnedControllerInactivities() (inactivities []*ControllerInactivity, err error) {
+	inactivities = make([]*ControllerInactivity, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), inactivityOperation.queryTimeout)
+	defer cancel()
+	err = inactivityOperation.db.WithContext(ctx).Where("status = ?", InactivityStatusWarned).Order("id").Find(&inactivities).Error
+	return
+}
+
+func (inactivityOperation *ControllerInactivityOperation) UpdateControllerInactivityStatus(inactivity *ControllerInactivity, status int) (err error) {
+	now := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), inactivityOperation.queryTimeout)
+	defer cancel()
+	result := inactivityOperation.db.WithContext(ctx).Model(inactivity).
+		Where("status = ?", inactivity.Status).
+		Updates(map[string]interface{}{
+			"status":     status,
+			"handled_at": now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrControllerInactivityChanged
+	}
+	inactivity.Status = status
+	inactivity.HandledAt = &now
+	return nil
+}
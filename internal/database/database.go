@@ -3,6 +3,7 @@ package database
 import (
 	"context"
 	. "fmt"
+	"github.com/half-nothing/simple-fsd/internal/health"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
 	. "github.com/half-nothing/simple-fsd/internal/interfaces/operation"
@@ -35,9 +36,13 @@ func (dc *DBCloseCallback) Invoke(ctx context.Context) error {
 	return err
 }
 
-func ConnectDatabase(lg log.LoggerInterface, config *config.Config, debug bool) (*DBCloseCallback, *DatabaseOperations, error) {
-	queryTimeout := config.Database.QueryDuration
+// models 需要自动迁移的数据模型, 顺序即为依赖顺序
+var models = []interface{}{
+	&User{}, &FlightPlan{}, &History{}, &Activity{}, &ActivityFacility{}, &ActivityATC{}, &ActivityPilot{}, &AuditLog{}, &AtcBooking{}, &AtcBookingLock{}, &HelpRequest{}, &Ticket{}, &TicketMessage{}, &UserSession{}, &OAuthClient{}, &ExternalIdentity{}, &Role{}, &UserRole{}, &ApiKey{}, &TrainingRequest{}, &TrainingReport{}, &SoloEndorsement{}, &PositionEndorsement{}, &ControllerInactivity{},
+}
 
+// openDatabase 连接数据库并完成迁移与连接池配置
+func openDatabase(lg log.LoggerInterface, config *config.Config, debug bool) (*gorm.DB, error) {
 	connection := config.Database.GetConnection(lg)
 
 	gormConfig := gorm.Config{}
@@ -53,16 +58,20 @@ func ConnectDatabase(lg log.LoggerInterface, config *config.Config, debug bool)
 
 	db, err := gorm.Open(connection, &gormConfig)
 	if err != nil {
-		return nil, nil, Errorf("error occured while connecting to operation: %v", err)
+		return nil, Errorf("error occured while connecting to operation: %v", err)
+	}
+
+	if err = registerMetricsCallbacks(db); err != nil {
+		return nil, Errorf("error occured while registering metrics callbacks: %v", err)
 	}
 
-	if err = db.Migrator().AutoMigrate(&User{}, &FlightPlan{}, &History{}, &Activity{}, &ActivityATC{}, &ActivityPilot{}, &ActivityFacility{}, &AuditLog{}); err != nil {
-		return nil, nil, Errorf("error occured while migrating operation: %v", err)
+	if err = db.Migrator().AutoMigrate(models...); err != nil {
+		return nil, Errorf("error occured while migrating operation: %v", err)
 	}
 
 	dbPool, err := db.DB()
 	if err != nil {
-		return nil, nil, Errorf("error occured while creating operation pool: %v", err)
+		return nil, Errorf("error occured while creating operation pool: %v", err)
 	}
 
 	maxOpenConnections := config.Database.ServerMaxConnections * 4 / 5 // 不超过数据库最大连接的80%
@@ -74,15 +83,40 @@ func ConnectDatabase(lg log.LoggerInterface, config *config.Config, debug bool)
 
 	err = dbPool.Ping()
 	if err != nil {
-		return nil, nil, Errorf("error occured while pinging operation: %v", err)
+		return nil, Errorf("error occured while pinging operation: %v", err)
+	}
+	return db, nil
+}
+
+func ConnectDatabase(lg log.LoggerInterface, config *config.Config, debug bool) (*DBCloseCallback, *DatabaseOperations, error) {
+	queryTimeout := config.Database.QueryDuration
+
+	db, err := openDatabase(lg, config, debug)
+	if err != nil {
+		return nil, nil, err
 	}
 	lg.Info("Database initialized and connection established")
 
+	if dbPool, err := db.DB(); err == nil {
+		health.Register("database", dbPool.PingContext)
+	}
+
 	userOperation := NewUserOperation(lg, db, queryTimeout, config.Server.General)
 	flightPlanOperation := NewFlightPlanOperation(lg, db, queryTimeout, config.Server.General)
 	historyOperation := NewHistoryOperation(lg, db, queryTimeout)
 	activityOperation := NewActivityOperation(lg, db, queryTimeout)
 	auditLogOperation := NewAuditLogOperation(lg, db, queryTimeout)
-
-	return NewDBCloseCallback(lg, db), NewDatabaseOperations(userOperation, flightPlanOperation, historyOperation, activityOperation, auditLogOperation), nil
+	atcBookingOperation := NewAtcBookingOperation(lg, db, queryTimeout)
+	helpRequestOperation := NewHelpRequestOperation(lg, db, queryTimeout)
+	ticketOperation := NewTicketOperation(lg, db, queryTimeout)
+	userSessionOperation := NewUserSessionOperation(lg, db, queryTimeout)
+	oauthClientOperation := NewOAuthClientOperation(lg, db, queryTimeout)
+	externalIdentityOperation := NewExternalIdentityOperation(lg, db, queryTimeout)
+	roleOperation := NewRoleOperation(lg, db, queryTimeout)
+	apiKeyOperation := NewApiKeyOperation(lg, db, queryTimeout)
+	trainingOperation := NewTrainingOperation(lg, db, queryTimeout)
+	endorsementOperation := NewPositionEndorsementOperation(lg, db, queryTimeout)
+	inactivityOperation := NewControllerInactivityOperation(lg, db, queryTimeout)
+
+	return NewDBCloseCallback(lg, db), NewDatabaseOperations(userOperation, flightPlanOperation, historyOperation, activityOperation, auditLogOperation, atcBookingOperation, helpRequestOperation, ticketOperation, userSessionOperation, oauthClientOperation, externalIdentityOperation, roleOperation, apiKeyOperation, trainingOperation, endorsementOperation, inactivityOperation), nil
 }
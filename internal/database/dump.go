@@ -0,0 +1,172 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	. "fmt"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/global"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	"gorm.io/gorm"
+	"io"
+	"time"
+)
+
+// DatabaseDump 数据库导出文件格式, Tables 按照 models 的顺序保存每张表的所有行
+type DatabaseDump struct {
+	Version    string                              `json:"version"`
+	ExportTime time.Time                           `json:"export_time"`
+	Tables     map[string][]map[string]interface{} `json:"tables"`
+}
+
+var (
+	ErrDumpVersionMismatch = errors.New("dump file version mismatch")
+	ErrDatabaseNotEmpty    = errors.New("target database is not empty")
+)
+
+// withDatabase 打开数据库连接并在回调结束后关闭
+func withDatabase(lg log.LoggerInterface, config *config.Config, fc func(db *gorm.DB) error) error {
+	db, err := openDatabase(lg, config, false)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = NewDBCloseCallback(lg, db).Invoke(context.Background()) }()
+	return fc(db)
+}
+
+// tableName 解析数据模型对应的表名
+func tableName(db *gorm.DB, model interface{}) (string, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return "", err
+	}
+	return stmt.Schema.Table, nil
+}
+
+// MigrateDatabase 只执行数据库迁移
+func MigrateDatabase(lg log.LoggerInterface, config *config.Config) error {
+	return withDatabase(lg, config, func(db *gorm.DB) error { return nil })
+}
+
+// ExportDatabase 将所有数据表导出为JSON
+func ExportDatabase(lg log.LoggerInterface, config *config.Config, writer io.Writer) error {
+	return withDatabase(lg, config, func(db *gorm.DB) error {
+		dump := &DatabaseDump{
+			Version:    global.AppVersion,
+			ExportTime: time.Now().UTC(),
+			Tables:     make(map[string][]map[string]interface{}),
+		}
+		for _, model := range models {
+			table, err := tableName(db, model)
+			if err != nil {
+				return err
+			}
+			rows := make([]map[string]interface{}, 0)
+			if err := db.Table(table).Order("id").Find(&rows).Error; err != nil {
+				return Errorf("error occured while exporting table %s: %v", table, err)
+			}
+			dump.Tables[table] = rows
+		}
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "\t")
+		return encoder.Encode(dump)
+	})
+}
+
+// checkDatabaseEmpty 检查所有数据表均为空
+func checkDatabaseEmpty(tx *gorm.DB) error {
+	for _, model := range models {
+		table, err := tableName(tx, model)
+		if err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Table(table).Limit(1).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return Errorf("%w, table %s already has data", ErrDatabaseNotEmpty, table)
+		}
+	}
+	return nil
+}
+
+// resetSequences 导入的行带有显式的主键, PostgreSQL的自增序列不会随之前进, 需要手动设置为最大主键之后
+func resetSequences(tx *gorm.DB) error {
+	if tx.Dialector.Name() != "postgres" {
+		return nil
+	}
+	for _, model := range models {
+		table, err := tableName(tx, model)
+		if err != nil {
+			return err
+		}
+		sql := Sprintf(`SELECT setval(pg_get_serial_sequence('%s', 'id'), COALESCE((SELECT MAX(id) FROM %q), 0) + 1, false)`, table, table)
+		if err := tx.Exec(sql).Error; err != nil {
+			return Errorf("error occured while resetting sequence of table %s: %v", table, err)
+		}
+	}
+	return nil
+}
+
+// ImportDatabase 向空数据库导入JSON数据, 整个导入过程在一个事务中完成
+func ImportDatabase(lg log.LoggerInterface, config *config.Config, reader io.Reader) error {
+	decoder := json.NewDecoder(reader)
+	decoder.UseNumber()
+	dump := &DatabaseDump{}
+	if err := decoder.Decode(dump); err != nil {
+		return err
+	}
+	if dump.Version != global.AppVersion {
+		return Errorf("%w, expected %s, got %s", ErrDumpVersionMismatch, global.AppVersion, dump.Version)
+	}
+	return withDatabase(lg, config, func(db *gorm.DB) error {
+		return db.Transaction(func(tx *gorm.DB) error {
+			if err := checkDatabaseEmpty(tx); err != nil {
+				return err
+			}
+			for _, model := range models {
+				table, err := tableName(tx, model)
+				if err != nil {
+					return err
+				}
+				rows := dump.Tables[table]
+				if len(rows) == 0 {
+					continue
+				}
+				for _, row := range rows {
+					for key, value := range row {
+						row[key] = normalizeDumpValue(value)
+					}
+				}
+				if err := tx.Table(table).CreateInBatches(rows, 100).Error; err != nil {
+					return Errorf("error occured while importing table %s: %v", table, err)
+				}
+				lg.InfoF("Imported %d rows into %s", len(rows), table)
+			}
+			return resetSequences(tx)
+		})
+	})
+}
+
+// normalizeDumpValue 将JSON解码后的值还原为数据库驱动可以接受的类型
+func normalizeDumpValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t
+		}
+		return v
+	default:
+		return v
+	}
+}
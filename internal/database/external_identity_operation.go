@@ -0,0 +1,39 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"gorm.io/gorm"
+	"time"
+)
+
+type ExternalIdentityOperation struct {
+	logger       log.LoggerInterface
+	db           *gorm.DB
+	queryTimeout time.Duration
+}
+
+func NewExternalIdentityOperation(logger log.LoggerInterface, db *gorm.DB, queryTimeout time.Duration) *ExternalIdentityOperation {
+	return &ExternalIdentityOperation{logger: logger, db: db, queryTimeout: queryTimeout}
+}
+
+func (identityOperation *ExternalIdentityOperation) GetExternalIdentity(issuer string, subject string) (identity *ExternalIdentity, err error) {
+	identity = &ExternalIdentity{}
+	ctx, cancel := context.WithTimeout(context.Background(), identityOperation.queryTimeout)
+	defer cancel()
+	err = identityOperation.db.WithContext(ctx).Where("issuer = ? and subject = ?", issuer, subject).First(identity).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = ErrExternalIdentityNotFound
+	}
+	return
+}
+
+func (identityOperation *ExternalIdentityOperation) AddExternalIdentity(issuer string, subject string, user *User) (identity *ExternalIdentity, err error) {
+	identity = &ExternalIdentity{Issuer: issuer, Subject: subject, Cid: user.Cid}
+	ctx, cancel := context.WithTimeout(context.Background(), identityOperation.queryTimeout)
+	defer cancel()
+	err = identityOperation.db.WithContext(ctx).Create(identity).Error
+	return
+}
@@ -113,6 +113,17 @@ func (flightPlanOperation *FlightPlanOperation) UpdateFlightPlan(flightPlan *Fli
 	})
 }
 
+func (flightPlanOperation *FlightPlanOperation) SaveFlightPlan(flightPlan *FlightPlan) (err error) {
+	if flightPlanOperation.config.SimulatorServer {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), flightPlanOperation.queryTimeout)
+	defer cancel()
+
+	return flightPlanOperation.db.WithContext(ctx).Save(flightPlan).Error
+}
+
 func (flightPlanOperation *FlightPlanOperation) UpdateCruiseAltitude(flightPlan *FlightPlan, cruiseAltitude string) (err error) {
 	flightPlan.CruiseAltitude = cruiseAltitude
 
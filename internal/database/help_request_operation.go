@@ -0,0 +1,112 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"gorm.io/gorm"
+	"time"
+)
+
+type HelpRequestOperation struct {
+	logger       log.LoggerInterface
+	db           *gorm.DB
+	queryTimeout time.Duration
+}
+
+func NewHelpRequestOperation(logger log.LoggerInterface, db *gorm.DB, queryTimeout time.Duration) *HelpRequestOperation {
+	return &HelpRequestOperation{logger: logger, db: db, queryTimeout: queryTimeout}
+}
+
+func (helpRequestOperation *HelpRequestOperation) NewHelpRequest(cid int, callsign string, message string) (request *HelpRequest) {
+	return &HelpRequest{
+		Cid:      cid,
+		Callsign: callsign,
+		Message:  message,
+		Status:   HelpRequestOpen,
+	}
+}
+
+func (helpRequestOperation *HelpRequestOperation) AddHelpRequest(request *HelpRequest) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), helpRequestOperation.queryTimeout)
+	defer cancel()
+	return helpRequestOperation.db.WithContext(ctx).Create(request).Error
+}
+
+func (helpRequestOperation *HelpRequestOperation) GetHelpRequestById(id uint) (request *HelpRequest, err error) {
+	request = &HelpRequest{}
+	ctx, cancel := context.WithTimeout(context.Background(), helpRequestOperation.queryTimeout)
+	defer cancel()
+	err = helpRequestOperation.db.WithContext(ctx).Where("id = ?", id).First(request).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = ErrHelpRequestNotFound
+	}
+	return
+}
+
+func (helpRequestOperation *HelpRequestOperation) GetUnclosedHelpRequests() (requests []*HelpRequest, err error) {
+	requests = make([]*HelpRequest, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), helpRequestOperation.queryTimeout)
+	defer cancel()
+	err = helpRequestOperation.db.WithContext(ctx).
+		Where("status <> ?", HelpRequestClosed).
+		Order("id").
+		Find(&requests).
+		Error
+	return
+}
+
+func (helpRequestOperation *HelpRequestOperation) ClaimHelpRequest(request *HelpRequest, cid int) (err error) {
+	switch request.Status {
+	case HelpRequestClaimed:
+		return ErrHelpRequestClaimed
+	case HelpRequestClosed:
+		return ErrHelpRequestClosed
+	}
+	now := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), helpRequestOperation.queryTimeout)
+	defer cancel()
+	// 只更新仍处于等待状态的请求, 避免多个管理员同时认领
+	result := helpRequestOperation.db.WithContext(ctx).Model(request).
+		Where("status = ?", HelpRequestOpen).
+		Updates(map[string]interface{}{
+			"status":     HelpRequestClaimed,
+			"claimed_by": cid,
+			"claimed_at": now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrHelpRequestClaimed
+	}
+	request.Status = HelpRequestClaimed
+	request.ClaimedBy = cid
+	request.ClaimedAt = &now
+	return nil
+}
+
+func (helpRequestOperation *HelpRequestOperation) CloseHelpRequest(request *HelpRequest) (err error) {
+	if request.Status == HelpRequestClosed {
+		return ErrHelpRequestClosed
+	}
+	now := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), helpRequestOperation.queryTimeout)
+	defer cancel()
+	result := helpRequestOperation.db.WithContext(ctx).Model(request).
+		Where("status <> ?", HelpRequestClosed).
+		Updates(map[string]interface{}{
+			"status":    HelpRequestClosed,
+			"closed_at": now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrHelpRequestClosed
+	}
+	request.Status = HelpRequestClosed
+	request.ClosedAt = &now
+	return nil
+}
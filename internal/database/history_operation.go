@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"errors"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
 	. "github.com/half-nothing/simple-fsd/internal/interfaces/operation"
 	"gorm.io/gorm"
@@ -59,3 +60,34 @@ func (historyOperation *HistoryOperation) GetUserHistory(cid int) (userHistory *
 	}
 	return
 }
+
+func (historyOperation *HistoryOperation) GetHistoryById(id uint) (history *History, err error) {
+	history = &History{}
+	ctx, cancel := context.WithTimeout(context.Background(), historyOperation.queryTimeout)
+	defer cancel()
+	err = historyOperation.db.WithContext(ctx).Where("id = ?", id).First(history).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = ErrHistoryNotFound
+	}
+	return
+}
+
+func (historyOperation *HistoryOperation) GetAtcTimeBetween(start, end time.Time) (atcTimes map[int]int, err error) {
+	histories := make([]*History, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), historyOperation.queryTimeout)
+	defer cancel()
+	err = historyOperation.db.WithContext(ctx).
+		Select("cid, start_time, end_time").
+		Where("is_atc = ? and start_time < ? and end_time > ?", true, end, start).
+		Find(&histories).
+		Error
+	if err != nil {
+		return
+	}
+	// 跨越考核周期边界的记录只计入周期内的部分
+	atcTimes = make(map[int]int)
+	for _, history := range histories {
+		atcTimes[history.Cid] += history.TimeBetween(start, end)
+	}
+	return
+}
@@ -0,0 +1,51 @@
+package database
+
+import (
+	"errors"
+	"github.com/half-nothing/simple-fsd/internal/metrics"
+	"gorm.io/gorm"
+	"time"
+)
+
+const metricsStartKey = "metrics:start_time"
+
+func metricsBefore(db *gorm.DB) {
+	db.InstanceSet(metricsStartKey, time.Now())
+}
+
+func metricsAfter(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(metricsStartKey)
+		if !ok {
+			return
+		}
+		start, ok := value.(time.Time)
+		if !ok {
+			return
+		}
+		table := db.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+		metrics.DatabaseQueryDuration.WithLabelValues(operation, table).Observe(time.Since(start).Seconds())
+	}
+}
+
+// registerMetricsCallbacks 注册gorm回调, 统计所有数据库操作的耗时
+func registerMetricsCallbacks(db *gorm.DB) error {
+	callback := db.Callback()
+	return errors.Join(
+		callback.Create().Before("gorm:create").Register("metrics:before_create", metricsBefore),
+		callback.Create().After("gorm:create").Register("metrics:after_create", metricsAfter("create")),
+		callback.Query().Before("gorm:query").Register("metrics:before_query", metricsBefore),
+		callback.Query().After("gorm:query").Register("metrics:after_query", metricsAfter("query")),
+		callback.Update().Before("gorm:update").Register("metrics:before_update", metricsBefore),
+		callback.Update().After("gorm:update").Register("metrics:after_update", metricsAfter("update")),
+		callback.Delete().Before("gorm:delete").Register("metrics:before_delete", metricsBefore),
+		callback.Delete().After("gorm:delete").Register("metrics:after_delete", metricsAfter("delete")),
+		callback.Row().Before("gorm:row").Register("metrics:before_row", metricsBefore),
+		callback.Row().After("gorm:row").Register("metrics:after_row", metricsAfter("row")),
+		callback.Raw().Before("gorm:raw").Register("metrics:before_raw", metricsBefore),
+		callback.Raw().After("gorm:raw").Register("metrics:after_raw", metricsAfter("raw")),
+	)
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"gorm.io/gorm"
+	"time"
+)
+
+type OAuthClientOperation struct {
+	logger       log.LoggerInterface
+	db           *gorm.DB
+	queryTimeout time.Duration
+}
+
+func NewOAuthClientOperation(logger log.LoggerInterface, db *gorm.DB, queryTimeout time.Duration) *OAuthClientOperation {
+	return &OAuthClientOperation{logger: logger, db: db, queryTimeout: queryTimeout}
+}
+
+func randomHex(length int) string {
+	buf := make([]byte, length)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// hashClientSecret 客户端密钥是高熵随机串, 直接使用SHA256摘要存储
+func hashClientSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func (clientOperation *OAuthClientOperation) NewOAuthClient(user *User, name string, redirectUris string, scopes string, confidential bool) (client *OAuthClient, secret string) {
+	client = &OAuthClient{
+		ClientId:     randomHex(16),
+		Name:         name,
+		RedirectUris: redirectUris,
+		Scopes:       scopes,
+		Confidential: confidential,
+		CreatedBy:    user.Cid,
+	}
+	if confidential {
+		secret = randomHex(32)
+		client.ClientSecret = hashClientSecret(secret)
+	}
+	return
+}
+
+func (clientOperation *OAuthClientOperation) AddOAuthClient(client *OAuthClient) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), clientOperation.queryTimeout)
+	defer cancel()
+	return clientOperation.db.WithContext(ctx).Create(client).Error
+}
+
+func (clientOperation *OAuthClientOperation) GetOAuthClientById(id uint) (client *OAuthClient, err error) {
+	client = &OAuthClient{}
+	ctx, cancel := context.WithTimeout(context.Background(), clientOperation.queryTimeout)
+	defer cancel()
+	err = clientOperation.db.WithContext(ctx).Where("id = ?", id).First(client).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = ErrOAuthClientNotFound
+	}
+	return
+}
+
+func (clientOperation *OAuthClientOperation) GetOAuthClientByClientId(clientId string) (client *OAuthClient, err error) {
+	client = &OAuthClient{}
+	ctx, cancel := context.WithTimeout(context.Background(), clientOperation.queryTimeout)
+	defer cancel()
+	err = clientOperation.db.WithContext(ctx).Where("client_id = ?", clientId).First(client).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = ErrOAuthClientNotFound
+	}
+	return
+}
+
+func (clientOperation *OAuthClientOperation) GetOAuthClients() (clients []*OAuthClient, err error) {
+	clients = make([]*OAuthClient, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), clientOperation.queryTimeout)
+	defer cancel()
+	err = clientOperation.db.WithContext(ctx).Order("id").Find(&clients).Error
+	return
+}
+
+func (clientOperation *OAuthClientOperation) DeleteOAuthClient(client *OAuthClient) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), clientOperation.queryTimeout)
+	defer cancel()
+	return clientOperation.db.WithContext(ctx).Delete(client).Error
+}
+
+func (clientOperation *OAuthClientOperation) VerifyOAuthClientSecret(client *OAuthClient, secret string) (pass bool) {
+	if !client.Confidential || client.ClientSecret == "" || secret == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(client.ClientSecret), []byte(hashClientSecret(secret))) == 1
+}
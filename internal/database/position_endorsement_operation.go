@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type PositionEndorsementOperation struct {
+	logger       log.LoggerInterface
+	db           *gorm.DB
+	queryTimeout time.Duration
+}
+
+func NewPositionEndorsementOperation(logger log.LoggerInterface, db *gorm.DB, queryTimeout time.Duration) *PositionEndorsementOperation {
+	return &PositionEndorsementOperation{logger: logger, db: db, queryTimeout: queryTimeout}
+}
+
+func (endorsementOperation *PositionEndorsementOperation) NewPositionEndorsement(user *User, pattern string, expiresAt *time.Time, operator *User) (endorsement *PositionEndorsement) {
+	return &PositionEndorsement{
+		Cid:       user.Cid,
+		Pattern:   pattern,
+		ExpiresAt: expiresAt,
+		GrantedBy: operator.Cid,
+	}
+}
+
+func (endorsementOperation *PositionEndorsementOperation) SavePositionEndorsement(endorsement *PositionEndorsement) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), endorsementOperation.queryTimeout)
+	defer cancel()
+	return endorsementOperation.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "cid"}, {Name: "pattern"}},
+		DoUpdates: clause.AssignmentColumns([]string{"expires_at", "granted_by", "updated_at"}),
+	}).Create(endorsement).Error
+}
+
+func (endorsementOperation *PositionEndorsementOperation) GetPositionEndorsementById(id uint) (endorsement *PositionEndorsement, err error) {
+	endorsement = &PositionEndorsement{}
+	ctx, cancel := context.WithTimeout(context.Background(), endorsementOperation.queryTimeout)
+	defer cancel()
+	err = endorsementOperation.db.WithContext(ctx).Where("id = ?", id).First(endorsement).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = ErrPositionEndorsementNotFound
+	}
+	return
+}
+
+func (endorsementOperation *PositionEndorsementOperation) GetPositionEndorsements(cid int, page, pageSize int) (endorsements []*PositionEndorsement, total int64, err error) {
+	endorsements = make([]*PositionEndorsement, 0, pageSize)
+	ctx, cancel := context.WithTimeout(context.Background(), endorsementOperation.queryTimeout)
+	defer cancel()
+	filter := func(db *gorm.DB) *gorm.DB {
+		if cid > 0 {
+			return db.Where("cid = ?", cid)
+		}
+		return db
+	}
+	endorsementOperation.db.WithContext(ctx).Model(&PositionEndorsement{}).Scopes(filter).Select("id").Count(&total)
+	err = endorsementOperation.db.WithContext(ctx).Scopes(filter).Offset((page - 1) * pageSize).Order("cid, pattern").Limit(pageSize).Find(&endorsements).Error
+	return
+}
+
+func (endorsementOperation *PositionEndorsementOperation) GetActivePositionEndorsements(cid int) (endorsements []*PositionEndorsement, err error) {
+	endorsements = make([]*PositionEndorsement, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), endorsementOperation.queryTimeout)
+	defer cancel()
+	err = endorsementOperation.db.WithContext(ctx).
+		Where("cid = ? and (expires_at is null or expires_at > ?)", cid, time.Now()).
+		Order("pattern").
+		Find(&endorsements).
+		Error
+	return
+}
+
+func (endorsementOperation *PositionEndorsementOperation) DeletePositionEndorsement(endorsement *PositionEndorsement) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), endorsementOperation.queryTimeout)
+	defer cancel()
+	return endorsementOperation.db.WithContext(ctx).Delete(endorsement).Error
+}
@@ -0,0 +1,137 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type RoleOperation struct {
+	logger       log.LoggerInterface
+	db           *gorm.DB
+	queryTimeout time.Duration
+}
+
+func NewRoleOperation(logger log.LoggerInterface, db *gorm.DB, queryTimeout time.Duration) *RoleOperation {
+	return &RoleOperation{logger: logger, db: db, queryTimeout: queryTimeout}
+}
+
+// preloadActiveRoles 预加载用户未过期的角色, 用于计算有效权限
+func preloadActiveRoles(db *gorm.DB) *gorm.DB {
+	return db.Preload("Roles", "expires_at IS NULL OR expires_at > ?", time.Now()).Preload("Roles.Role")
+}
+
+func (roleOperation *RoleOperation) NewRole(user *User, name string, description string, permission PermissionSet) (role *Role) {
+	return &Role{
+		Name:        name,
+		Description: description,
+		Permissions: permission.String(),
+		CreatedBy:   user.Cid,
+	}
+}
+
+func (roleOperation *RoleOperation) AddRole(role *Role) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), roleOperation.queryTimeout)
+	defer cancel()
+	err = roleOperation.db.WithContext(ctx).Create(role).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		err = ErrRoleNameTaken
+	}
+	return
+}
+
+func (roleOperation *RoleOperation) GetRoleById(id uint) (role *Role, err error) {
+	role = &Role{}
+	ctx, cancel := context.WithTimeout(context.Background(), roleOperation.queryTimeout)
+	defer cancel()
+	err = roleOperation.db.WithContext(ctx).Where("id = ?", id).First(role).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = ErrRoleNotFound
+	}
+	return
+}
+
+func (roleOperation *RoleOperation) GetRoles() (roles []*Role, err error) {
+	roles = make([]*Role, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), roleOperation.queryTimeout)
+	defer cancel()
+	err = roleOperation.db.WithContext(ctx).Order("id").Find(&roles).Error
+	return
+}
+
+func (roleOperation *RoleOperation) UpdateRole(role *Role, updates map[string]interface{}) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), roleOperation.queryTimeout)
+	defer cancel()
+	err = roleOperation.db.WithContext(ctx).Model(role).Updates(updates).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		err = ErrRoleNameTaken
+	}
+	return
+}
+
+func (roleOperation *RoleOperation) DeleteRole(role *Role) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), roleOperation.queryTimeout)
+	defer cancel()
+	return roleOperation.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ?", role.ID).Delete(&UserRole{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(role).Error
+	})
+}
+
+func (roleOperation *RoleOperation) GetRoleHolders(role *Role) (cids []int, err error) {
+	cids = make([]int, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), roleOperation.queryTimeout)
+	defer cancel()
+	err = roleOperation.db.WithContext(ctx).Model(&UserRole{}).
+		Where("role_id = ? AND (expires_at IS NULL OR expires_at > ?)", role.ID, time.Now()).
+		Pluck("cid", &cids).Error
+	return
+}
+
+func (roleOperation *RoleOperation) GetUserRoles(cid int) (roles []*UserRole, err error) {
+	roles = make([]*UserRole, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), roleOperation.queryTimeout)
+	defer cancel()
+	err = roleOperation.db.WithContext(ctx).Preload("Role").Where("cid = ?", cid).Order("id").Find(&roles).Error
+	return
+}
+
+func (roleOperation *RoleOperation) GetUserRole(cid int, roleId uint) (userRole *UserRole, err error) {
+	userRole = &UserRole{}
+	ctx, cancel := context.WithTimeout(context.Background(), roleOperation.queryTimeout)
+	defer cancel()
+	err = roleOperation.db.WithContext(ctx).Preload("Role").Where("cid = ? AND role_id = ?", cid, roleId).First(userRole).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = ErrUserRoleNotFound
+	}
+	return
+}
+
+func (roleOperation *RoleOperation) AssignRole(user *User, role *Role, expiresAt *time.Time, operator *User) (userRole *UserRole, err error) {
+	userRole = &UserRole{
+		Cid:       user.Cid,
+		RoleId:    role.ID,
+		ExpiresAt: expiresAt,
+		GrantedBy: operator.Cid,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), roleOperation.queryTimeout)
+	defer cancel()
+	err = roleOperation.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "cid"}, {Name: "role_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"expires_at", "granted_by"}),
+	}).Create(userRole).Error
+	userRole.Role = role
+	return
+}
+
+func (roleOperation *RoleOperation) RevokeRole(userRole *UserRole) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), roleOperation.queryTimeout)
+	defer cancel()
+	return roleOperation.db.WithContext(ctx).Delete(userRole).Error
+}
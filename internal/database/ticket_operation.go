@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"gorm.io/gorm"
+	"time"
+)
+
+type TicketOperation struct {
+	logger       log.LoggerInterface
+	db           *gorm.DB
+	queryTimeout time.Duration
+}
+
+func NewTicketOperation(logger log.LoggerInterface, db *gorm.DB, queryTimeout time.Duration) *TicketOperation {
+	return &TicketOperation{logger: logger, db: db, queryTimeout: queryTimeout}
+}
+
+func (ticketOperation *TicketOperation) NewTicket(user *User, title string, content string) (ticket *Ticket) {
+	return &Ticket{
+		Cid:     user.Cid,
+		Title:   title,
+		Content: content,
+		Status:  TicketStatusOpen,
+	}
+}
+
+func (ticketOperation *TicketOperation) AddTicket(ticket *Ticket) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ticketOperation.queryTimeout)
+	defer cancel()
+	return ticketOperation.db.WithContext(ctx).Create(ticket).Error
+}
+
+func (ticketOperation *TicketOperation) GetTicketById(id uint) (ticket *Ticket, err error) {
+	ticket = &Ticket{}
+	ctx, cancel := context.WithTimeout(context.Background(), ticketOperation.queryTimeout)
+	defer cancel()
+	err = ticketOperation.db.WithContext(ctx).
+		Preload("Replies", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Where("id = ?", id).
+		First(ticket).
+		Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = ErrTicketNotFound
+	}
+	return
+}
+
+func (ticketOperation *TicketOperation) GetTickets(page, pageSize int) (tickets []*Ticket, total int64, err error) {
+	tickets = make([]*Ticket, 0, pageSize)
+	ctx, cancel := context.WithTimeout(context.Background(), ticketOperation.queryTimeout)
+	defer cancel()
+	ticketOperation.db.WithContext(ctx).Model(&Ticket{}).Select("id").Count(&total)
+	err = ticketOperation.db.WithContext(ctx).Offset((page - 1) * pageSize).Order("status, updated_at desc").Limit(pageSize).Find(&tickets).Error
+	return
+}
+
+func (ticketOperation *TicketOperation) GetUserTickets(cid int, page, pageSize int) (tickets []*Ticket, total int64, err error) {
+	tickets = make([]*Ticket, 0, pageSize)
+	ctx, cancel := context.WithTimeout(context.Background(), ticketOperation.queryTimeout)
+	defer cancel()
+	ticketOperation.db.WithContext(ctx).Model(&Ticket{}).Select("id").Where("cid = ?", cid).Count(&total)
+	err = ticketOperation.db.WithContext(ctx).Offset((page-1)*pageSize).Where("cid = ?", cid).Order("updated_at desc").Limit(pageSize).Find(&tickets).Error
+	return
+}
+
+func (ticketOperation *TicketOperation) NewTicketReply(ticket *Ticket, user *User, content string) (reply *TicketMessage) {
+	return &TicketMessage{
+		TicketId: ticket.ID,
+		Cid:      user.Cid,
+		Content:  content,
+	}
+}
+
+func (ticketOperation *TicketOperation) AddTicketReply(ticket *Ticket, reply *TicketMessage) (err error) {
+	if ticket.Status == TicketStatusClosed {
+		return ErrTicketClosed
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ticketOperation.queryTimeout)
+	defer cancel()
+	return ticketOperation.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(reply).Error; err != nil {
+			return err
+		}
+		// 更新工单的更新时间, 便于按最近活动排序
+		if err := tx.Model(ticket).Update("updated_at", time.Now()).Error; err != nil {
+			return err
+		}
+		ticket.Replies = append(ticket.Replies, reply)
+		return nil
+	})
+}
+
+func (ticketOperation *TicketOperation) AssignTicket(ticket *Ticket, cid int) (err error) {
+	if ticket.Status == TicketStatusClosed {
+		return ErrTicketClosed
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ticketOperation.queryTimeout)
+	defer cancel()
+	if err := ticketOperation.db.WithContext(ctx).Model(ticket).Update("assigned_to", cid).Error; err != nil {
+		return err
+	}
+	ticket.AssignedTo = cid
+	return nil
+}
+
+func (ticketOperation *TicketOperation) CloseTicket(ticket *Ticket) (err error) {
+	if ticket.Status == TicketStatusClosed {
+		return ErrTicketClosed
+	}
+	now := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), ticketOperation.queryTimeout)
+	defer cancel()
+	if err := ticketOperation.db.WithContext(ctx).Model(ticket).Updates(map[string]interface{}{
+		"status":    TicketStatusClosed,
+		"closed_at": &now,
+	}).Error; err != nil {
+		return err
+	}
+	ticket.Status = TicketStatusClosed
+	ticket.ClosedAt = &now
+	return nil
+}
@@ -0,0 +1,234 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"gorm.io/gorm"
+	"time"
+)
+
+type TrainingOperation struct {
+	logger       log.LoggerInterface
+	db           *gorm.DB
+	queryTimeout time.Duration
+}
+
+func NewTrainingOperation(logger log.LoggerInterface, db *gorm.DB, queryTimeout time.Duration) *TrainingOperation {
+	return &TrainingOperation{logger: logger, db: db, queryTimeout: queryTimeout}
+}
+
+func (trainingOperation *TrainingOperation) NewTrainingRequest(user *User, targetRating int, remark string) (request *TrainingRequest) {
+	return &TrainingRequest{
+		Cid:          user.Cid,
+		TargetRating: targetRating,
+		Remark:       remark,
+		Status:       TrainingStatusPending,
+	}
+}
+
+func (trainingOperation *TrainingOperation) AddTrainingRequest(request *TrainingRequest) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), trainingOperation.queryTimeout)
+	defer cancel()
+	return trainingOperation.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&TrainingRequest{}).
+			Where("cid = ? and status in ?", request.Cid, []int{TrainingStatusPending, TrainingStatusInProgress}).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrTrainingExists
+		}
+		return tx.Create(request).Error
+	})
+}
+
+func (trainingOperation *TrainingOperation) GetTrainingRequestById(id uint) (request *TrainingRequest, err error) {
+	request = &TrainingRequest{}
+	ctx, cancel := context.WithTimeout(context.Background(), trainingOperation.queryTimeout)
+	defer cancel()
+	err = trainingOperation.db.WithContext(ctx).
+		Preload("Reports", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Reports.History").
+		Preload("Solos", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Where("id = ?", id).
+		First(request).
+		Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = ErrTrainingNotFound
+	}
+	return
+}
+
+func (trainingOperation *TrainingOperation) GetTrainingRequests(page, pageSize int) (requests []*TrainingRequest, total int64, err error) {
+	requests = make([]*TrainingRequest, 0, pageSize)
+	ctx, cancel := context.WithTimeout(context.Background(), trainingOperation.queryTimeout)
+	defer cancel()
+	trainingOperation.db.WithContext(ctx).Model(&TrainingRequest{}).Select("id").Count(&total)
+	err = trainingOperation.db.WithContext(ctx).Offset((page - 1) * pageSize).Order("status, updated_at desc").Limit(pageSize).Find(&requests).Error
+	return
+}
+
+func (trainingOperation *TrainingOperation) GetUserTrainingRequests(cid int, page, pageSize int) (requests []*TrainingRequest, total int64, err error) {
+	requests = make([]*TrainingRequest, 0, pageSize)
+	ctx, cancel := context.WithTimeout(context.Background(), trainingOperation.queryTimeout)
+	defer cancel()
+	trainingOperation.db.WithContext(ctx).Model(&TrainingRequest{}).Select("id").Where("cid = ? or mentor_cid = ?", cid, cid).Count(&total)
+	err = trainingOperation.db.WithContext(ctx).Offset((page-1)*pageSize).Where("cid = ? or mentor_cid = ?", cid, cid).Order("status, updated_at desc").Limit(pageSize).Find(&requests).Error
+	return
+}
+
+func (trainingOperation *TrainingOperation) AssignMentor(request *TrainingRequest, mentorCid int) (err error) {
+	if request.Closed() {
+		return ErrTrainingClosed
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), trainingOperation.queryTimeout)
+	defer cancel()
+	if err := trainingOperation.db.WithContext(ctx).Model(request).Updates(map[string]interface{}{
+		"mentor_cid": mentorCid,
+		"status":     TrainingStatusInProgress,
+	}).Error; err != nil {
+		return err
+	}
+	request.MentorCid = mentorCid
+	request.Status = TrainingStatusInProgress
+	return nil
+}
+
+func (trainingOperation *TrainingOperation) CloseTrainingRequest(request *TrainingRequest, status int) (err error) {
+	if request.Closed() {
+		return ErrTrainingClosed
+	}
+	now := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), trainingOperation.queryTimeout)
+	defer cancel()
+	err = trainingOperation.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(request).Updates(map[string]interface{}{
+			"status":    status,
+			"closed_at": &now,
+		}).Error; err != nil {
+			return err
+		}
+		// 培训结束后单飞授权随之失效
+		return tx.Model(&SoloEndorsement{}).
+			Where("training_id = ? and revoked_at is null", request.ID).
+			Update("revoked_at", &now).Error
+	})
+	if err != nil {
+		return err
+	}
+	request.Status = status
+	request.ClosedAt = &now
+	for _, solo := range request.Solos {
+		if solo.RevokedAt == nil {
+			solo.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (trainingOperation *TrainingOperation) CancelUserTrainingRequests(cid int) (err error) {
+	now := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), trainingOperation.queryTimeout)
+	defer cancel()
+	return trainingOperation.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var ids []uint
+		if err := tx.Model(&TrainingRequest{}).
+			Where("cid = ? and status in ?", cid, []int{TrainingStatusPending, TrainingStatusInProgress}).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		if err := tx.Model(&TrainingRequest{}).Where("id in ?", ids).Updates(map[string]interface{}{
+			"status":    TrainingStatusCancelled,
+			"closed_at": &now,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&SoloEndorsement{}).
+			Where("training_id in ? and revoked_at is null", ids).
+			Update("revoked_at", &now).Error
+	})
+}
+
+func (trainingOperation *TrainingOperation) NewTrainingReport(request *TrainingRequest, mentor *User, history *History, content string) (report *TrainingReport) {
+	return &TrainingReport{
+		TrainingId: request.ID,
+		MentorCid:  mentor.Cid,
+		HistoryId:  history.ID,
+		History:    history,
+		Content:    content,
+	}
+}
+
+func (trainingOperation *TrainingOperation) AddTrainingReport(request *TrainingRequest, report *TrainingReport) (err error) {
+	if request.Closed() {
+		return ErrTrainingClosed
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), trainingOperation.queryTimeout)
+	defer cancel()
+	return trainingOperation.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("History").Create(report).Error; err != nil {
+			return err
+		}
+		// 更新申请的更新时间, 便于按最近活动排序
+		if err := tx.Model(request).Update("updated_at", time.Now()).Error; err != nil {
+			return err
+		}
+		request.Reports = append(request.Reports, report)
+		return nil
+	})
+}
+
+func (trainingOperation *TrainingOperation) NewSoloEndorsement(request *TrainingRequest, mentor *User, facility int, expiresAt time.Time) (solo *SoloEndorsement) {
+	return &SoloEndorsement{
+		TrainingId: request.ID,
+		Cid:        request.Cid,
+		Facility:   facility,
+		ExpiresAt:  expiresAt,
+		GrantedBy:  mentor.Cid,
+	}
+}
+
+func (trainingOperation *TrainingOperation) AddSoloEndorsement(request *TrainingRequest, solo *SoloEndorsement) (err error) {
+	if request.Closed() {
+		return ErrTrainingClosed
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), trainingOperation.queryTimeout)
+	defer cancel()
+	if err := trainingOperation.db.WithContext(ctx).Create(solo).Error; err != nil {
+		return err
+	}
+	request.Solos = append(request.Solos, solo)
+	return nil
+}
+
+func (trainingOperation *TrainingOperation) GetActiveSoloEndorsement(cid int, facility int) (solo *SoloEndorsement, err error) {
+	solo = &SoloEndorsement{}
+	ctx, cancel := context.WithTimeout(context.Background(), trainingOperation.queryTimeout)
+	defer cancel()
+	err = trainingOperation.db.WithContext(ctx).
+		Where("cid = ? and facility = ? and revoked_at is null and expires_at > ?", cid, facility, time.Now()).
+		Order("expires_at desc").
+		First(solo).
+		Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = ErrSoloEndorsementNotFound
+	}
+	return
+}
+
+func (trainingOperation *TrainingOperation) RevokeSoloEndorsement(solo *SoloEndorsement) (err error) {
+	now := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), trainingOperation.queryTimeout)
+	defer cancel()
+	if err := trainingOperation.db.WithContext(ctx).Model(solo).Update("revoked_at", &now).Error; err != nil {
+		return err
+	}
+	solo.RevokedAt = &now
+	return nil
+}
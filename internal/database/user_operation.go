@@ -28,7 +28,7 @@ func (userOperation *UserOperation) GetUserByUid(uid uint) (user *User, err erro
 	user = &User{}
 	ctx, cancel := context.WithTimeout(context.Background(), userOperation.queryTimeout)
 	defer cancel()
-	err = userOperation.db.WithContext(ctx).
+	err = preloadActiveRoles(userOperation.db.WithContext(ctx)).
 		Where("id = ?", uid).
 		First(user).Error
 	if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -41,7 +41,7 @@ func (userOperation *UserOperation) GetUserByCid(cid int) (user *User, err error
 	user = &User{}
 	ctx, cancel := context.WithTimeout(context.Background(), userOperation.queryTimeout)
 	defer cancel()
-	err = userOperation.db.WithContext(ctx).
+	err = preloadActiveRoles(userOperation.db.WithContext(ctx)).
 		Where("cid = ?", cid).
 		First(user).Error
 	if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -54,7 +54,7 @@ func (userOperation *UserOperation) GetUserByUsername(username string) (user *Us
 	user = &User{}
 	ctx, cancel := context.WithTimeout(context.Background(), userOperation.queryTimeout)
 	defer cancel()
-	err = userOperation.db.WithContext(ctx).
+	err = preloadActiveRoles(userOperation.db.WithContext(ctx)).
 		Where("username = ?", username).
 		First(user).Error
 	if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -67,20 +67,20 @@ func (userOperation *UserOperation) GetUserByEmail(email string) (user *User, er
 	user = &User{}
 	ctx, cancel := context.WithTimeout(context.Background(), userOperation.queryTimeout)
 	defer cancel()
-	err = userOperation.db.WithContext(ctx).
+	err = preloadActiveRoles(userOperation.db.WithContext(ctx)).
 		Where("email = ?", email).
 		First(user).Error
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		err = ErrUserNotFound
 	}
-	return user, nil
+	return
 }
 
 func (userOperation *UserOperation) GetUserByUsernameOrEmail(ident string) (user *User, err error) {
 	user = &User{}
 	ctx, cancel := context.WithTimeout(context.Background(), userOperation.queryTimeout)
 	defer cancel()
-	err = userOperation.db.WithContext(ctx).
+	err = preloadActiveRoles(userOperation.db.WithContext(ctx)).
 		Where("username = ? OR email = ?", ident, ident).
 		First(user).Error
 	if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -107,6 +107,14 @@ func (userOperation *UserOperation) GetControllers(page, pageSize int) (users []
 	return
 }
 
+func (userOperation *UserOperation) GetControllersByRating(minRating, maxRating int) (users []*User, err error) {
+	users = make([]*User, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), userOperation.queryTimeout)
+	defer cancel()
+	err = userOperation.db.WithContext(ctx).Where("rating >= ? and rating <= ?", minRating, maxRating).Order("cid").Find(&users).Error
+	return
+}
+
 func (userOperation *UserOperation) NewUser(username string, email string, cid int, password string) (user *User, err error) {
 	encodePassword, err := bcrypt.GenerateFromPassword([]byte(password), userOperation.config.BcryptCost)
 	if err != nil {
@@ -165,12 +173,38 @@ func (userOperation *UserOperation) UpdateUserRating(user *User, rating int) err
 	})
 }
 
-func (userOperation *UserOperation) UpdateUserPermission(user *User, permission Permission) error {
-	user.Permission = int64(permission)
+func (userOperation *UserOperation) BanUser(user *User, until time.Time, reason string) error {
+	user.BannedUntil = &until
+	user.BanReason = reason
+	ctx, cancel := context.WithTimeout(context.Background(), userOperation.queryTimeout)
+	defer cancel()
+	return userOperation.db.WithContext(ctx).Model(user).Updates(map[string]interface{}{
+		"banned_until": until,
+		"ban_reason":   reason,
+	}).Error
+}
+
+func (userOperation *UserOperation) UnbanUser(user *User) error {
+	user.BannedUntil = nil
+	user.BanReason = ""
+	ctx, cancel := context.WithTimeout(context.Background(), userOperation.queryTimeout)
+	defer cancel()
+	return userOperation.db.WithContext(ctx).Model(user).Updates(map[string]interface{}{
+		"banned_until": nil,
+		"ban_reason":   "",
+	}).Error
+}
+
+func (userOperation *UserOperation) UpdateUserPermission(user *User, permission PermissionSet) error {
+	legacy, ok := permission.Legacy()
+	if !ok {
+		return ErrPermissionNodeNotExists
+	}
+	user.Permission = legacy
 	ctx, cancel := context.WithTimeout(context.Background(), userOperation.queryTimeout)
 	defer cancel()
 	return userOperation.db.Clauses(clause.Locking{Strength: "UPDATE"}).WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		return tx.Model(user).Update("permission", int64(permission)).Error
+		return tx.Model(user).Update("permission", legacy).Error
 	})
 }
 
@@ -196,7 +230,7 @@ func (userOperation *UserOperation) SaveUser(user *User) error {
 	ctx, cancel := context.WithTimeout(context.Background(), userOperation.queryTimeout)
 	defer cancel()
 
-	err := userOperation.db.WithContext(ctx).Save(user).Error
+	err := userOperation.db.WithContext(ctx).Omit("Roles").Save(user).Error
 	return err
 }
 
@@ -232,6 +266,31 @@ func (userOperation *UserOperation) GetTotalUsers() (total int64, err error) {
 	return
 }
 
+func (userOperation *UserOperation) GetUnusedCid(minCid, maxCid int) (cid int, err error) {
+	cids := make([]int, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), userOperation.queryTimeout)
+	defer cancel()
+	err = userOperation.db.WithContext(ctx).Model(&User{}).
+		Where("cid between ? and ?", minCid, maxCid).
+		Order("cid").
+		Pluck("cid", &cids).
+		Error
+	if err != nil {
+		return 0, err
+	}
+	cid = minCid
+	for _, used := range cids {
+		if used != cid {
+			break
+		}
+		cid++
+	}
+	if cid > maxCid {
+		return 0, ErrNoUnusedCid
+	}
+	return cid, nil
+}
+
 func (userOperation *UserOperation) GetTotalControllers() (total int64, err error) {
 	ctx, cancel := context.WithTimeout(context.Background(), userOperation.queryTimeout)
 	defer cancel()
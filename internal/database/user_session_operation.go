@@ -0,0 +1,127 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"gorm.io/gorm"
+	"time"
+)
+
+type UserSessionOperation struct {
+	logger       log.LoggerInterface
+	db           *gorm.DB
+	queryTimeout time.Duration
+}
+
+func NewUserSessionOperation(logger log.LoggerInterface, db *gorm.DB, queryTimeout time.Duration) *UserSessionOperation {
+	return &UserSessionOperation{logger: logger, db: db, queryTimeout: queryTimeout}
+}
+
+func (sessionOperation *UserSessionOperation) NewUserSession(user *User, ip string, userAgent string, expiresAt time.Time) (session *UserSession) {
+	jti := make([]byte, 16)
+	_, _ = rand.Read(jti)
+	if len(userAgent) > 256 {
+		userAgent = userAgent[:256]
+	}
+	now := time.Now()
+	return &UserSession{
+		Jti:        hex.EncodeToString(jti),
+		Cid:        user.Cid,
+		Ip:         ip,
+		UserAgent:  userAgent,
+		LastUsedAt: now,
+		ExpiresAt:  expiresAt,
+	}
+}
+
+func (sessionOperation *UserSessionOperation) AddUserSession(session *UserSession) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sessionOperation.queryTimeout)
+	defer cancel()
+	return sessionOperation.db.WithContext(ctx).Create(session).Error
+}
+
+func (sessionOperation *UserSessionOperation) GetUserSessionByJti(jti string) (session *UserSession, err error) {
+	session = &UserSession{}
+	ctx, cancel := context.WithTimeout(context.Background(), sessionOperation.queryTimeout)
+	defer cancel()
+	err = sessionOperation.db.WithContext(ctx).Where("jti = ?", jti).First(session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = ErrUserSessionNotFound
+	}
+	return
+}
+
+func (sessionOperation *UserSessionOperation) GetUserSessionById(id uint) (session *UserSession, err error) {
+	session = &UserSession{}
+	ctx, cancel := context.WithTimeout(context.Background(), sessionOperation.queryTimeout)
+	defer cancel()
+	err = sessionOperation.db.WithContext(ctx).Where("id = ?", id).First(session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = ErrUserSessionNotFound
+	}
+	return
+}
+
+func (sessionOperation *UserSessionOperation) GetActiveUserSessions(cid int) (sessions []*UserSession, err error) {
+	sessions = make([]*UserSession, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), sessionOperation.queryTimeout)
+	defer cancel()
+	err = sessionOperation.db.WithContext(ctx).
+		Where("cid = ? and revoked_at is null and expires_at > ?", cid, time.Now()).
+		Order("last_used_at desc").
+		Find(&sessions).
+		Error
+	return
+}
+
+func (sessionOperation *UserSessionOperation) TouchUserSession(session *UserSession, ip string, expiresAt time.Time) (err error) {
+	updates := map[string]interface{}{
+		"last_used_at": time.Now(),
+		"ip":           ip,
+	}
+	if !expiresAt.IsZero() {
+		updates["expires_at"] = expiresAt
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sessionOperation.queryTimeout)
+	defer cancel()
+	return sessionOperation.db.WithContext(ctx).Model(session).Updates(updates).Error
+}
+
+func (sessionOperation *UserSessionOperation) RevokeUserSession(session *UserSession) (err error) {
+	now := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), sessionOperation.queryTimeout)
+	defer cancel()
+	if err := sessionOperation.db.WithContext(ctx).Model(session).Update("revoked_at", &now).Error; err != nil {
+		return err
+	}
+	session.RevokedAt = &now
+	return nil
+}
+
+func (sessionOperation *UserSessionOperation) RevokeUserSessions(cid int, exceptJti string) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sessionOperation.queryTimeout)
+	defer cancel()
+	return sessionOperation.db.WithContext(ctx).Model(&UserSession{}).
+		Where("cid = ? and jti <> ? and revoked_at is null", cid, exceptJti).
+		Update("revoked_at", time.Now()).
+		Error
+}
+
+func (sessionOperation *UserSessionOperation) ClearUserSessionsTotpPending(cid int) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sessionOperation.queryTimeout)
+	defer cancel()
+	return sessionOperation.db.WithContext(ctx).Model(&UserSession{}).
+		Where("cid = ? and totp_pending = ?", cid, true).
+		Update("totp_pending", false).
+		Error
+}
+
+func (sessionOperation *UserSessionOperation) DeleteExpiredUserSessions(before time.Time) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sessionOperation.queryTimeout)
+	defer cancel()
+	return sessionOperation.db.WithContext(ctx).Where("expires_at < ?", before).Delete(&UserSession{}).Error
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+
+package fsd_server
+
+import (
+	"github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// watchDrainSignal 收到SIGUSR1信号时进入排空模式
+func watchDrainSignal(logger log.LoggerInterface, clientManager fsd.ClientManagerInterface, duration time.Duration) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	go func() {
+		for range signals {
+			logger.Info("Received SIGUSR1, entering drain mode")
+			if _, err := clientManager.Drain(duration); err != nil {
+				logger.WarnF("Fail to enter drain mode: %v", err)
+			}
+		}
+	}()
+}
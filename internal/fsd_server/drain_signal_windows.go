@@ -0,0 +1,12 @@
+//go:build windows
+
+package fsd_server
+
+import (
+	"github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	"time"
+)
+
+// watchDrainSignal Windows下没有SIGUSR1信号, 只能通过管理接口进入排空模式
+func watchDrainSignal(_ log.LoggerInterface, _ fsd.ClientManagerInterface, _ time.Duration) {}
@@ -9,6 +9,7 @@ import (
 	"github.com/half-nothing/simple-fsd/internal/interfaces/global"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"github.com/half-nothing/simple-fsd/internal/metrics"
 	"github.com/half-nothing/simple-fsd/internal/utils"
 	"slices"
 	"sync"
@@ -48,6 +49,8 @@ type Client struct {
 	reconnectTimer      *time.Timer
 	lock                sync.RWMutex
 	pathTrigger         *utils.OverflowTrigger
+	restored            bool      // 会话是否从快照恢复, 恢复的会话在关闭服务器时已经保存过连线记录
+	resumeTime          time.Time // 恢复的会话重连的时间
 }
 
 func (cm *ClientManager) NewClient(
@@ -87,7 +90,7 @@ func (cm *ClientManager) NewClient(
 		protocol:            protocol,
 		realName:            realName,
 		socket:              session,
-		position:            [4]Position{{0, 0}, {0, 0}, {0, 0}, {0, 0}},
+		position:            [4]Position{},
 		simType:             0,
 		transponder:         "2000",
 		altitude:            0,
@@ -131,20 +134,25 @@ func (client *Client) Delete() {
 			client.reconnectTimer = nil
 		}
 
-		if client.isAtc || !client.config.Server.General.SimulatorServer {
-			if err := client.historyOperation.EndRecordAndSaveHistory(client.history); err != nil {
-				client.logger.ErrorF("[%s](%s) Failed to end history: %v", client.socket.ConnId(), client.callsign, err)
+		// 恢复后没有重连的会话在关闭服务器时已经保存过连线记录与连线时长
+		if !client.restored || !client.resumeTime.IsZero() {
+			onlineTime := 0
+			if client.isAtc || !client.config.Server.General.SimulatorServer {
+				var err error
+				if onlineTime, err = client.endHistory(); err != nil {
+					client.logger.ErrorF("[%s](%s) Failed to end history: %v", client.socket.ConnId(), client.callsign, err)
+				}
 			}
-		}
 
-		if client.isAtc {
-			if err := client.userOperation.UpdateUserAtcTime(client.user, client.history.OnlineTime); err != nil {
-				client.logger.ErrorF("[%s](%s) Failed to add ATC time: %v", client.socket.ConnId(), client.callsign, err)
-			}
-		} else if !client.config.Server.General.SimulatorServer {
-			// 如果不是模拟机服务器, 则写入机组连线时长
-			if err := client.userOperation.UpdateUserPilotTime(client.user, client.history.OnlineTime); err != nil {
-				client.logger.ErrorF("[%s](%s) Failed to add pilot time: %v", client.socket.ConnId(), client.callsign, err)
+			if client.isAtc {
+				if err := client.userOperation.UpdateUserAtcTime(client.user, onlineTime); err != nil {
+					client.logger.ErrorF("[%s](%s) Failed to add ATC time: %v", client.socket.ConnId(), client.callsign, err)
+				}
+			} else if !client.config.Server.General.SimulatorServer {
+				// 如果不是模拟机服务器, 则写入机组连线时长
+				if err := client.userOperation.UpdateUserPilotTime(client.user, onlineTime); err != nil {
+					client.logger.ErrorF("[%s](%s) Failed to add pilot time: %v", client.socket.ConnId(), client.callsign, err)
+				}
 			}
 		}
 
@@ -154,6 +162,20 @@ func (client *Client) Delete() {
 	}
 }
 
+// endHistory 结束并保存连线记录, 返回需要累加到用户的连线时长(秒)
+func (client *Client) endHistory() (int, error) {
+	if !client.restored {
+		err := client.historyOperation.EndRecordAndSaveHistory(client.history)
+		return client.history.OnlineTime, err
+	}
+	// 恢复的会话继续之前的连线记录, 只累加重连之后的时长
+	now := time.Now()
+	onlineTime := int(now.Sub(client.resumeTime).Seconds())
+	client.history.EndTime = now
+	client.history.OnlineTime += onlineTime
+	return onlineTime, client.historyOperation.SaveHistory(client.history)
+}
+
 func (client *Client) Reconnect(socket SessionInterface) bool {
 	client.lock.Lock()
 	defer client.lock.Unlock()
@@ -169,6 +191,12 @@ func (client *Client) Reconnect(socket SessionInterface) bool {
 		client.reconnectTimer = nil
 	}
 
+	if client.restored && client.resumeTime.IsZero() {
+		client.resumeTime = time.Now()
+		if client.history.ID == 0 {
+			client.history.StartTime = client.resumeTime
+		}
+	}
 	client.ClearAtcAtisInfo()
 	client.disconnect.Store(false)
 	client.socket = socket
@@ -189,10 +217,8 @@ func (client *Client) MarkedDisconnect(immediate bool) {
 		return
 	}
 
-	// 关闭连接
-	if client.socket.Conn() != nil {
-		_ = client.socket.Conn().Close()
-	}
+	// 写出已排队的消息(如踢出通知)后关闭连接
+	client.socket.Close()
 
 	// 取消之前的定时器
 	if client.reconnectTimer != nil {
@@ -248,6 +274,7 @@ func (client *Client) UpdatePilotPos(transponder int, lat float64, lon float64,
 	client.altitude = alt
 	client.groundSpeed = groundSpeed
 	client.pbh = pbh
+	client.clientManager.UpdateClientPosition(client)
 	go client.pathTrigger.Tick()
 }
 
@@ -278,6 +305,7 @@ func (client *Client) SendError(result *Result) {
 		return
 	}
 
+	metrics.ErrorsSent.WithLabelValues(result.Errno.String()).Inc()
 	packet := makePacket(Error, "global.FSDServerName", client.callsign, fmt.Sprintf("%03d", result.Errno.Index()), result.Env, result.Errno.String())
 	client.SendLine(packet)
 
@@ -302,12 +330,11 @@ func (client *Client) SendLineWithoutLog(line []byte) {
 	}
 
 	if !bytes.HasSuffix(line, splitSign) {
-		line = append(line, splitSign...)
+		// 限制容量保证append重新分配, 不写入调用方缓冲区的剩余空间
+		line = append(line[:len(line):len(line)], splitSign...)
 	}
 
-	if _, err := client.socket.Conn().Write(line); err != nil {
-		client.logger.ErrorF("[%s](%s) Failed to send data: %v", client.socket.ConnId(), client.callsign, err)
-	}
+	client.socket.Send(line)
 }
 
 func (client *Client) SendLine(line []byte) {
@@ -321,14 +348,12 @@ func (client *Client) SendLine(line []byte) {
 
 	if !bytes.HasSuffix(line, splitSign) {
 		client.logger.DebugF("[%s](%s) <- %s", client.socket.ConnId(), client.callsign, line)
-		line = append(line, splitSign...)
+		line = append(line[:len(line):len(line)], splitSign...)
 	} else {
 		client.logger.DebugF("[%s](%s) <- %s", client.socket.ConnId(), client.callsign, line[:len(line)-splitSignLen])
 	}
 
-	if _, err := client.socket.Conn().Write(line); err != nil {
-		client.logger.WarnF("[%s](%s) Failed to send data: %v", client.socket.ConnId(), client.callsign, err)
-	}
+	client.socket.Send(line)
 }
 
 func (client *Client) SendMotd() {
@@ -338,7 +363,7 @@ func (client *Client) SendMotd() {
 	}
 
 	buffer := bytes.Buffer{}
-	for _, message := range client.config.Server.FSDServer.Motd {
+	for _, message := range client.config.Server.FSDServer.MotdLines() {
 		buffer.Write(makePacket(Message, global.FSDServerName, client.callsign, message))
 	}
 
@@ -396,3 +421,7 @@ func (client *Client) Heading() int {
 func (client *Client) Paths() []*PilotPath {
 	return client.paths
 }
+
+func (client *Client) SendQueueDepth() int { return client.socket.SendQueueDepth() }
+
+func (client *Client) SendQueueDropped() uint64 { return client.socket.SendQueueDropped() }
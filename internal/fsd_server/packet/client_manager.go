@@ -6,6 +6,7 @@ import (
 	"github.com/half-nothing/simple-fsd/internal/interfaces"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
 	. "github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
+	"github.com/half-nothing/simple-fsd/internal/metrics"
 	"math/rand"
 	"strconv"
 	"sync"
@@ -17,10 +18,16 @@ type ClientManager struct {
 	clients            map[string]ClientInterface
 	lock               sync.RWMutex
 	shuttingDown       atomic.Bool
+	draining           atomic.Bool
 	config             *config.Config
 	heartbeatSender    *HeartbeatSender
+	sectors            *SectorMap
+	spatialIndex       *SpatialIndex
 	clientSlicePool    sync.Pool
 	applicationContent *interfaces.ApplicationContent
+	emailService       atomic.Value // service.EmailServiceInterface
+	helpRequestTimes   map[int]time.Time
+	helpRequestLock    sync.Mutex
 }
 
 var (
@@ -36,46 +43,68 @@ func NewClientManager(applicationContent *interfaces.ApplicationContent) *Client
 				clients:            make(map[string]ClientInterface),
 				shuttingDown:       atomic.Bool{},
 				config:             c,
+				sectors:            NewSectorMap(c.Server.FSDServer.SectorData),
+				spatialIndex:       NewSpatialIndex(),
 				applicationContent: applicationContent,
+				helpRequestTimes:   make(map[int]time.Time),
 				clientSlicePool: sync.Pool{
 					New: func() interface{} {
 						return make([]ClientInterface, 0, 128)
 					},
 				},
 			}
+			metrics.RegisterClientCollector(clientManager)
 			clientManager.heartbeatSender = NewHeartbeatSender(applicationContent.Logger(), c.Server.FSDServer.HeartbeatDuration, clientManager.SendHeartBeat)
 		}
 	})
 	return clientManager
 }
 
+func (cm *ClientManager) Sectors() *SectorMap {
+	return cm.sectors
+}
+
 func (cm *ClientManager) PutSlice(clients []ClientInterface) {
 	cm.clientSlicePool.Put(clients)
 }
 
+// ShuttingDown 服务器是否正在关闭
+func (cm *ClientManager) ShuttingDown() bool {
+	return cm.shuttingDown.Load()
+}
+
+// Shutdown 保存会话快照后保存飞行计划与连线记录并断开所有客户端, ctx超时后不再等待
 func (cm *ClientManager) Shutdown(ctx context.Context) error {
 	if !cm.shuttingDown.CompareAndSwap(false, true) {
 		return fmt.Errorf("shutting down already in progress")
 	}
-	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
 
 	cm.heartbeatSender.Stop()
 
 	clients := cm.GetClientSnapshot()
-	defer cm.PutSlice(clients)
+	// 会话快照只读取内存, 最先保存, 避免数据库写入超时导致快照丢失
+	if err := cm.saveSessionSnapshot(clients); err != nil {
+		cm.applicationContent.Logger().ErrorF("Fail to save session snapshot: %v", err)
+	}
 
 	done := make(chan struct{})
 	go func() {
 		defer close(done)
+		// 超时返回后协程仍在使用clients, 完成后再归还
+		defer cm.PutSlice(clients)
+		cm.flushFlightPlans()
 		cm.disconnectClients(clients)
+		// 断开客户端时连线记录已写入数据库, 重新保存快照以便恢复后继续同一条连线记录
+		if err := cm.saveSessionSnapshot(clients); err != nil {
+			cm.applicationContent.Logger().ErrorF("Fail to save session snapshot: %v", err)
+		}
 	}()
 
 	select {
 	case <-done:
 		return nil
-	case <-timeoutCtx.Done():
-		return timeoutCtx.Err()
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
@@ -94,6 +123,24 @@ func (cm *ClientManager) GetClientSnapshot() []ClientInterface {
 	return clients
 }
 
+// flushFlightPlans 保存所有机组的飞行计划
+func (cm *ClientManager) flushFlightPlans() {
+	if cm.config.Server.General.SimulatorServer {
+		return
+	}
+	flightPlanOperation := cm.applicationContent.Operations().FlightPlanOperation()
+	clients := cm.GetClientSnapshot()
+	defer cm.PutSlice(clients)
+	for _, client := range clients {
+		if client.IsAtc() || client.FlightPlan() == nil {
+			continue
+		}
+		if err := flightPlanOperation.SaveFlightPlan(client.FlightPlan()); err != nil {
+			cm.applicationContent.Logger().ErrorF("[%s] Fail to save flight plan while draining: %v", client.Callsign(), err)
+		}
+	}
+}
+
 // 并发断开所有客户端连接
 func (cm *ClientManager) disconnectClients(clients []ClientInterface) {
 	if len(clients) == 0 {
@@ -141,6 +188,7 @@ func (cm *ClientManager) AddClient(client ClientInterface) error {
 		return fmt.Errorf("client already registered: %s", client.Callsign())
 	}
 	cm.clients[client.Callsign()] = client
+	cm.spatialIndex.Update(client)
 	return nil
 }
 
@@ -160,14 +208,20 @@ func (cm *ClientManager) DeleteClient(callsign string) bool {
 	cm.lock.Lock()
 	defer cm.lock.Unlock()
 
-	if _, exists := cm.clients[callsign]; !exists {
+	client, exists := cm.clients[callsign]
+	if !exists {
 		return false
 	}
 
 	delete(cm.clients, callsign)
+	cm.spatialIndex.Remove(client)
 	return true
 }
 
+func (cm *ClientManager) UpdateClientPosition(client ClientInterface) {
+	cm.spatialIndex.Update(client)
+}
+
 func (cm *ClientManager) SendMessageTo(callsign string, message []byte) error {
 	if cm.shuttingDown.Load() {
 		return fmt.Errorf("Server is shutting down")
@@ -202,19 +256,41 @@ func (cm *ClientManager) BroadcastMessage(message []byte, fromClient ClientInter
 	clients := cm.GetClientSnapshot()
 	defer cm.PutSlice(clients) // 重置并放回池中
 
+	cm.broadcast(message, fromClient, filter, clients)
+}
+
+// BroadcastMessageInRange 通过空间索引只向fromClient附近的客户端与所有管制员广播
+// filter只会作用于候选客户端, 因此只能用于结果必然在范围内或者是管制员的过滤器
+func (cm *ClientManager) BroadcastMessageInRange(message []byte, fromClient ClientInterface, filter BroadcastFilter) {
+	if fromClient == nil {
+		cm.BroadcastMessage(message, fromClient, filter)
+		return
+	}
+	if cm.shuttingDown.Load() || len(message) == 0 {
+		return
+	}
+
+	clients := cm.clientSlicePool.Get().([]ClientInterface)
+	clients = cm.spatialIndex.Candidates(fromClient, clients[:0])
+	defer cm.PutSlice(clients)
+
+	cm.broadcast(message, fromClient, filter, clients)
+}
+
+func (cm *ClientManager) broadcast(message []byte, fromClient ClientInterface, filter BroadcastFilter, clients []ClientInterface) {
 	if len(clients) == 0 {
 		return
 	}
 
+	start := time.Now()
+	defer func() { metrics.BroadcastDuration.Observe(time.Since(start).Seconds()) }()
+
 	// 准备完整消息（包含分割符）
 	fullMsg := make([]byte, len(message), len(message)+len(splitSign))
 	copy(fullMsg, message)
 	fullMsg = append(fullMsg, splitSign...)
 
-	// 并发广播
-	var wg sync.WaitGroup
-	sem := make(chan struct{}, cm.config.Server.FSDServer.MaxBroadcastWorkers)
-
+	// 发送队列的Push不会阻塞, 直接在当前协程中逐个入队
 	for _, client := range clients {
 		if client == fromClient || client.Disconnected() {
 			continue
@@ -224,18 +300,7 @@ func (cm *ClientManager) BroadcastMessage(message []byte, fromClient ClientInter
 			continue
 		}
 
-		wg.Add(1)
-		sem <- struct{}{}
-		go func(cl ClientInterface) {
-			defer func() {
-				<-sem
-				wg.Done()
-			}()
-
-			cm.applicationContent.Logger().DebugF("[Broadcast] -> [%s] %s", cl.Callsign(), message)
-			cl.SendLineWithoutLog(fullMsg)
-		}(client)
+		cm.applicationContent.Logger().DebugF("[Broadcast] -> [%s] %s", client.Callsign(), message)
+		client.SendLineWithoutLog(fullMsg)
 	}
-
-	wg.Wait()
 }
@@ -2,12 +2,15 @@
 package packet
 
 import (
+	"errors"
 	"fmt"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
 	. "github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/global"
 	. "github.com/half-nothing/simple-fsd/internal/interfaces/operation"
 	"github.com/half-nothing/simple-fsd/internal/utils"
 	"strings"
+	"time"
 )
 
 func (session *Session) checkPacketLength(data []string, requirement *CommandRequirement) (*Result, bool) {
@@ -30,30 +33,34 @@ func (session *Session) verifyUserInfo(callsign string, protocol int, cid UserId
 
 	client, ok := session.clientManager.GetClient(callsign)
 
-	// 客户端存在且标记为断开连接
-	if ok {
-		if client.Reconnect(session) {
-			// 客户端重连
-			session.client = client
-		} else {
-			// 呼号已被使用
-			return ResultError(CallsignInUse, true, callsign, nil)
-		}
+	// 呼号已被在线客户端使用
+	if ok && !client.Disconnected() {
+		return ResultError(CallsignInUse, true, callsign, nil)
+	}
+
+	// 排空模式下只允许已有会话重连
+	if !ok && (session.clientManager.Draining() || session.clientManager.ShuttingDown()) {
+		return ResultError(ServerShuttingDown, true, callsign, nil)
 	}
 
 	user, err := cid.GetUser(session.userOperation)
 	if err != nil {
 		return ResultError(AuthFail, true, callsign, err)
 	}
-	if user.Rating == Ban.Index() {
+	if user.Rating == Ban.Index() || user.Banned() {
 		return ResultError(UserBaned, true, callsign, nil)
 	}
 	if !session.userOperation.VerifyUserPassword(user, password) {
 		return ResultError(AuthFail, true, callsign, nil)
 	}
 
-	// 重设重连客户端的User
-	if client != nil {
+	// 客户端存在且标记为断开连接, 只有同一用户才能接管之前的会话
+	if ok {
+		if client.User() == nil || client.User().Cid != user.Cid || !client.Reconnect(session) {
+			return ResultError(CallsignInUse, true, callsign, nil)
+		}
+		session.client = client
+		// 重设重连客户端的User
 		client.SetUser(user)
 	}
 	session.user = user
@@ -61,6 +68,104 @@ func (session *Session) verifyUserInfo(callsign string, protocol int, cid UserId
 	return nil
 }
 
+// checkAtcBooking 检查管制席位当前是否被他人预约, 返回非nil的booking表示需要向客户端发出警告
+func (session *Session) checkAtcBooking(callsign string) (*Result, *AtcBooking) {
+	mode := session.config.Server.FSDServer.AtcBookingCheck
+	if mode == config.AtcBookingCheckDisable {
+		return nil, nil
+	}
+	booking, err := session.atcBookingOperation.GetActiveAtcBooking(callsign, time.Now())
+	if errors.Is(err, ErrAtcBookingNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		session.logger.WarnF("[%s] fail to query atc booking, %v", callsign, err)
+		return nil, nil
+	}
+	if booking.Cid == session.user.Cid {
+		return nil, nil
+	}
+	if mode == config.AtcBookingCheckRefuse {
+		return ResultError(CallsignInUse, true, callsign,
+			fmt.Errorf("facility booked by %04d until %s", booking.Cid, booking.EndTime.UTC().Format("15:04Z"))), booking
+	}
+	return nil, booking
+}
+
+// endorsementRecheckInterval 缓存的单飞授权与席位授权重新查询数据库的间隔, 使网页端的撤销能够在会话中生效
+const endorsementRecheckInterval = time.Minute
+
+// checkSoloEndorsement 检查用户是否持有席位facility的有效单飞授权
+func (session *Session) checkSoloEndorsement(facility Facility) bool {
+	if session.user == nil {
+		return false
+	}
+	cached := session.soloEndorsement
+	if cached != nil && cached.Facility == facility.Index() && cached.Active() && time.Since(session.soloCheckedAt) < endorsementRecheckInterval {
+		return true
+	}
+	solo, err := session.trainingOperation.GetActiveSoloEndorsement(session.user.Cid, facility.Index())
+	if errors.Is(err, ErrSoloEndorsementNotFound) {
+		session.soloEndorsement = nil
+		return false
+	}
+	if err != nil {
+		// 数据库暂时不可用时沿用仍在有效期内的缓存, 避免断开正在单飞的学员
+		session.logger.WarnF("[%s] fail to query solo endorsement, %v", session.callsign, err)
+		return cached != nil && cached.Facility == facility.Index() && cached.Active()
+	}
+	session.soloEndorsement = solo
+	session.soloCheckedAt = time.Now()
+	return true
+}
+
+// positionRestricted 登录该席位是否需要席位授权
+func (session *Session) positionRestricted(callsign string) bool {
+	for _, pattern := range session.config.Server.FSDServer.EndorsedPositionPatterns() {
+		if utils.MatchCallsignPattern(pattern, callsign) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPositionEndorsement 检查用户是否持有登录callsign席位的有效席位授权
+func (session *Session) checkPositionEndorsement(callsign string) bool {
+	if session.user == nil {
+		return false
+	}
+	if session.endorsedCallsign == callsign && time.Since(session.endorsementCheckedAt) < endorsementRecheckInterval {
+		return true
+	}
+	endorsements, err := session.endorsementOperation.GetActivePositionEndorsements(session.user.Cid)
+	if err != nil {
+		// 数据库暂时不可用时, 已经通过检查的席位继续保持连线
+		session.logger.WarnF("[%s] fail to query position endorsement, %v", callsign, err)
+		return session.endorsedCallsign == callsign
+	}
+	for _, endorsement := range endorsements {
+		if utils.MatchCallsignPattern(endorsement.Pattern, callsign) {
+			session.endorsedCallsign = callsign
+			session.endorsementCheckedAt = time.Now()
+			return true
+		}
+	}
+	session.endorsedCallsign = ""
+	return false
+}
+
+// rejectUnendorsedPosition 向客户端说明席位需要授权并返回拒绝登录的错误
+func (session *Session) rejectUnendorsedPosition(callsign string) *Result {
+	packet := makePacket(Message, global.FSDServerName, callsign,
+		fmt.Sprintf("Position %s requires a position endorsement, please contact your training department", callsign))
+	if session.client != nil {
+		session.client.SendLine(packet)
+	} else {
+		session.sendQueue.Push(packet)
+	}
+	return ResultError(RequestLevelTooHigh, true, callsign, fmt.Errorf("position %s not endorsed", callsign))
+}
+
 // handleAddAtc 处理管制员登录
 func (session *Session) handleAddAtc(data []string, rawLine []byte) *Result {
 	// #AA 2352_OBS SERVER 2352 2352 123456  1  9  1  0  29.86379 119.49287 100
@@ -77,17 +182,34 @@ func (session *Session) handleAddAtc(data []string, rawLine []byte) *Result {
 	if reqRating > session.user.Rating {
 		return ResultError(RequestLevelTooHigh, true, callsign, nil)
 	}
+	// 管制权限不允许的席位需要有效的单飞授权
+	if facility, ok := FacilityFromCallsign(callsign); ok &&
+		!Rating(reqRating).CheckRatingFacility(facility) && !session.checkSoloEndorsement(facility) {
+		return ResultError(RequestLevelTooHigh, true, callsign, nil)
+	}
+	if session.positionRestricted(callsign) && !session.checkPositionEndorsement(callsign) {
+		return session.rejectUnendorsedPosition(callsign)
+	}
 	realName := data[2]
 	latitude := utils.StrToFloat(data[9], 0)
 	longitude := utils.StrToFloat(data[10], 0)
+	var booking *AtcBooking
 	if session.client == nil {
+		if result, booking = session.checkAtcBooking(callsign); result != nil {
+			return result
+		}
 		session.client = session.clientManager.NewClient(callsign, Rating(reqRating), protocol, realName, session, true)
 		_ = session.client.SetPosition(0, latitude, longitude)
 		_ = session.clientManager.AddClient(session.client)
 	}
 	session.client.SendLine(makePacket(ClientQuery, global.FSDServerName, callsign, "ATIS"))
-	go session.clientManager.BroadcastMessage(rawLine, session.client, BroadcastToClientInRange)
+	go session.clientManager.BroadcastMessageInRange(rawLine, session.client, BroadcastToClientInRange)
 	session.client.SendMotd()
+	if booking != nil {
+		session.client.SendLine(makePacket(Message, global.FSDServerName, callsign,
+			fmt.Sprintf("This facility is booked by %04d from %s to %s, please hand over to the booked controller in time",
+				booking.Cid, booking.StartTime.UTC().Format("15:04Z"), booking.EndTime.UTC().Format("15:04Z"))))
+	}
 	session.logger.InfoF("[%s] ATC login successfully", callsign)
 	return ResultSuccess()
 }
@@ -105,7 +227,7 @@ func (session *Session) handleAddPilot(data []string, rawLine []byte) *Result {
 		return result
 	}
 	reqRating := Rating(utils.StrToInt(data[4], 0) - 1)
-	if reqRating != Normal || !RatingFacilityMap[reqRating].CheckFacility(Pilot) {
+	if reqRating != Normal || !reqRating.CheckRatingFacility(Pilot) {
 		return ResultError(RequestLevelTooHigh, true, callsign, nil)
 	}
 	simType := utils.StrToInt(data[6], 0)
@@ -115,10 +237,10 @@ func (session *Session) handleAddPilot(data []string, rawLine []byte) *Result {
 		session.client.SetSimType(simType)
 		_ = session.clientManager.AddClient(session.client)
 	}
-	go session.clientManager.BroadcastMessage(rawLine, session.client, BroadcastToClientInRange)
+	go session.clientManager.BroadcastMessageInRange(rawLine, session.client, BroadcastToClientInRange)
 	session.client.SendMotd()
 	session.logger.InfoF("[%s] client login successfully", callsign)
-	if !session.config.SimulatorServer {
+	if !session.config.Server.General.SimulatorServer {
 		flightPlan := session.client.FlightPlan()
 		if flightPlan != nil && flightPlan.FromWeb && callsign != flightPlan.Callsign {
 			session.client.SendLine(makePacket(Message, "FPlanManager", callsign,
@@ -138,9 +260,12 @@ func (session *Session) handleAtcPosUpdate(data []string, rawLine []byte) *Resul
 	callsign := data[0]
 	facility := Facility(1 << utils.StrToInt(data[2], 0))
 	rating := Rating(utils.StrToInt(data[4], 0))
-	if !rating.CheckRatingFacility(facility) {
+	if !rating.CheckRatingFacility(facility) && !session.checkSoloEndorsement(facility) {
 		return ResultError(RequestLevelTooHigh, true, callsign, nil)
 	}
+	if session.positionRestricted(callsign) && !session.checkPositionEndorsement(callsign) {
+		return session.rejectUnendorsedPosition(callsign)
+	}
 	frequency := utils.StrToInt(data[1], 0)
 	visualRange := utils.StrToFloat(data[3], 0)
 	latitude := utils.StrToFloat(data[5], 0)
@@ -148,7 +273,7 @@ func (session *Session) handleAtcPosUpdate(data []string, rawLine []byte) *Resul
 	if session.client == nil {
 		return ResultError(Syntax, false, "", fmt.Errorf("client not register"))
 	}
-	go session.clientManager.BroadcastMessage(rawLine, session.client, BroadcastToClientInRange)
+	go session.clientManager.BroadcastMessageInRange(rawLine, session.client, BroadcastToClientInRange)
 	session.client.UpdateAtcPos(frequency, facility, visualRange, latitude, longitude)
 	return ResultSuccess()
 }
@@ -166,7 +291,7 @@ func (session *Session) handlePilotPosUpdate(data []string, rawLine []byte) *Res
 	if session.client == nil {
 		return ResultError(Syntax, false, "", fmt.Errorf("client not register"))
 	}
-	go session.clientManager.BroadcastMessage(rawLine, session.client, BroadcastToClientInRange)
+	go session.clientManager.BroadcastMessageInRange(rawLine, session.client, BroadcastToClientInRange)
 	session.client.UpdatePilotPos(transponder, latitude, longitude, altitude, groundSpeed, pbh)
 	return ResultSuccess()
 }
@@ -196,10 +321,10 @@ func (session *Session) sendFrequencyMessage(targetStation string, rawLine []byt
 	}
 	if frequencyValid(frequency) {
 		// 合法频率, 发给所有客户端
-		go session.clientManager.BroadcastMessage(rawLine, session.client, BroadcastToClientInRange)
+		go session.clientManager.BroadcastMessageInRange(rawLine, session.client, BroadcastToClientInRange)
 	} else {
 		// 非法频率, 大概率是管制使用, 只发给管制
-		go session.clientManager.BroadcastMessage(rawLine, session.client, CombineBroadcastFilter(BroadcastToAtc, BroadcastToClientInRange))
+		go session.clientManager.BroadcastMessageInRange(rawLine, session.client, CombineBroadcastFilter(BroadcastToAtc, BroadcastToClientInRange))
 	}
 	return nil
 }
@@ -233,7 +358,7 @@ func (session *Session) handleClientQuery(data []string, rawLine []byte) *Result
 	// 如果发送目标是一个频率
 	if strings.HasPrefix(targetStation, "@") {
 		// 如果目标频率是94835
-		if !session.config.SimulatorServer && targetStation == SpecialFrequency {
+		if !session.config.Server.General.SimulatorServer && targetStation == SpecialFrequency {
 			// 这里并不是发给服务器的, 所以如果客户端没有权限, 直接返回就行
 			if !session.client.CheckFacility(AllowAtcFacility) {
 				return ResultSuccess()
@@ -295,6 +420,14 @@ func (session *Session) handleMessage(data []string, rawLine []byte) *Result {
 	// #TM ZSHA_CTR ZSSS_APP 111
 	// [0] [   1  ] [   2  ] [3]
 	targetStation := data[1]
+	if targetStation == global.FSDServerName {
+		// 消息内容中可能包含分隔符
+		message := strings.Join(data[2:], ":")
+		if session.client != nil && strings.HasPrefix(message, SupervisorCommandPrefix) {
+			return session.handleSupervisorCommand(message)
+		}
+		return ResultSuccess()
+	}
 	if strings.HasPrefix(targetStation, "@") {
 		result := session.sendFrequencyMessage(targetStation, rawLine)
 		if result != nil {
@@ -303,7 +436,12 @@ func (session *Session) handleMessage(data []string, rawLine []byte) *Result {
 	} else if strings.HasPrefix(targetStation, "*") {
 		// 广播消息
 		if targetStation == string(AllSup) {
-			go session.clientManager.BroadcastMessage(rawLine, session.client, BroadcastToSup)
+			// 管理员之间直接转发, 其他客户端发送的消息作为求助请求处理
+			if session.client == nil || BroadcastToSup(session.client, nil) {
+				go session.clientManager.BroadcastMessage(rawLine, session.client, BroadcastToSup)
+			} else {
+				session.replyFromServer(session.createHelpRequest(strings.Join(data[2:], ":"))...)
+			}
 		}
 	} else {
 		_ = session.clientManager.SendMessageTo(targetStation, rawLine)
@@ -326,7 +464,9 @@ func (session *Session) handlePlan(data []string, rawLine []byte) *Result {
 		return ResultError(Syntax, false, session.client.Callsign(), err)
 	}
 	if !session.client.FlightPlan().Locked {
-		go session.clientManager.BroadcastMessage(rawLine, session.client, CombineBroadcastFilter(BroadcastToAtc, BroadcastToClientInRange))
+		// 除了范围内的管制员, 负责飞行员所在扇区的管制员也需要收到计划
+		go session.clientManager.BroadcastMessageInRange(rawLine, session.client, CombineBroadcastFilter(BroadcastToAtc,
+			AnyBroadcastFilter(BroadcastToClientInRange, session.clientManager.Sectors().BroadcastToSectorOwner(session.client))))
 	}
 	return ResultSuccess()
 }
@@ -350,15 +490,16 @@ func (session *Session) handleAtcEditPlan(data []string, _ []byte) *Result {
 	if !ok {
 		return ResultError(SourceCallsignInvalid, false, session.client.Callsign(), fmt.Errorf("%s not exists", targetCallsign))
 	}
-	if client.FlightPlan == nil {
+	if client.FlightPlan() == nil {
 		return ResultError(NoFlightPlan, false, session.client.Callsign(), fmt.Errorf("%s do not have filght plan", session.client.Callsign()))
 	}
-	client.FlightPlan().Locked = !session.config.SimulatorServer
+	client.FlightPlan().Locked = !session.config.Server.General.SimulatorServer
 	if err := session.flightPlanOperation.UpdateFlightPlan(client.FlightPlan(), data[1:], true); err != nil {
 		return ResultError(Syntax, false, session.client.Callsign(), err)
 	}
-	go session.clientManager.BroadcastMessage([]byte(session.flightPlanOperation.ToString(client.FlightPlan(), string(AllATC))),
-		session.client, CombineBroadcastFilter(BroadcastToAtc, BroadcastToClientInRange))
+	go session.clientManager.BroadcastMessageInRange([]byte(session.flightPlanOperation.ToString(client.FlightPlan(), string(AllATC))),
+		session.client, CombineBroadcastFilter(BroadcastToAtc,
+			AnyBroadcastFilter(BroadcastToClientInRange, session.clientManager.Sectors().BroadcastToSectorOwner(client))))
 	return ResultSuccess()
 }
 
@@ -2,12 +2,14 @@ package packet
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
 	. "github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/global"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"github.com/half-nothing/simple-fsd/internal/metrics"
 	"net"
 	"sync/atomic"
 	"time"
@@ -19,40 +21,58 @@ var (
 )
 
 type Session struct {
-	logger              log.LoggerInterface
-	conn                net.Conn
-	connId              string
-	callsign            string
-	client              ClientInterface
-	clientManager       ClientManagerInterface
-	user                *operation.User
-	disconnected        atomic.Bool
-	config              *config.GeneralConfig
-	userOperation       operation.UserOperationInterface
-	flightPlanOperation operation.FlightPlanOperationInterface
+	logger               log.LoggerInterface
+	conn                 net.Conn
+	connId               string
+	callsign             string
+	client               ClientInterface
+	clientManager        ClientManagerInterface
+	user                 *operation.User
+	disconnected         atomic.Bool
+	config               *config.Config
+	userOperation        operation.UserOperationInterface
+	flightPlanOperation  operation.FlightPlanOperationInterface
+	atcBookingOperation  operation.AtcBookingOperationInterface
+	auditLogOperation    operation.AuditLogOperationInterface
+	helpRequestOperation operation.HelpRequestOperationInterface
+	trainingOperation    operation.TrainingOperationInterface
+	soloEndorsement      *operation.SoloEndorsement
+	soloCheckedAt        time.Time
+	endorsementOperation operation.PositionEndorsementOperationInterface
+	endorsedCallsign     string
+	endorsementCheckedAt time.Time
+	userSessionOperation operation.UserSessionOperationInterface
+	sendQueue            *SendQueue
 }
 
 func NewSession(
 	logger log.LoggerInterface,
-	config *config.GeneralConfig,
+	config *config.Config,
 	conn net.Conn,
 	cm ClientManagerInterface,
-	userOperation operation.UserOperationInterface,
-	flightPlanOperation operation.FlightPlanOperationInterface,
+	operations *operation.DatabaseOperations,
 ) *Session {
-	return &Session{
-		logger:              logger,
-		conn:                conn,
-		connId:              conn.RemoteAddr().String(),
-		callsign:            "unknown",
-		client:              nil,
-		clientManager:       cm,
-		user:                nil,
-		disconnected:        atomic.Bool{},
-		config:              config,
-		userOperation:       userOperation,
-		flightPlanOperation: flightPlanOperation,
+	session := &Session{
+		logger:               logger,
+		conn:                 conn,
+		connId:               conn.RemoteAddr().String(),
+		callsign:             "unknown",
+		client:               nil,
+		clientManager:        cm,
+		user:                 nil,
+		disconnected:         atomic.Bool{},
+		config:               config,
+		userOperation:        operations.UserOperation(),
+		flightPlanOperation:  operations.FlightPlanOperation(),
+		atcBookingOperation:  operations.AtcBookingOperation(),
+		auditLogOperation:    operations.AuditLogOperation(),
+		helpRequestOperation: operations.HelpRequestOperation(),
+		trainingOperation:    operations.TrainingOperation(),
+		endorsementOperation: operations.PositionEndorsementOperation(),
+		userSessionOperation: operations.UserSessionOperation(),
 	}
+	session.sendQueue = NewSendQueue(logger, conn, config.Server.FSDServer, func() { _ = conn.Close() })
+	return session
 }
 
 func (session *Session) SendError(result *Result) {
@@ -63,9 +83,10 @@ func (session *Session) SendError(result *Result) {
 		session.client.SendError(result)
 		return
 	}
+	metrics.ErrorsSent.WithLabelValues(result.Errno.String()).Inc()
 	packet := makePacket(Error, global.FSDServerName, session.callsign, fmt.Sprintf("%03d", result.Errno.Index()), result.Env, result.Errno.String())
 	session.logger.DebugF("[%s](%s) <- %s", session.connId, session.callsign, packet[:len(packet)-splitSignLen])
-	_, _ = session.conn.Write(packet)
+	session.sendQueue.Push(packet)
 	if result.Fatal {
 		session.disconnected.Store(true)
 		time.AfterFunc(global.FSDDisconnectDelay, func() {
@@ -79,6 +100,7 @@ func (session *Session) handleLine(line []byte) {
 		return
 	}
 	command, data := parserCommandLine(line)
+	metrics.PacketsReceived.WithLabelValues(string(command)).Inc()
 	result := session.handleCommand(command, data, line)
 	if result == nil {
 		session.logger.WarnF("[%s](%s) handleCommand return a nil result", session.connId, session.callsign)
@@ -91,7 +113,9 @@ func (session *Session) handleLine(line []byte) {
 }
 
 func (session *Session) HandleConnection() {
+	go session.sendQueue.Run()
 	defer func() {
+		session.sendQueue.Close()
 		session.logger.DebugF("[%s](%s) x Connection closed", session.connId, session.callsign)
 		if err := session.conn.Close(); err != nil && !isNetClosedError(err) {
 			session.logger.WarnF("[%s](%s) Error occurred while closing connection, details: %v", session.connId, session.callsign, err)
@@ -100,7 +124,8 @@ func (session *Session) HandleConnection() {
 	scanner := bufio.NewScanner(session.conn)
 	scanner.Split(createSplitFunc(splitSign))
 	for scanner.Scan() {
-		line := scanner.Bytes()
+		// 下一次Scan会复用缓冲区, 而广播等异步转发仍会读取原始数据, 因此每行使用独立的副本
+		line := bytes.Clone(scanner.Bytes())
 		session.logger.DebugF("[%s](%s) -> %s", session.connId, session.callsign, line)
 		session.handleLine(line)
 		if session.disconnected.Load() {
@@ -110,9 +135,9 @@ func (session *Session) HandleConnection() {
 
 	if session.client != nil {
 		if session.client.IsAtc() {
-			session.clientManager.BroadcastMessage(makePacket(RemoveAtc, session.client.Callsign(), global.FSDServerName), session.client, BroadcastToClientInRange)
+			session.clientManager.BroadcastMessageInRange(makePacket(RemoveAtc, session.client.Callsign(), global.FSDServerName), session.client, BroadcastToClientInRange)
 		} else {
-			session.clientManager.BroadcastMessage(makePacket(RemovePilot, session.client.Callsign(), global.FSDServerName), session.client, BroadcastToClientInRange)
+			session.clientManager.BroadcastMessageInRange(makePacket(RemovePilot, session.client.Callsign(), global.FSDServerName), session.client, BroadcastToClientInRange)
 		}
 		session.client.MarkedDisconnect(false)
 	}
@@ -131,3 +156,12 @@ func (session *Session) ConnId() string { return session.connId }
 func (session *Session) Conn() net.Conn { return session.conn }
 
 func (session *Session) SetDisconnected(disconnect bool) { session.disconnected.Store(disconnect) }
+
+func (session *Session) Send(line []byte) { session.sendQueue.Push(line) }
+
+// Close 写出已排队的消息后关闭连接
+func (session *Session) Close() { session.sendQueue.CloseAfterFlush() }
+
+func (session *Session) SendQueueDepth() int { return session.sendQueue.Depth() }
+
+func (session *Session) SendQueueDropped() uint64 { return session.sendQueue.Dropped() }
@@ -0,0 +1,80 @@
+package packet
+
+import (
+	"fmt"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/global"
+	"time"
+)
+
+// drainNoticePoints 排空倒计时通知的剩余时间点
+var drainNoticePoints = []time.Duration{
+	30 * time.Minute, 15 * time.Minute, 10 * time.Minute, 5 * time.Minute,
+	4 * time.Minute, 3 * time.Minute, 2 * time.Minute, time.Minute,
+	30 * time.Second, 10 * time.Second,
+}
+
+// drainNotices 计算需要发送倒计时通知的剩余时间, 从大到小排列, 第一个为立即发送
+func drainNotices(duration time.Duration) []time.Duration {
+	duration = duration.Round(time.Second)
+	if duration <= 0 {
+		return nil
+	}
+	notices := []time.Duration{duration}
+	for _, point := range drainNoticePoints {
+		if point < duration {
+			notices = append(notices, point)
+		}
+	}
+	return notices
+}
+
+// formatRemaining 将剩余时间格式化为便于阅读的形式
+func formatRemaining(remaining time.Duration) string {
+	if remaining >= time.Minute && remaining%time.Minute == 0 {
+		minutes := int(remaining / time.Minute)
+		if minutes == 1 {
+			return "1 minute"
+		}
+		return fmt.Sprintf("%d minutes", minutes)
+	}
+	return fmt.Sprintf("%d seconds", int(remaining.Round(time.Second)/time.Second))
+}
+
+func (cm *ClientManager) Draining() bool {
+	return cm.draining.Load()
+}
+
+// Drain 进入排空模式, 拒绝新的客户端登录并向所有客户端广播倒计时,
+// 倒计时结束后保存飞行计划与连线记录, 断开所有客户端并关闭服务器
+func (cm *ClientManager) Drain(duration time.Duration) (time.Time, error) {
+	if cm.shuttingDown.Load() {
+		return time.Time{}, ErrServerShuttingDown
+	}
+	if !cm.draining.CompareAndSwap(false, true) {
+		return time.Time{}, ErrDrainInProgress
+	}
+	deadline := time.Now().Add(duration)
+	cm.applicationContent.Logger().WarnF("Drain mode started, server will shut down at %s", deadline.Format(time.DateTime))
+	go cm.drain(deadline)
+	return deadline, nil
+}
+
+func (cm *ClientManager) drain(deadline time.Time) {
+	for _, notice := range drainNotices(time.Until(deadline)) {
+		time.Sleep(time.Until(deadline.Add(-notice)))
+		if cm.shuttingDown.Load() {
+			return
+		}
+		cm.BroadcastMessage(makePacket(Message, global.FSDServerName, string(AllClient),
+			fmt.Sprintf("Server will shut down for maintenance in %s, please wrap up your session", formatRemaining(notice))),
+			nil, BroadcastToAll)
+	}
+	time.Sleep(time.Until(deadline))
+	if cm.shuttingDown.Load() {
+		return
+	}
+	cm.applicationContent.Logger().Warn("Drain countdown finished, shutting down server")
+	// 飞行计划与连线记录会在Shutdown断开客户端时保存
+	cm.applicationContent.Cleaner().Clean()
+}
@@ -0,0 +1,134 @@
+package packet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDrainNotices(t *testing.T) {
+	notices := drainNotices(5*time.Minute - 10*time.Millisecond)
+	expected := []time.Duration{5 * time.Minute, 4 * time.Minute, 3 * time.Minute, 2 * time.Minute,
+		time.Minute, 30 * time.Second, 10 * time.Second}
+	if !reflect.DeepEqual(notices, expected) {
+		t.Errorf("unexpected notices: %v", notices)
+	}
+	if notices := drainNotices(45 * time.Second); !reflect.DeepEqual(notices, []time.Duration{45 * time.Second, 30 * time.Second, 10 * time.Second}) {
+		t.Errorf("unexpected notices: %v", notices)
+	}
+	if notices := drainNotices(0); len(notices) != 0 {
+		t.Errorf("expected no notice, got %v", notices)
+	}
+}
+
+func TestFormatRemaining(t *testing.T) {
+	cases := map[time.Duration]string{
+		5 * time.Minute:  "5 minutes",
+		time.Minute:      "1 minute",
+		90 * time.Second: "90 seconds",
+		10 * time.Second: "10 seconds",
+	}
+	for remaining, expected := range cases {
+		if actual := formatRemaining(remaining); actual != expected {
+			t.Errorf("formatRemaining(%v) = %q, want %q", remaining, actual, expected)
+		}
+	}
+}
+
+// readSnapshot 读取会话快照文件, 文件不存在时返回nil
+func readSnapshot(t *testing.T, path string) *sessionSnapshot {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot := &sessionSnapshot{}
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		t.Fatal(err)
+	}
+	return snapshot
+}
+
+func TestShutdownSavesSnapshotBeforeTimeout(t *testing.T) {
+	env := newTestEnvironment(t)
+	env.newUser(t, 2352, Normal)
+	cm := env.newClientManager()
+	cm.heartbeatSender = NewHeartbeatSender(nopLogger{}, time.Minute, cm.SendHeartBeat)
+	loginPilot(t, env, cm, "CES2352", "2352")
+	path := env.config.Server.FSDServer.SessionSnapshotFile
+
+	// 超时已经到达时仍然先保存快照
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := cm.Shutdown(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context canceled, got %v", err)
+	}
+	snapshot := readSnapshot(t, path)
+	if snapshot == nil || len(snapshot.Clients) != 1 || snapshot.Clients[0].Callsign != "CES2352" {
+		t.Fatalf("snapshot not saved before timeout: %+v", snapshot)
+	}
+
+	// 断开客户端后重新保存的快照包含已写入数据库的连线记录
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		snapshot = readSnapshot(t, path)
+		if snapshot != nil && len(snapshot.Clients) == 1 && snapshot.Clients[0].History.ID != 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("snapshot not updated with history id: %+v", snapshot)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRestoreSnapshotWithoutHistoryId(t *testing.T) {
+	env := newTestEnvironment(t)
+	env.newUser(t, 2352, Normal)
+	cm := env.newClientManager()
+	client := loginPilot(t, env, cm, "CES2352", "2352")
+	client.history.StartTime = time.Now().Add(-time.Hour)
+	// 关闭时先保存的快照中连线记录还没有写入数据库
+	clients := cm.GetClientSnapshot()
+	if err := cm.saveSessionSnapshot(clients); err != nil {
+		t.Fatal(err)
+	}
+	cm.disconnectClients(clients)
+	cm.PutSlice(clients)
+
+	restored := env.newClientManager()
+	if err := restored.RestoreSessions(); err != nil {
+		t.Fatal(err)
+	}
+	session := env.newSession(t, restored)
+	if result := session.verifyUserInfo("CES2352", 9, operation.GetUserId("2352"), "123456"); result != nil {
+		t.Fatalf("reconnect failed: %v", result.Errno)
+	}
+	restoredClient := session.client.(*Client)
+	if restoredClient.history.ID != 0 || restoredClient.history.StartTime.Before(restoredClient.resumeTime) {
+		t.Errorf("expected new history starting at reconnect, got id %d start %s", restoredClient.history.ID, restoredClient.history.StartTime)
+	}
+	restoredClient.MarkedDisconnect(true)
+
+	history, err := env.operations.HistoryOperation().GetUserHistory(2352)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Pilots) != 2 {
+		t.Fatalf("expected history before shutdown and after reconnect, got %d", len(history.Pilots))
+	}
+	for _, pilot := range history.Pilots {
+		if pilot.OnlineTime > 3700 {
+			t.Errorf("history counted twice, online time %d", pilot.OnlineTime)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/global"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/service"
+	"github.com/half-nothing/simple-fsd/internal/utils"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HelpRequestCommand 飞行员发送求助请求的命令
+const HelpRequestCommand = "help"
+
+// maxHelpRequestLength 求助内容的最大字符数, 与数据库字段长度一致
+const maxHelpRequestLength = 512
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+type helpRequestWebhookPayload struct {
+	Server  string                 `json:"server"`
+	Request *operation.HelpRequest `json:"request"`
+}
+
+// SetEmailService 设置邮件服务, 用于在没有管理员在线时发送求助请求通知
+func (cm *ClientManager) SetEmailService(emailService service.EmailServiceInterface) {
+	cm.emailService.Store(emailService)
+}
+
+// SupervisorOnline 是否有管理员在线
+func (cm *ClientManager) SupervisorOnline() bool {
+	clients := cm.GetClientSnapshot()
+	defer cm.PutSlice(clients)
+	for _, client := range clients {
+		if !client.Disconnected() && BroadcastToSup(client, nil) {
+			return true
+		}
+	}
+	return false
+}
+
+// AcquireHelpRequest 检查cid距离上次求助请求是否超过 help_request_interval
+func (cm *ClientManager) AcquireHelpRequest(cid int) (time.Duration, bool) {
+	interval := cm.config.Server.FSDServer.HelpRequestDuration
+	if interval <= 0 {
+		return 0, true
+	}
+	cm.helpRequestLock.Lock()
+	defer cm.helpRequestLock.Unlock()
+	now := time.Now()
+	for id, last := range cm.helpRequestTimes {
+		if now.Sub(last) >= interval {
+			delete(cm.helpRequestTimes, id)
+		}
+	}
+	if last, ok := cm.helpRequestTimes[cid]; ok {
+		return interval - now.Sub(last), false
+	}
+	cm.helpRequestTimes[cid] = now
+	return 0, true
+}
+
+// NotifyHelpRequest 向在线管理员广播求助请求, 没有管理员在线时通过Webhook和邮件通知
+func (cm *ClientManager) NotifyHelpRequest(request *operation.HelpRequest) (supervisorOnline bool) {
+	if cm.SupervisorOnline() {
+		message := fmt.Sprintf("Help request #%d from %s: %s, use .claim %d to claim it", request.ID,
+			request.Callsign, request.Message, request.ID)
+		cm.BroadcastMessage(makePacket(Message, global.FSDServerName, string(AllSup), message), nil, BroadcastToSup)
+		return true
+	}
+	go cm.notifyHelpRequestOffline(request)
+	return false
+}
+
+func (cm *ClientManager) notifyHelpRequestOffline(request *operation.HelpRequest) {
+	logger := cm.applicationContent.Logger()
+	fsdConfig := cm.config.Server.FSDServer
+	if fsdConfig.HelpRequestWebhook != "" {
+		if err := postHelpRequestWebhook(fsdConfig.HelpRequestWebhook, fsdConfig.FSDName, request); err != nil {
+			logger.ErrorF("Fail to send help request #%d to webhook: %v", request.ID, err)
+		}
+	}
+	emailService, ok := cm.emailService.Load().(service.EmailServiceInterface)
+	if ok && len(fsdConfig.HelpRequestEmails) > 0 && cm.config.Server.HttpServer.Email.Templates().EnableHelpRequestEmail {
+		if err := emailService.SendHelpRequestEmail(fsdConfig.HelpRequestEmails, request); err != nil {
+			logger.ErrorF("Fail to send help request #%d email: %v", request.ID, err)
+		}
+	}
+}
+
+func postHelpRequestWebhook(url string, server string, request *operation.HelpRequest) error {
+	data, err := json.Marshal(&helpRequestWebhookPayload{Server: server, Request: request})
+	if err != nil {
+		return err
+	}
+	resp, err := webhookClient.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// createHelpRequest 保存客户端发送的求助请求并通知管理员, 返回需要回复给客户端的消息
+func (session *Session) createHelpRequest(message string) []string {
+	message = strings.TrimSpace(message)
+	if message == "" {
+		return []string{"Usage: " + supervisorCommands[HelpRequestCommand].usage}
+	}
+	message = utils.TruncateRunes(message, maxHelpRequestLength)
+	if wait, ok := session.clientManager.AcquireHelpRequest(session.client.User().Cid); !ok {
+		return []string{fmt.Sprintf("You have sent a help request recently, please wait %d seconds before sending another one",
+			int(wait.Seconds())+1)}
+	}
+	request := session.helpRequestOperation.NewHelpRequest(session.client.User().Cid, session.client.Callsign(), message)
+	if err := session.helpRequestOperation.AddHelpRequest(request); err != nil {
+		session.logger.ErrorF("[%s] fail to save help request: %v", session.client.Callsign(), err)
+		return []string{"Internal server error, please try again later"}
+	}
+	session.logger.InfoF("[%s] help request #%d: %s", session.client.Callsign(), request.ID, message)
+	if session.clientManager.NotifyHelpRequest(request) {
+		return []string{fmt.Sprintf("Your help request #%d has been sent to online supervisors", request.ID)}
+	}
+	return []string{fmt.Sprintf("No supervisor is online, your help request #%d has been recorded", request.ID)}
+}
+
+// notifyRequester 通知发起求助的客户端, 客户端不在线时忽略
+func (session *Session) notifyRequester(request *operation.HelpRequest, message string) {
+	client, ok := session.clientManager.GetClient(request.Callsign)
+	if !ok || client.Disconnected() || client.User() == nil || client.User().Cid != request.Cid {
+		return
+	}
+	client.SendLine(makePacket(Message, global.FSDServerName, client.Callsign(), message))
+}
+
+func (session *Session) getHelpRequest(value string) (*operation.HelpRequest, string) {
+	id, err := strconv.ParseUint(strings.TrimPrefix(value, "#"), 10, 64)
+	if err != nil {
+		return nil, fmt.Sprintf("Invalid help request id %s", value)
+	}
+	request, err := session.helpRequestOperation.GetHelpRequestById(uint(id))
+	if errors.Is(err, operation.ErrHelpRequestNotFound) {
+		return nil, fmt.Sprintf("Help request #%d not found", id)
+	}
+	if err != nil {
+		session.logger.ErrorF("[%s] fail to get help request %d: %v", session.client.Callsign(), id, err)
+		return nil, "Internal server error, please try again later"
+	}
+	return request, ""
+}
+
+func supervisorHelpRequest(session *Session, _ *operation.User, args []string) []string {
+	return session.createHelpRequest(strings.Join(args, " "))
+}
+
+func supervisorRequests(session *Session, _ *operation.User, _ []string) []string {
+	requests, err := session.helpRequestOperation.GetUnclosedHelpRequests()
+	if err != nil {
+		session.logger.ErrorF("[%s] fail to get help requests: %v", session.client.Callsign(), err)
+		return []string{"Internal server error, please try again later"}
+	}
+	if len(requests) == 0 {
+		return []string{"No open help requests"}
+	}
+	lines := make([]string, 0, len(requests))
+	for _, request := range requests {
+		status := "open"
+		if request.Status == operation.HelpRequestClaimed {
+			status = fmt.Sprintf("claimed by %04d", request.ClaimedBy)
+		}
+		lines = append(lines, fmt.Sprintf("#%d %s(%04d) at %s, %s: %s", request.ID, request.Callsign, request.Cid,
+			request.CreatedAt.UTC().Format("2006-01-02 15:04Z"), status, request.Message))
+	}
+	return lines
+}
+
+func supervisorClaim(session *Session, operator *operation.User, args []string) []string {
+	if len(args) < 1 {
+		return []string{"Usage: " + supervisorCommands["claim"].usage}
+	}
+	request, reply := session.getHelpRequest(args[0])
+	if request == nil {
+		return []string{reply}
+	}
+	err := session.helpRequestOperation.ClaimHelpRequest(request, operator.Cid)
+	switch {
+	case errors.Is(err, operation.ErrHelpRequestClaimed):
+		return []string{fmt.Sprintf("Help request #%d has already been claimed", request.ID)}
+	case errors.Is(err, operation.ErrHelpRequestClosed):
+		return []string{fmt.Sprintf("Help request #%d has already been closed", request.ID)}
+	case err != nil:
+		session.logger.ErrorF("[%s] fail to claim help request %d: %v", session.client.Callsign(), request.ID, err)
+		return []string{"Internal server error, please try again later"}
+	}
+	session.notifyRequester(request, fmt.Sprintf("Your help request #%d has been claimed by %s", request.ID,
+		session.client.Callsign()))
+	session.clientManager.BroadcastMessage(makePacket(Message, global.FSDServerName, string(AllSup),
+		fmt.Sprintf("Help request #%d has been claimed by %s", request.ID, session.client.Callsign())),
+		session.client, BroadcastToSup)
+	return []string{fmt.Sprintf("You have claimed help request #%d from %s", request.ID, request.Callsign)}
+}
+
+func supervisorClose(session *Session, _ *operation.User, args []string) []string {
+	if len(args) < 1 {
+		return []string{"Usage: " + supervisorCommands["close"].usage}
+	}
+	request, reply := session.getHelpRequest(args[0])
+	if request == nil {
+		return []string{reply}
+	}
+	err := session.helpRequestOperation.CloseHelpRequest(request)
+	if errors.Is(err, operation.ErrHelpRequestClosed) {
+		return []string{fmt.Sprintf("Help request #%d has already been closed", request.ID)}
+	}
+	if err != nil {
+		session.logger.ErrorF("[%s] fail to close help request %d: %v", session.client.Callsign(), request.ID, err)
+		return []string{"Internal server error, please try again later"}
+	}
+	session.notifyRequester(request, fmt.Sprintf("Your help request #%d has been closed by %s", request.ID,
+		session.client.Callsign()))
+	return []string{fmt.Sprintf("Help request #%d has been closed", request.ID)}
+}
@@ -0,0 +1,147 @@
+package packet
+
+import (
+	"encoding/json"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestPostHelpRequestWebhook(t *testing.T) {
+	var payload helpRequestWebhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	request := &operation.HelpRequest{ID: 3, Cid: 1234, Callsign: "CES2352", Message: "need help"}
+	if err := postHelpRequestWebhook(server.URL, "Simple-Fsd", request); err != nil {
+		t.Fatalf("postHelpRequestWebhook error: %v", err)
+	}
+	if payload.Server != "Simple-Fsd" || payload.Request == nil || payload.Request.ID != 3 || payload.Request.Message != "need help" {
+		t.Errorf("unexpected payload %+v", payload)
+	}
+}
+
+func TestPostHelpRequestWebhookStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	if err := postHelpRequestWebhook(server.URL, "Simple-Fsd", &operation.HelpRequest{ID: 1}); err == nil {
+		t.Error("expected error for non 2xx status")
+	}
+}
+
+func TestHelpRequestSupervisorOnline(t *testing.T) {
+	env := newTestEnvironment(t)
+	cm := env.newClientManager()
+	_, supervisor := env.login(t, cm, env.newUser(t, 2001, Supervisor), "ZSHA_SUP", true)
+	session, pilot := env.login(t, cm, env.newUser(t, 2003, Normal), "CES2003", false)
+
+	session.handleSupervisorCommand(".help need help")
+	if !pilot.received("has been sent to online supervisors") {
+		t.Error("requester not notified")
+	}
+	if !supervisor.received("Help request #1 from CES2003: need help") {
+		t.Error("supervisor not notified")
+	}
+	requests, err := env.operations.HelpRequestOperation().GetUnclosedHelpRequests()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 1 || requests[0].Cid != 2003 || requests[0].Message != "need help" {
+		t.Errorf("unexpected help requests %v", requests)
+	}
+}
+
+func TestHelpRequestNoSupervisor(t *testing.T) {
+	env := newTestEnvironment(t)
+	payloads := make(chan helpRequestWebhookPayload, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload helpRequestWebhookPayload
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		payloads <- payload
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	env.config.Server.FSDServer.HelpRequestWebhook = server.URL
+	cm := env.newClientManager()
+	// 等级不足的管制员不算作管理员
+	_, controller := env.login(t, cm, env.newUser(t, 2001, CTR3), "ZSHA_CTR", true)
+	session, pilot := env.login(t, cm, env.newUser(t, 2003, Normal), "CES2003", false)
+
+	session.handleSupervisorCommand(".help need help")
+	if !pilot.received("No supervisor is online, your help request #1 has been recorded") {
+		t.Error("requester not notified")
+	}
+	select {
+	case payload := <-payloads:
+		if payload.Request == nil || payload.Request.Callsign != "CES2003" || payload.Request.Message != "need help" {
+			t.Errorf("unexpected payload %+v", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not called")
+	}
+	if controller.received("Help request") {
+		t.Error("controller should not receive help request")
+	}
+}
+
+func TestHelpRequestCooldown(t *testing.T) {
+	env := newTestEnvironment(t)
+	env.config.Server.FSDServer.HelpRequestDuration = time.Minute
+	cm := env.newClientManager()
+	env.login(t, cm, env.newUser(t, 2001, Supervisor), "ZSHA_SUP", true)
+	session, pilot := env.login(t, cm, env.newUser(t, 2003, Normal), "CES2003", false)
+	otherSession, other := env.login(t, cm, env.newUser(t, 2004, Normal), "CES2004", false)
+
+	session.handleSupervisorCommand(".help first")
+	if !pilot.received("has been sent") {
+		t.Fatal("first request failed")
+	}
+	session.handleSupervisorCommand(".help second")
+	if !pilot.received("please wait") {
+		t.Error("second request should be rejected during cooldown")
+	}
+	otherSession.handleSupervisorCommand(".help other")
+	if !other.received("has been sent") {
+		t.Error("cooldown should be per user")
+	}
+	requests, err := env.operations.HelpRequestOperation().GetUnclosedHelpRequests()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 2 {
+		t.Errorf("expected 2 help requests, got %d", len(requests))
+	}
+}
+
+func TestHelpRequestTruncate(t *testing.T) {
+	env := newTestEnvironment(t)
+	cm := env.newClientManager()
+	session, _ := env.login(t, cm, env.newUser(t, 2003, Normal), "CES2003", false)
+
+	session.createHelpRequest(strings.Repeat("需", maxHelpRequestLength+10))
+	requests, err := env.operations.HelpRequestOperation().GetUnclosedHelpRequests()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 help request, got %d", len(requests))
+	}
+	message := requests[0].Message
+	if !utf8.ValidString(message) || utf8.RuneCountInString(message) != maxHelpRequestLength {
+		t.Errorf("message not truncated by rune, %d runes", utf8.RuneCountInString(message))
+	}
+}
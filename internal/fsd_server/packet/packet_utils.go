@@ -24,6 +24,16 @@ func parserCommandLine(line []byte) (ClientCommand, []string) {
 	return TempData, nil
 }
 
+// commandOfLine 获取数据包对应的命令, 未知命令返回TempData
+func commandOfLine(line []byte) ClientCommand {
+	for _, prefix := range PossibleClientCommands {
+		if bytes.HasPrefix(line, prefix) {
+			return ClientCommand(prefix)
+		}
+	}
+	return TempData
+}
+
 func makePacket(command ClientCommand, parts ...string) []byte {
 	totalLen := len(command)
 	if len(parts) > 0 {
@@ -0,0 +1,234 @@
+package packet
+
+import (
+	"bytes"
+	"errors"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	"github.com/half-nothing/simple-fsd/internal/metrics"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SendQueue 每个连接独立的有界发送队列, 由单独的写协程负责写入socket
+// 避免慢客户端阻塞广播协程
+type SendQueue struct {
+	logger       log.LoggerInterface
+	conn         net.Conn
+	connId       string
+	capacity     int
+	policy       int
+	writeTimeout time.Duration
+	lock         sync.Mutex
+	pending      [][]byte
+	coalesce     map[string]int // 可合并消息的key -> 在pending中的下标
+	notify       chan struct{}
+	done         chan struct{}
+	exited       chan struct{}
+	closed       bool
+	closeConn    bool
+	dropped      atomic.Uint64
+	onOverflow   func()
+}
+
+func NewSendQueue(logger log.LoggerInterface, conn net.Conn, config *config.FSDServerConfig, onOverflow func()) *SendQueue {
+	return &SendQueue{
+		logger:       logger,
+		conn:         conn,
+		connId:       conn.RemoteAddr().String(),
+		capacity:     config.SendQueueSize,
+		policy:       config.SendQueuePolicy,
+		writeTimeout: config.WriteTimeoutDuration,
+		pending:      make([][]byte, 0, 16),
+		coalesce:     make(map[string]int),
+		notify:       make(chan struct{}, 1),
+		done:         make(chan struct{}),
+		exited:       make(chan struct{}),
+		onOverflow:   onOverflow,
+	}
+}
+
+// coalesceKey 位置更新包只需要发送最新的一条, 返回发送者相关的key, 其余包返回空字符串
+func coalesceKey(line []byte) string {
+	if len(line) == 0 {
+		return ""
+	}
+	// @N:CPA421:... 与 %ZSHA_CTR:...
+	separators := 0
+	switch line[0] {
+	case '@':
+		separators = 2
+	case '%':
+		separators = 1
+	default:
+		return ""
+	}
+	for i, b := range line {
+		if b != ':' {
+			continue
+		}
+		separators--
+		if separators == 0 {
+			return string(line[:i])
+		}
+	}
+	return ""
+}
+
+// Push 将消息的副本放入队列, 返回false表示消息未能入队
+// 调用方传入的line可能来自读取缓冲区, 返回后会被复用, 因此不能直接保存
+func (queue *SendQueue) Push(line []byte) bool {
+	queue.lock.Lock()
+	if queue.closed {
+		queue.lock.Unlock()
+		return false
+	}
+	line = bytes.Clone(line)
+
+	metrics.PacketsSent.WithLabelValues(string(commandOfLine(line))).Inc()
+
+	key := coalesceKey(line)
+	if key != "" {
+		if index, ok := queue.coalesce[key]; ok {
+			queue.pending[index] = line
+			queue.lock.Unlock()
+			return true
+		}
+	}
+
+	if len(queue.pending) >= queue.capacity {
+		if queue.policy == config.SendQueueDisconnect {
+			queue.lock.Unlock()
+			queue.dropped.Add(1)
+			metrics.SendQueueDropped.Inc()
+			queue.logger.WarnF("[%s] Send queue full(%d), disconnecting slow client", queue.connId, queue.capacity)
+			if queue.onOverflow != nil {
+				queue.onOverflow()
+			}
+			return false
+		}
+		queue.dropOldest()
+	}
+
+	if key != "" {
+		queue.coalesce[key] = len(queue.pending)
+	}
+	queue.pending = append(queue.pending, line)
+	queue.lock.Unlock()
+
+	select {
+	case queue.notify <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// dropOldest 丢弃最旧的一条消息, 调用时需持有锁
+func (queue *SendQueue) dropOldest() {
+	if key := coalesceKey(queue.pending[0]); key != "" && queue.coalesce[key] == 0 {
+		delete(queue.coalesce, key)
+	}
+	queue.pending[0] = nil
+	queue.pending = queue.pending[1:]
+	for key, index := range queue.coalesce {
+		queue.coalesce[key] = index - 1
+	}
+	metrics.SendQueueDropped.Inc()
+	if queue.dropped.Add(1)%uint64(queue.capacity) == 1 {
+		queue.logger.WarnF("[%s] Send queue full(%d), dropping oldest message, %d dropped in total", queue.connId, queue.capacity, queue.dropped.Load())
+	}
+}
+
+func (queue *SendQueue) takeAll() [][]byte {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	if len(queue.pending) == 0 {
+		return nil
+	}
+	batch := queue.pending
+	queue.pending = make([][]byte, 0, cap(batch))
+	clear(queue.coalesce)
+	return batch
+}
+
+// flush 一次性写出队列中的所有消息, 返回false表示连接已不可用
+func (queue *SendQueue) flush() bool {
+	batch := queue.takeAll()
+	if len(batch) == 0 {
+		return true
+	}
+	if queue.writeTimeout > 0 {
+		_ = queue.conn.SetWriteDeadline(time.Now().Add(queue.writeTimeout))
+	}
+	buffers := net.Buffers(batch)
+	if _, err := buffers.WriteTo(queue.conn); err != nil {
+		if !errors.Is(err, net.ErrClosed) {
+			queue.logger.WarnF("[%s] Failed to send data: %v", queue.connId, err)
+		}
+		_ = queue.conn.Close()
+		return false
+	}
+	return true
+}
+
+// Run 写协程主循环, 直到队列关闭或连接出错
+func (queue *SendQueue) Run() {
+	defer close(queue.exited)
+	for {
+		select {
+		case <-queue.notify:
+			if !queue.flush() {
+				return
+			}
+		case <-queue.done:
+			// 关闭前尽量写出剩余消息
+			queue.flush()
+			if queue.closeConn {
+				_ = queue.conn.Close()
+			}
+			return
+		}
+	}
+}
+
+// Close 关闭队列并等待写协程退出
+func (queue *SendQueue) Close() {
+	queue.lock.Lock()
+	if queue.closed {
+		// 已由CloseAfterFlush关闭时, 仍需等待剩余消息写出
+		queue.lock.Unlock()
+		<-queue.exited
+		return
+	}
+	queue.closed = true
+	queue.lock.Unlock()
+	close(queue.done)
+	<-queue.exited
+}
+
+// CloseAfterFlush 停止接收新消息, 写协程写出剩余消息后关闭连接, 不等待写协程退出
+func (queue *SendQueue) CloseAfterFlush() {
+	queue.lock.Lock()
+	if queue.closed {
+		queue.lock.Unlock()
+		return
+	}
+	queue.closed = true
+	queue.closeConn = true
+	queue.lock.Unlock()
+	close(queue.done)
+}
+
+// Depth 当前队列中待发送的消息数
+func (queue *SendQueue) Depth() int {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	return len(queue.pending)
+}
+
+// Dropped 因队列已满被丢弃的消息总数
+func (queue *SendQueue) Dropped() uint64 {
+	return queue.dropped.Load()
+}
@@ -0,0 +1,111 @@
+package packet
+
+import (
+	"bufio"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	"net"
+	"testing"
+	"time"
+)
+
+type nopLogger struct {
+	log.LoggerInterface
+}
+
+func (nopLogger) WarnF(string, ...interface{})  {}
+func (nopLogger) DebugF(string, ...interface{}) {}
+
+func newTestSendQueue(size, policy int) (*SendQueue, net.Conn) {
+	server, client := net.Pipe()
+	queue := NewSendQueue(nopLogger{}, server, &config.FSDServerConfig{
+		SendQueueSize:        size,
+		SendQueuePolicy:      policy,
+		WriteTimeoutDuration: time.Second,
+	}, func() { _ = server.Close() })
+	return queue, client
+}
+
+func TestCoalesceKey(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"@N:CPA421:7000:1:38.96244:121.53479:87:0:4290770974:278\r\n", "@N:CPA421"},
+		{"%ZSHA_CTR:24550:6:600:5:27.28025:118.28701:0\r\n", "%ZSHA_CTR"},
+		{"#TMZSHA_CTR:CPA421:hello\r\n", ""},
+		{"@N", ""},
+	}
+	pass := 0
+	fail := 0
+	for _, test := range tests {
+		result := coalesceKey([]byte(test.input))
+		if result != test.expected {
+			fail++
+			t.Errorf("coalesceKey(%q) = %q; expected %q", test.input, result, test.expected)
+			continue
+		}
+		pass++
+	}
+	t.Logf("TestCoalesceKey: %d pass, %d fail", pass, fail)
+}
+
+func TestSendQueuePush(t *testing.T) {
+	queue, _ := newTestSendQueue(3, config.SendQueueDropOldest)
+	queue.Push([]byte("@N:CPA421:7000:1:38.1:121.1\r\n"))
+	queue.Push([]byte("#TMSERVER:CPA421:hello\r\n"))
+	queue.Push([]byte("@N:CPA421:7000:1:38.2:121.2\r\n"))
+	if depth := queue.Depth(); depth != 2 {
+		t.Errorf("Depth after coalesce = %d; expected 2", depth)
+	}
+	queue.Push([]byte("#TMSERVER:CPA421:one\r\n"))
+	queue.Push([]byte("#TMSERVER:CPA421:two\r\n"))
+	if depth, dropped := queue.Depth(), queue.Dropped(); depth != 3 || dropped != 1 {
+		t.Errorf("Depth, Dropped after overflow = %d, %d; expected 3, 1", depth, dropped)
+	}
+	// 最旧的位置包被丢弃后, 新的位置包不应再被合并到错误的位置
+	queue.Push([]byte("@N:CPA421:7000:1:38.3:121.3\r\n"))
+	if depth, dropped := queue.Depth(), queue.Dropped(); depth != 3 || dropped != 2 {
+		t.Errorf("Depth, Dropped after second overflow = %d, %d; expected 3, 2", depth, dropped)
+	}
+}
+
+func TestSendQueueDisconnect(t *testing.T) {
+	queue, client := newTestSendQueue(1, config.SendQueueDisconnect)
+	queue.Push([]byte("#TMSERVER:CPA421:one\r\n"))
+	if queue.Push([]byte("#TMSERVER:CPA421:two\r\n")) {
+		t.Errorf("Push on full queue with disconnect policy = true; expected false")
+	}
+	if _, err := client.Read(make([]byte, 1)); err == nil {
+		t.Errorf("connection still open after overflow")
+	}
+}
+
+func TestSendQueueRun(t *testing.T) {
+	queue, client := newTestSendQueue(16, config.SendQueueDropOldest)
+	go queue.Run()
+	queue.Push([]byte("#TMSERVER:CPA421:one\r\n"))
+	queue.Push([]byte("#TMSERVER:CPA421:two\r\n"))
+	reader := bufio.NewReader(client)
+	for _, expected := range []string{"#TMSERVER:CPA421:one\r\n", "#TMSERVER:CPA421:two\r\n"} {
+		line, err := reader.ReadString('\n')
+		if err != nil || line != expected {
+			t.Errorf("ReadString = %q, %v; expected %q", line, err, expected)
+		}
+	}
+	queue.Close()
+}
+
+func TestSendQueuePushCopiesLine(t *testing.T) {
+	queue, client := newTestSendQueue(16, config.SendQueueDropOldest)
+	go queue.Run()
+	defer queue.Close()
+	// 模拟bufio.Scanner在下一次Scan时复用缓冲区
+	buffer := []byte("#TMZSHA_CTR:CPA421:hello\r\n")
+	queue.Push(buffer)
+	copy(buffer, "#TMZSHA_CTR:CPA421:XXXXX\r\n")
+	line, err := bufio.NewReader(client).ReadString('\n')
+	if err != nil || line != "#TMZSHA_CTR:CPA421:hello\r\n" {
+		t.Errorf("ReadString = %q, %v; expected original line", line, err)
+	}
+}
@@ -0,0 +1,245 @@
+package packet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/global"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// sessionSnapshotVersion 会话快照格式版本, 格式不兼容时跳过恢复
+const sessionSnapshotVersion = 1
+
+type sessionSnapshot struct {
+	Version int               `json:"version"`
+	SavedAt time.Time         `json:"saved_at"`
+	Clients []*clientSnapshot `json:"clients"`
+}
+
+type historySnapshot struct {
+	ID         uint      `json:"id"`
+	StartTime  time.Time `json:"start_time"`
+	EndTime    time.Time `json:"end_time"`
+	OnlineTime int       `json:"online_time"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type clientSnapshot struct {
+	Callsign    string          `json:"callsign"`
+	Cid         int             `json:"cid"`
+	IsAtc       bool            `json:"is_atc"`
+	Rating      Rating          `json:"rating"`
+	Facility    Facility        `json:"facility"`
+	Protocol    int             `json:"protocol"`
+	RealName    string          `json:"real_name"`
+	Position    [4]Position     `json:"position"`
+	SimType     int             `json:"sim_type"`
+	Transponder string          `json:"transponder"`
+	Altitude    int             `json:"altitude"`
+	GroundSpeed int             `json:"ground_speed"`
+	Frequency   int             `json:"frequency"`
+	Pbh         uint32          `json:"pbh"`
+	VisualRange float64         `json:"visual_range"`
+	AtisInfo    []string        `json:"atis_info"`
+	Paths       []*PilotPath    `json:"paths"`
+	History     historySnapshot `json:"history"`
+	// FlightPlanLocked 内存中飞行计划的锁定状态, 没有飞行计划时为nil
+	FlightPlanLocked *bool `json:"flight_plan_locked,omitempty"`
+}
+
+func newClientSnapshot(client *Client) *clientSnapshot {
+	client.lock.RLock()
+	defer client.lock.RUnlock()
+	var flightPlanLocked *bool
+	if client.flightPlan != nil {
+		locked := client.flightPlan.Locked
+		flightPlanLocked = &locked
+	}
+	return &clientSnapshot{
+		Callsign:    client.callsign,
+		Cid:         client.user.Cid,
+		IsAtc:       client.isAtc,
+		Rating:      client.rating,
+		Facility:    client.facility,
+		Protocol:    client.protocol,
+		RealName:    client.realName,
+		Position:    client.position,
+		SimType:     client.simType,
+		Transponder: client.transponder,
+		Altitude:    client.altitude,
+		GroundSpeed: client.groundSpeed,
+		Frequency:   client.frequency,
+		Pbh:         client.pbh,
+		VisualRange: client.visualRange,
+		AtisInfo:    client.atisInfo,
+		Paths:       client.paths,
+		History: historySnapshot{
+			ID:         client.history.ID,
+			StartTime:  client.history.StartTime,
+			EndTime:    client.history.EndTime,
+			OnlineTime: client.history.OnlineTime,
+			CreatedAt:  client.history.CreatedAt,
+		},
+		FlightPlanLocked: flightPlanLocked,
+	}
+}
+
+// saveSessionSnapshot 将客户端状态写入快照文件, 需要在客户端断开并保存连线记录后调用
+func (cm *ClientManager) saveSessionSnapshot(clients []ClientInterface) error {
+	path := cm.config.Server.FSDServer.SessionSnapshotFile
+	if path == "" {
+		return nil
+	}
+	snapshot := &sessionSnapshot{
+		Version: sessionSnapshotVersion,
+		SavedAt: time.Now(),
+		Clients: make([]*clientSnapshot, 0, len(clients)),
+	}
+	for _, c := range clients {
+		client, ok := c.(*Client)
+		if !ok || client.user == nil {
+			continue
+		}
+		snapshot.Clients = append(snapshot.Clients, newClientSnapshot(client))
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), global.DefaultDirectoryPermission); err != nil {
+		return err
+	}
+	// 先写入临时文件再重命名, 避免关闭过程中被中断导致快照损坏
+	tempFile := path + ".tmp"
+	if err := os.WriteFile(tempFile, data, global.DefaultFilePermissions); err != nil {
+		return err
+	}
+	if err := os.Rename(tempFile, path); err != nil {
+		return err
+	}
+	cm.applicationContent.Logger().InfoF("Saved %d sessions to %s", len(snapshot.Clients), path)
+	return nil
+}
+
+// RestoreSessions 从快照文件恢复上次关闭服务器时的会话,
+// 恢复的会话处于断开状态, 同一用户在 session_restore_time 内使用相同呼号重连会继续之前的会话
+func (cm *ClientManager) RestoreSessions() error {
+	path := cm.config.Server.FSDServer.SessionSnapshotFile
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// 快照只恢复一次, 避免之后的异常退出重复恢复过期的会话
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+
+	snapshot := &sessionSnapshot{}
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return fmt.Errorf("invalid session snapshot %s, %v", path, err)
+	}
+	if snapshot.Version != sessionSnapshotVersion {
+		return fmt.Errorf("unsupported session snapshot version %d", snapshot.Version)
+	}
+
+	logger := cm.applicationContent.Logger()
+	userOperation := cm.applicationContent.Operations().UserOperation()
+	restored := 0
+	for _, data := range snapshot.Clients {
+		user, err := userOperation.GetUserByCid(data.Cid)
+		if err != nil {
+			logger.WarnF("[%s] Fail to restore session, cannot get user %04d: %v", data.Callsign, data.Cid, err)
+			continue
+		}
+		if user.Rating == Ban.Index() || user.Banned() {
+			continue
+		}
+		client := cm.newRestoredClient(user, data)
+		if err := cm.AddClient(client); err != nil {
+			logger.WarnF("[%s] Fail to restore session: %v", data.Callsign, err)
+			continue
+		}
+		restored++
+	}
+	logger.InfoF("Restored %d sessions from %s saved at %s", restored, path, snapshot.SavedAt.Format(time.DateTime))
+	return nil
+}
+
+func (cm *ClientManager) newRestoredClient(user *operation.User, data *clientSnapshot) *Client {
+	session := &restoredSession{callsign: data.Callsign, user: user}
+	client := cm.NewClient(data.Callsign, data.Rating, data.Protocol, data.RealName, session, data.IsAtc).(*Client)
+	client.facility = data.Facility
+	client.position = data.Position
+	client.simType = data.SimType
+	client.transponder = data.Transponder
+	client.altitude = data.Altitude
+	client.groundSpeed = data.GroundSpeed
+	client.frequency = data.Frequency
+	client.pbh = data.Pbh
+	client.visualRange = data.VisualRange
+	if data.AtisInfo != nil {
+		client.atisInfo = data.AtisInfo
+	}
+	if data.Paths != nil {
+		client.paths = data.Paths
+	}
+	// 连线记录尚未写入数据库时快照中没有ID, 重连后开始新的连线记录
+	if data.History.ID != 0 {
+		client.history.ID = data.History.ID
+		client.history.StartTime = data.History.StartTime
+		client.history.EndTime = data.History.EndTime
+		client.history.OnlineTime = data.History.OnlineTime
+		client.history.CreatedAt = data.History.CreatedAt
+	}
+	if client.flightPlan != nil && data.FlightPlanLocked != nil {
+		client.flightPlan.Locked = *data.FlightPlanLocked
+	}
+	client.restored = true
+	client.disconnect.Store(true)
+	client.reconnectTimer = time.AfterFunc(cm.config.Server.FSDServer.SessionRestoreDuration, client.Delete)
+	return client
+}
+
+// restoredSession 恢复的会话在重连之前使用的占位连接, 丢弃所有发送的数据
+type restoredSession struct {
+	callsign string
+	user     *operation.User
+}
+
+func (session *restoredSession) SendError(_ *Result) {}
+
+func (session *restoredSession) HandleConnection() {}
+
+func (session *restoredSession) Callsign() string { return session.callsign }
+
+func (session *restoredSession) SetCallsign(callsign string) { session.callsign = callsign }
+
+func (session *restoredSession) User() *operation.User { return session.user }
+
+func (session *restoredSession) SetUser(user *operation.User) { session.user = user }
+
+func (session *restoredSession) ConnId() string { return "restored" }
+
+func (session *restoredSession) Conn() net.Conn { return nil }
+
+func (session *restoredSession) SetDisconnected(_ bool) {}
+
+func (session *restoredSession) Close() {}
+
+func (session *restoredSession) Send(_ []byte) {}
+
+func (session *restoredSession) SendQueueDepth() int { return 0 }
+
+func (session *restoredSession) SendQueueDropped() uint64 { return 0 }
@@ -0,0 +1,262 @@
+package packet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/half-nothing/simple-fsd/internal/database"
+	"github.com/half-nothing/simple-fsd/internal/interfaces"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/global"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func (nopLogger) Debug(string, ...interface{})  {}
+func (nopLogger) Info(string, ...interface{})   {}
+func (nopLogger) InfoF(string, ...interface{})  {}
+func (nopLogger) Warn(string, ...interface{})   {}
+func (nopLogger) Error(string, ...interface{})  {}
+func (nopLogger) ErrorF(string, ...interface{}) {}
+
+type testConfigManager struct {
+	interfaces.ConfigManagerInterface
+	config *config.Config
+}
+
+func (manager *testConfigManager) Config() *config.Config { return manager.config }
+
+type testCleaner struct{}
+
+func (testCleaner) Init()                 {}
+func (testCleaner) Add(_ global.Callable) {}
+func (testCleaner) Clean()                {}
+
+// testEnvironment 使用临时sqlite数据库的测试环境
+type testEnvironment struct {
+	config     *config.Config
+	operations *operation.DatabaseOperations
+	content    *interfaces.ApplicationContent
+}
+
+func newTestEnvironment(t *testing.T) *testEnvironment {
+	t.Helper()
+	dir := t.TempDir()
+	c := config.DefaultConfig()
+	c.Database.Type = string(config.SQLite)
+	c.Database.DBType = config.SQLite
+	c.Database.Database = filepath.Join(dir, "database.db")
+	c.Database.QueryDuration = 5 * time.Second
+	c.Database.ConnectIdleDuration = time.Hour
+	fsdConfig := c.Server.FSDServer
+	fsdConfig.SessionSnapshotFile = filepath.Join(dir, "sessions.json")
+	fsdConfig.SessionCleanDuration = time.Minute
+	fsdConfig.SessionRestoreDuration = time.Minute
+	fsdConfig.WriteTimeoutDuration = time.Second
+
+	closeCallback, operations, err := database.ConnectDatabase(nopLogger{}, c, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = closeCallback.Invoke(context.Background()) })
+	return &testEnvironment{
+		config:     c,
+		operations: operations,
+		content:    interfaces.NewApplicationContent(&testConfigManager{config: c}, testCleaner{}, nopLogger{}, operations),
+	}
+}
+
+// newClientManager 创建独立于全局单例的客户端管理器
+func (env *testEnvironment) newClientManager() *ClientManager {
+	return &ClientManager{
+		clients:            make(map[string]ClientInterface),
+		shuttingDown:       atomic.Bool{},
+		config:             env.config,
+		sectors:            NewSectorMap(nil),
+		spatialIndex:       NewSpatialIndex(),
+		applicationContent: env.content,
+		helpRequestTimes:   make(map[int]time.Time),
+		clientSlicePool: sync.Pool{
+			New: func() interface{} {
+				return make([]ClientInterface, 0, 128)
+			},
+		},
+	}
+}
+
+// newUser 创建用户, 密码为123456
+func (env *testEnvironment) newUser(t *testing.T, cid int, rating Rating) *operation.User {
+	t.Helper()
+	userOperation := env.operations.UserOperation()
+	user, err := userOperation.NewUser(fmt.Sprintf("user%d", cid), fmt.Sprintf("user%d@example.com", cid), cid, "123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.Rating = rating.Index()
+	if err := userOperation.AddUser(user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// newSession 创建连接到cm的会话, 另一端的数据会被丢弃
+func (env *testEnvironment) newSession(t *testing.T, cm *ClientManager) *Session {
+	t.Helper()
+	server, client := net.Pipe()
+	t.Cleanup(func() {
+		_ = server.Close()
+		_ = client.Close()
+	})
+	return NewSession(nopLogger{}, env.config, server, cm, env.operations)
+}
+
+var testFlightPlanData = []string{"CES2352", "SERVER", "I", "H/A320/L", "474", "ZSSS", "1115", "0", "FL371", "ZBAA",
+	"2", "8", "3", "6", "ZBTJ", "/V/", "DCT"}
+
+// loginPilot 模拟机组登录并返回新建的客户端
+func loginPilot(t *testing.T, env *testEnvironment, cm *ClientManager, callsign, cid string) *Client {
+	t.Helper()
+	session := env.newSession(t, cm)
+	if result := session.verifyUserInfo(callsign, 9, operation.GetUserId(cid), "123456"); result != nil {
+		t.Fatalf("login %s failed: %v", callsign, result.Errno)
+	}
+	client := cm.NewClient(callsign, Normal, 9, "Half_nothing", session, false).(*Client)
+	if err := cm.AddClient(client); err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// shutdownWithSnapshot 断开所有客户端后保存快照, 与Shutdown在连线记录写入数据库后的第二次保存相同
+func shutdownWithSnapshot(t *testing.T, cm *ClientManager) {
+	t.Helper()
+	clients := cm.GetClientSnapshot()
+	defer cm.PutSlice(clients)
+	cm.disconnectClients(clients)
+	if err := cm.saveSessionSnapshot(clients); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSessionSnapshotRestore(t *testing.T) {
+	env := newTestEnvironment(t)
+	env.newUser(t, 2352, Normal)
+	cm := env.newClientManager()
+	client := loginPilot(t, env, cm, "CES2352", "2352")
+	if err := client.UpsertFlightPlan(testFlightPlanData); err != nil {
+		t.Fatal(err)
+	}
+	// 管制员修改后锁定的计划只存在于内存中
+	client.flightPlan.Locked = true
+	client.transponder = "4512"
+	client.history.StartTime = time.Now().Add(-10 * time.Minute)
+	shutdownWithSnapshot(t, cm)
+
+	restored := env.newClientManager()
+	if err := restored.RestoreSessions(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(env.config.Server.FSDServer.SessionSnapshotFile); !errors.Is(err, os.ErrNotExist) {
+		t.Error("snapshot file should be removed after restore")
+	}
+	c, ok := restored.GetClient("CES2352")
+	if !ok {
+		t.Fatal("session not restored")
+	}
+	restoredClient := c.(*Client)
+	if !restoredClient.Disconnected() || !restoredClient.restored {
+		t.Error("restored session should wait for reconnect")
+	}
+	if restoredClient.transponder != "4512" || restoredClient.history.ID != client.history.ID {
+		t.Errorf("unexpected restored state, transponder %s history %d", restoredClient.transponder, restoredClient.history.ID)
+	}
+	if restoredClient.FlightPlan() == nil || !restoredClient.FlightPlan().Locked {
+		t.Error("flight plan lock state not restored")
+	}
+}
+
+func TestSessionSnapshotReconnect(t *testing.T) {
+	env := newTestEnvironment(t)
+	env.newUser(t, 2352, Normal)
+	env.newUser(t, 2353, Normal)
+	cm := env.newClientManager()
+	client := loginPilot(t, env, cm, "CES2352", "2352")
+	client.history.StartTime = time.Now().Add(-10 * time.Minute)
+	shutdownWithSnapshot(t, cm)
+
+	restored := env.newClientManager()
+	if err := restored.RestoreSessions(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 其他用户不能接管恢复的会话
+	session := env.newSession(t, restored)
+	if result := session.verifyUserInfo("CES2352", 9, operation.GetUserId("2353"), "123456"); result == nil || result.Errno != CallsignInUse {
+		t.Fatalf("expected callsign in use for other user, got %v", result)
+	}
+
+	session = env.newSession(t, restored)
+	if result := session.verifyUserInfo("CES2352", 9, operation.GetUserId("2352"), "123456"); result != nil {
+		t.Fatalf("reconnect failed: %v", result.Errno)
+	}
+	restoredClient := session.client.(*Client)
+	if restoredClient.Disconnected() || restoredClient.resumeTime.IsZero() {
+		t.Fatal("restored session not resumed")
+	}
+
+	// 重连之后继续之前的连线记录, 只累加重连之后的时长
+	restoredClient.resumeTime = time.Now().Add(-5 * time.Minute)
+	restoredClient.MarkedDisconnect(true)
+
+	history, err := env.operations.HistoryOperation().GetUserHistory(2352)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Pilots) != 1 {
+		t.Fatalf("expected a single history record, got %d", len(history.Pilots))
+	}
+	if onlineTime := history.Pilots[0].OnlineTime; onlineTime < 900 || onlineTime > 910 {
+		t.Errorf("unexpected online time %d", onlineTime)
+	}
+	user, err := env.operations.UserOperation().GetUserByCid(2352)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.TotalPilotTime < 900 || user.TotalPilotTime > 910 {
+		t.Errorf("unexpected pilot time %d", user.TotalPilotTime)
+	}
+}
+
+func TestSessionSnapshotExpired(t *testing.T) {
+	env := newTestEnvironment(t)
+	env.newUser(t, 2352, Normal)
+	cm := env.newClientManager()
+	client := loginPilot(t, env, cm, "CES2352", "2352")
+	client.history.StartTime = time.Now().Add(-10 * time.Minute)
+	shutdownWithSnapshot(t, cm)
+
+	restored := env.newClientManager()
+	if err := restored.RestoreSessions(); err != nil {
+		t.Fatal(err)
+	}
+	c, _ := restored.GetClient("CES2352")
+	// 没有重连的会话过期删除时不会重复累加连线时长
+	c.Delete()
+	if _, ok := restored.GetClient("CES2352"); ok {
+		t.Error("expired session not deleted")
+	}
+	user, err := env.operations.UserOperation().GetUserByCid(2352)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.TotalPilotTime < 600 || user.TotalPilotTime > 605 {
+		t.Errorf("unexpected pilot time %d", user.TotalPilotTime)
+	}
+}
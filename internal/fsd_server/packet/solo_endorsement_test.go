@@ -0,0 +1,89 @@
+package packet
+
+import (
+	"fmt"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"testing"
+	"time"
+)
+
+// grantSolo 为student授予席位facility的单飞授权
+func (env *testEnvironment) grantSolo(t *testing.T, student *operation.User, facility Facility, expiresAt time.Time) *operation.SoloEndorsement {
+	t.Helper()
+	trainingOperation := env.operations.TrainingOperation()
+	mentor := env.newUser(t, student.Cid+1, Instructor1)
+	request := trainingOperation.NewTrainingRequest(student, STU2.Index(), "")
+	if err := trainingOperation.AddTrainingRequest(request); err != nil {
+		t.Fatal(err)
+	}
+	solo := trainingOperation.NewSoloEndorsement(request, mentor, facility.Index(), expiresAt)
+	if err := trainingOperation.AddSoloEndorsement(request, solo); err != nil {
+		t.Fatal(err)
+	}
+	return solo
+}
+
+// addAtc 以user的管制权限发送管制员登录
+func addAtc(session *Session, user *operation.User, callsign string) *Result {
+	// #AA ZSSS_TWR SERVER name cid password rating protocol ...
+	data := []string{callsign, "SERVER", user.Username, fmt.Sprintf("%d", user.Cid), "123456",
+		fmt.Sprintf("%d", user.Rating), "9", "1", "0", "31.19", "121.33", "100"}
+	return session.handleAddAtc(data, []byte("#AA"+callsign))
+}
+
+func TestSoloEndorsementLogin(t *testing.T) {
+	env := newTestEnvironment(t)
+	cm := env.newClientManager()
+	student := env.newUser(t, 2000, STU1)
+
+	if result := addAtc(env.newSession(t, cm), student, "ZSSS_TWR"); result == nil || result.Errno != RequestLevelTooHigh {
+		t.Fatalf("expected login without solo endorsement rejected, got %+v", result)
+	}
+
+	env.grantSolo(t, student, TWR, time.Now().Add(time.Hour))
+	if result := addAtc(env.newSession(t, cm), student, "ZSSS_APP"); result == nil || result.Errno != RequestLevelTooHigh {
+		t.Errorf("expected solo endorsement limited to its facility, got %+v", result)
+	}
+	if result := addAtc(env.newSession(t, cm), student, "ZSSS_TWR"); result != nil && !result.Success {
+		t.Errorf("expected login with solo endorsement accepted, got %+v", result)
+	}
+}
+
+func TestSoloEndorsementExpired(t *testing.T) {
+	env := newTestEnvironment(t)
+	cm := env.newClientManager()
+	student := env.newUser(t, 2000, STU1)
+	env.grantSolo(t, student, TWR, time.Now().Add(-time.Minute))
+
+	if result := addAtc(env.newSession(t, cm), student, "ZSSS_TWR"); result == nil || result.Errno != RequestLevelTooHigh {
+		t.Errorf("expected expired solo endorsement rejected, got %+v", result)
+	}
+}
+
+func TestSoloEndorsementRevokedDuringSession(t *testing.T) {
+	env := newTestEnvironment(t)
+	cm := env.newClientManager()
+	student := env.newUser(t, 2000, STU1)
+	solo := env.grantSolo(t, student, TWR, time.Now().Add(time.Hour))
+
+	session, _ := env.login(t, cm, student, "ZSSS_TWR", true)
+	if session.checkSoloEndorsement(APP) {
+		t.Error("expected solo endorsement limited to its facility")
+	}
+	if !session.checkSoloEndorsement(TWR) {
+		t.Fatal("expected solo endorsement accepted")
+	}
+
+	if err := env.operations.TrainingOperation().RevokeSoloEndorsement(solo); err != nil {
+		t.Fatal(err)
+	}
+	// 缓存的单飞授权在重新查询间隔内仍然有效
+	if !session.checkSoloEndorsement(TWR) {
+		t.Fatal("expected cached solo endorsement used")
+	}
+	session.soloCheckedAt = time.Now().Add(-endorsementRecheckInterval)
+	if session.checkSoloEndorsement(TWR) {
+		t.Error("expected revoked solo endorsement rejected after recheck")
+	}
+}
@@ -0,0 +1,164 @@
+package packet
+
+import (
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
+	"math"
+	"sync"
+)
+
+const (
+	spatialCellSize     = 1.0 // 网格边长, 单位为度
+	nauticalMilesPerDeg = 60.0
+)
+
+type gridCell struct {
+	lat int
+	lon int
+}
+
+// SpatialIndex 基于经纬度网格的空间索引
+// 只有飞行员会被放入网格, 管制员数量少且视程范围大, 单独保存并总是作为候选
+type SpatialIndex struct {
+	lock          sync.RWMutex
+	lonCells      int
+	cells         map[gridCell]map[ClientInterface]struct{}
+	location      map[ClientInterface]gridCell
+	controllers   map[ClientInterface]struct{}
+	maxPilotRange float64
+	cellSetPool   sync.Pool
+}
+
+func NewSpatialIndex() *SpatialIndex {
+	return &SpatialIndex{
+		lonCells:    int(360 / spatialCellSize),
+		cells:       make(map[gridCell]map[ClientInterface]struct{}),
+		location:    make(map[ClientInterface]gridCell),
+		controllers: make(map[ClientInterface]struct{}),
+		cellSetPool: sync.Pool{
+			New: func() interface{} {
+				return make(map[gridCell]struct{}, 64)
+			},
+		},
+	}
+}
+
+func (index *SpatialIndex) cellOf(position Position) gridCell {
+	return gridCell{
+		lat: int(math.Floor((position.Latitude + 90) / spatialCellSize)),
+		lon: index.wrapLon(int(math.Floor((position.Longitude + 180) / spatialCellSize))),
+	}
+}
+
+func (index *SpatialIndex) wrapLon(lon int) int {
+	return ((lon % index.lonCells) + index.lonCells) % index.lonCells
+}
+
+func (index *SpatialIndex) removeLocked(client ClientInterface) {
+	cell, ok := index.location[client]
+	if !ok {
+		return
+	}
+	delete(index.location, client)
+	if clients, ok := index.cells[cell]; ok {
+		delete(clients, client)
+		if len(clients) == 0 {
+			delete(index.cells, cell)
+		}
+	}
+}
+
+// Update 根据客户端当前位置更新索引
+func (index *SpatialIndex) Update(client ClientInterface) {
+	index.lock.Lock()
+	defer index.lock.Unlock()
+
+	if client.IsAtc() {
+		index.controllers[client] = struct{}{}
+		return
+	}
+
+	position := client.Position()[0]
+	if !position.PositionValid() {
+		// 没有有效位置的客户端不可能在任何人的范围内
+		index.removeLocked(client)
+		return
+	}
+
+	if client.VisualRange() > index.maxPilotRange {
+		index.maxPilotRange = client.VisualRange()
+	}
+
+	cell := index.cellOf(position)
+	if old, ok := index.location[client]; ok {
+		if old == cell {
+			return
+		}
+		index.removeLocked(client)
+	}
+	clients, ok := index.cells[cell]
+	if !ok {
+		clients = make(map[ClientInterface]struct{})
+		index.cells[cell] = clients
+	}
+	clients[client] = struct{}{}
+	index.location[client] = cell
+}
+
+// Remove 从索引中移除客户端
+func (index *SpatialIndex) Remove(client ClientInterface) {
+	index.lock.Lock()
+	defer index.lock.Unlock()
+	delete(index.controllers, client)
+	index.removeLocked(client)
+}
+
+// Candidates 获取可能在fromClient范围内的所有客户端, 追加到out中返回
+// 结果是BroadcastToClientInRange判定结果的超集, 调用方仍需进行精确的距离过滤
+func (index *SpatialIndex) Candidates(fromClient ClientInterface, out []ClientInterface) []ClientInterface {
+	index.lock.RLock()
+	defer index.lock.RUnlock()
+
+	for client := range index.controllers {
+		out = append(out, client)
+	}
+
+	// 飞行员之间的判定阈值为双方视程之和, 涉及管制员时为管制员视程, 均不超过该半径
+	radius := (fromClient.VisualRange() + index.maxPilotRange) / nauticalMilesPerDeg
+	visited := index.cellSetPool.Get().(map[gridCell]struct{})
+	defer func() {
+		clear(visited)
+		index.cellSetPool.Put(visited)
+	}()
+
+	for _, position := range fromClient.Position() {
+		if !position.PositionValid() {
+			continue
+		}
+		minLat := int(math.Floor((math.Max(position.Latitude-radius, -90) + 90) / spatialCellSize))
+		maxLat := int(math.Floor((math.Min(position.Latitude+radius, 90) + 90) / spatialCellSize))
+
+		// 经度方向的跨度随纬度增大, 取范围内最高纬度计算
+		edgeLat := math.Min(math.Abs(position.Latitude)+radius, 90)
+		minLon, maxLon := 0, index.lonCells-1
+		if cos := math.Cos(edgeLat * math.Pi / 180); cos > 0 {
+			if lonRadius := radius / cos; lonRadius < 180 {
+				minLon = int(math.Floor((position.Longitude - lonRadius + 180) / spatialCellSize))
+				maxLon = int(math.Floor((position.Longitude + lonRadius + 180) / spatialCellSize))
+			}
+		}
+
+		for lat := minLat; lat <= maxLat; lat++ {
+			for lon := minLon; lon <= maxLon; lon++ {
+				cell := gridCell{lat: lat, lon: index.wrapLon(lon)}
+				if _, ok := visited[cell]; ok {
+					continue
+				}
+				visited[cell] = struct{}{}
+				for client := range index.cells[cell] {
+					out = append(out, client)
+				}
+			}
+		}
+	}
+	return out
+}
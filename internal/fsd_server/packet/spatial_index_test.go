@@ -0,0 +1,125 @@
+package packet
+
+import (
+	"fmt"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
+	"math/rand"
+	"testing"
+)
+
+// fakeClient 只实现范围判定需要的方法
+type fakeClient struct {
+	ClientInterface
+	callsign    string
+	isAtc       bool
+	position    [4]Position
+	visualRange float64
+}
+
+func (client *fakeClient) Callsign() string          { return client.callsign }
+func (client *fakeClient) IsAtc() bool               { return client.isAtc }
+func (client *fakeClient) Position() [4]Position     { return client.position }
+func (client *fakeClient) VisualRange() float64      { return client.visualRange }
+func (client *fakeClient) Disconnected() bool        { return false }
+func (client *fakeClient) SendLineWithoutLog([]byte) {}
+
+// generateClients 在中国附近随机生成客户端, 其中5%为管制员
+func generateClients(count int, seed int64) []ClientInterface {
+	random := rand.New(rand.NewSource(seed))
+	clients := make([]ClientInterface, 0, count)
+	for i := 0; i < count; i++ {
+		client := &fakeClient{
+			callsign:    fmt.Sprintf("TEST%04d", i),
+			visualRange: 40,
+		}
+		client.position[0] = Position{Latitude: 18 + random.Float64()*32, Longitude: 75 + random.Float64()*60}
+		if i%20 == 0 {
+			client.isAtc = true
+			client.visualRange = 50 + random.Float64()*550
+		}
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+func newTestIndex(clients []ClientInterface) *SpatialIndex {
+	index := NewSpatialIndex()
+	for _, client := range clients {
+		index.Update(client)
+	}
+	return index
+}
+
+func TestSpatialIndexCandidates(t *testing.T) {
+	clients := generateClients(2000, 1)
+	index := newTestIndex(clients)
+	pass := 0
+	fail := 0
+	for _, from := range clients {
+		candidates := make(map[ClientInterface]struct{})
+		for _, client := range index.Candidates(from, nil) {
+			candidates[client] = struct{}{}
+		}
+		missing := 0
+		for _, to := range clients {
+			if to == from || !BroadcastToClientInRange(to, from) {
+				continue
+			}
+			if _, ok := candidates[to]; !ok {
+				missing++
+			}
+		}
+		if missing > 0 {
+			fail++
+			t.Errorf("Candidates(%s) missing %d clients in range", from.Callsign(), missing)
+			continue
+		}
+		pass++
+	}
+	t.Logf("TestSpatialIndexCandidates: %d pass, %d fail", pass, fail)
+}
+
+func TestSpatialIndexRemove(t *testing.T) {
+	clients := generateClients(100, 2)
+	index := newTestIndex(clients)
+	for _, client := range clients {
+		index.Remove(client)
+	}
+	if candidates := index.Candidates(clients[1], nil); len(candidates) != 0 {
+		t.Errorf("Candidates after remove all = %d; expected 0", len(candidates))
+	}
+}
+
+func benchmarkLinear(b *testing.B, count int) {
+	clients := generateClients(count, 3)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		from := clients[i%count]
+		for _, to := range clients {
+			if to != from {
+				BroadcastToClientInRange(to, from)
+			}
+		}
+	}
+}
+
+func benchmarkSpatialIndex(b *testing.B, count int) {
+	clients := generateClients(count, 3)
+	index := newTestIndex(clients)
+	buffer := make([]ClientInterface, 0, count)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		from := clients[i%count]
+		buffer = index.Candidates(from, buffer[:0])
+		for _, to := range buffer {
+			if to != from {
+				BroadcastToClientInRange(to, from)
+			}
+		}
+	}
+}
+
+func BenchmarkBroadcastRangeLinear500(b *testing.B)  { benchmarkLinear(b, 500) }
+func BenchmarkBroadcastRangeLinear2000(b *testing.B) { benchmarkLinear(b, 2000) }
+func BenchmarkBroadcastRangeIndex500(b *testing.B)   { benchmarkSpatialIndex(b, 500) }
+func BenchmarkBroadcastRangeIndex2000(b *testing.B)  { benchmarkSpatialIndex(b, 2000) }
@@ -0,0 +1,405 @@
+package packet
+
+import (
+	"errors"
+	"fmt"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/global"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SupervisorCommandPrefix 以该前缀开头并发送给 SERVER 的 #TM 消息会被当作管理命令处理
+const SupervisorCommandPrefix = "."
+
+type supervisorCommand struct {
+	usage       string
+	description string
+	// permissions 执行命令需要的用户权限, 为空时只检查管制等级
+	permissions []operation.Permission
+	// public 所有客户端均可使用, 不检查管制等级与权限
+	public bool
+	run    func(session *Session, operator *operation.User, args []string) []string
+}
+
+var supervisorCommands map[string]*supervisorCommand
+
+func init() {
+	supervisorCommands = map[string]*supervisorCommand{
+		"kick": {
+			usage:       ".kick CALLSIGN [reason]",
+			description: "kick a client from the server",
+			permissions: []operation.Permission{operation.ClientKill},
+			run:         supervisorKick,
+		},
+		"ban": {
+			usage:       ".ban CID DURATION [reason]",
+			description: "ban a user for a duration, e.g. 30m, 24h, 7d",
+			permissions: []operation.Permission{operation.UserEditRating},
+			run:         supervisorBan,
+		},
+		"unban": {
+			usage:       ".unban CID",
+			description: "lift a temporary ban of a user",
+			permissions: []operation.Permission{operation.UserEditRating},
+			run:         supervisorUnban,
+		},
+		"wallop": {
+			usage:       ".wallop MESSAGE",
+			description: "broadcast a message to all clients",
+			permissions: []operation.Permission{operation.ClientSendMessage},
+			run:         supervisorWallop,
+		},
+		"info": {
+			usage:       ".info CALLSIGN",
+			description: "show details of an online client",
+			run:         supervisorInfo,
+		},
+		"find": {
+			usage:       ".find CID",
+			description: "find online callsigns of a user",
+			run:         supervisorFind,
+		},
+		"motd": {
+			usage:       ".motd",
+			description: "broadcast the message of the day to all clients",
+			permissions: []operation.Permission{operation.ClientSendMessage},
+			run:         supervisorMotd,
+		},
+		"commands": {
+			usage:       ".commands",
+			description: "show available commands",
+			run:         supervisorCommandList,
+		},
+		HelpRequestCommand: {
+			usage:       ".help MESSAGE",
+			description: "send a help request to supervisors",
+			public:      true,
+			run:         supervisorHelpRequest,
+		},
+		"requests": {
+			usage:       ".requests",
+			description: "list help requests that are not closed",
+			run:         supervisorRequests,
+		},
+		"claim": {
+			usage:       ".claim ID",
+			description: "claim a help request",
+			run:         supervisorClaim,
+		},
+		"close": {
+			usage:       ".close ID",
+			description: "close a help request",
+			run:         supervisorClose,
+		},
+	}
+}
+
+// parseBanDuration 解析封禁时长, 在 time.ParseDuration 的基础上支持以d结尾的天数
+func parseBanDuration(value string) (time.Duration, error) {
+	var duration time.Duration
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		day, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %s", value)
+		}
+		duration = time.Duration(day) * 24 * time.Hour
+	} else {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %s", value)
+		}
+		duration = d
+	}
+	if duration <= 0 {
+		return 0, errors.New("duration must be positive")
+	}
+	return duration, nil
+}
+
+// formatFrequency 将FSD频率格式(22800)转换为便于阅读的形式(122.800)
+func formatFrequency(frequency int) string {
+	return fmt.Sprintf("1%02d.%03d", frequency/1000, frequency%1000)
+}
+
+// replyFromServer 以 SERVER 的身份向当前客户端发送消息
+func (session *Session) replyFromServer(lines ...string) {
+	for _, line := range lines {
+		session.client.SendLine(makePacket(Message, global.FSDServerName, session.client.Callsign(), line))
+	}
+}
+
+func (session *Session) remoteIp() string {
+	host, _, err := net.SplitHostPort(session.connId)
+	if err != nil {
+		return session.connId
+	}
+	return host
+}
+
+// saveAuditLog 记录管理命令的审计日志
+func (session *Session) saveAuditLog(eventType operation.EventType, operator *operation.User, object string, changeDetail *operation.ChangeDetail) {
+	ip := session.remoteIp()
+	userAgent := fmt.Sprintf("FSD(%s)", session.client.Callsign())
+	go func() {
+		auditLog := session.auditLogOperation.NewAuditLog(eventType, operator.Cid, object, ip, userAgent, changeDetail)
+		if err := session.auditLogOperation.SaveAuditLog(auditLog); err != nil {
+			session.logger.ErrorF("Fail to create audit log for %s, detail: %v", eventType, err)
+		}
+	}()
+}
+
+// handleSupervisorCommand 处理发送给 SERVER 的管理命令
+func (session *Session) handleSupervisorCommand(message string) *Result {
+	fields := strings.Fields(strings.TrimPrefix(message, SupervisorCommandPrefix))
+	if len(fields) == 0 {
+		return ResultSuccess()
+	}
+	command, ok := supervisorCommands[strings.ToLower(fields[0])]
+	if !ok {
+		session.replyFromServer(fmt.Sprintf("Unknown command %s, use .commands to list available commands", fields[0]))
+		return ResultSuccess()
+	}
+	if command.public {
+		session.replyFromServer(command.run(session, nil, fields[1:])...)
+		return ResultSuccess()
+	}
+	if !session.client.IsAtc() || !session.client.CheckRating(AllowKillRating) {
+		session.replyFromServer("You are not allowed to use supervisor commands")
+		return ResultSuccess()
+	}
+	// 重新获取用户以使用最新的权限
+	operator, err := session.userOperation.GetUserByCid(session.client.User().Cid)
+	if err != nil {
+		session.logger.ErrorF("[%s] fail to get operator for supervisor command: %v", session.client.Callsign(), err)
+		session.replyFromServer("Internal server error, please try again later")
+		return ResultSuccess()
+	}
+	permission := operator.EffectivePermission()
+	for _, perm := range command.permissions {
+		if !permission.HasPermission(perm) {
+			session.replyFromServer("Permission denied")
+			return ResultSuccess()
+		}
+	}
+	session.logger.InfoF("[%s] supervisor command: %s", session.client.Callsign(), message)
+	session.replyFromServer(command.run(session, operator, fields[1:])...)
+	return ResultSuccess()
+}
+
+func supervisorKick(session *Session, operator *operation.User, args []string) []string {
+	if len(args) < 1 {
+		return []string{"Usage: " + supervisorCommands["kick"].usage}
+	}
+	targetCallsign := strings.ToUpper(args[0])
+	reason := strings.Join(args[1:], " ")
+	client, ok := session.clientManager.GetClient(targetCallsign)
+	if !ok || client.Disconnected() {
+		return []string{fmt.Sprintf("%s not found", targetCallsign)}
+	}
+	notice := "You have been kicked from the server by a supervisor"
+	if reason != "" {
+		notice += ", reason: " + reason
+	}
+	client.SendLine(makePacket(Message, global.FSDServerName, targetCallsign, notice))
+	client.MarkedDisconnect(false)
+	session.saveAuditLog(operation.ClientKicked, operator, fmt.Sprintf("%s(%s)", targetCallsign, reason), nil)
+	return []string{fmt.Sprintf("%s has been kicked", targetCallsign)}
+}
+
+func supervisorBan(session *Session, operator *operation.User, args []string) []string {
+	if len(args) < 2 {
+		return []string{"Usage: " + supervisorCommands["ban"].usage}
+	}
+	cid, err := strconv.Atoi(args[0])
+	if err != nil {
+		return []string{fmt.Sprintf("Invalid cid %s", args[0])}
+	}
+	duration, err := parseBanDuration(args[1])
+	if err != nil {
+		return []string{err.Error()}
+	}
+	reason := strings.Join(args[2:], " ")
+	user, err := session.userOperation.GetUserByCid(cid)
+	if errors.Is(err, operation.ErrUserNotFound) {
+		return []string{fmt.Sprintf("User %04d not found", cid)}
+	}
+	if err != nil {
+		session.logger.ErrorF("[%s] fail to get user %04d: %v", session.client.Callsign(), cid, err)
+		return []string{"Internal server error, please try again later"}
+	}
+	if user.Cid == operator.Cid || user.Rating >= operator.Rating {
+		return []string{fmt.Sprintf("You are not allowed to ban %04d", cid)}
+	}
+	until := time.Now().Add(duration)
+	if err := session.userOperation.BanUser(user, until, reason); err != nil {
+		session.logger.ErrorF("[%s] fail to ban user %04d: %v", session.client.Callsign(), cid, err)
+		return []string{"Internal server error, please try again later"}
+	}
+	// 同时注销该用户的网页会话
+	if err := session.userSessionOperation.RevokeUserSessions(cid, ""); err != nil {
+		session.logger.ErrorF("[%s] fail to revoke sessions of user %04d: %v", session.client.Callsign(), cid, err)
+	}
+	// 封禁期间不能继续培训
+	if err := session.trainingOperation.CancelUserTrainingRequests(cid); err != nil {
+		session.logger.ErrorF("[%s] fail to cancel training requests of user %04d: %v", session.client.Callsign(), cid, err)
+	}
+
+	// 断开该用户所有在线的客户端
+	notice := fmt.Sprintf("You have been banned until %s", until.UTC().Format("2006-01-02 15:04Z"))
+	if reason != "" {
+		notice += ", reason: " + reason
+	}
+	kicked := make([]string, 0)
+	clients := session.clientManager.GetClientSnapshot()
+	for _, client := range clients {
+		if client.User() == nil || client.User().Cid != cid || client.Disconnected() {
+			continue
+		}
+		client.SendLine(makePacket(Message, global.FSDServerName, client.Callsign(), notice))
+		client.MarkedDisconnect(false)
+		kicked = append(kicked, client.Callsign())
+	}
+	session.clientManager.PutSlice(clients)
+
+	session.saveAuditLog(operation.UserBanned, operator, strconv.Itoa(cid), &operation.ChangeDetail{
+		NewValue: fmt.Sprintf("%s(%s)", until.Format(time.RFC3339), reason),
+	})
+	reply := fmt.Sprintf("User %04d has been banned until %s", cid, until.UTC().Format("2006-01-02 15:04Z"))
+	if len(kicked) > 0 {
+		reply += ", disconnected " + strings.Join(kicked, ", ")
+	}
+	return []string{reply}
+}
+
+func supervisorUnban(session *Session, operator *operation.User, args []string) []string {
+	if len(args) < 1 {
+		return []string{"Usage: " + supervisorCommands["unban"].usage}
+	}
+	cid, err := strconv.Atoi(args[0])
+	if err != nil {
+		return []string{fmt.Sprintf("Invalid cid %s", args[0])}
+	}
+	user, err := session.userOperation.GetUserByCid(cid)
+	if errors.Is(err, operation.ErrUserNotFound) {
+		return []string{fmt.Sprintf("User %04d not found", cid)}
+	}
+	if err != nil {
+		session.logger.ErrorF("[%s] fail to get user %04d: %v", session.client.Callsign(), cid, err)
+		return []string{"Internal server error, please try again later"}
+	}
+	if user.Cid == operator.Cid || user.Rating >= operator.Rating {
+		return []string{fmt.Sprintf("You are not allowed to unban %04d", cid)}
+	}
+	if !user.Banned() {
+		return []string{fmt.Sprintf("User %04d is not banned", cid)}
+	}
+	if err := session.userOperation.UnbanUser(user); err != nil {
+		session.logger.ErrorF("[%s] fail to unban user %04d: %v", session.client.Callsign(), cid, err)
+		return []string{"Internal server error, please try again later"}
+	}
+	session.saveAuditLog(operation.UserUnbanned, operator, strconv.Itoa(cid), nil)
+	return []string{fmt.Sprintf("User %04d has been unbanned", cid)}
+}
+
+func supervisorWallop(session *Session, operator *operation.User, args []string) []string {
+	if len(args) == 0 {
+		return []string{"Usage: " + supervisorCommands["wallop"].usage}
+	}
+	message := strings.Join(args, " ")
+	session.clientManager.BroadcastMessage(makePacket(Message, global.FSDServerName, string(AllClient), message),
+		session.client, BroadcastToAll)
+	session.saveAuditLog(operation.ClientMessage, operator, fmt.Sprintf("%s(%s)", AllClient, message), nil)
+	return []string{"Message broadcast to all clients"}
+}
+
+func supervisorInfo(session *Session, operator *operation.User, args []string) []string {
+	if len(args) < 1 {
+		return []string{"Usage: " + supervisorCommands["info"].usage}
+	}
+	targetCallsign := strings.ToUpper(args[0])
+	client, ok := session.clientManager.GetClient(targetCallsign)
+	if !ok {
+		return []string{fmt.Sprintf("%s not found", targetCallsign)}
+	}
+	session.saveAuditLog(operation.SupervisorQuery, operator, targetCallsign, nil)
+	lines := make([]string, 0, 4)
+	status := "online"
+	if client.Disconnected() {
+		status = "disconnected, waiting for reconnect"
+	}
+	cid := 0
+	if client.User() != nil {
+		cid = client.User().Cid
+	}
+	lines = append(lines, fmt.Sprintf("%s: CID %04d, %s, rating %s, %s", targetCallsign, cid, client.RealName(),
+		client.Rating().String(), status))
+	position := client.Position()[0]
+	if client.IsAtc() {
+		lines = append(lines, fmt.Sprintf("Facility %s, frequency %s, visual range %.0fnm, position %.5f %.5f",
+			client.Facility().String(), formatFrequency(client.Frequency()), client.VisualRange(),
+			position.Latitude, position.Longitude))
+	} else {
+		lines = append(lines, fmt.Sprintf("Squawk %s, altitude %dft, ground speed %dkt, position %.5f %.5f",
+			client.Transponder(), client.Altitude(), client.GroundSpeed(), position.Latitude, position.Longitude))
+		if flightPlan := client.FlightPlan(); flightPlan != nil {
+			lines = append(lines, fmt.Sprintf("Flight plan %s-%s, %s, locked: %t", flightPlan.DepartureAirport,
+				flightPlan.ArrivalAirport, flightPlan.AircraftType, flightPlan.Locked))
+		}
+	}
+	if history := client.History(); history != nil {
+		lines = append(lines, fmt.Sprintf("Online since %s", history.StartTime.UTC().Format("2006-01-02 15:04Z")))
+	}
+	return lines
+}
+
+func supervisorFind(session *Session, operator *operation.User, args []string) []string {
+	if len(args) < 1 {
+		return []string{"Usage: " + supervisorCommands["find"].usage}
+	}
+	cid, err := strconv.Atoi(args[0])
+	if err != nil {
+		return []string{fmt.Sprintf("Invalid cid %s", args[0])}
+	}
+	session.saveAuditLog(operation.SupervisorQuery, operator, strconv.Itoa(cid), nil)
+	callsigns := make([]string, 0)
+	clients := session.clientManager.GetClientSnapshot()
+	for _, client := range clients {
+		if client.User() != nil && client.User().Cid == cid {
+			callsigns = append(callsigns, client.Callsign())
+		}
+	}
+	session.clientManager.PutSlice(clients)
+	if len(callsigns) == 0 {
+		return []string{fmt.Sprintf("User %04d is not online", cid)}
+	}
+	slices.Sort(callsigns)
+	return []string{fmt.Sprintf("User %04d is online as %s", cid, strings.Join(callsigns, ", "))}
+}
+
+func supervisorMotd(session *Session, operator *operation.User, _ []string) []string {
+	for _, line := range session.config.Server.FSDServer.MotdLines() {
+		session.clientManager.BroadcastMessage(makePacket(Message, global.FSDServerName, string(AllClient), line),
+			session.client, BroadcastToAll)
+	}
+	session.saveAuditLog(operation.ClientMessage, operator, fmt.Sprintf("%s(motd)", AllClient), nil)
+	return []string{"MOTD broadcast to all clients"}
+}
+
+func supervisorCommandList(_ *Session, _ *operation.User, _ []string) []string {
+	names := make([]string, 0, len(supervisorCommands))
+	for name := range supervisorCommands {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		command := supervisorCommands[name]
+		lines = append(lines, fmt.Sprintf("%s - %s", command.usage, command.description))
+	}
+	return lines
+}
@@ -0,0 +1,255 @@
+package packet
+
+import (
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseBanDuration(t *testing.T) {
+	cases := map[string]time.Duration{
+		"30m": 30 * time.Minute,
+		"24h": 24 * time.Hour,
+		"7d":  7 * 24 * time.Hour,
+	}
+	for value, expected := range cases {
+		duration, err := parseBanDuration(value)
+		if err != nil {
+			t.Fatalf("parseBanDuration(%q) error: %v", value, err)
+		}
+		if duration != expected {
+			t.Errorf("parseBanDuration(%q) = %v, want %v", value, duration, expected)
+		}
+	}
+	for _, value := range []string{"", "abc", "xd", "0h", "-1d"} {
+		if _, err := parseBanDuration(value); err == nil {
+			t.Errorf("parseBanDuration(%q) expected error", value)
+		}
+	}
+}
+
+func TestFormatFrequency(t *testing.T) {
+	if frequency := formatFrequency(22800); frequency != "122.800" {
+		t.Errorf("unexpected frequency %s", frequency)
+	}
+	if frequency := formatFrequency(18050); frequency != "118.050" {
+		t.Errorf("unexpected frequency %s", frequency)
+	}
+}
+
+func grantPermission(t *testing.T, env *testEnvironment, user *operation.User, permissions ...operation.Permission) {
+	t.Helper()
+	permission := operation.NewPermissionSet(user.Permission)
+	for _, perm := range permissions {
+		permission.Grant(perm)
+	}
+	if err := env.operations.UserOperation().UpdateUserPermission(user, permission); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSupervisorCommandRating(t *testing.T) {
+	env := newTestEnvironment(t)
+	cm := env.newClientManager()
+	pilot := env.newUser(t, 2001, Administrator)
+	controller := env.newUser(t, 2002, CTR3)
+	grantPermission(t, env, pilot, operation.ClientKill)
+	grantPermission(t, env, controller, operation.ClientKill)
+	env.newUser(t, 2003, Normal)
+	_, target := env.login(t, cm, env.mustGetUser(t, 2003), "CES2003", false)
+
+	// 机组客户端与管制等级不足的管制员都不能使用管理命令
+	pilotSession, pilotSocket := env.login(t, cm, pilot, "CES2001", false)
+	pilotSession.handleSupervisorCommand(".kick CES2003")
+	if !pilotSocket.received("not allowed to use supervisor commands") {
+		t.Error("pilot should not use supervisor commands")
+	}
+	controllerSession, controllerSocket := env.login(t, cm, controller, "ZSSS_APP", true)
+	controllerSession.handleSupervisorCommand(".kick CES2003")
+	if !controllerSocket.received("not allowed to use supervisor commands") {
+		t.Error("controller below supervisor should not use supervisor commands")
+	}
+	if target.received("kicked") {
+		t.Error("target should not be kicked")
+	}
+}
+
+func TestSupervisorCommandPermission(t *testing.T) {
+	env := newTestEnvironment(t)
+	cm := env.newClientManager()
+	supervisor := env.newUser(t, 2001, Supervisor)
+	session, socket := env.login(t, cm, supervisor, "ZSHA_SUP", true)
+	env.newUser(t, 2003, Normal)
+	_, target := env.login(t, cm, env.mustGetUser(t, 2003), "CES2003", false)
+
+	for _, command := range []string{".kick CES2003", ".ban 2003 1d", ".wallop hello", ".unban 2003"} {
+		session.handleSupervisorCommand(command)
+		if !socket.received("Permission denied") {
+			t.Errorf("%s should require permission", command)
+		}
+	}
+	if target.received("") {
+		t.Error("target should not receive anything")
+	}
+
+	grantPermission(t, env, supervisor, operation.ClientSendMessage)
+	session.handleSupervisorCommand(".wallop hello")
+	if !socket.received("Message broadcast") || !target.received("hello") {
+		t.Error("wallop not broadcast")
+	}
+
+	grantPermission(t, env, supervisor, operation.ClientKill)
+	session.handleSupervisorCommand(".kick CES2003 test")
+	if !socket.received("CES2003 has been kicked") || !target.received("kicked from the server") {
+		t.Error("kick failed")
+	}
+}
+
+func TestSupervisorBan(t *testing.T) {
+	env := newTestEnvironment(t)
+	cm := env.newClientManager()
+	supervisor := env.newUser(t, 2001, Supervisor)
+	grantPermission(t, env, supervisor, operation.UserEditRating)
+	session, socket := env.login(t, cm, supervisor, "ZSHA_SUP", true)
+	env.newUser(t, 2002, Supervisor)
+	env.newUser(t, 2003, Normal)
+	_, target := env.login(t, cm, env.mustGetUser(t, 2003), "CES2003", false)
+
+	// 不能封禁管制等级不低于自己的用户
+	session.handleSupervisorCommand(".ban 2002 1d")
+	if !socket.received("not allowed to ban 2002") || env.mustGetUser(t, 2002).Banned() {
+		t.Error("supervisor should not ban user with same rating")
+	}
+
+	sessionOperation := env.operations.UserSessionOperation()
+	webSession := sessionOperation.NewUserSession(env.mustGetUser(t, 2003), "127.0.0.1", "test", time.Now().Add(time.Hour))
+	if err := sessionOperation.AddUserSession(webSession); err != nil {
+		t.Fatal(err)
+	}
+
+	trainingOperation := env.operations.TrainingOperation()
+	training := trainingOperation.NewTrainingRequest(env.mustGetUser(t, 2003), STU1.Index(), "")
+	if err := trainingOperation.AddTrainingRequest(training); err != nil {
+		t.Fatal(err)
+	}
+
+	session.handleSupervisorCommand(".ban 2003 1d spam")
+	if !socket.received("User 2003 has been banned") || !target.received("banned until") {
+		t.Error("ban failed")
+	}
+	if user := env.mustGetUser(t, 2003); !user.Banned() || user.BanReason != "spam" {
+		t.Error("ban not saved")
+	}
+	if client, ok := cm.GetClient("CES2003"); !ok || !client.Disconnected() {
+		t.Error("banned client not disconnected")
+	}
+	if sessions, err := sessionOperation.GetActiveUserSessions(2003); err != nil || len(sessions) != 0 {
+		t.Errorf("web sessions of banned user not revoked, %d active", len(sessions))
+	}
+	if training, err := trainingOperation.GetTrainingRequestById(training.ID); err != nil || training.Status != operation.TrainingStatusCancelled {
+		t.Error("training of banned user not cancelled")
+	}
+
+	session.handleSupervisorCommand(".unban 2003")
+	if !socket.received("User 2003 has been unbanned") {
+		t.Error("unban failed")
+	}
+	if user := env.mustGetUser(t, 2003); user.Banned() || user.BannedUntil != nil || user.BanReason != "" {
+		t.Error("unban not saved")
+	}
+}
+
+func TestSupervisorKickFlushesNotice(t *testing.T) {
+	env := newTestEnvironment(t)
+	cm := env.newClientManager()
+	supervisor := env.newUser(t, 2001, Supervisor)
+	grantPermission(t, env, supervisor, operation.ClientKill)
+	session, _ := env.login(t, cm, supervisor, "ZSHA_SUP", true)
+	target := env.newUser(t, 2003, Normal)
+
+	// 被踢出的客户端使用真实的连接, 以检查通知在连接关闭前写出
+	server, client := net.Pipe()
+	defer func() { _ = client.Close() }()
+	targetSession := NewSession(nopLogger{}, env.config, server, cm, env.operations)
+	targetSession.user = target
+	targetSession.client = cm.NewClient("CES2003", Normal, 9, target.Username, targetSession, false)
+	if err := cm.AddClient(targetSession.client); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		targetSession.HandleConnection()
+		close(done)
+	}()
+
+	go session.handleSupervisorCommand(".kick CES2003 test")
+	data, err := io.ReadAll(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "kicked from the server by a supervisor, reason: test") {
+		t.Errorf("kick notice not received before close, got %q", data)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection not closed after kick")
+	}
+}
+
+func (env *testEnvironment) mustGetUser(t *testing.T, cid int) *operation.User {
+	t.Helper()
+	user, err := env.operations.UserOperation().GetUserByCid(cid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// recordingSession 记录发送给客户端的消息, 用于检查服务器的回复
+type recordingSession struct {
+	restoredSession
+	lock  sync.Mutex
+	lines []string
+}
+
+func (session *recordingSession) ConnId() string { return "127.0.0.1:6809" }
+
+func (session *recordingSession) Send(line []byte) {
+	session.lock.Lock()
+	defer session.lock.Unlock()
+	session.lines = append(session.lines, string(line))
+}
+
+// received 返回收到的消息中是否有包含text的消息, 并清空已记录的消息
+func (session *recordingSession) received(text string) bool {
+	session.lock.Lock()
+	defer session.lock.Unlock()
+	found := false
+	for _, line := range session.lines {
+		if strings.Contains(line, text) {
+			found = true
+			break
+		}
+	}
+	session.lines = session.lines[:0]
+	return found
+}
+
+// login 为user创建已登录的客户端, 返回客户端所属的会话与记录客户端收到消息的连接
+func (env *testEnvironment) login(t *testing.T, cm *ClientManager, user *operation.User, callsign string, isAtc bool) (*Session, *recordingSession) {
+	t.Helper()
+	session := env.newSession(t, cm)
+	session.user = user
+	socket := &recordingSession{restoredSession: restoredSession{user: user}}
+	session.client = cm.NewClient(callsign, Rating(user.Rating), 9, user.Username, socket, isAtc)
+	if err := cm.AddClient(session.client); err != nil {
+		t.Fatal(err)
+	}
+	return session, socket
+}
@@ -2,41 +2,40 @@ package fsd_server
 
 import (
 	"context"
+	"errors"
 	"github.com/half-nothing/simple-fsd/internal/fsd_server/packet"
+	"github.com/half-nothing/simple-fsd/internal/health"
 	. "github.com/half-nothing/simple-fsd/internal/interfaces"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
 	"net"
+	"sync/atomic"
 	"time"
 )
 
+var (
+	ErrListenerNotAccepting = errors.New("fsd listener is not accepting connections")
+	ErrServerDraining       = errors.New("server is shutting down")
+)
+
 type FsdCloseCallback struct {
 	clientManager fsd.ClientManagerInterface
+	timeout       time.Duration
 }
 
-func NewFsdCloseCallback(clientManager fsd.ClientManagerInterface) *FsdCloseCallback {
-	return &FsdCloseCallback{clientManager: clientManager}
+func NewFsdCloseCallback(clientManager fsd.ClientManagerInterface, timeout time.Duration) *FsdCloseCallback {
+	return &FsdCloseCallback{clientManager: clientManager, timeout: timeout}
 }
 
 func (dc *FsdCloseCallback) Invoke(ctx context.Context) error {
-	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	// 使用 fsd_server.shutdown_time 作为超时时间, 而不是清理器统一的超时时间
+	timeoutCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), dc.timeout)
 	defer cancel()
-
-	done := make(chan struct{})
-	go func() {
-		if err := dc.clientManager.Shutdown(timeoutCtx); err != nil {
-			return
-		}
-		close(done)
-	}()
-
-	select {
-	case <-done:
-		return nil
-	case <-timeoutCtx.Done():
-		return timeoutCtx.Err()
-	}
+	return dc.clientManager.Shutdown(timeoutCtx)
 }
 
+// acceptRetryDelay Accept出错后重试前的等待时间
+const acceptRetryDelay = 100 * time.Millisecond
+
 // StartFSDServer 启动FSD服务器
 func StartFSDServer(applicationContent *ApplicationContent) {
 	config := applicationContent.ConfigManager().Config()
@@ -44,6 +43,9 @@ func StartFSDServer(applicationContent *ApplicationContent) {
 
 	// 初始化客户端管理器
 	cm := packet.NewClientManager(applicationContent)
+	if err := cm.RestoreSessions(); err != nil {
+		logger.ErrorF("Fail to restore sessions: %v", err)
+	}
 
 	// 创建TCP监听器
 	sem := make(chan struct{}, config.Server.FSDServer.MaxWorkers)
@@ -54,24 +56,54 @@ func StartFSDServer(applicationContent *ApplicationContent) {
 	}
 	logger.InfoF("FSD Server Listen On " + ln.Addr().String())
 
+	// 注册就绪检查, accepting反映最近一次Accept是否成功
+	accepting := atomic.Bool{}
+	accepting.Store(true)
+	health.Register("fsd_listener", func(ctx context.Context) error {
+		if !accepting.Load() {
+			return ErrListenerNotAccepting
+		}
+		return nil
+	})
+	health.Register("shutdown", func(ctx context.Context) error {
+		if cm.ShuttingDown() || cm.Draining() {
+			return ErrServerDraining
+		}
+		return nil
+	})
+
+	watchDrainSignal(logger, cm, config.Server.FSDServer.DrainDuration)
+
 	// 确保在函数退出时关闭监听器
 	defer func() {
+		accepting.Store(false)
 		err := ln.Close()
 		if err != nil {
 			logger.ErrorF("Server close error: %v", err)
 		}
 	}()
 
-	applicationContent.Cleaner().Add(NewFsdCloseCallback(cm))
-
-	userOperation := applicationContent.Operations().UserOperation()
-	flightPlanOperation := applicationContent.Operations().FlightPlanOperation()
+	applicationContent.Cleaner().Add(NewFsdCloseCallback(cm, config.Server.FSDServer.ShutdownDuration))
 
 	// 循环接受新的连接
 	for {
 		conn, err := ln.Accept()
 		if err != nil {
+			accepting.Store(false)
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
 			logger.ErrorF("Accept connection error: %v", err)
+			// 持续出错(如文件描述符耗尽)时避免空转
+			time.Sleep(acceptRetryDelay)
+			continue
+		}
+		accepting.Store(true)
+
+		// 服务器关闭过程中不再接受新会话
+		if cm.ShuttingDown() {
+			logger.DebugF("Server shutting down, rejecting connection from %s", conn.RemoteAddr().String())
+			_ = conn.Close()
 			continue
 		}
 
@@ -82,11 +114,10 @@ func StartFSDServer(applicationContent *ApplicationContent) {
 		go func(c net.Conn) {
 			connection := packet.NewSession(
 				logger,
-				config.Server.General,
+				config,
 				conn,
 				cm,
-				userOperation,
-				flightPlanOperation,
+				applicationContent.Operations(),
 			)
 			connection.HandleConnection()
 			// 释放信号量
@@ -0,0 +1,142 @@
+// Package health 存活与就绪检查
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	StatusUp       = "up"
+	StatusDown     = "down"
+	StatusDisabled = "disabled"
+)
+
+// checkTimeout 单个组件检查的超时时间
+const checkTimeout = 3 * time.Second
+
+// ErrDisabled 组件未启用, 不影响就绪状态
+var ErrDisabled = errors.New("component disabled")
+
+// Checker 组件检查函数, 返回nil表示组件正常
+type Checker func(ctx context.Context) error
+
+type ComponentStatus struct {
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	Latency string `json:"latency"`
+}
+
+type Report struct {
+	Status     string                      `json:"status"`
+	Components map[string]*ComponentStatus `json:"components"`
+}
+
+var (
+	checkers = make(map[string]Checker)
+	mu       sync.RWMutex
+)
+
+// Register 注册就绪检查组件, 同名组件会被覆盖
+func Register(name string, checker Checker) {
+	mu.Lock()
+	defer mu.Unlock()
+	checkers[name] = checker
+}
+
+// Cached 缓存检查结果, 用于开销较大的检查(比如SMTP连接)
+func Cached(ttl time.Duration, checker Checker) Checker {
+	var (
+		lock      sync.Mutex
+		lastCheck time.Time
+		lastErr   error
+	)
+	return func(ctx context.Context) error {
+		lock.Lock()
+		defer lock.Unlock()
+		if !lastCheck.IsZero() && time.Since(lastCheck) < ttl {
+			return lastErr
+		}
+		lastErr = checker(ctx)
+		lastCheck = time.Now()
+		return lastErr
+	}
+}
+
+// Check 并发执行所有组件检查
+func Check(ctx context.Context) *Report {
+	mu.RLock()
+	names := make([]string, 0, len(checkers))
+	for name := range checkers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	snapshot := make([]Checker, len(names))
+	for i, name := range names {
+		snapshot[i] = checkers[name]
+	}
+	mu.RUnlock()
+
+	results := make([]*ComponentStatus, len(names))
+	wg := sync.WaitGroup{}
+	for i, checker := range snapshot {
+		wg.Add(1)
+		go func(i int, checker Checker) {
+			defer wg.Done()
+			timeoutCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+			start := time.Now()
+			err := checker(timeoutCtx)
+			status := &ComponentStatus{Status: StatusUp, Latency: time.Since(start).String()}
+			switch {
+			case errors.Is(err, ErrDisabled):
+				status.Status = StatusDisabled
+			case err != nil:
+				status.Status = StatusDown
+				status.Error = err.Error()
+			}
+			results[i] = status
+		}(i, checker)
+	}
+	wg.Wait()
+
+	report := &Report{Status: StatusUp, Components: make(map[string]*ComponentStatus, len(names))}
+	for i, name := range names {
+		report.Components[name] = results[i]
+		if results[i].Status == StatusDown {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+func writeJson(w http.ResponseWriter, code int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+// LivenessHandler 进程存活检查, 只要能响应就返回200
+func LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJson(w, http.StatusOK, map[string]string{"status": StatusUp})
+	})
+}
+
+// ReadinessHandler 就绪检查, 任一组件异常时返回503
+func ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := Check(r.Context())
+		code := http.StatusOK
+		if report.Status != StatusUp {
+			code = http.StatusServiceUnavailable
+		}
+		writeJson(w, code, report)
+	})
+}
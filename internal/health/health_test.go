@@ -0,0 +1,53 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func resetCheckers() {
+	mu.Lock()
+	checkers = make(map[string]Checker)
+	mu.Unlock()
+}
+
+func TestReadinessHandler(t *testing.T) {
+	resetCheckers()
+	Register("ok", func(ctx context.Context) error { return nil })
+	Register("disabled", func(ctx context.Context) error { return ErrDisabled })
+
+	recorder := httptest.NewRecorder()
+	ReadinessHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	Register("broken", func(ctx context.Context) error { return errors.New("boom") })
+	recorder = httptest.NewRecorder()
+	ReadinessHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", recorder.Code)
+	}
+
+	report := Check(context.Background())
+	if report.Components["broken"].Error != "boom" || report.Components["disabled"].Status != StatusDisabled {
+		t.Errorf("unexpected report: %+v", report.Components)
+	}
+}
+
+func TestCached(t *testing.T) {
+	calls := 0
+	checker := Cached(time.Hour, func(ctx context.Context) error {
+		calls++
+		return nil
+	})
+	_ = checker(context.Background())
+	_ = checker(context.Background())
+	if calls != 1 {
+		t.Errorf("expected 1 call, got %d", calls)
+	}
+}
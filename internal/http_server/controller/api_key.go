@@ -0,0 +1,73 @@
+// Package controller
+package controller
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/service"
+	"github.com/labstack/echo/v4"
+)
+
+type ApiKeyControllerInterface interface {
+	GetApiKeys(ctx echo.Context) error
+	CreateApiKey(ctx echo.Context) error
+	RevokeApiKey(ctx echo.Context) error
+}
+
+type ApiKeyController struct {
+	logger        log.LoggerInterface
+	apiKeyService ApiKeyServiceInterface
+}
+
+func NewApiKeyController(logger log.LoggerInterface, apiKeyService ApiKeyServiceInterface) *ApiKeyController {
+	return &ApiKeyController{
+		logger:        logger,
+		apiKeyService: apiKeyService,
+	}
+}
+
+func (controller *ApiKeyController) GetApiKeys(ctx echo.Context) error {
+	data := &RequestGetApiKeys{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("ApiKeyController.GetApiKeys bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	return controller.apiKeyService.GetApiKeys(data).Response(ctx)
+}
+
+func (controller *ApiKeyController) CreateApiKey(ctx echo.Context) error {
+	data := &RequestCreateApiKey{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("ApiKeyController.CreateApiKey bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
+	return controller.apiKeyService.CreateApiKey(data).Response(ctx)
+}
+
+func (controller *ApiKeyController) RevokeApiKey(ctx echo.Context) error {
+	data := &RequestRevokeApiKey{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("ApiKeyController.RevokeApiKey bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
+	return controller.apiKeyService.RevokeApiKey(data).Response(ctx)
+}
@@ -0,0 +1,74 @@
+// Package controller
+package controller
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/service"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+type AtcBookingControllerInterface interface {
+	GetAtcBookings(ctx echo.Context) error
+	GetAtcBookingCalendar(ctx echo.Context) error
+	AddAtcBooking(ctx echo.Context) error
+	DeleteAtcBooking(ctx echo.Context) error
+}
+
+type AtcBookingController struct {
+	logger            log.LoggerInterface
+	atcBookingService AtcBookingServiceInterface
+}
+
+func NewAtcBookingController(logger log.LoggerInterface, atcBookingService AtcBookingServiceInterface) *AtcBookingController {
+	return &AtcBookingController{
+		logger:            logger,
+		atcBookingService: atcBookingService,
+	}
+}
+
+func (controller *AtcBookingController) GetAtcBookings(ctx echo.Context) error {
+	data := &RequestGetAtcBookings{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("AtcBookingController.GetAtcBookings bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	return controller.atcBookingService.GetAtcBookings(data).Response(ctx)
+}
+
+func (controller *AtcBookingController) GetAtcBookingCalendar(ctx echo.Context) error {
+	return ctx.Blob(http.StatusOK, "text/calendar; charset=utf-8", controller.atcBookingService.GetAtcBookingCalendar())
+}
+
+func (controller *AtcBookingController) AddAtcBooking(ctx echo.Context) error {
+	data := &RequestAddAtcBooking{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("AtcBookingController.AddAtcBooking bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
+	return controller.atcBookingService.AddAtcBooking(data).Response(ctx)
+}
+
+func (controller *AtcBookingController) DeleteAtcBooking(ctx echo.Context) error {
+	data := &RequestDeleteAtcBooking{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("AtcBookingController.DeleteAtcBooking bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
+	return controller.atcBookingService.DeleteAtcBooking(data).Response(ctx)
+}
@@ -12,6 +12,7 @@ import (
 type ClientControllerInterface interface {
 	GetOnlineClients(ctx echo.Context) error
 	GetClientPath(ctx echo.Context) error
+	GetSectorCoverage(ctx echo.Context) error
 	SendMessageToClient(ctx echo.Context) error
 	KillClient(ctx echo.Context) error
 }
@@ -72,3 +73,12 @@ func (controller *ClientController) KillClient(ctx echo.Context) error {
 	data.UserAgent = ctx.Request().UserAgent()
 	return controller.clientService.KillClient(data).Response(ctx)
 }
+
+func (controller *ClientController) GetSectorCoverage(ctx echo.Context) error {
+	data := &RequestSectorCoverage{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("ClientController.GetSectorCoverage bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	return controller.clientService.GetSectorCoverage(data).Response(ctx)
+}
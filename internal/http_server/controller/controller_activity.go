@@ -0,0 +1,38 @@
+// Package controller
+package controller
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/service"
+	"github.com/labstack/echo/v4"
+)
+
+type ControllerActivityControllerInterface interface {
+	GetControllerActivity(ctx echo.Context) error
+}
+
+type ControllerActivityController struct {
+	logger          log.LoggerInterface
+	activityService ControllerActivityServiceInterface
+}
+
+func NewControllerActivityController(logger log.LoggerInterface, activityService ControllerActivityServiceInterface) *ControllerActivityController {
+	return &ControllerActivityController{
+		logger:          logger,
+		activityService: activityService,
+	}
+}
+
+func (controller *ControllerActivityController) GetControllerActivity(ctx echo.Context) error {
+	data := &RequestGetControllerActivity{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("ControllerActivityController.GetControllerActivity bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Permission = claim.Permission
+	return controller.activityService.GetControllerActivity(data).Response(ctx)
+}
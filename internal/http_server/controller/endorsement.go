@@ -0,0 +1,101 @@
+// Package controller
+package controller
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/service"
+	"github.com/labstack/echo/v4"
+)
+
+type EndorsementControllerInterface interface {
+	GetEndorsedPositions(ctx echo.Context) error
+	GetEndorsements(ctx echo.Context) error
+	GetEndorsementsPage(ctx echo.Context) error
+	AddEndorsement(ctx echo.Context) error
+	DeleteEndorsement(ctx echo.Context) error
+}
+
+type EndorsementController struct {
+	logger             log.LoggerInterface
+	endorsementService EndorsementServiceInterface
+}
+
+func NewEndorsementController(logger log.LoggerInterface, endorsementService EndorsementServiceInterface) *EndorsementController {
+	return &EndorsementController{
+		logger:             logger,
+		endorsementService: endorsementService,
+	}
+}
+
+func (controller *EndorsementController) GetEndorsedPositions(ctx echo.Context) error {
+	data := &RequestGetEndorsedPositions{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("EndorsementController.GetEndorsedPositions bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Permission = claim.Permission
+	return controller.endorsementService.GetEndorsedPositions(data).Response(ctx)
+}
+
+func (controller *EndorsementController) GetEndorsements(ctx echo.Context) error {
+	data := &RequestGetEndorsements{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("EndorsementController.GetEndorsements bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	return controller.endorsementService.GetEndorsements(data).Response(ctx)
+}
+
+func (controller *EndorsementController) GetEndorsementsPage(ctx echo.Context) error {
+	data := &RequestGetEndorsementsPage{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("EndorsementController.GetEndorsementsPage bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Permission = claim.Permission
+	return controller.endorsementService.GetEndorsementsPage(data).Response(ctx)
+}
+
+func (controller *EndorsementController) AddEndorsement(ctx echo.Context) error {
+	data := &RequestAddEndorsement{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("EndorsementController.AddEndorsement bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
+	return controller.endorsementService.AddEndorsement(data).Response(ctx)
+}
+
+func (controller *EndorsementController) DeleteEndorsement(ctx echo.Context) error {
+	data := &RequestDeleteEndorsement{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("EndorsementController.DeleteEndorsement bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
+	return controller.endorsementService.DeleteEndorsement(data).Response(ctx)
+}
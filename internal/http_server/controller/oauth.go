@@ -0,0 +1,147 @@
+// Package controller
+package controller
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/service"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strings"
+)
+
+type OAuthControllerInterface interface {
+	GetAuthorizeInfo(ctx echo.Context) error
+	Authorize(ctx echo.Context) error
+	Token(ctx echo.Context) error
+	UserInfo(ctx echo.Context) error
+	Discovery(ctx echo.Context) error
+	Jwks(ctx echo.Context) error
+	GetClients(ctx echo.Context) error
+	CreateClient(ctx echo.Context) error
+	DeleteClient(ctx echo.Context) error
+}
+
+type OAuthController struct {
+	logger       log.LoggerInterface
+	oauthService OAuthServiceInterface
+}
+
+func NewOAuthController(logger log.LoggerInterface, oauthService OAuthServiceInterface) *OAuthController {
+	return &OAuthController{
+		logger:       logger,
+		oauthService: oauthService,
+	}
+}
+
+func (controller *OAuthController) GetAuthorizeInfo(ctx echo.Context) error {
+	data := &RequestOAuthAuthorize{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("OAuthController.GetAuthorizeInfo bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Permission = claim.Permission
+	return controller.oauthService.GetAuthorizeInfo(data).Response(ctx)
+}
+
+func (controller *OAuthController) Authorize(ctx echo.Context) error {
+	data := &RequestOAuthAuthorize{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("OAuthController.Authorize bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Permission = claim.Permission
+	return controller.oauthService.Authorize(data).Response(ctx)
+}
+
+// oauthResponse 令牌相关接口按照RFC 6749返回, 不使用统一的响应格式
+func oauthResponse(ctx echo.Context, data interface{}, oauthErr *OAuthError) error {
+	ctx.Response().Header().Set("Cache-Control", "no-store")
+	ctx.Response().Header().Set("Pragma", "no-cache")
+	if oauthErr != nil {
+		if oauthErr.HttpCode == http.StatusUnauthorized {
+			ctx.Response().Header().Set("WWW-Authenticate", `Bearer error="`+oauthErr.Error+`"`)
+		}
+		return ctx.JSON(oauthErr.HttpCode, oauthErr)
+	}
+	return ctx.JSON(http.StatusOK, data)
+}
+
+func (controller *OAuthController) Token(ctx echo.Context) error {
+	data := &RequestOAuthToken{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("OAuthController.Token bind error: %v", err)
+		return oauthResponse(ctx, nil, &OAuthError{Error: "invalid_request", Description: "malformed request body", HttpCode: http.StatusBadRequest})
+	}
+	// 支持client_secret_basic认证方式
+	if clientId, clientSecret, ok := ctx.Request().BasicAuth(); ok {
+		data.ClientId = clientId
+		data.ClientSecret = clientSecret
+	}
+	result, oauthErr := controller.oauthService.Token(data)
+	return oauthResponse(ctx, result, oauthErr)
+}
+
+func (controller *OAuthController) UserInfo(ctx echo.Context) error {
+	accessToken, found := strings.CutPrefix(ctx.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+	if !found || accessToken == "" {
+		return oauthResponse(ctx, nil, &OAuthError{Error: "invalid_token", Description: "missing access token", HttpCode: http.StatusUnauthorized})
+	}
+	result, oauthErr := controller.oauthService.UserInfo(accessToken)
+	return oauthResponse(ctx, result, oauthErr)
+}
+
+func (controller *OAuthController) Discovery(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, controller.oauthService.Discovery())
+}
+
+func (controller *OAuthController) Jwks(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, controller.oauthService.Jwks())
+}
+
+func (controller *OAuthController) GetClients(ctx echo.Context) error {
+	data := &RequestGetOAuthClients{}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Permission = claim.Permission
+	return controller.oauthService.GetClients(data).Response(ctx)
+}
+
+func (controller *OAuthController) CreateClient(ctx echo.Context) error {
+	data := &RequestCreateOAuthClient{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("OAuthController.CreateClient bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
+	return controller.oauthService.CreateClient(data).Response(ctx)
+}
+
+func (controller *OAuthController) DeleteClient(ctx echo.Context) error {
+	data := &RequestDeleteOAuthClient{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("OAuthController.DeleteClient bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
+	return controller.oauthService.DeleteClient(data).Response(ctx)
+}
@@ -0,0 +1,143 @@
+// Package controller
+package controller
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/service"
+	"github.com/labstack/echo/v4"
+)
+
+type RoleControllerInterface interface {
+	GetPermissionNodes(ctx echo.Context) error
+	GetRoles(ctx echo.Context) error
+	CreateRole(ctx echo.Context) error
+	EditRole(ctx echo.Context) error
+	DeleteRole(ctx echo.Context) error
+	GetUserRoles(ctx echo.Context) error
+	AssignUserRole(ctx echo.Context) error
+	RevokeUserRole(ctx echo.Context) error
+}
+
+type RoleController struct {
+	logger      log.LoggerInterface
+	roleService RoleServiceInterface
+}
+
+func NewRoleController(logger log.LoggerInterface, roleService RoleServiceInterface) *RoleController {
+	return &RoleController{
+		logger:      logger,
+		roleService: roleService,
+	}
+}
+
+func (controller *RoleController) GetPermissionNodes(ctx echo.Context) error {
+	data := &RequestGetPermissionNodes{}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Permission = claim.Permission
+	return controller.roleService.GetPermissionNodes(data).Response(ctx)
+}
+
+func (controller *RoleController) GetRoles(ctx echo.Context) error {
+	data := &RequestGetRoles{}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Permission = claim.Permission
+	return controller.roleService.GetRoles(data).Response(ctx)
+}
+
+func (controller *RoleController) CreateRole(ctx echo.Context) error {
+	data := &RequestCreateRole{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("RoleController.CreateRole bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
+	return controller.roleService.CreateRole(data).Response(ctx)
+}
+
+func (controller *RoleController) EditRole(ctx echo.Context) error {
+	data := &RequestEditRole{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("RoleController.EditRole bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
+	return controller.roleService.EditRole(data).Response(ctx)
+}
+
+func (controller *RoleController) DeleteRole(ctx echo.Context) error {
+	data := &RequestDeleteRole{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("RoleController.DeleteRole bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
+	return controller.roleService.DeleteRole(data).Response(ctx)
+}
+
+func (controller *RoleController) GetUserRoles(ctx echo.Context) error {
+	data := &RequestGetUserRoles{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("RoleController.GetUserRoles bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Permission = claim.Permission
+	return controller.roleService.GetUserRoles(data).Response(ctx)
+}
+
+func (controller *RoleController) AssignUserRole(ctx echo.Context) error {
+	data := &RequestAssignUserRole{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("RoleController.AssignUserRole bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
+	return controller.roleService.AssignUserRole(data).Response(ctx)
+}
+
+func (controller *RoleController) RevokeUserRole(ctx echo.Context) error {
+	data := &RequestRevokeUserRole{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("RoleController.RevokeUserRole bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
+	return controller.roleService.RevokeUserRole(data).Response(ctx)
+}
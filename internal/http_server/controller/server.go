@@ -2,6 +2,7 @@
 package controller
 
 import (
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
 	. "github.com/half-nothing/simple-fsd/internal/interfaces/service"
 	"github.com/labstack/echo/v4"
@@ -11,6 +12,8 @@ type ServerControllerInterface interface {
 	GetServerConfig(ctx echo.Context) error
 	GetServerInfo(ctx echo.Context) error
 	GetServerOnlineTime(ctx echo.Context) error
+	ReloadConfig(ctx echo.Context) error
+	DrainServer(ctx echo.Context) error
 }
 
 type ServerController struct {
@@ -36,3 +39,31 @@ func (controller *ServerController) GetServerInfo(ctx echo.Context) error {
 func (controller *ServerController) GetServerOnlineTime(ctx echo.Context) error {
 	return controller.serverService.GetTimeRating().Response(ctx)
 }
+
+func (controller *ServerController) ReloadConfig(ctx echo.Context) error {
+	data := &RequestReloadConfig{}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Permission = claim.Permission
+	data.Cid = claim.Cid
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
+	return controller.serverService.ReloadConfig(data).Response(ctx)
+}
+
+func (controller *ServerController) DrainServer(ctx echo.Context) error {
+	data := &RequestDrainServer{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("ServerController.DrainServer bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Permission = claim.Permission
+	data.Cid = claim.Cid
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
+	return controller.serverService.DrainServer(data).Response(ctx)
+}
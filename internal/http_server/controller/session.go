@@ -0,0 +1,58 @@
+// Package controller
+package controller
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/service"
+	"github.com/labstack/echo/v4"
+)
+
+type SessionControllerInterface interface {
+	GetSessions(ctx echo.Context) error
+	RevokeSession(ctx echo.Context) error
+	RevokeOtherSessions(ctx echo.Context) error
+}
+
+type SessionController struct {
+	logger         log.LoggerInterface
+	sessionService SessionServiceInterface
+}
+
+func NewSessionController(logger log.LoggerInterface, sessionService SessionServiceInterface) *SessionController {
+	return &SessionController{
+		logger:         logger,
+		sessionService: sessionService,
+	}
+}
+
+func (controller *SessionController) GetSessions(ctx echo.Context) error {
+	data := &RequestGetSessions{}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Cid = claim.Cid
+	data.SessionId = claim.ID
+	return controller.sessionService.GetSessions(data).Response(ctx)
+}
+
+func (controller *SessionController) RevokeSession(ctx echo.Context) error {
+	data := &RequestRevokeSession{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("SessionController.RevokeSession bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Cid = claim.Cid
+	data.SessionId = claim.ID
+	return controller.sessionService.RevokeSession(data).Response(ctx)
+}
+
+func (controller *SessionController) RevokeOtherSessions(ctx echo.Context) error {
+	data := &RequestRevokeOtherSessions{}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Cid = claim.Cid
+	data.SessionId = claim.ID
+	return controller.sessionService.RevokeOtherSessions(data).Response(ctx)
+}
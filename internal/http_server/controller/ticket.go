@@ -0,0 +1,136 @@
+// Package controller
+package controller
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/service"
+	"github.com/labstack/echo/v4"
+)
+
+type TicketControllerInterface interface {
+	GetTickets(ctx echo.Context) error
+	GetTicketsPage(ctx echo.Context) error
+	GetTicket(ctx echo.Context) error
+	AddTicket(ctx echo.Context) error
+	ReplyTicket(ctx echo.Context) error
+	AssignTicket(ctx echo.Context) error
+	CloseTicket(ctx echo.Context) error
+}
+
+type TicketController struct {
+	logger        log.LoggerInterface
+	ticketService TicketServiceInterface
+}
+
+func NewTicketController(logger log.LoggerInterface, ticketService TicketServiceInterface) *TicketController {
+	return &TicketController{
+		logger:        logger,
+		ticketService: ticketService,
+	}
+}
+
+func (controller *TicketController) GetTickets(ctx echo.Context) error {
+	data := &RequestGetTickets{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("TicketController.GetTickets bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	return controller.ticketService.GetTickets(data).Response(ctx)
+}
+
+func (controller *TicketController) GetTicketsPage(ctx echo.Context) error {
+	data := &RequestGetTicketsPage{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("TicketController.GetTicketsPage bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Permission = claim.Permission
+	return controller.ticketService.GetTicketsPage(data).Response(ctx)
+}
+
+func (controller *TicketController) GetTicket(ctx echo.Context) error {
+	data := &RequestGetTicket{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("TicketController.GetTicket bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	return controller.ticketService.GetTicket(data).Response(ctx)
+}
+
+func (controller *TicketController) AddTicket(ctx echo.Context) error {
+	data := &RequestAddTicket{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("TicketController.AddTicket bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
+	return controller.ticketService.AddTicket(data).Response(ctx)
+}
+
+func (controller *TicketController) ReplyTicket(ctx echo.Context) error {
+	data := &RequestReplyTicket{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("TicketController.ReplyTicket bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
+	return controller.ticketService.ReplyTicket(data).Response(ctx)
+}
+
+func (controller *TicketController) AssignTicket(ctx echo.Context) error {
+	data := &RequestAssignTicket{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("TicketController.AssignTicket bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
+	return controller.ticketService.AssignTicket(data).Response(ctx)
+}
+
+func (controller *TicketController) CloseTicket(ctx echo.Context) error {
+	data := &RequestCloseTicket{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("TicketController.CloseTicket bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
+	return controller.ticketService.CloseTicket(data).Response(ctx)
+}
@@ -0,0 +1,202 @@
+// Package controller
+package controller
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/service"
+	"github.com/labstack/echo/v4"
+)
+
+type TrainingControllerInterface interface {
+	GetTrainings(ctx echo.Context) error
+	GetTrainingsPage(ctx echo.Context) error
+	GetTraining(ctx echo.Context) error
+	GetTrainingHistory(ctx echo.Context) error
+	AddTraining(ctx echo.Context) error
+	AssignMentor(ctx echo.Context) error
+	AddTrainingReport(ctx echo.Context) error
+	GrantSolo(ctx echo.Context) error
+	RevokeSolo(ctx echo.Context) error
+	CompleteTraining(ctx echo.Context) error
+	CancelTraining(ctx echo.Context) error
+}
+
+type TrainingController struct {
+	logger          log.LoggerInterface
+	trainingService TrainingServiceInterface
+}
+
+func NewTrainingController(logger log.LoggerInterface, trainingService TrainingServiceInterface) *TrainingController {
+	return &TrainingController{
+		logger:          logger,
+		trainingService: trainingService,
+	}
+}
+
+func (controller *TrainingController) GetTrainings(ctx echo.Context) error {
+	data := &RequestGetTrainings{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("TrainingController.GetTrainings bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	return controller.trainingService.GetTrainings(data).Response(ctx)
+}
+
+func (controller *TrainingController) GetTrainingsPage(ctx echo.Context) error {
+	data := &RequestGetTrainingsPage{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("TrainingController.GetTrainingsPage bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Permission = claim.Permission
+	return controller.trainingService.GetTrainingsPage(data).Response(ctx)
+}
+
+func (controller *TrainingController) GetTraining(ctx echo.Context) error {
+	data := &RequestGetTraining{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("TrainingController.GetTraining bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	return controller.trainingService.GetTraining(data).Response(ctx)
+}
+
+func (controller *TrainingController) GetTrainingHistory(ctx echo.Context) error {
+	data := &RequestGetTraining{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("TrainingController.GetTrainingHistory bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	return controller.trainingService.GetTrainingHistory(data).Response(ctx)
+}
+
+func (controller *TrainingController) AddTraining(ctx echo.Context) error {
+	data := &RequestAddTraining{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("TrainingController.AddTraining bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
+	return controller.trainingService.AddTraining(data).Response(ctx)
+}
+
+func (controller *TrainingController) AssignMentor(ctx echo.Context) error {
+	data := &RequestAssignMentor{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("TrainingController.AssignMentor bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
+	return controller.trainingService.AssignMentor(data).Response(ctx)
+}
+
+func (controller *TrainingController) AddTrainingReport(ctx echo.Context) error {
+	data := &RequestAddTrainingReport{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("TrainingController.AddTrainingReport bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
+	return controller.trainingService.AddTrainingReport(data).Response(ctx)
+}
+
+func (controller *TrainingController) GrantSolo(ctx echo.Context) error {
+	data := &RequestGrantSolo{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("TrainingController.GrantSolo bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
+	return controller.trainingService.GrantSolo(data).Response(ctx)
+}
+
+func (controller *TrainingController) RevokeSolo(ctx echo.Context) error {
+	data := &RequestRevokeSolo{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("TrainingController.RevokeSolo bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
+	return controller.trainingService.RevokeSolo(data).Response(ctx)
+}
+
+func (controller *TrainingController) CompleteTraining(ctx echo.Context) error {
+	data := &RequestCompleteTraining{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("TrainingController.CompleteTraining bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
+	return controller.trainingService.CompleteTraining(data).Response(ctx)
+}
+
+func (controller *TrainingController) CancelTraining(ctx echo.Context) error {
+	data := &RequestCancelTraining{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("TrainingController.CancelTraining bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
+	return controller.trainingService.CancelTraining(data).Response(ctx)
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
 	. "github.com/half-nothing/simple-fsd/internal/interfaces/service"
 	"github.com/labstack/echo/v4"
+	"net/http"
 )
 
 type UserControllerInterface interface {
@@ -22,6 +23,14 @@ type UserControllerInterface interface {
 	EditUserRating(ctx echo.Context) error
 	GetUserHistory(ctx echo.Context) error
 	GetToken(ctx echo.Context) error
+	SendPasswordResetCode(ctx echo.Context) error
+	ResetPassword(ctx echo.Context) error
+	SetupTotp(ctx echo.Context) error
+	ConfirmTotp(ctx echo.Context) error
+	DisableTotp(ctx echo.Context) error
+	RegenerateRecoveryCodes(ctx echo.Context) error
+	GetExternalLoginUrl(ctx echo.Context) error
+	ExternalLogin(ctx echo.Context) error
 }
 
 type UserController struct {
@@ -42,6 +51,8 @@ func (controller *UserController) UserRegister(ctx echo.Context) error {
 		controller.logger.ErrorF("UserController.UserRegister bind error: %v", err)
 		return NewErrorResponse(ctx, &ErrLackParam)
 	}
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
 	return controller.service.UserRegister(data).Response(ctx)
 }
 
@@ -51,6 +62,8 @@ func (controller *UserController) UserLogin(ctx echo.Context) error {
 		controller.logger.ErrorF("UserController.UserLogin bind error: %v", err)
 		return NewErrorResponse(ctx, &ErrLackParam)
 	}
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
 	return controller.service.UserLogin(data).Response(ctx)
 }
 
@@ -80,6 +93,7 @@ func (controller *UserController) EditCurrentProfile(ctx echo.Context) error {
 	claim := token.Claims.(*Claims)
 	data.ID = claim.Uid
 	data.Cid = claim.Cid
+	data.SessionId = claim.ID
 	return controller.service.EditCurrentProfile(data).Response(ctx)
 }
 
@@ -185,5 +199,122 @@ func (controller *UserController) GetToken(ctx echo.Context) error {
 	token := ctx.Get("user").(*jwt.Token)
 	claim := token.Claims.(*Claims)
 	data.Claims = claim
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
 	return controller.service.GetTokenWithFlushToken(data).Response(ctx)
 }
+
+func (controller *UserController) SendPasswordResetCode(ctx echo.Context) error {
+	data := &RequestPasswordResetCode{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("UserController.SendPasswordResetCode bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	return controller.service.SendPasswordResetCode(data).Response(ctx)
+}
+
+func (controller *UserController) ResetPassword(ctx echo.Context) error {
+	data := &RequestResetPassword{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("UserController.ResetPassword bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
+	return controller.service.ResetPassword(data).Response(ctx)
+}
+
+func (controller *UserController) SetupTotp(ctx echo.Context) error {
+	data := &RequestSetupTotp{}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Permission = claim.Permission
+	return controller.service.SetupTotp(data).Response(ctx)
+}
+
+func (controller *UserController) ConfirmTotp(ctx echo.Context) error {
+	data := &RequestConfirmTotp{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("UserController.ConfirmTotp bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
+	return controller.service.ConfirmTotp(data).Response(ctx)
+}
+
+func (controller *UserController) DisableTotp(ctx echo.Context) error {
+	data := &RequestDisableTotp{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("UserController.DisableTotp bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Cid = claim.Cid
+	data.Permission = claim.Permission
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
+	return controller.service.DisableTotp(data).Response(ctx)
+}
+
+func (controller *UserController) RegenerateRecoveryCodes(ctx echo.Context) error {
+	data := &RequestRegenerateRecoveryCodes{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("UserController.RegenerateRecoveryCodes bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	token := ctx.Get("user").(*jwt.Token)
+	claim := token.Claims.(*Claims)
+	data.Uid = claim.Uid
+	data.Permission = claim.Permission
+	return controller.service.RegenerateRecoveryCodes(data).Response(ctx)
+}
+
+// externalLoginCookie 保存外部登录的浏览器绑定, 防止state被其他浏览器使用
+const externalLoginCookie = "external_login"
+
+func setExternalLoginCookie(ctx echo.Context, value string, maxAge int) {
+	ctx.SetCookie(&http.Cookie{
+		Name:     externalLoginCookie,
+		Value:    value,
+		Path:     "/api/sessions/external",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   ctx.Scheme() == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (controller *UserController) GetExternalLoginUrl(ctx echo.Context) error {
+	res := controller.service.GetExternalLoginUrl(&RequestExternalLoginUrl{})
+	if res.Data != nil {
+		setExternalLoginCookie(ctx, res.Data.Binding, 600)
+	}
+	return res.Response(ctx)
+}
+
+func (controller *UserController) ExternalLogin(ctx echo.Context) error {
+	data := &RequestExternalLogin{}
+	if err := ctx.Bind(data); err != nil {
+		controller.logger.ErrorF("UserController.ExternalLogin bind error: %v", err)
+		return NewErrorResponse(ctx, &ErrLackParam)
+	}
+	if cookie, err := ctx.Cookie(externalLoginCookie); err == nil {
+		data.Binding = cookie.Value
+	}
+	data.Ip = ctx.RealIP()
+	data.UserAgent = ctx.Request().UserAgent()
+	res := controller.service.ExternalLogin(data)
+	if res.Data != nil {
+		setExternalLoginCookie(ctx, "", -1)
+	}
+	return res.Response(ctx)
+}
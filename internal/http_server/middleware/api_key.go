@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/service"
+	"github.com/labstack/echo/v4"
+	"time"
+)
+
+// ApiKeyHeader 携带API密钥的请求头
+const ApiKeyHeader = "X-API-Key"
+
+// apiKeyTouchInterval 密钥最后使用时间的最小更新间隔, 避免每个请求都写数据库
+const apiKeyTouchInterval = time.Minute
+
+// HasApiKey 请求是否携带了API密钥
+func HasApiKey(c echo.Context) bool {
+	return c.Request().Header.Get(ApiKeyHeader) != ""
+}
+
+// ApiKeyMiddleware 创建API密钥认证中间件, 认证通过后以与JWT中间件相同的形式写入令牌声明
+// 声明中的权限为密钥权限范围与持有人当前有效权限的交集
+// 每个密钥使用limiter中单独的限流桶, 无效密钥按客户端IP计入限流
+// 密钥验证通过后撤销该请求在ipLimiter中的计数, 因此只有运行本中间件的接口才不受IP限流
+// 密钥不能访问deniedPrefixes开头的接口
+func ApiKeyMiddleware(
+	logger log.LoggerInterface,
+	apiKeyOperation operation.ApiKeyOperationInterface,
+	userOperation operation.UserOperationInterface,
+	limiter *SlidingWindowLimiter,
+	ipLimiter *SlidingWindowLimiter,
+	ipKeyFunc func(c echo.Context) string,
+	deniedPrefixes ...string,
+) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if hasAnyPrefix(c.Path(), deniedPrefixes) {
+				return service.NewErrorResponse(c, &service.ErrApiKeyNotAllowed)
+			}
+			key, err := apiKeyOperation.GetApiKeyByPlain(c.Request().Header.Get(ApiKeyHeader))
+			if err != nil && !errors.Is(err, operation.ErrApiKeyNotFound) {
+				logger.ErrorF("Fail to get api key, detail: %v", err)
+				return service.NewErrorResponse(c, &service.ErrDatabaseFail)
+			}
+			if err != nil || !key.Active() {
+				if !limiter.Allow("invalid|" + c.RealIP()) {
+					return rateLimitExceeded(c)
+				}
+				return service.NewErrorResponse(c, &service.ErrApiKeyInvalid)
+			}
+			if !limiter.AllowWithLimit(fmt.Sprintf("key|%d", key.ID), key.RateLimit) {
+				return rateLimitExceeded(c)
+			}
+			ipLimiter.Release(ipKeyFunc(c))
+			user, err := userOperation.GetUserByCid(key.Cid)
+			if err != nil {
+				if errors.Is(err, operation.ErrUserNotFound) {
+					return service.NewErrorResponse(c, &service.ErrApiKeyInvalid)
+				}
+				logger.ErrorF("Fail to get owner %04d of api key %d, detail: %v", key.Cid, key.ID, err)
+				return service.NewErrorResponse(c, &service.ErrDatabaseFail)
+			}
+			// 封禁包括永久封禁的管制等级与临时封禁
+			if user.Rating == fsd.Ban.Index() || user.Banned() {
+				return service.NewErrorResponse(c, &service.ErrApiKeyInvalid)
+			}
+			if key.LastUsedAt == nil || time.Since(*key.LastUsedAt) > apiKeyTouchInterval {
+				ip := c.RealIP()
+				go func() {
+					if err := apiKeyOperation.TouchApiKey(key, ip); err != nil {
+						logger.ErrorF("Fail to update api key %d, detail: %v", key.ID, err)
+					}
+				}()
+			}
+			c.Set("user", &jwt.Token{Valid: true, Claims: service.NewApiKeyClaims(user, key)})
+			return next(c)
+		}
+	}
+}
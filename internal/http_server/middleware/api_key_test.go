@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testLogger struct {
+	log.LoggerInterface
+}
+
+func (testLogger) ErrorF(string, ...interface{}) {}
+
+type testApiKeyOperation struct {
+	operation.ApiKeyOperationInterface
+	key *operation.ApiKey
+}
+
+func (op *testApiKeyOperation) GetApiKeyByPlain(plain string) (*operation.ApiKey, error) {
+	if plain != "valid" {
+		return nil, operation.ErrApiKeyNotFound
+	}
+	return op.key, nil
+}
+
+type testUserOperation struct {
+	operation.UserOperationInterface
+	user operation.User
+}
+
+func (op testUserOperation) GetUserByCid(cid int) (*operation.User, error) {
+	user := op.user
+	user.Cid = cid
+	return &user, nil
+}
+
+// newTestServer 每个IP每个接口在窗口内只允许2个请求, /private需要API密钥, /public不需要
+func newTestServer() *echo.Echo {
+	return newTestServerWithOwner(operation.User{})
+}
+
+// newTestServerWithOwner 与newTestServer相同, 密钥持有人的信息由owner指定
+func newTestServerWithOwner(owner operation.User) *echo.Echo {
+	now := time.Now()
+	ipLimiter := NewSlidingWindowLimiter(time.Minute, 2)
+	apiKeyLimiter := NewSlidingWindowLimiter(time.Minute, 100)
+	apiKeyOperation := &testApiKeyOperation{key: &operation.ApiKey{ID: 1, Cid: 1000, LastUsedAt: &now}}
+	e := echo.New()
+	e.Use(RateLimitMiddleware(ipLimiter, CombinedKeyFunc))
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	e.GET("/public", ok)
+	e.GET("/private", ok, ApiKeyMiddleware(testLogger{}, apiKeyOperation, testUserOperation{user: owner}, apiKeyLimiter, ipLimiter, CombinedKeyFunc))
+	return e
+}
+
+func requestStatus(e *echo.Echo, path string, apiKey string) int {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set(ApiKeyHeader, apiKey)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestApiKeySkipsIpLimitAfterValidation(t *testing.T) {
+	e := newTestServer()
+	for i := 0; i < 5; i++ {
+		if code := requestStatus(e, "/private", "valid"); code != http.StatusOK {
+			t.Fatalf("request %d with valid key: expected 200, got %d", i, code)
+		}
+	}
+}
+
+func TestApiKeyHeaderDoesNotBypassIpLimit(t *testing.T) {
+	e := newTestServer()
+	// 不支持密钥的接口即使携带有效密钥也按IP限流
+	for i := 0; i < 2; i++ {
+		if code := requestStatus(e, "/public", "valid"); code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i, code)
+		}
+	}
+	if code := requestStatus(e, "/public", "valid"); code != http.StatusTooManyRequests {
+		t.Errorf("expected public route limited by ip, got %d", code)
+	}
+
+	// 无效密钥同样按IP限流
+	for i := 0; i < 2; i++ {
+		requestStatus(e, "/private", "invalid")
+	}
+	if code := requestStatus(e, "/private", "invalid"); code != http.StatusTooManyRequests {
+		t.Errorf("expected invalid key limited by ip, got %d", code)
+	}
+}
+
+func TestApiKeyOwnerBanned(t *testing.T) {
+	until := time.Now().Add(time.Hour)
+	expired := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name     string
+		owner    operation.User
+		expected int
+	}{
+		{"normal", operation.User{Rating: fsd.Normal.Index()}, http.StatusOK},
+		{"ban rating", operation.User{Rating: fsd.Ban.Index()}, http.StatusUnauthorized},
+		{"temporary ban", operation.User{Rating: fsd.Normal.Index(), BannedUntil: &until}, http.StatusUnauthorized},
+		{"expired ban", operation.User{Rating: fsd.Normal.Index(), BannedUntil: &expired}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := requestStatus(newTestServerWithOwner(tt.owner), "/private", "valid"); code != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, code)
+			}
+		})
+	}
+}
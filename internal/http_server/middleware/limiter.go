@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"github.com/half-nothing/simple-fsd/internal/metrics"
 	"net/http"
 	"sync"
 	"time"
@@ -25,15 +26,32 @@ func NewSlidingWindowLimiter(windowSize time.Duration, maxRequests int) *Sliding
 	}
 }
 
+// SetLimit 更新限流参数, 用于配置热重载
+func (l *SlidingWindowLimiter) SetLimit(windowSize time.Duration, maxRequests int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.windowSize = windowSize
+	l.maxRequests = maxRequests
+}
+
 // Allow 检查是否允许请求
 func (l *SlidingWindowLimiter) Allow(key string) bool {
+	return l.AllowWithLimit(key, 0)
+}
+
+// AllowWithLimit 使用单独的请求数上限检查是否允许请求, maxRequests不大于0时使用默认上限
+func (l *SlidingWindowLimiter) AllowWithLimit(key string, maxRequests int) bool {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	if maxRequests <= 0 {
+		maxRequests = l.maxRequests
+	}
+
 	now := time.Now()
 
 	if _, exists := l.requestRecords[key]; !exists {
-		l.requestRecords[key] = make([]time.Time, 0, l.maxRequests*2)
+		l.requestRecords[key] = make([]time.Time, 0, maxRequests*2)
 	}
 
 	windowStart := now.Add(-l.windowSize)
@@ -42,7 +60,7 @@ func (l *SlidingWindowLimiter) Allow(key string) bool {
 		records = records[1:]
 	}
 
-	if len(records) >= l.maxRequests {
+	if len(records) >= maxRequests {
 		l.requestRecords[key] = records
 		return false
 	}
@@ -52,6 +70,15 @@ func (l *SlidingWindowLimiter) Allow(key string) bool {
 	return true
 }
 
+// Release 撤销key最近一次被允许的请求, 用于已由其他限流桶计数的请求
+func (l *SlidingWindowLimiter) Release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if records := l.requestRecords[key]; len(records) > 0 {
+		l.requestRecords[key] = records[:len(records)-1]
+	}
+}
+
 func (l *SlidingWindowLimiter) StartCleanup(interval time.Duration) {
 	ticker := time.NewTicker(interval)
 	go func() {
@@ -75,18 +102,20 @@ func (l *SlidingWindowLimiter) cleanup() {
 	}
 }
 
-// RateLimitMiddleware 创建 Echo 限流中间件
-func RateLimitMiddleware(limiter *SlidingWindowLimiter, keyFunc func(c echo.Context) string) echo.MiddlewareFunc {
+// RateLimitMiddleware 创建 Echo 限流中间件, 任意skipper返回true时跳过限流
+func RateLimitMiddleware(limiter *SlidingWindowLimiter, keyFunc func(c echo.Context) string, skippers ...func(c echo.Context) bool) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
+			for _, skipper := range skippers {
+				if skipper(c) {
+					return next(c)
+				}
+			}
+
 			key := keyFunc(c)
 
 			if !limiter.Allow(key) {
-				return c.JSON(http.StatusTooManyRequests, map[string]interface{}{
-					"code":    "RATE_LIMIT_EXCEEDED",
-					"message": "请求次数过多, 请稍后再试",
-					"data":    nil,
-				})
+				return rateLimitExceeded(c)
 			}
 
 			return next(c)
@@ -94,6 +123,15 @@ func RateLimitMiddleware(limiter *SlidingWindowLimiter, keyFunc func(c echo.Cont
 	}
 }
 
+func rateLimitExceeded(c echo.Context) error {
+	metrics.RateLimitRejected.Inc()
+	return c.JSON(http.StatusTooManyRequests, map[string]interface{}{
+		"code":    "RATE_LIMIT_EXCEEDED",
+		"message": "请求次数过多, 请稍后再试",
+		"data":    nil,
+	})
+}
+
 // IPKeyFunc 基于客户端IP生成键
 func IPKeyFunc(c echo.Context) string {
 	return c.RealIP()
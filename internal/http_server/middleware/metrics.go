@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"errors"
+	"github.com/half-nothing/simple-fsd/internal/metrics"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MetricsMiddleware 按路由统计请求次数与耗时, 使用路由模板而不是实际路径, 避免标签数量膨胀
+func MetricsMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+
+			status := c.Response().Status
+			if err != nil {
+				var httpError *echo.HTTPError
+				if errors.As(err, &httpError) {
+					status = httpError.Code
+				} else {
+					status = http.StatusInternalServerError
+				}
+			}
+			route := c.Path()
+			if route == "" {
+				route = "unknown"
+			}
+			method := c.Request().Method
+			metrics.HttpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+			metrics.HttpRequestDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+			return err
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/service"
+	"github.com/labstack/echo/v4"
+	"strings"
+	"time"
+)
+
+// sessionTouchInterval 会话最后使用时间的最小更新间隔, 避免每个请求都写数据库
+const sessionTouchInterval = time.Minute
+
+// SessionMiddleware 创建会话校验中间件, 需要放在JWT中间件之后
+// 令牌对应的会话不存在、已撤销、已过期或不属于令牌持有人时拒绝请求
+// 需要启用两步验证的会话只能访问totpPendingPrefixes开头的接口
+func SessionMiddleware(logger log.LoggerInterface, sessionOperation operation.UserSessionOperationInterface, totpPendingPrefixes ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token, ok := c.Get("user").(*jwt.Token)
+			if !ok {
+				return service.NewErrorResponse(c, &service.ErrMissingOrMalformedJwt)
+			}
+			claims, ok := token.Claims.(*service.Claims)
+			if !ok || claims.ID == "" {
+				return service.NewErrorResponse(c, &service.ErrSessionRevoked)
+			}
+			session, err := sessionOperation.GetUserSessionByJti(claims.ID)
+			if err != nil {
+				if errors.Is(err, operation.ErrUserSessionNotFound) {
+					return service.NewErrorResponse(c, &service.ErrSessionRevoked)
+				}
+				logger.ErrorF("Fail to get session %s, detail: %v", claims.ID, err)
+				return service.NewErrorResponse(c, &service.ErrDatabaseFail)
+			}
+			if !session.Active() || session.Cid != claims.Cid {
+				return service.NewErrorResponse(c, &service.ErrSessionRevoked)
+			}
+			if session.TotpPending && !hasAnyPrefix(c.Path(), totpPendingPrefixes) {
+				return service.NewErrorResponse(c, &service.ErrTotpEnrolmentRequired)
+			}
+			if time.Since(session.LastUsedAt) > sessionTouchInterval {
+				ip := c.RealIP()
+				go func() {
+					if err := sessionOperation.TouchUserSession(session, ip, time.Time{}); err != nil {
+						logger.ErrorF("Fail to update session %d, detail: %v", session.ID, err)
+					}
+				}()
+			}
+			return next(c)
+		}
+	}
+}
+
+func hasAnyPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// StartSessionCleanup 定期删除已过期的会话记录
+func StartSessionCleanup(logger log.LoggerInterface, sessionOperation operation.UserSessionOperationInterface, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			if err := sessionOperation.DeleteExpiredUserSessions(time.Now()); err != nil {
+				logger.ErrorF("Fail to delete expired sessions, detail: %v", err)
+			}
+		}
+	}()
+}
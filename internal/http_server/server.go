@@ -7,12 +7,15 @@ import (
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/half-nothing/simple-fsd/internal/fsd_server/packet"
+	"github.com/half-nothing/simple-fsd/internal/health"
 	"github.com/half-nothing/simple-fsd/internal/http_server/controller"
 	mid "github.com/half-nothing/simple-fsd/internal/http_server/middleware"
 	impl "github.com/half-nothing/simple-fsd/internal/http_server/service"
 	"github.com/half-nothing/simple-fsd/internal/http_server/service/store"
 	. "github.com/half-nothing/simple-fsd/internal/interfaces"
+	c "github.com/half-nothing/simple-fsd/internal/interfaces/config"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/service"
+	"github.com/half-nothing/simple-fsd/internal/metrics"
 	"github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -40,6 +43,29 @@ func (hc *HttpServerShutdownCallback) Invoke(ctx context.Context) error {
 	return hc.serverHandler.Shutdown(timeoutCtx)
 }
 
+// smtpChecker 检查SMTP服务器是否可以连接, 未配置邮件服务器时返回 health.ErrDisabled
+func smtpChecker(emailConfig *c.EmailConfig) health.Checker {
+	return func(ctx context.Context) error {
+		if emailConfig.EmailServer == nil {
+			return health.ErrDisabled
+		}
+		result := make(chan error, 1)
+		go func() {
+			sender, err := emailConfig.EmailServer.Dial()
+			if err == nil {
+				err = sender.Close()
+			}
+			result <- err
+		}()
+		select {
+		case err := <-result:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
 func StartHttpServer(applicationContent *ApplicationContent) {
 	config := applicationContent.ConfigManager().Config()
 	logger := applicationContent.Logger()
@@ -94,29 +120,23 @@ func StartHttpServer(applicationContent *ApplicationContent) {
 		Level: 5,
 	}))
 
-	if httpConfig.Limits.RateLimit <= 0 {
-		logger.WarnF("Invalid rate limit value %d, using default 15", httpConfig.Limits.RateLimit)
-		httpConfig.Limits.RateLimit = 15
-	}
-
-	if httpConfig.Limits.RateLimitDuration <= 0 {
-		logger.WarnF("Invalid rate limit duration %v, using default 1m", httpConfig.Limits.RateLimitDuration)
-		httpConfig.Limits.RateLimitDuration = time.Minute
-	}
-
-	ipPathLimiter := mid.NewSlidingWindowLimiter(
-		httpConfig.Limits.RateLimitDuration,
-		httpConfig.Limits.RateLimit,
-	)
-	cleanupInterval := httpConfig.Limits.RateLimitDuration * 2
+	rateLimit := httpConfig.Limits.CurrentRateLimit()
+	ipPathLimiter := mid.NewSlidingWindowLimiter(rateLimit.Window, rateLimit.Limit)
+	cleanupInterval := rateLimit.Window * 2
 	if cleanupInterval > time.Hour {
 		cleanupInterval = time.Hour
 		logger.InfoF("Limiting cleanup interval to 1 hour for efficiency")
 	}
 	ipPathLimiter.StartCleanup(cleanupInterval)
+	applicationContent.ConfigManager().OnReload(func(config *c.Config) {
+		rule := config.Server.HttpServer.Limits.CurrentRateLimit()
+		ipPathLimiter.SetLimit(rule.Window, rule.Limit)
+	})
 
 	whazzupContent := fmt.Sprintf("url0=%s/api/clients", httpConfig.ServerAddress)
 
+	e.Use(mid.MetricsMiddleware())
+	// 携带有效API密钥的请求在密钥验证通过后改用密钥自己的限流桶
 	e.Use(mid.RateLimitMiddleware(ipPathLimiter, mid.CombinedKeyFunc))
 
 	jwtConfig := echojwt.Config{
@@ -140,7 +160,32 @@ func StartHttpServer(applicationContent *ApplicationContent) {
 		},
 	}
 
-	jwtMiddleware := echojwt.WithConfig(jwtConfig)
+	userSessionOperation := applicationContent.Operations().UserSessionOperation()
+	jwtAuth := echojwt.WithConfig(jwtConfig)
+	// 需要启用两步验证的会话只能访问个人信息与会话相关接口
+	sessionCheck := mid.SessionMiddleware(logger, userSessionOperation, "/api/profile", "/api/sessions")
+	jwtMiddleware := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return jwtAuth(sessionCheck(next))
+	}
+	mid.StartSessionCleanup(logger, userSessionOperation, time.Hour)
+
+	if apiKeyConfig := httpConfig.ApiKey; apiKeyConfig.Enabled {
+		apiKeyLimiter := mid.NewSlidingWindowLimiter(apiKeyConfig.RateLimitDuration, apiKeyConfig.RateLimit)
+		apiKeyLimiter.StartCleanup(min(apiKeyConfig.RateLimitDuration*2, time.Hour))
+		// API密钥不能用于刷新令牌, 修改个人信息与管理密钥, 也不能授权第三方应用
+		apiKeyAuth := mid.ApiKeyMiddleware(logger, applicationContent.Operations().ApiKeyOperation(), applicationContent.Operations().UserOperation(),
+			apiKeyLimiter, ipPathLimiter, mid.CombinedKeyFunc, "/api/profile", "/api/sessions", "/api/keys", "/api/oauth/authorize")
+		tokenMiddleware := jwtMiddleware
+		jwtMiddleware = func(next echo.HandlerFunc) echo.HandlerFunc {
+			withToken, withApiKey := tokenMiddleware(next), apiKeyAuth(next)
+			return func(c echo.Context) error {
+				if mid.HasApiKey(c) {
+					return withApiKey(c)
+				}
+				return withToken(c)
+			}
+		}
+	}
 
 	emailService := impl.NewEmailService(logger, config.Server.HttpServer.Email)
 	impl.InitValidator(config.Server.HttpServer.Limits)
@@ -158,13 +203,31 @@ func StartHttpServer(applicationContent *ApplicationContent) {
 	historyOperation := applicationContent.Operations().HistoryOperation()
 	auditLogOperation := applicationContent.Operations().AuditLogOperation()
 	activityOperation := applicationContent.Operations().ActivityOperation()
+	atcBookingOperation := applicationContent.Operations().AtcBookingOperation()
+	ticketOperation := applicationContent.Operations().TicketOperation()
 
-	userService := impl.NewUserService(logger, httpConfig, userOperation, historyOperation, auditLogOperation, storeService, emailService)
+	userService := impl.NewUserService(logger, httpConfig, userOperation, historyOperation, auditLogOperation, userSessionOperation,
+		applicationContent.Operations().ExternalIdentityOperation(), applicationContent.Operations().TrainingOperation(), storeService, emailService)
 	clientManager := packet.NewClientManager(applicationContent)
+	clientManager.SetEmailService(emailService)
 	clientService := impl.NewClientService(logger, httpConfig, userOperation, auditLogOperation, clientManager, emailService)
-	serverService := impl.NewServerService(logger, config.Server, userOperation, activityOperation)
+	serverService := impl.NewServerService(logger, config.Server, userOperation, activityOperation, auditLogOperation, applicationContent.ConfigManager(), clientManager)
 	activityService := impl.NewActivityService(logger, httpConfig, userOperation, activityOperation, auditLogOperation, storeService)
 	auditLogService := impl.NewAuditService(logger, auditLogOperation)
+	atcBookingService := impl.NewAtcBookingService(logger, config.Server, userOperation, atcBookingOperation, auditLogOperation)
+	ticketService := impl.NewTicketService(logger, httpConfig, userOperation, ticketOperation, auditLogOperation, emailService)
+	sessionService := impl.NewSessionService(logger, userSessionOperation)
+	oauthService := impl.NewOAuthService(logger, httpConfig, userOperation, applicationContent.Operations().OAuthClientOperation(), auditLogOperation)
+	roleService := impl.NewRoleService(logger, userOperation, applicationContent.Operations().RoleOperation(), userSessionOperation, auditLogOperation)
+	apiKeyService := impl.NewApiKeyService(logger, httpConfig.ApiKey, userOperation, applicationContent.Operations().ApiKeyOperation(), auditLogOperation)
+	trainingService := impl.NewTrainingService(logger, httpConfig, userOperation, historyOperation, applicationContent.Operations().TrainingOperation(),
+		userSessionOperation, auditLogOperation, emailService)
+	endorsementService := impl.NewEndorsementService(logger, config.Server, userOperation, applicationContent.Operations().PositionEndorsementOperation(), auditLogOperation)
+	controllerActivityService := impl.NewControllerActivityService(logger, httpConfig, userOperation, historyOperation, applicationContent.Operations().ControllerInactivityOperation(),
+		userSessionOperation, auditLogOperation, emailService, clientManager)
+	if httpConfig.Inactivity.Enabled {
+		controllerActivityService.StartCheck(httpConfig.Inactivity.CheckDuration)
+	}
 
 	userController := controller.NewUserHandler(logger, userService)
 	emailController := controller.NewEmailController(logger, emailService)
@@ -173,6 +236,31 @@ func StartHttpServer(applicationContent *ApplicationContent) {
 	activityController := controller.NewActivityController(logger, activityService)
 	fileController := controller.NewFileController(logger, storeService)
 	auditLogController := controller.NewAuditLogController(logger, auditLogService)
+	atcBookingController := controller.NewAtcBookingController(logger, atcBookingService)
+	ticketController := controller.NewTicketController(logger, ticketService)
+	sessionController := controller.NewSessionController(logger, sessionService)
+	oauthController := controller.NewOAuthController(logger, oauthService)
+	roleController := controller.NewRoleController(logger, roleService)
+	apiKeyController := controller.NewApiKeyController(logger, apiKeyService)
+	trainingController := controller.NewTrainingController(logger, trainingService)
+	endorsementController := controller.NewEndorsementController(logger, endorsementService)
+	controllerActivityController := controller.NewControllerActivityController(logger, controllerActivityService)
+
+	if metricsConfig := config.Server.MetricsServer; metricsConfig.Enabled && !metricsConfig.Standalone() {
+		metricsHandler := echo.WrapHandler(metrics.Handler())
+		e.GET(metricsConfig.Path, func(c echo.Context) error {
+			// 使用echo解析的真实IP, 与代理配置保持一致
+			if !metricsConfig.Authorized(c.RealIP(), c.Request().Header.Get(echo.HeaderAuthorization)) {
+				return c.NoContent(http.StatusUnauthorized)
+			}
+			return metricsHandler(c)
+		})
+	}
+
+	health.Register("smtp", health.Cached(time.Minute, smtpChecker(config.Server.HttpServer.Email)))
+	health.Register("store", health.Cached(30*time.Second, storeService.Ping))
+	e.GET("/healthz", echo.WrapHandler(health.LivenessHandler()))
+	e.GET("/readyz", echo.WrapHandler(health.ReadinessHandler()))
 
 	apiGroup := e.Group("/api")
 	apiGroup.POST("/sessions", userController.UserLogin)
@@ -180,22 +268,52 @@ func StartHttpServer(applicationContent *ApplicationContent) {
 	apiGroup.POST("/codes", emailController.SendVerifyEmail)
 	apiGroup.GET("/profile", userController.GetCurrentUserProfile, jwtMiddleware)
 	apiGroup.PATCH("/profile", userController.EditCurrentProfile, jwtMiddleware)
+	apiGroup.GET("/profile/sessions", sessionController.GetSessions, jwtMiddleware)
+	apiGroup.DELETE("/profile/sessions", sessionController.RevokeOtherSessions, jwtMiddleware)
+	apiGroup.DELETE("/profile/sessions/:id", sessionController.RevokeSession, jwtMiddleware)
+	apiGroup.POST("/profile/totp", userController.SetupTotp, jwtMiddleware)
+	apiGroup.POST("/profile/totp/confirm", userController.ConfirmTotp, jwtMiddleware)
+	apiGroup.DELETE("/profile/totp", userController.DisableTotp, jwtMiddleware)
+	apiGroup.POST("/profile/totp/recovery_codes", userController.RegenerateRecoveryCodes, jwtMiddleware)
 	apiGroup.GET("/history", userController.GetUserHistory, jwtMiddleware)
 
 	userGroup := apiGroup.Group("/users")
 	userGroup.POST("", userController.UserRegister)
 	userGroup.GET("", userController.GetUsers, jwtMiddleware)
 	userGroup.GET("/controllers", userController.GetControllers, jwtMiddleware)
+	userGroup.GET("/controllers/activity", controllerActivityController.GetControllerActivity, jwtMiddleware)
 	userGroup.GET("/availability", userController.CheckUserAvailability)
+	userGroup.POST("/password/reset/codes", userController.SendPasswordResetCode)
+	userGroup.POST("/password/reset", userController.ResetPassword)
+	apiGroup.GET("/sessions/external", userController.GetExternalLoginUrl)
+	apiGroup.POST("/sessions/external", userController.ExternalLogin)
 	userGroup.GET("/:uid/profile", userController.GetUserProfile, jwtMiddleware)
 	userGroup.PATCH("/:uid/profile", userController.EditProfile, jwtMiddleware)
 	userGroup.PATCH("/:uid/permission", userController.EditUserPermission, jwtMiddleware)
 	userGroup.PUT("/:uid/rating", userController.EditUserRating, jwtMiddleware)
+	userGroup.GET("/:uid/roles", roleController.GetUserRoles, jwtMiddleware)
+	userGroup.POST("/:uid/roles", roleController.AssignUserRole, jwtMiddleware)
+	userGroup.DELETE("/:uid/roles/:id", roleController.RevokeUserRole, jwtMiddleware)
+
+	roleGroup := apiGroup.Group("/roles")
+	roleGroup.GET("", roleController.GetRoles, jwtMiddleware)
+	roleGroup.GET("/nodes", roleController.GetPermissionNodes, jwtMiddleware)
+	roleGroup.POST("", roleController.CreateRole, jwtMiddleware)
+	roleGroup.PATCH("/:id", roleController.EditRole, jwtMiddleware)
+	roleGroup.DELETE("/:id", roleController.DeleteRole, jwtMiddleware)
+
+	if httpConfig.ApiKey.Enabled {
+		apiKeyGroup := apiGroup.Group("/keys")
+		apiKeyGroup.GET("", apiKeyController.GetApiKeys, jwtMiddleware)
+		apiKeyGroup.POST("", apiKeyController.CreateApiKey, jwtMiddleware)
+		apiKeyGroup.DELETE("/:id", apiKeyController.RevokeApiKey, jwtMiddleware)
+	}
 
 	clientGroup := apiGroup.Group("/clients")
 	clientGroup.GET("/status", func(c echo.Context) error { return c.String(http.StatusOK, whazzupContent) })
 	clientGroup.GET("", clientController.GetOnlineClients)
 	clientGroup.GET("/paths", clientController.GetClientPath, jwtMiddleware)
+	clientGroup.GET("/sectors", clientController.GetSectorCoverage)
 	clientGroup.POST("/:callsign/message", clientController.SendMessageToClient, jwtMiddleware)
 	clientGroup.DELETE("/:callsign", clientController.KillClient, jwtMiddleware)
 
@@ -203,6 +321,8 @@ func StartHttpServer(applicationContent *ApplicationContent) {
 	serverGroup.GET("/base", serverController.GetServerConfig)
 	serverGroup.GET("/info", serverController.GetServerInfo, jwtMiddleware)
 	serverGroup.GET("/rating", serverController.GetServerOnlineTime, jwtMiddleware)
+	serverGroup.POST("/config/reload", serverController.ReloadConfig, jwtMiddleware)
+	serverGroup.POST("/drain", serverController.DrainServer, jwtMiddleware)
 
 	activityGroup := apiGroup.Group("/activities")
 	activityGroup.GET("", activityController.GetActivities, jwtMiddleware)
@@ -224,6 +344,55 @@ func StartHttpServer(applicationContent *ApplicationContent) {
 	auditLogGroup := apiGroup.Group("/audits")
 	auditLogGroup.GET("", auditLogController.GetAuditLogs, jwtMiddleware)
 
+	bookingGroup := apiGroup.Group("/bookings")
+	bookingGroup.GET("", atcBookingController.GetAtcBookings)
+	bookingGroup.GET("/calendar.ics", atcBookingController.GetAtcBookingCalendar)
+	bookingGroup.POST("", atcBookingController.AddAtcBooking, jwtMiddleware)
+	bookingGroup.DELETE("/:id", atcBookingController.DeleteAtcBooking, jwtMiddleware)
+
+	ticketGroup := apiGroup.Group("/tickets")
+	ticketGroup.GET("", ticketController.GetTickets, jwtMiddleware)
+	ticketGroup.GET("/list", ticketController.GetTicketsPage, jwtMiddleware)
+	ticketGroup.GET("/:id", ticketController.GetTicket, jwtMiddleware)
+	ticketGroup.POST("", ticketController.AddTicket, jwtMiddleware)
+	ticketGroup.POST("/:id/replies", ticketController.ReplyTicket, jwtMiddleware)
+	ticketGroup.PUT("/:id/assignee", ticketController.AssignTicket, jwtMiddleware)
+	ticketGroup.POST("/:id/close", ticketController.CloseTicket, jwtMiddleware)
+
+	trainingGroup := apiGroup.Group("/trainings")
+	trainingGroup.GET("", trainingController.GetTrainings, jwtMiddleware)
+	trainingGroup.GET("/list", trainingController.GetTrainingsPage, jwtMiddleware)
+	trainingGroup.GET("/:id", trainingController.GetTraining, jwtMiddleware)
+	trainingGroup.GET("/:id/history", trainingController.GetTrainingHistory, jwtMiddleware)
+	trainingGroup.POST("", trainingController.AddTraining, jwtMiddleware)
+	trainingGroup.PUT("/:id/mentor", trainingController.AssignMentor, jwtMiddleware)
+	trainingGroup.POST("/:id/reports", trainingController.AddTrainingReport, jwtMiddleware)
+	trainingGroup.POST("/:id/solos", trainingController.GrantSolo, jwtMiddleware)
+	trainingGroup.DELETE("/:id/solos/:solo_id", trainingController.RevokeSolo, jwtMiddleware)
+	trainingGroup.POST("/:id/complete", trainingController.CompleteTraining, jwtMiddleware)
+	trainingGroup.POST("/:id/cancel", trainingController.CancelTraining, jwtMiddleware)
+
+	endorsementGroup := apiGroup.Group("/endorsements")
+	endorsementGroup.GET("", endorsementController.GetEndorsements, jwtMiddleware)
+	endorsementGroup.GET("/list", endorsementController.GetEndorsementsPage, jwtMiddleware)
+	endorsementGroup.GET("/positions", endorsementController.GetEndorsedPositions, jwtMiddleware)
+	endorsementGroup.POST("", endorsementController.AddEndorsement, jwtMiddleware)
+	endorsementGroup.DELETE("/:id", endorsementController.DeleteEndorsement, jwtMiddleware)
+
+	if httpConfig.OAuth.Enabled {
+		e.GET("/.well-known/openid-configuration", oauthController.Discovery)
+		oauthGroup := apiGroup.Group("/oauth")
+		oauthGroup.GET("/authorize", oauthController.GetAuthorizeInfo, jwtMiddleware)
+		oauthGroup.POST("/authorize", oauthController.Authorize, jwtMiddleware)
+		oauthGroup.POST("/token", oauthController.Token)
+		oauthGroup.GET("/userinfo", oauthController.UserInfo)
+		oauthGroup.POST("/userinfo", oauthController.UserInfo)
+		oauthGroup.GET("/jwks", oauthController.Jwks)
+		oauthGroup.GET("/clients", oauthController.GetClients, jwtMiddleware)
+		oauthGroup.POST("/clients", oauthController.CreateClient, jwtMiddleware)
+		oauthGroup.DELETE("/clients/:id", oauthController.DeleteClient, jwtMiddleware)
+	}
+
 	apiGroup.Use(middleware.Static(httpConfig.Store.LocalStorePath))
 
 	applicationContent.Cleaner().Add(NewHttpServerShutdownCallback(e))
@@ -233,9 +402,7 @@ func StartHttpServer(applicationContent *ApplicationContent) {
 		protocol = "https"
 	}
 	logger.InfoF("Starting %s server on %s", protocol, httpConfig.Address)
-	logger.InfoF("Rate limit: %d requests per %v",
-		httpConfig.Limits.RateLimit,
-		httpConfig.Limits.RateLimitDuration)
+	logger.InfoF("Rate limit: %d requests per %v", rateLimit.Limit, rateLimit.Window)
 
 	var err error
 	if httpConfig.SSL.Enable {
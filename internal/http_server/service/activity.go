@@ -59,10 +59,10 @@ func (activityService *ActivityService) GetActivitiesPage(req *RequestGetActivit
 	if req.Page <= 0 || req.PageSize <= 0 {
 		return NewApiResponse[ResponseGetActivitiesPage](&ErrIllegalParam, Unsatisfied, nil)
 	}
-	if req.Permission <= 0 {
+	if !req.Permission.IsStaff() {
 		return NewApiResponse[ResponseGetActivitiesPage](&ErrNoPermission, Unsatisfied, nil)
 	}
-	permission := operation.Permission(req.Permission)
+	permission := req.Permission
 	if !permission.HasPermission(operation.ActivityShowList) {
 		return NewApiResponse[ResponseGetActivitiesPage](&ErrNoPermission, Unsatisfied, nil)
 	}
@@ -96,10 +96,10 @@ func (activityService *ActivityService) GetActivityInfo(req *RequestActivityInfo
 var SuccessAddActivity = ApiStatus{StatusName: "ADD_ACTIVITY", Description: "成功添加活动", HttpCode: Ok}
 
 func (activityService *ActivityService) AddActivity(req *RequestAddActivity) *ApiResponse[ResponseAddActivity] {
-	if req.Permission <= 0 {
+	if !req.Permission.IsStaff() {
 		return NewApiResponse[ResponseAddActivity](&ErrNoPermission, Unsatisfied, nil)
 	}
-	permission := operation.Permission(req.Permission)
+	permission := req.Permission
 	if !permission.HasPermission(operation.ActivityPublish) {
 		return NewApiResponse[ResponseAddActivity](&ErrNoPermission, Unsatisfied, nil)
 	}
@@ -132,10 +132,10 @@ var (
 )
 
 func (activityService *ActivityService) DeleteActivity(req *RequestDeleteActivity) *ApiResponse[ResponseDeleteActivity] {
-	if req.Permission <= 0 {
+	if !req.Permission.IsStaff() {
 		return NewApiResponse[ResponseDeleteActivity](&ErrNoPermission, Unsatisfied, nil)
 	}
-	permission := operation.Permission(req.Permission)
+	permission := req.Permission
 	if !permission.HasPermission(operation.ActivityDelete) {
 		return NewApiResponse[ResponseDeleteActivity](&ErrNoPermission, Unsatisfied, nil)
 	}
@@ -270,10 +270,10 @@ func (activityService *ActivityService) EditActivity(req *RequestEditActivity) *
 	if req.Activity == nil {
 		return NewApiResponse[ResponseEditActivity](&ErrIllegalParam, Unsatisfied, nil)
 	}
-	if req.Permission <= 0 {
+	if !req.Permission.IsStaff() {
 		return NewApiResponse[ResponseEditActivity](&ErrNoPermission, Unsatisfied, nil)
 	}
-	permission := operation.Permission(req.Permission)
+	permission := req.Permission
 	if !permission.HasPermission(operation.ActivityEdit) {
 		return NewApiResponse[ResponseEditActivity](&ErrNoPermission, Unsatisfied, nil)
 	}
@@ -323,10 +323,10 @@ func (activityService *ActivityService) EditActivityStatus(req *RequestEditActiv
 
 	status := operation.ActivityStatus(req.Status)
 
-	if req.Permission <= 0 {
+	if !req.Permission.IsStaff() {
 		return NewApiResponse[ResponseEditActivityStatus](&ErrNoPermission, Unsatisfied, nil)
 	}
-	permission := operation.Permission(req.Permission)
+	permission := req.Permission
 	if !permission.HasPermission(operation.ActivityEditState) {
 		return NewApiResponse[ResponseEditActivityStatus](&ErrNoPermission, Unsatisfied, nil)
 	}
@@ -347,10 +347,10 @@ func (activityService *ActivityService) EditPilotStatus(req *RequestEditPilotSta
 
 	status := operation.ActivityPilotStatus(req.Status)
 
-	if req.Permission <= 0 {
+	if !req.Permission.IsStaff() {
 		return NewApiResponse[ResponseEditPilotStatus](&ErrNoPermission, Unsatisfied, nil)
 	}
-	permission := operation.Permission(req.Permission)
+	permission := req.Permission
 	if !permission.HasPermission(operation.ActivityEditState) {
 		return NewApiResponse[ResponseEditPilotStatus](&ErrNoPermission, Unsatisfied, nil)
 	}
@@ -0,0 +1,160 @@
+// Package service
+package service
+
+import (
+	"fmt"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/service"
+	"time"
+)
+
+type ApiKeyService struct {
+	logger            log.LoggerInterface
+	config            *config.ApiKeyConfig
+	userOperation     operation.UserOperationInterface
+	apiKeyOperation   operation.ApiKeyOperationInterface
+	auditLogOperation operation.AuditLogOperationInterface
+}
+
+func NewApiKeyService(
+	logger log.LoggerInterface,
+	config *config.ApiKeyConfig,
+	userOperation operation.UserOperationInterface,
+	apiKeyOperation operation.ApiKeyOperationInterface,
+	auditLogOperation operation.AuditLogOperationInterface,
+) *ApiKeyService {
+	return &ApiKeyService{
+		logger:            logger,
+		config:            config,
+		userOperation:     userOperation,
+		apiKeyOperation:   apiKeyOperation,
+		auditLogOperation: auditLogOperation,
+	}
+}
+
+var SuccessGetApiKeys = ApiStatus{StatusName: "GET_API_KEYS", Description: "获取API密钥列表成功", HttpCode: Ok}
+
+func (apiKeyService *ApiKeyService) GetApiKeys(req *RequestGetApiKeys) *ApiResponse[ResponseGetApiKeys] {
+	cid := req.Cid
+	if req.All {
+		if !req.Permission.HasPermission(operation.ApiKeyManage) {
+			return NewApiResponse[ResponseGetApiKeys](&ErrNoPermission, Unsatisfied, nil)
+		}
+		cid = 0
+	}
+	keys, err := apiKeyService.apiKeyOperation.GetApiKeys(cid)
+	if err != nil {
+		return NewApiResponse[ResponseGetApiKeys](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+	data := ResponseGetApiKeys(keys)
+	return NewApiResponse(&SuccessGetApiKeys, Unsatisfied, &data)
+}
+
+var (
+	ErrApiKeyRateLimit  = ApiStatus{StatusName: "API_KEY_RATE_LIMIT_INVALID", Description: "限流值超出允许范围", HttpCode: BadRequest}
+	ErrApiKeyExpiresAt  = ApiStatus{StatusName: "API_KEY_EXPIRES_AT_INVALID", Description: "过期时间必须晚于当前时间", HttpCode: BadRequest}
+	ErrApiKeyTooMany    = ApiStatus{StatusName: "API_KEY_TOO_MANY", Description: "有效的API密钥数量已达上限", HttpCode: Conflict}
+	SuccessCreateApiKey = ApiStatus{StatusName: "CREATE_API_KEY", Description: "创建API密钥成功, 请妥善保存密钥", HttpCode: Ok}
+)
+
+var apiKeyNameValidator = &FieldValidator{
+	Min:      1,
+	Max:      64,
+	ErrShort: &ApiStatus{StatusName: "API_KEY_NAME_TOO_SHORT", Description: "密钥名称不能为空", HttpCode: BadRequest},
+	ErrLong:  &ApiStatus{StatusName: "API_KEY_NAME_TOO_LONG", Description: "密钥名称过长", HttpCode: BadRequest},
+}
+
+func (apiKeyService *ApiKeyService) CreateApiKey(req *RequestCreateApiKey) *ApiResponse[ResponseCreateApiKey] {
+	if res := apiKeyNameValidator.CheckString(req.Name); res != nil {
+		return NewApiResponse[ResponseCreateApiKey](res, Unsatisfied, nil)
+	}
+	if len(req.Permissions) == 0 {
+		return NewApiResponse[ResponseCreateApiKey](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	if req.RateLimit < 0 || req.RateLimit > apiKeyService.config.MaxRateLimit {
+		return NewApiResponse[ResponseCreateApiKey](&ErrApiKeyRateLimit, Unsatisfied, nil)
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return NewApiResponse[ResponseCreateApiKey](&ErrApiKeyExpiresAt, Unsatisfied, nil)
+	}
+	user, res := CallDBFuncAndCheckError[operation.User, ResponseCreateApiKey](func() (*operation.User, error) {
+		return apiKeyService.userOperation.GetUserByUid(req.Uid)
+	})
+	if res != nil {
+		return res
+	}
+	permission := req.RealtimePermission(user)
+	if !permission.HasPermission(operation.ApiKeyCreate) {
+		return NewApiResponse[ResponseCreateApiKey](&ErrNoPermission, Unsatisfied, nil)
+	}
+	// 密钥的权限范围不能超过创建者自己的权限
+	scope, res := parsePermissions[ResponseCreateApiKey](permission, req.Permissions)
+	if res != nil {
+		return res
+	}
+	count, err := apiKeyService.apiKeyOperation.CountActiveApiKeys(user.Cid)
+	if err != nil {
+		return NewApiResponse[ResponseCreateApiKey](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+	if count >= int64(apiKeyService.config.MaxKeysPerUser) {
+		return NewApiResponse[ResponseCreateApiKey](&ErrApiKeyTooMany, Unsatisfied, nil)
+	}
+	key, plain := apiKeyService.apiKeyOperation.NewApiKey(user, req.Name, scope, req.RateLimit, req.ExpiresAt)
+	if _, res := CallDBFuncAndCheckError[interface{}, ResponseCreateApiKey](func() (*interface{}, error) {
+		return nil, apiKeyService.apiKeyOperation.AddApiKey(key)
+	}); res != nil {
+		return res
+	}
+	apiKeyService.saveAuditLog(operation.ApiKeyCreated, &req.EchoContentHeader, req.Cid, key)
+	return NewApiResponse(&SuccessCreateApiKey, Unsatisfied, &ResponseCreateApiKey{
+		Key:    key,
+		ApiKey: plain,
+	})
+}
+
+var SuccessRevokeApiKey = ApiStatus{StatusName: "REVOKE_API_KEY", Description: "API密钥已撤销", HttpCode: Ok}
+
+func (apiKeyService *ApiKeyService) RevokeApiKey(req *RequestRevokeApiKey) *ApiResponse[ResponseRevokeApiKey] {
+	if req.KeyId <= 0 {
+		return NewApiResponse[ResponseRevokeApiKey](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	key, res := CallDBFuncAndCheckError[operation.ApiKey, ResponseRevokeApiKey](func() (*operation.ApiKey, error) {
+		return apiKeyService.apiKeyOperation.GetApiKeyById(req.KeyId)
+	})
+	if res != nil {
+		return res
+	}
+	// 用户可以撤销自己的密钥, 撤销他人的密钥需要ApiKeyManage权限
+	if key.Cid != req.Cid {
+		user, res := CallDBFuncAndCheckError[operation.User, ResponseRevokeApiKey](func() (*operation.User, error) {
+			return apiKeyService.userOperation.GetUserByUid(req.Uid)
+		})
+		if res != nil {
+			return res
+		}
+		if !req.RealtimePermission(user).HasPermission(operation.ApiKeyManage) {
+			return NewApiResponse[ResponseRevokeApiKey](&ErrNoPermission, Unsatisfied, nil)
+		}
+	}
+	if key.RevokedAt == nil {
+		if _, res := CallDBFuncAndCheckError[interface{}, ResponseRevokeApiKey](func() (*interface{}, error) {
+			return nil, apiKeyService.apiKeyOperation.RevokeApiKey(key)
+		}); res != nil {
+			return res
+		}
+		apiKeyService.saveAuditLog(operation.ApiKeyRevoked, &req.EchoContentHeader, req.Cid, key)
+	}
+	data := ResponseRevokeApiKey(true)
+	return NewApiResponse(&SuccessRevokeApiKey, Unsatisfied, &data)
+}
+
+func (apiKeyService *ApiKeyService) saveAuditLog(eventType operation.EventType, req *EchoContentHeader, cid int, key *operation.ApiKey) {
+	go func() {
+		auditLog := apiKeyService.auditLogOperation.NewAuditLog(eventType, cid, fmt.Sprintf("%04d(%s)", key.Cid, key.Prefix), req.Ip, req.UserAgent, nil)
+		if err := apiKeyService.auditLogOperation.SaveAuditLog(auditLog); err != nil {
+			apiKeyService.logger.ErrorF("Fail to create audit log for %s, detail: %v", eventType, err)
+		}
+	}()
+}
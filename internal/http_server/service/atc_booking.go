@@ -0,0 +1,202 @@
+// Package service
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/service"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxAtcBookingDuration = 24 * time.Hour      // 单次预约最长时间
+	maxAtcBookingQuery    = 31 * 24 * time.Hour // 单次查询最长时间跨度
+	icalTimeFormat        = "20060102T150405Z"
+)
+
+type AtcBookingService struct {
+	logger              log.LoggerInterface
+	config              *config.ServerConfig
+	userOperation       operation.UserOperationInterface
+	atcBookingOperation operation.AtcBookingOperationInterface
+	auditLogOperation   operation.AuditLogOperationInterface
+}
+
+func NewAtcBookingService(
+	logger log.LoggerInterface,
+	config *config.ServerConfig,
+	userOperation operation.UserOperationInterface,
+	atcBookingOperation operation.AtcBookingOperationInterface,
+	auditLogOperation operation.AuditLogOperationInterface,
+) *AtcBookingService {
+	return &AtcBookingService{
+		logger:              logger,
+		config:              config,
+		userOperation:       userOperation,
+		atcBookingOperation: atcBookingOperation,
+		auditLogOperation:   auditLogOperation,
+	}
+}
+
+var SuccessGetAtcBookings = ApiStatus{StatusName: "GET_ATC_BOOKINGS", Description: "成功获取席位预约", HttpCode: Ok}
+
+func (bookingService *AtcBookingService) GetAtcBookings(req *RequestGetAtcBookings) *ApiResponse[ResponseGetAtcBookings] {
+	startTime := time.Now()
+	endTime := startTime.AddDate(0, 0, 7)
+	var err error
+	if req.StartTime != "" {
+		if startTime, err = time.Parse(time.RFC3339, req.StartTime); err != nil {
+			return NewApiResponse[ResponseGetAtcBookings](&ErrIllegalParam, Unsatisfied, nil)
+		}
+	}
+	if req.EndTime != "" {
+		if endTime, err = time.Parse(time.RFC3339, req.EndTime); err != nil {
+			return NewApiResponse[ResponseGetAtcBookings](&ErrIllegalParam, Unsatisfied, nil)
+		}
+	}
+	if !startTime.Before(endTime) || endTime.Sub(startTime) > maxAtcBookingQuery {
+		return NewApiResponse[ResponseGetAtcBookings](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	bookings, err := bookingService.atcBookingOperation.GetAtcBookings(startTime, endTime)
+	if err != nil {
+		return NewApiResponse[ResponseGetAtcBookings](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+	return NewApiResponse(&SuccessGetAtcBookings, Unsatisfied, &ResponseGetAtcBookings{Items: bookings})
+}
+
+// GetAtcBookingCalendar 生成从7天前到30天后所有预约的iCalendar数据
+func (bookingService *AtcBookingService) GetAtcBookingCalendar() []byte {
+	now := time.Now()
+	bookings, err := bookingService.atcBookingOperation.GetAtcBookings(now.AddDate(0, 0, -7), now.AddDate(0, 0, 30))
+	if err != nil {
+		bookingService.logger.ErrorF("AtcBookingService.GetAtcBookings error: %v", err)
+		bookings = make([]*operation.AtcBooking, 0)
+	}
+	fsdName := bookingService.config.FSDServer.FSDName
+	stamp := now.UTC().Format(icalTimeFormat)
+	buffer := bytes.Buffer{}
+	writeLine := func(format string, args ...interface{}) {
+		_, _ = fmt.Fprintf(&buffer, format+"\r\n", args...)
+	}
+	writeLine("BEGIN:VCALENDAR")
+	writeLine("VERSION:2.0")
+	writeLine("PRODID:-//%s//ATC Booking//EN", fsdName)
+	writeLine("CALSCALE:GREGORIAN")
+	writeLine("X-WR-CALNAME:%s ATC Booking", fsdName)
+	for _, booking := range bookings {
+		writeLine("BEGIN:VEVENT")
+		writeLine("UID:atc-booking-%d@%s", booking.ID, strings.ReplaceAll(fsdName, " ", "-"))
+		writeLine("DTSTAMP:%s", stamp)
+		writeLine("DTSTART:%s", booking.StartTime.UTC().Format(icalTimeFormat))
+		writeLine("DTEND:%s", booking.EndTime.UTC().Format(icalTimeFormat))
+		writeLine("SUMMARY:%s - %04d", booking.Callsign, booking.Cid)
+		writeLine("END:VEVENT")
+	}
+	writeLine("END:VCALENDAR")
+	return buffer.Bytes()
+}
+
+var (
+	ErrAtcBookingTime         = ApiStatus{StatusName: "ATC_BOOKING_TIME_INVALID", Description: "预约时间不正确", HttpCode: BadRequest}
+	ErrAtcBookingCallsign     = ApiStatus{StatusName: "ATC_BOOKING_CALLSIGN_INVALID", Description: "预约呼号不是有效的管制席位", HttpCode: BadRequest}
+	ErrAtcBookingOverlap      = ApiStatus{StatusName: "ATC_BOOKING_OVERLAP", Description: "该时间段席位已被预约", HttpCode: Conflict}
+	ErrAtcBookingUserConflict = ApiStatus{StatusName: "ATC_BOOKING_USER_CONFLICT", Description: "你在该时间段已有其他预约", HttpCode: Conflict}
+	SuccessAddAtcBooking      = ApiStatus{StatusName: "ADD_ATC_BOOKING", Description: "预约成功", HttpCode: Ok}
+)
+
+func (bookingService *AtcBookingService) AddAtcBooking(req *RequestAddAtcBooking) *ApiResponse[ResponseAddAtcBooking] {
+	if req.Callsign == "" || req.StartTime.IsZero() || req.EndTime.IsZero() {
+		return NewApiResponse[ResponseAddAtcBooking](&ErrLackParam, Unsatisfied, nil)
+	}
+	if !req.StartTime.Before(req.EndTime) || req.EndTime.Before(time.Now()) || req.EndTime.Sub(req.StartTime) > maxAtcBookingDuration {
+		return NewApiResponse[ResponseAddAtcBooking](&ErrAtcBookingTime, Unsatisfied, nil)
+	}
+	callsign := strings.ToUpper(req.Callsign)
+	facility, ok := fsd.FacilityFromCallsign(callsign)
+	if !ok {
+		return NewApiResponse[ResponseAddAtcBooking](&ErrAtcBookingCallsign, Unsatisfied, nil)
+	}
+	// 管制权限可能在签发令牌后变化, 这里获取实时数据
+	user, res := CallDBFuncAndCheckError[operation.User, ResponseAddAtcBooking](func() (*operation.User, error) {
+		return bookingService.userOperation.GetUserByUid(req.Uid)
+	})
+	if res != nil {
+		return res
+	}
+	if !fsd.Rating(user.Rating).CheckRatingFacility(facility) {
+		return NewApiResponse[ResponseAddAtcBooking](&ErrRatingTooLow, Unsatisfied, nil)
+	}
+	booking := bookingService.atcBookingOperation.NewAtcBooking(user, callsign, req.StartTime, req.EndTime)
+	if err := bookingService.atcBookingOperation.AddAtcBooking(booking); err != nil {
+		switch {
+		case errors.Is(err, operation.ErrAtcBookingTimeInvalid):
+			return NewApiResponse[ResponseAddAtcBooking](&ErrAtcBookingTime, Unsatisfied, nil)
+		case errors.Is(err, operation.ErrAtcBookingOverlap):
+			return NewApiResponse[ResponseAddAtcBooking](&ErrAtcBookingOverlap, Unsatisfied, nil)
+		case errors.Is(err, operation.ErrAtcBookingUserOverlap):
+			return NewApiResponse[ResponseAddAtcBooking](&ErrAtcBookingUserConflict, Unsatisfied, nil)
+		}
+		bookingService.logger.ErrorF("Error adding atc booking: %v", err)
+		return NewApiResponse[ResponseAddAtcBooking](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+
+	go func() {
+		newValue, _ := json.Marshal(booking)
+		changeDetail := &operation.ChangeDetail{
+			OldValue: "",
+			NewValue: string(newValue),
+		}
+		auditLog := bookingService.auditLogOperation.NewAuditLog(operation.AtcBookingCreated, req.Cid,
+			strconv.Itoa(int(booking.ID)), req.Ip, req.UserAgent, changeDetail)
+		if err := bookingService.auditLogOperation.SaveAuditLog(auditLog); err != nil {
+			bookingService.logger.ErrorF("Fail to create audit log for atc_booking_created, detail: %v", err)
+		}
+	}()
+
+	return NewApiResponse(&SuccessAddAtcBooking, Unsatisfied, (*ResponseAddAtcBooking)(booking))
+}
+
+var SuccessDeleteAtcBooking = ApiStatus{StatusName: "DELETE_ATC_BOOKING", Description: "成功取消预约", HttpCode: Ok}
+
+func (bookingService *AtcBookingService) DeleteAtcBooking(req *RequestDeleteAtcBooking) *ApiResponse[ResponseDeleteAtcBooking] {
+	if req.BookingId <= 0 {
+		return NewApiResponse[ResponseDeleteAtcBooking](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	booking, res := CallDBFuncAndCheckError[operation.AtcBooking, ResponseDeleteAtcBooking](func() (*operation.AtcBooking, error) {
+		return bookingService.atcBookingOperation.GetAtcBookingById(req.BookingId)
+	})
+	if res != nil {
+		return res
+	}
+	permission := req.Permission
+	if booking.Cid != req.Cid && !permission.HasPermission(operation.AtcBookingManage) {
+		return NewApiResponse[ResponseDeleteAtcBooking](&ErrNoPermission, Unsatisfied, nil)
+	}
+	if err := bookingService.atcBookingOperation.DeleteAtcBooking(booking); err != nil {
+		return NewApiResponse[ResponseDeleteAtcBooking](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+
+	go func() {
+		oldValue, _ := json.Marshal(booking)
+		changeDetail := &operation.ChangeDetail{
+			OldValue: string(oldValue),
+			NewValue: "",
+		}
+		auditLog := bookingService.auditLogOperation.NewAuditLog(operation.AtcBookingDeleted, req.Cid,
+			strconv.Itoa(int(booking.ID)), req.Ip, req.UserAgent, changeDetail)
+		if err := bookingService.auditLogOperation.SaveAuditLog(auditLog); err != nil {
+			bookingService.logger.ErrorF("Fail to create audit log for atc_booking_deleted, detail: %v", err)
+		}
+	}()
+
+	data := ResponseDeleteAtcBooking(true)
+	return NewApiResponse(&SuccessDeleteAtcBooking, Unsatisfied, &data)
+}
@@ -28,10 +28,10 @@ func (auditLogService *AudioLogService) GetAuditLogPage(req *RequestGetAuditLog)
 	if req.Page <= 0 || req.PageSize <= 0 {
 		return NewApiResponse[ResponseGetAuditLog](&ErrIllegalParam, Unsatisfied, nil)
 	}
-	if req.Permission <= 0 {
+	if !req.Permission.IsStaff() {
 		return NewApiResponse[ResponseGetAuditLog](&ErrNoPermission, Unsatisfied, nil)
 	}
-	permission := operation.Permission(req.Permission)
+	permission := req.Permission
 	if !permission.HasPermission(operation.AuditLogShow) {
 		return NewApiResponse[ResponseGetAuditLog](&ErrNoPermission, Unsatisfied, nil)
 	}
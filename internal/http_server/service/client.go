@@ -16,6 +16,7 @@ import (
 type ClientService struct {
 	logger            log.LoggerInterface
 	onlineClient      *utils.CachedValue[OnlineClients]
+	sectorCoverage    *utils.CachedValue[ResponseSectorCoverage]
 	clientManager     fsd.ClientManagerInterface
 	emailService      EmailServiceInterface
 	config            *config.HttpServerConfig
@@ -40,6 +41,7 @@ func NewClientService(
 		auditLogOperation: auditLogOperation,
 	}
 	service.onlineClient = utils.NewCachedValue[OnlineClients](config.CacheDuration, func() *OnlineClients { return service.getOnlineClient() })
+	service.sectorCoverage = utils.NewCachedValue[ResponseSectorCoverage](config.CacheDuration, func() *ResponseSectorCoverage { return service.getSectorCoverage() })
 	return service
 }
 
@@ -116,10 +118,10 @@ func (clientService *ClientService) SendMessageToClient(req *RequestSendMessageT
 	if req.Uid <= 0 || req.SendTo == "" || req.Message == "" {
 		return NewApiResponse[ResponseSendMessageToClient](&ErrIllegalParam, Unsatisfied, nil)
 	}
-	if req.Permission <= 0 {
+	if !req.Permission.IsStaff() {
 		return NewApiResponse[ResponseSendMessageToClient](&ErrNoPermission, Unsatisfied, nil)
 	}
-	permission := operation.Permission(req.Permission)
+	permission := req.Permission
 	if !permission.HasPermission(operation.ClientSendMessage) {
 		return NewApiResponse[ResponseSendMessageToClient](&ErrNoPermission, Unsatisfied, nil)
 	}
@@ -155,7 +157,7 @@ func (clientService *ClientService) KillClient(req *RequestKillClient) *ApiRespo
 	if res != nil {
 		return res
 	}
-	permission := operation.Permission(user.Permission)
+	permission := req.RealtimePermission(user)
 	if !permission.HasPermission(operation.ClientKill) {
 		return NewApiResponse[ResponseKillClient](&ErrNoPermission, Unsatisfied, nil)
 	}
@@ -166,7 +168,7 @@ func (clientService *ClientService) KillClient(req *RequestKillClient) *ApiRespo
 	client.MarkedDisconnect(false)
 
 	go func() {
-		if clientService.config.Email.Template.EnableKickedFromServerEmail {
+		if clientService.config.Email.Templates().EnableKickedFromServerEmail {
 			if err := clientService.emailService.SendKickedFromServerEmail(client.User(), user, req.Reason); err != nil {
 				clientService.logger.ErrorF("SendRatingChangeEmail Failed: %v", err)
 			}
@@ -202,3 +204,63 @@ func (clientService *ClientService) GetClientPath(req *RequestClientPath) *ApiRe
 	data := ResponseClientPath(client.Paths())
 	return NewApiResponse(&SuccessGetClientPath, Unsatisfied, &data)
 }
+
+func (clientService *ClientService) getSectorCoverage() *ResponseSectorCoverage {
+	sectors := clientService.clientManager.Sectors().Sectors()
+	data := make(ResponseSectorCoverage, 0, len(sectors))
+	for _, sector := range sectors {
+		data = append(data, &SectorCoverage{
+			Callsign:    sector.Callsign,
+			Name:        sector.Name,
+			Controllers: make([]string, 0),
+			Pilots:      make([]string, 0),
+		})
+	}
+	if len(data) == 0 {
+		return &data
+	}
+
+	clientCopy := clientService.clientManager.GetClientSnapshot()
+	defer clientService.clientManager.PutSlice(clientCopy)
+
+	for _, client := range clientCopy {
+		if client == nil || client.Disconnected() {
+			continue
+		}
+		for index, sector := range sectors {
+			if client.IsAtc() {
+				if sector.OwnedBy(client.Callsign()) {
+					data[index].Controllers = append(data[index].Controllers, client.Callsign())
+				}
+			} else if sector.Contains(client.Position()[0]) {
+				data[index].Pilots = append(data[index].Pilots, client.Callsign())
+			}
+		}
+	}
+	return &data
+}
+
+var SuccessGetSectorCoverage = ApiStatus{StatusName: "GET_SECTOR_COVERAGE", Description: "成功获取扇区覆盖情况", HttpCode: Ok}
+
+func (clientService *ClientService) GetSectorCoverage(req *RequestSectorCoverage) *ApiResponse[ResponseSectorCoverage] {
+	coverage := clientService.sectorCoverage.GetValue()
+	if req.Callsign == "" {
+		return NewApiResponse(&SuccessGetSectorCoverage, Unsatisfied, coverage)
+	}
+	// 指定呼号时只返回该客户端所在的扇区
+	client, exist := clientService.clientManager.GetClient(req.Callsign)
+	if !exist {
+		return NewApiResponse[ResponseSectorCoverage](&ErrClientNotFound, Unsatisfied, nil)
+	}
+	sectors := clientService.clientManager.Sectors().FindSectors(client.Position()[0])
+	data := make(ResponseSectorCoverage, 0, len(sectors))
+	for _, sector := range sectors {
+		for _, item := range *coverage {
+			if item.Callsign == sector.Callsign {
+				data = append(data, item)
+				break
+			}
+		}
+	}
+	return NewApiResponse(&SuccessGetSectorCoverage, Unsatisfied, &data)
+}
@@ -0,0 +1,309 @@
+// Package service
+package service
+
+import (
+	"errors"
+	"fmt"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/service"
+	"strconv"
+	"time"
+)
+
+// maxActivityPeriodOffset 活跃度报告最多向前查询的考核周期数
+const maxActivityPeriodOffset = 40
+
+type ControllerActivityService struct {
+	logger              log.LoggerInterface
+	config              *config.HttpServerConfig
+	userOperation       operation.UserOperationInterface
+	historyOperation    operation.HistoryOperationInterface
+	inactivityOperation operation.ControllerInactivityOperationInterface
+	sessionOperation    operation.UserSessionOperationInterface
+	auditLogOperation   operation.AuditLogOperationInterface
+	emailService        EmailServiceInterface
+	clientManager       fsd.ClientManagerInterface
+}
+
+func NewControllerActivityService(
+	logger log.LoggerInterface,
+	config *config.HttpServerConfig,
+	userOperation operation.UserOperationInterface,
+	historyOperation operation.HistoryOperationInterface,
+	inactivityOperation operation.ControllerInactivityOperationInterface,
+	sessionOperation operation.UserSessionOperationInterface,
+	auditLogOperation operation.AuditLogOperationInterface,
+	emailService EmailServiceInterface,
+	clientManager fsd.ClientManagerInterface,
+) *ControllerActivityService {
+	return &ControllerActivityService{
+		logger:              logger,
+		config:              config,
+		userOperation:       userOperation,
+		historyOperation:    historyOperation,
+		inactivityOperation: inactivityOperation,
+		sessionOperation:    sessionOperation,
+		auditLogOperation:   auditLogOperation,
+		emailService:        emailService,
+		clientManager:       clientManager,
+	}
+}
+
+// systemOperator 定时任务发送邮件时使用的操作人, 联系方式为发件邮箱
+func (activityService *ControllerActivityService) systemOperator() *operation.User {
+	return &operation.User{Cid: 0, Email: activityService.config.Email.Username}
+}
+
+// saveAuditLog 定时任务产生的审计日志, 操作人为0
+func (activityService *ControllerActivityService) saveAuditLog(eventType operation.EventType, object string, changeDetail *operation.ChangeDetail) {
+	go func() {
+		auditLog := activityService.auditLogOperation.NewAuditLog(eventType, 0, object, "", "system", changeDetail)
+		if err := activityService.auditLogOperation.SaveAuditLog(auditLog); err != nil {
+			activityService.logger.ErrorF("Fail to create audit log for %s, detail: %v", eventType, err)
+		}
+	}()
+}
+
+// StartCheck 立即检查一次管制员活跃度, 之后每隔interval检查一次
+// 多个实例同时检查时由不活跃记录的唯一约束和状态的条件更新保证每个警告和降级只执行一次
+func (activityService *ControllerActivityService) StartCheck(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		activityService.CheckControllerActivity(time.Now())
+		for now := range ticker.C {
+			activityService.CheckControllerActivity(now)
+		}
+	}()
+}
+
+// atcTimeBetween 统计[start, end)之间的管制时长(秒), 包括已保存的联飞记录和当前仍在线的管制席位
+func (activityService *ControllerActivityService) atcTimeBetween(start, end, now time.Time) (map[int]int, error) {
+	atcTimes, err := activityService.historyOperation.GetAtcTimeBetween(start, end)
+	if err != nil {
+		return nil, err
+	}
+	clientCopy := activityService.clientManager.GetClientSnapshot()
+	defer activityService.clientManager.PutSlice(clientCopy)
+	for _, client := range clientCopy {
+		if client == nil || client.Disconnected() {
+			continue
+		}
+		// 在线席位的联飞记录在断开连接时才会写入数据库, 以当前时间作为结束时间计入
+		history := client.History()
+		if history == nil || !history.IsAtc {
+			continue
+		}
+		online := &operation.History{StartTime: history.StartTime, EndTime: now}
+		atcTimes[history.Cid] += online.TimeBetween(start, end)
+	}
+	return atcTimes, nil
+}
+
+// CheckControllerActivity 警告上一个考核周期内未达标的管制员, 并处理宽限期已结束的不活跃记录
+func (activityService *ControllerActivityService) CheckControllerActivity(now time.Time) {
+	inactivityConfig := activityService.config.Inactivity
+	periodEnd := inactivityConfig.PeriodStart(now)
+	periodStart := periodEnd.AddDate(0, -inactivityConfig.PeriodMonths, 0)
+	activityService.warnInactiveControllers(periodStart, periodEnd, now)
+	activityService.handleWarnedControllers(now)
+}
+
+func (activityService *ControllerActivityService) warnInactiveControllers(periodStart, periodEnd, now time.Time) {
+	inactivityConfig := activityService.config.Inactivity
+	controllers, err := activityService.userOperation.GetControllersByRating(inactivityConfig.MinRating, inactivityConfig.MaxRating)
+	if err != nil {
+		activityService.logger.ErrorF("Fail to get controllers, detail: %v", err)
+		return
+	}
+	inactivities, err := activityService.inactivityOperation.GetControllerInactivities(periodStart)
+	if err != nil {
+		activityService.logger.ErrorF("Fail to get controller inactivities, detail: %v", err)
+		return
+	}
+	atcTimes, err := activityService.atcTimeBetween(periodStart, periodEnd, now)
+	if err != nil {
+		activityService.logger.ErrorF("Fail to get controller atc time, detail: %v", err)
+		return
+	}
+
+	warned := make(map[int]bool, len(inactivities))
+	for _, inactivity := range inactivities {
+		warned[inactivity.Cid] = true
+	}
+	minimumTime := int(inactivityConfig.MinimumTime().Seconds())
+	for _, user := range controllers {
+		// 考核周期开始后注册的用户不参与该周期的考核
+		if warned[user.Cid] || user.CreatedAt.After(periodStart) || atcTimes[user.Cid] >= minimumTime {
+			continue
+		}
+		inactivity := activityService.inactivityOperation.NewControllerInactivity(user, periodStart, periodEnd, atcTimes[user.Cid], now.Add(inactivityConfig.GraceDuration))
+		if err := activityService.inactivityOperation.AddControllerInactivity(inactivity); err != nil {
+			if !errors.Is(err, operation.ErrControllerInactivityExists) {
+				activityService.logger.ErrorF("Fail to add inactivity of controller %04d, detail: %v", user.Cid, err)
+			}
+			continue
+		}
+		activityService.logger.InfoF("Controller %04d controlled %ds in period starting %s, warned", user.Cid, inactivity.AtcTime, periodStart.Format(time.DateOnly))
+
+		activityService.saveAuditLog(operation.ControllerWarned, fmt.Sprintf("%04d", user.Cid), &operation.ChangeDetail{
+			OldValue: "",
+			NewValue: strconv.Itoa(inactivity.AtcTime),
+		})
+
+		if activityService.config.Email.Templates().EnableInactivityEmail {
+			if err := activityService.emailService.SendInactivityEmail(user, inactivity, inactivityConfig); err != nil {
+				activityService.logger.ErrorF("SendInactivityEmail Failed: %v", err)
+			}
+		}
+	}
+}
+
+func (activityService *ControllerActivityService) handleWarnedControllers(now time.Time) {
+	inactivityConfig := activityService.config.Inactivity
+	inactivities, err := activityService.inactivityOperation.GetWarnedControllerInactivities()
+	if err != nil {
+		activityService.logger.ErrorF("Fail to get warned controller inactivities, detail: %v", err)
+		return
+	}
+	if len(inactivities) == 0 {
+		return
+	}
+	// 宽限期内以最近一个考核周期长度的管制时长判断是否已经达标
+	atcTimes, err := activityService.atcTimeBetween(now.AddDate(0, -inactivityConfig.PeriodMonths, 0), now, now)
+	if err != nil {
+		activityService.logger.ErrorF("Fail to get controller atc time, detail: %v", err)
+		return
+	}
+
+	minimumTime := int(inactivityConfig.MinimumTime().Seconds())
+	for _, inactivity := range inactivities {
+		user, err := activityService.userOperation.GetUserByCid(inactivity.Cid)
+		if err != nil {
+			if errors.Is(err, operation.ErrUserNotFound) {
+				activityService.updateInactivityStatus(inactivity, operation.InactivityStatusResolved)
+			} else {
+				activityService.logger.ErrorF("Fail to get controller %04d, detail: %v", inactivity.Cid, err)
+			}
+			continue
+		}
+		// 管制权限已被其他途径修改时不再处理
+		if user.Rating != inactivity.OldRating || atcTimes[inactivity.Cid] >= minimumTime {
+			activityService.updateInactivityStatus(inactivity, operation.InactivityStatusResolved)
+			continue
+		}
+		if now.Before(inactivity.GraceUntil) {
+			continue
+		}
+		if inactivityConfig.Demote {
+			activityService.demoteController(user, inactivity)
+			continue
+		}
+		if activityService.updateInactivityStatus(inactivity, operation.InactivityStatusInactive) {
+			activityService.logger.InfoF("Controller %04d marked as inactive", user.Cid)
+			activityService.saveAuditLog(operation.ControllerInactive, fmt.Sprintf("%04d", user.Cid), &operation.ChangeDetail{
+				OldValue: "",
+				NewValue: "inactive",
+			})
+		}
+	}
+}
+
+func (activityService *ControllerActivityService) updateInactivityStatus(inactivity *operation.ControllerInactivity, status int) bool {
+	if err := activityService.inactivityOperation.UpdateControllerInactivityStatus(inactivity, status); err != nil {
+		// 记录已被其他实例处理
+		if !errors.Is(err, operation.ErrControllerInactivityChanged) {
+			activityService.logger.ErrorF("Fail to update inactivity %d, detail: %v", inactivity.ID, err)
+		}
+		return false
+	}
+	return true
+}
+
+func (activityService *ControllerActivityService) demoteController(user *operation.User, inactivity *operation.ControllerInactivity) {
+	oldRating := fsd.Rating(user.Rating)
+	newRating := fsd.Rating(activityService.config.Inactivity.DemoteRating)
+	// 先占用不活跃记录再降级, 保证多个实例同时检查时只降级一次
+	if !activityService.updateInactivityStatus(inactivity, operation.InactivityStatusDemoted) {
+		return
+	}
+	if err := activityService.userOperation.UpdateUserRating(user, newRating.Index()); err != nil {
+		activityService.logger.ErrorF("Fail to demote controller %04d, detail: %v", user.Cid, err)
+		// 恢复为宽限期状态, 下次检查时重试
+		activityService.updateInactivityStatus(inactivity, operation.InactivityStatusWarned)
+		return
+	}
+	if err := activityService.sessionOperation.RevokeUserSessions(user.Cid, ""); err != nil {
+		activityService.logger.ErrorF("Fail to revoke sessions of user %04d, detail: %v", user.Cid, err)
+	}
+	activityService.logger.InfoF("Controller %04d demoted from %s to %s for inactivity", user.Cid, oldRating, newRating)
+
+	activityService.saveAuditLog(operation.UserRatingChange, strconv.Itoa(user.Cid), &operation.ChangeDetail{
+		OldValue: oldRating.String(),
+		NewValue: newRating.String(),
+	})
+	activityService.saveAuditLog(operation.ControllerInactive, fmt.Sprintf("%04d", user.Cid), &operation.ChangeDetail{
+		OldValue: "",
+		NewValue: "demoted",
+	})
+
+	if activityService.config.Email.Templates().EnableRatingChangeEmail {
+		if err := activityService.emailService.SendRatingChangeEmail(user, activityService.systemOperator(), oldRating, newRating); err != nil {
+			activityService.logger.ErrorF("SendRatingChangeEmail Failed: %v", err)
+		}
+	}
+}
+
+var SuccessGetControllerActivity = ApiStatus{StatusName: "GET_CONTROLLER_ACTIVITY", Description: "成功获取管制员活跃度", HttpCode: Ok}
+
+func (activityService *ControllerActivityService) GetControllerActivity(req *RequestGetControllerActivity) *ApiResponse[ResponseGetControllerActivity] {
+	if req.Offset < 0 || req.Offset > maxActivityPeriodOffset {
+		return NewApiResponse[ResponseGetControllerActivity](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	permission := req.Permission
+	if !permission.HasPermission(operation.ControllerActivityShow) {
+		return NewApiResponse[ResponseGetControllerActivity](&ErrNoPermission, Unsatisfied, nil)
+	}
+
+	inactivityConfig := activityService.config.Inactivity
+	periodStart := inactivityConfig.PeriodStart(time.Now()).AddDate(0, -req.Offset*inactivityConfig.PeriodMonths, 0)
+	periodEnd := periodStart.AddDate(0, inactivityConfig.PeriodMonths, 0)
+
+	controllers, err := activityService.userOperation.GetControllersByRating(inactivityConfig.MinRating, inactivityConfig.MaxRating)
+	if err != nil {
+		return NewApiResponse[ResponseGetControllerActivity](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+	atcTimes, err := activityService.atcTimeBetween(periodStart, periodEnd, time.Now())
+	if err != nil {
+		return NewApiResponse[ResponseGetControllerActivity](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+	inactivities, err := activityService.inactivityOperation.GetControllerInactivities(periodStart)
+	if err != nil {
+		return NewApiResponse[ResponseGetControllerActivity](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+
+	inactivityMap := make(map[int]*operation.ControllerInactivity, len(inactivities))
+	for _, inactivity := range inactivities {
+		inactivityMap[inactivity.Cid] = inactivity
+	}
+	minimumTime := int(inactivityConfig.MinimumTime().Seconds())
+	items := make([]*ControllerActivity, 0, len(controllers))
+	for _, user := range controllers {
+		items = append(items, &ControllerActivity{
+			Cid:        user.Cid,
+			Rating:     user.Rating,
+			AtcTime:    atcTimes[user.Cid],
+			Satisfied:  atcTimes[user.Cid] >= minimumTime,
+			Inactivity: inactivityMap[user.Cid],
+		})
+	}
+
+	return NewApiResponse(&SuccessGetControllerActivity, Unsatisfied, &ResponseGetControllerActivity{
+		PeriodStart: periodStart,
+		PeriodEnd:   periodEnd,
+		MinimumTime: minimumTime,
+		Items:       items,
+	})
+}
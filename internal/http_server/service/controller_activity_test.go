@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/half-nothing/simple-fsd/internal/database"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/service"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Info 连接数据库时使用
+func (testLogger) Info(string, ...interface{}) {}
+
+// testActivityEmailService 记录活跃度检查发出的邮件
+type testActivityEmailService struct {
+	EmailServiceInterface
+	lock          sync.Mutex
+	inactivity    []int
+	ratingChanges []int
+}
+
+func (service *testActivityEmailService) SendInactivityEmail(user *operation.User, _ *operation.ControllerInactivity, _ *config.InactivityConfig) error {
+	service.lock.Lock()
+	defer service.lock.Unlock()
+	service.inactivity = append(service.inactivity, user.Cid)
+	return nil
+}
+
+func (service *testActivityEmailService) SendRatingChangeEmail(user *operation.User, _ *operation.User, _, _ fsd.Rating) error {
+	service.lock.Lock()
+	defer service.lock.Unlock()
+	service.ratingChanges = append(service.ratingChanges, user.Cid)
+	return nil
+}
+
+type testActivityClient struct {
+	fsd.ClientInterface
+	history *operation.History
+}
+
+func (client *testActivityClient) Disconnected() bool          { return false }
+func (client *testActivityClient) History() *operation.History { return client.history }
+
+type testActivityClientManager struct {
+	fsd.ClientManagerInterface
+	clients []fsd.ClientInterface
+}
+
+func (manager *testActivityClientManager) GetClientSnapshot() []fsd.ClientInterface {
+	return manager.clients
+}
+
+func (manager *testActivityClientManager) PutSlice([]fsd.ClientInterface) {}
+
+// activityEnvironment 使用临时sqlite数据库, 同一数据库上可以创建多个服务模拟多实例部署
+type activityEnvironment struct {
+	config        *config.HttpServerConfig
+	operations    *operation.DatabaseOperations
+	emails        *testActivityEmailService
+	clientManager *testActivityClientManager
+}
+
+func newActivityEnvironment(t *testing.T) *activityEnvironment {
+	t.Helper()
+	c := config.DefaultConfig()
+	c.Database.Type = string(config.SQLite)
+	c.Database.DBType = config.SQLite
+	c.Database.Database = filepath.Join(t.TempDir(), "database.db")
+	c.Database.QueryDuration = 5 * time.Second
+	c.Database.ConnectIdleDuration = time.Hour
+	closeCallback, operations, err := database.ConnectDatabase(testLogger{}, c, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = closeCallback.Invoke(context.Background()) })
+
+	httpConfig := c.Server.HttpServer
+	httpConfig.Inactivity.Enabled = true
+	httpConfig.Inactivity.GraceDuration = 30 * 24 * time.Hour
+	return &activityEnvironment{
+		config:        httpConfig,
+		operations:    operations,
+		emails:        &testActivityEmailService{},
+		clientManager: &testActivityClientManager{},
+	}
+}
+
+func (env *activityEnvironment) newService() *ControllerActivityService {
+	return NewControllerActivityService(testLogger{}, env.config, env.operations.UserOperation(), env.operations.HistoryOperation(),
+		env.operations.ControllerInactivityOperation(), env.operations.UserSessionOperation(), env.operations.AuditLogOperation(),
+		env.emails, env.clientManager)
+}
+
+func (env *activityEnvironment) newController(t *testing.T, cid int, rating fsd.Rating) *operation.User {
+	t.Helper()
+	userOperation := env.operations.UserOperation()
+	user, err := userOperation.NewUser(fmt.Sprintf("controller%d", cid), fmt.Sprintf("controller%d@example.com", cid), cid, "123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.Rating = rating.Index()
+	if err := userOperation.AddUser(user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func (env *activityEnvironment) addAtcHistory(t *testing.T, cid int, start, end time.Time) {
+	t.Helper()
+	history := &operation.History{Cid: cid, Callsign: "ZBAA_TWR", StartTime: start, EndTime: end, OnlineTime: int(end.Sub(start).Seconds()), IsAtc: true}
+	if err := env.operations.HistoryOperation().SaveHistory(history); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (env *activityEnvironment) inactivities(t *testing.T, periodStart time.Time) []*operation.ControllerInactivity {
+	t.Helper()
+	inactivities, err := env.operations.ControllerInactivityOperation().GetControllerInactivities(periodStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return inactivities
+}
+
+// checkTime 返回一年后第三季度内的检查时间, 保证测试用户在上一个考核周期开始前注册
+func checkTime() time.Time {
+	return time.Date(time.Now().Year()+1, time.August, 15, 12, 0, 0, 0, time.Local)
+}
+
+func TestAtcTimeBetweenClipsToPeriod(t *testing.T) {
+	env := newActivityEnvironment(t)
+	service := env.newService()
+	periodStart := time.Date(2025, time.April, 1, 0, 0, 0, 0, time.Local)
+	periodEnd := time.Date(2025, time.July, 1, 0, 0, 0, 0, time.Local)
+
+	// 跨越周期开始和结束的记录只计入周期内的一小时
+	env.addAtcHistory(t, 1001, periodStart.Add(-2*time.Hour), periodStart.Add(time.Hour))
+	env.addAtcHistory(t, 1001, periodEnd.Add(-time.Hour), periodEnd.Add(3*time.Hour))
+	env.addAtcHistory(t, 1001, periodEnd.Add(time.Hour), periodEnd.Add(2*time.Hour))
+	// 仍在线的席位从登录时间计入到当前时间
+	env.clientManager.clients = []fsd.ClientInterface{
+		&testActivityClient{history: &operation.History{Cid: 1002, StartTime: periodEnd.Add(-90 * time.Minute), IsAtc: true}},
+		&testActivityClient{history: &operation.History{Cid: 1003, StartTime: periodEnd.Add(-time.Hour), IsAtc: false}},
+	}
+
+	atcTimes, err := service.atcTimeBetween(periodStart, periodEnd, periodEnd.Add(-30*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if atcTimes[1001] != 2*3600 {
+		t.Errorf("expected clipped atc time 7200, got %d", atcTimes[1001])
+	}
+	if atcTimes[1002] != 3600 {
+		t.Errorf("expected online atc time 3600, got %d", atcTimes[1002])
+	}
+	if atcTimes[1003] != 0 {
+		t.Errorf("expected pilot session not counted, got %d", atcTimes[1003])
+	}
+}
+
+func TestCheckControllerActivityWarnsOnceAcrossInstances(t *testing.T) {
+	env := newActivityEnvironment(t)
+	env.newController(t, 1001, fsd.STU2)
+	active := env.newController(t, 1002, fsd.STU2)
+	now := checkTime()
+	periodEnd := env.config.Inactivity.PeriodStart(now)
+	periodStart := periodEnd.AddDate(0, -env.config.Inactivity.PeriodMonths, 0)
+	env.addAtcHistory(t, active.Cid, periodStart.Add(time.Hour), periodStart.Add(5*time.Hour))
+
+	first, second := env.newService(), env.newService()
+	first.CheckControllerActivity(now)
+	second.CheckControllerActivity(now)
+
+	inactivities := env.inactivities(t, periodStart)
+	if len(inactivities) != 1 || inactivities[0].Cid != 1001 || inactivities[0].Status != operation.InactivityStatusWarned {
+		t.Fatalf("expected one warning for 1001, got %+v", inactivities)
+	}
+	if len(env.emails.inactivity) != 1 {
+		t.Errorf("expected one warning email, got %v", env.emails.inactivity)
+	}
+}
+
+func TestCheckControllerActivityDemotesOnceAcrossInstances(t *testing.T) {
+	env := newActivityEnvironment(t)
+	env.config.Inactivity.Demote = true
+	env.config.Inactivity.DemoteRating = fsd.Observer.Index()
+	env.newController(t, 1001, fsd.STU2)
+	now := checkTime()
+	periodStart := env.config.Inactivity.PeriodStart(now).AddDate(0, -env.config.Inactivity.PeriodMonths, 0)
+
+	first, second := env.newService(), env.newService()
+	first.CheckControllerActivity(now)
+
+	// 宽限期内不降级
+	first.CheckControllerActivity(now.Add(24 * time.Hour))
+	if user, _ := env.operations.UserOperation().GetUserByCid(1001); user.Rating != fsd.STU2.Index() {
+		t.Fatalf("expected rating unchanged within grace period, got %d", user.Rating)
+	}
+
+	after := now.Add(env.config.Inactivity.GraceDuration + time.Hour)
+	var wg sync.WaitGroup
+	for _, service := range []*ControllerActivityService{first, second} {
+		wg.Add(1)
+		go func(service *ControllerActivityService) {
+			defer wg.Done()
+			service.handleWarnedControllers(after)
+		}(service)
+	}
+	wg.Wait()
+
+	if user, _ := env.operations.UserOperation().GetUserByCid(1001); user.Rating != fsd.Observer.Index() {
+		t.Errorf("expected demoted to observer, got %d", user.Rating)
+	}
+	inactivities := env.inactivities(t, periodStart)
+	if len(inactivities) != 1 || inactivities[0].Status != operation.InactivityStatusDemoted {
+		t.Fatalf("expected inactivity demoted, got %+v", inactivities)
+	}
+	if len(env.emails.ratingChanges) != 1 {
+		t.Errorf("expected one rating change email, got %v", env.emails.ratingChanges)
+	}
+}
+
+func TestCheckControllerActivityMarksInactiveWithoutDemote(t *testing.T) {
+	env := newActivityEnvironment(t)
+	env.newController(t, 1001, fsd.STU2)
+	now := checkTime()
+	periodStart := env.config.Inactivity.PeriodStart(now).AddDate(0, -env.config.Inactivity.PeriodMonths, 0)
+
+	service := env.newService()
+	service.CheckControllerActivity(now)
+	service.CheckControllerActivity(now.Add(env.config.Inactivity.GraceDuration + time.Hour))
+
+	if user, _ := env.operations.UserOperation().GetUserByCid(1001); user.Rating != fsd.STU2.Index() {
+		t.Errorf("expected rating unchanged, got %d", user.Rating)
+	}
+	if inactivities := env.inactivities(t, periodStart); len(inactivities) != 1 || inactivities[0].Status != operation.InactivityStatusInactive {
+		t.Fatalf("expected inactivity marked inactive, got %+v", inactivities)
+	}
+}
+
+func TestCheckControllerActivityResolvesWithinGrace(t *testing.T) {
+	env := newActivityEnvironment(t)
+	env.config.Inactivity.Demote = true
+	env.config.Inactivity.DemoteRating = fsd.Observer.Index()
+	env.newController(t, 1001, fsd.STU2)
+	env.newController(t, 1002, fsd.STU2)
+	now := checkTime()
+	periodStart := env.config.Inactivity.PeriodStart(now).AddDate(0, -env.config.Inactivity.PeriodMonths, 0)
+
+	service := env.newService()
+	service.CheckControllerActivity(now)
+
+	// 1001在宽限期内补足管制时长, 1002在检查时仍在线且已达标
+	env.addAtcHistory(t, 1001, now.Add(time.Hour), now.Add(5*time.Hour))
+	after := now.Add(env.config.Inactivity.GraceDuration + time.Hour)
+	env.clientManager.clients = []fsd.ClientInterface{
+		&testActivityClient{history: &operation.History{Cid: 1002, StartTime: after.Add(-4 * time.Hour), IsAtc: true}},
+	}
+	service.CheckControllerActivity(after)
+
+	for _, inactivity := range env.inactivities(t, periodStart) {
+		if inactivity.Status != operation.InactivityStatusResolved {
+			t.Errorf("expected inactivity of %d resolved, got status %d", inactivity.Cid, inactivity.Status)
+		}
+	}
+	for _, cid := range []int{1001, 1002} {
+		if user, _ := env.operations.UserOperation().GetUserByCid(cid); user.Rating != fsd.STU2.Index() {
+			t.Errorf("expected %d not demoted, got %d", cid, user.Rating)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
 	. "github.com/half-nothing/simple-fsd/internal/interfaces/service"
+	"github.com/half-nothing/simple-fsd/internal/metrics"
 	"gopkg.in/gomail.v2"
 	"html/template"
 	"math/rand"
@@ -26,7 +27,9 @@ var (
 type EmailService struct {
 	logger       log.LoggerInterface
 	emailCodes   map[string]EmailCode
+	resetCodes   map[string]EmailCode
 	lastSendTime map[string]time.Time
+	lock         sync.Mutex // 保护验证码与发送时间
 	config       *config.EmailConfig
 }
 
@@ -34,8 +37,12 @@ type EmailCode struct {
 	code     int
 	cid      int
 	sendTime time.Time
+	attempts int
 }
 
+// maxEmailCodeAttempts 验证码允许的最大错误次数, 达到后验证码失效
+const maxEmailCodeAttempts = 5
+
 type EmailVerifyTemplateData struct {
 	Cid     string
 	Code    string
@@ -64,12 +71,43 @@ type EmailKickedFromServerData struct {
 	Contact  string
 }
 
+type EmailTicketUpdateData struct {
+	Cid      string
+	TicketId string
+	Title    string
+	Event    string
+	Content  string
+	Time     string
+	Operator string
+}
+
+type EmailInactivityData struct {
+	Cid          string
+	PeriodStart  string
+	PeriodEnd    string
+	AtcTime      string
+	MinimumTime  string
+	PeriodMonths string
+	GraceUntil   string
+	Action       string
+	Contact      string
+}
+
+type EmailHelpRequestData struct {
+	Id       string
+	Cid      string
+	Callsign string
+	Message  string
+	Time     string
+}
+
 func NewEmailService(logger log.LoggerInterface, config *config.EmailConfig) *EmailService {
 	once.Do(func() {
 		emailService = &EmailService{
 			logger:       logger,
 			config:       config,
 			emailCodes:   make(map[string]EmailCode),
+			resetCodes:   make(map[string]EmailCode),
 			lastSendTime: make(map[string]time.Time),
 		}
 	})
@@ -101,25 +139,78 @@ func (emailService *EmailService) VerifyCode(email string, code int, cid int) er
 	if emailService.config.EmailServer == nil {
 		return nil
 	}
+	return emailService.verifyCode(emailService.emailCodes, email, code, cid)
+}
+
+// VerifyPasswordResetCode 校验重置密码验证码, 与注册验证码分开保存, 避免互相冒用
+func (emailService *EmailService) VerifyPasswordResetCode(email string, code int, cid int) error {
+	if emailService.config.EmailServer == nil {
+		return nil
+	}
+	return emailService.verifyCode(emailService.resetCodes, email, code, cid)
+}
+
+func (emailService *EmailService) verifyCode(codes map[string]EmailCode, email string, code int, cid int) error {
 	email = strings.ToLower(email)
-	emailCode, ok := emailService.emailCodes[email]
+	emailService.lock.Lock()
+	defer emailService.lock.Unlock()
+	emailCode, ok := codes[email]
 	if !ok {
 		return ErrEmailCodeNotFound
 	}
 
 	if time.Since(emailCode.sendTime) > emailService.config.VerifyExpiredDuration {
+		delete(codes, email)
 		return ErrEmailCodeExpired
 	}
 
+	var err error
 	if emailCode.code != code {
-		return ErrInvalidEmailCode
+		err = ErrInvalidEmailCode
+	} else if emailCode.cid != cid {
+		err = ErrCidMismatch
+	}
+	if err != nil {
+		// 错误次数过多时验证码失效, 防止暴力猜测
+		emailCode.attempts++
+		if emailCode.attempts >= maxEmailCodeAttempts {
+			delete(codes, email)
+		} else {
+			codes[email] = emailCode
+		}
+		return err
 	}
 
-	if emailCode.cid != cid {
-		return ErrCidMismatch
+	delete(codes, email)
+	return nil
+}
+
+// checkSendInterval 检查距离上次向email发送邮件是否超过发送间隔, 未超过时返回 ErrEmailSendInterval, 否则记录本次发送时间
+func (emailService *EmailService) checkSendInterval(email string) error {
+	emailService.lock.Lock()
+	defer emailService.lock.Unlock()
+	if lastSendTime, ok := emailService.lastSendTime[email]; ok {
+		if time.Since(lastSendTime) < emailService.config.SendDuration {
+			return ErrEmailSendInterval
+		}
 	}
+	emailService.lastSendTime[email] = time.Now()
+	return nil
+}
+
+// saveCode 保存发送给email的验证码
+func (emailService *EmailService) saveCode(codes map[string]EmailCode, email string, emailCode EmailCode) {
+	emailService.lock.Lock()
+	defer emailService.lock.Unlock()
+	codes[email] = emailCode
+}
 
-	delete(emailService.emailCodes, email)
+// dialAndSend 发送邮件并统计发送失败次数
+func (emailService *EmailService) dialAndSend(emailType string, message *gomail.Message) error {
+	if err := emailService.config.EmailServer.DialAndSend(message); err != nil {
+		metrics.EmailSendFailures.WithLabelValues(emailType).Inc()
+		return err
+	}
 	return nil
 }
 
@@ -128,10 +219,8 @@ func (emailService *EmailService) SendEmailCode(email string, cid int) error {
 		return nil
 	}
 	email = strings.ToLower(email)
-	if lastSendTime, ok := emailService.lastSendTime[email]; ok {
-		if time.Since(lastSendTime) < emailService.config.SendDuration {
-			return ErrEmailSendInterval
-		}
+	if err := emailService.checkSendInterval(email); err != nil {
+		return err
 	}
 	code := rand.Intn(9e5) + 1e5
 	emailCode := EmailCode{code: code, cid: cid, sendTime: time.Now()}
@@ -141,7 +230,7 @@ func (emailService *EmailService) SendEmailCode(email string, cid int) error {
 		Expired: strconv.Itoa(int(emailService.config.VerifyExpiredDuration.Minutes())),
 	}
 
-	message, err := emailService.RenderTemplate(emailService.config.Template.EmailVerifyTemplate, data)
+	message, err := emailService.RenderTemplate(emailService.config.Templates().EmailVerifyTemplate, data)
 	if err != nil {
 		emailService.logger.WarnF("Error rendering email verification template: %v", err)
 		return ErrRenderingTemplate
@@ -153,12 +242,50 @@ func (emailService *EmailService) SendEmailCode(email string, cid int) error {
 	m.SetHeader("Subject", "您的验证码")
 	m.SetBody("text/html", message)
 
-	emailService.emailCodes[email] = emailCode
-	emailService.lastSendTime[email] = time.Now()
+	emailService.saveCode(emailService.emailCodes, email, emailCode)
 
 	emailService.logger.InfoF("Sending email verification code(%d) to %s(%d)", code, email, cid)
 
-	return emailService.config.EmailServer.DialAndSend(m)
+	return emailService.dialAndSend("verify_code", m)
+}
+
+func (emailService *EmailService) SendPasswordResetCode(email string, user *operation.User) error {
+	if emailService.config.EmailServer == nil {
+		return nil
+	}
+	email = strings.ToLower(email)
+	// 未注册的邮箱同样记录发送间隔, 使响应与已注册的邮箱一致
+	if err := emailService.checkSendInterval(email); err != nil {
+		return err
+	}
+	if user == nil {
+		return nil
+	}
+	code := rand.Intn(9e5) + 1e5
+	emailCode := EmailCode{code: code, cid: user.Cid, sendTime: time.Now()}
+	data := &EmailVerifyTemplateData{
+		Cid:     fmt.Sprintf("%04d", user.Cid),
+		Code:    strconv.Itoa(code),
+		Expired: strconv.Itoa(int(emailService.config.VerifyExpiredDuration.Minutes())),
+	}
+
+	message, err := emailService.RenderTemplate(emailService.config.Templates().PasswordResetTemplate, data)
+	if err != nil {
+		emailService.logger.WarnF("Error rendering password reset template: %v", err)
+		return ErrRenderingTemplate
+	}
+
+	m := gomail.NewMessage()
+	m.SetHeader("From", emailService.config.Username)
+	m.SetHeader("To", email)
+	m.SetHeader("Subject", "重置密码验证码")
+	m.SetBody("text/html", message)
+
+	emailService.saveCode(emailService.resetCodes, email, emailCode)
+
+	emailService.logger.InfoF("Sending password reset code to %s(%d)", email, user.Cid)
+
+	return emailService.dialAndSend("password_reset", m)
 }
 
 func (emailService *EmailService) SendPermissionChangeEmail(user *operation.User, operator *operation.User) error {
@@ -171,7 +298,7 @@ func (emailService *EmailService) SendPermissionChangeEmail(user *operation.User
 		Operator: fmt.Sprintf("%04d", operator.Cid),
 		Contact:  operator.Email,
 	}
-	message, err := emailService.RenderTemplate(emailService.config.Template.PermissionChangeTemplate, data)
+	message, err := emailService.RenderTemplate(emailService.config.Templates().PermissionChangeTemplate, data)
 	if err != nil {
 		emailService.logger.WarnF("Error rendering email verification template: %v", err)
 		return ErrRenderingTemplate
@@ -185,7 +312,7 @@ func (emailService *EmailService) SendPermissionChangeEmail(user *operation.User
 
 	emailService.logger.InfoF("Sending permission change email to %s(%d)", email, user.Cid)
 
-	return emailService.config.EmailServer.DialAndSend(m)
+	return emailService.dialAndSend("permission_change", m)
 }
 
 func (emailService *EmailService) SendRatingChangeEmail(user *operation.User, operator *operation.User, oldRating, newRating fsd.Rating) error {
@@ -200,7 +327,7 @@ func (emailService *EmailService) SendRatingChangeEmail(user *operation.User, op
 		Operator: fmt.Sprintf("%04d", operator.Cid),
 		Contact:  operator.Email,
 	}
-	message, err := emailService.RenderTemplate(emailService.config.Template.ATCRatingChangeTemplate, data)
+	message, err := emailService.RenderTemplate(emailService.config.Templates().ATCRatingChangeTemplate, data)
 	if err != nil {
 		emailService.logger.WarnF("Error rendering email verification template: %v", err)
 		return ErrRenderingTemplate
@@ -214,7 +341,7 @@ func (emailService *EmailService) SendRatingChangeEmail(user *operation.User, op
 
 	emailService.logger.InfoF("Sending rating change email to %s(%d)", email, user.Cid)
 
-	return emailService.config.EmailServer.DialAndSend(m)
+	return emailService.dialAndSend("rating_change", m)
 }
 
 func (emailService *EmailService) SendKickedFromServerEmail(user *operation.User, operator *operation.User, reason string) error {
@@ -229,7 +356,7 @@ func (emailService *EmailService) SendKickedFromServerEmail(user *operation.User
 		Operator: fmt.Sprintf("%04d", operator.Cid),
 		Contact:  operator.Email,
 	}
-	message, err := emailService.RenderTemplate(emailService.config.Template.KickedFromServerTemplate, data)
+	message, err := emailService.RenderTemplate(emailService.config.Templates().KickedFromServerTemplate, data)
 	if err != nil {
 		emailService.logger.WarnF("Error rendering email verification template: %v", err)
 		return ErrRenderingTemplate
@@ -243,7 +370,109 @@ func (emailService *EmailService) SendKickedFromServerEmail(user *operation.User
 
 	emailService.logger.InfoF("Sending kick message email to %s(%d)", email, user.Cid)
 
-	return emailService.config.EmailServer.DialAndSend(m)
+	return emailService.dialAndSend("kicked_from_server", m)
+}
+
+func (emailService *EmailService) SendHelpRequestEmail(emails []string, request *operation.HelpRequest) error {
+	if emailService.config.EmailServer == nil || len(emails) == 0 {
+		return nil
+	}
+	data := &EmailHelpRequestData{
+		Id:       strconv.Itoa(int(request.ID)),
+		Cid:      fmt.Sprintf("%04d", request.Cid),
+		Callsign: request.Callsign,
+		Message:  request.Message,
+		Time:     request.CreatedAt.Format(time.DateTime),
+	}
+	message, err := emailService.RenderTemplate(emailService.config.Templates().HelpRequestTemplate, data)
+	if err != nil {
+		emailService.logger.WarnF("Error rendering help request template: %v", err)
+		return ErrRenderingTemplate
+	}
+
+	m := gomail.NewMessage()
+	m.SetHeader("From", emailService.config.Username)
+	m.SetHeader("To", emails...)
+	m.SetHeader("Subject", fmt.Sprintf("求助请求 #%d", request.ID))
+	m.SetBody("text/html", message)
+
+	emailService.logger.InfoF("Sending help request #%d email to %s", request.ID, strings.Join(emails, ", "))
+
+	return emailService.dialAndSend("help_request", m)
+}
+
+func (emailService *EmailService) SendTicketUpdateEmail(user *operation.User, operator *operation.User, ticket *operation.Ticket, event string, content string) error {
+	if emailService.config.EmailServer == nil {
+		return nil
+	}
+	email := strings.ToLower(user.Email)
+	data := &EmailTicketUpdateData{
+		Cid:      strconv.Itoa(user.Cid),
+		TicketId: strconv.Itoa(int(ticket.ID)),
+		Title:    ticket.Title,
+		Event:    event,
+		Content:  content,
+		Time:     time.Now().Format(time.DateTime),
+		Operator: fmt.Sprintf("%04d", operator.Cid),
+	}
+	message, err := emailService.RenderTemplate(emailService.config.Templates().TicketUpdateTemplate, data)
+	if err != nil {
+		emailService.logger.WarnF("Error rendering ticket update template: %v", err)
+		return ErrRenderingTemplate
+	}
+
+	m := gomail.NewMessage()
+	m.SetHeader("From", emailService.config.Username)
+	m.SetHeader("To", email)
+	m.SetHeader("Subject", fmt.Sprintf("工单 #%d 更新通知", ticket.ID))
+	m.SetBody("text/html", message)
+
+	emailService.logger.InfoF("Sending ticket #%d update email to %s(%d)", ticket.ID, email, user.Cid)
+
+	return emailService.dialAndSend("ticket_update", m)
+}
+
+// formatAtcTime 将管制时长格式化为小时与分钟
+func formatAtcTime(duration time.Duration) string {
+	minutes := int(duration.Minutes())
+	return fmt.Sprintf("%d小时%d分钟", minutes/60, minutes%60)
+}
+
+func (emailService *EmailService) SendInactivityEmail(user *operation.User, inactivity *operation.ControllerInactivity, config *config.InactivityConfig) error {
+	if emailService.config.EmailServer == nil {
+		return nil
+	}
+	email := strings.ToLower(user.Email)
+	action := "您将被标记为不活跃管制员"
+	if config.Demote {
+		action = fmt.Sprintf("您的管制权限将被降为 %s", fsd.Rating(config.DemoteRating).String())
+	}
+	data := &EmailInactivityData{
+		Cid:          strconv.Itoa(user.Cid),
+		PeriodStart:  inactivity.PeriodStart.Format(time.DateOnly),
+		PeriodEnd:    inactivity.PeriodEnd.Add(-time.Second).Format(time.DateOnly),
+		AtcTime:      formatAtcTime(time.Duration(inactivity.AtcTime) * time.Second),
+		MinimumTime:  formatAtcTime(config.MinimumTime()),
+		PeriodMonths: strconv.Itoa(config.PeriodMonths),
+		GraceUntil:   inactivity.GraceUntil.Format(time.DateTime),
+		Action:       action,
+		Contact:      emailService.config.Username,
+	}
+	message, err := emailService.RenderTemplate(emailService.config.Templates().InactivityTemplate, data)
+	if err != nil {
+		emailService.logger.WarnF("Error rendering inactivity template: %v", err)
+		return ErrRenderingTemplate
+	}
+
+	m := gomail.NewMessage()
+	m.SetHeader("From", emailService.config.Username)
+	m.SetHeader("To", email)
+	m.SetHeader("Subject", "管制活跃度警告")
+	m.SetBody("text/html", message)
+
+	emailService.logger.InfoF("Sending inactivity email to %s(%d)", email, user.Cid)
+
+	return emailService.dialAndSend("inactivity", m)
 }
 
 var (
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"errors"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestEmailService() *EmailService {
+	return &EmailService{
+		emailCodes:   make(map[string]EmailCode),
+		resetCodes:   make(map[string]EmailCode),
+		lastSendTime: make(map[string]time.Time),
+		config: &config.EmailConfig{
+			VerifyExpiredDuration: 5 * time.Minute,
+			SendDuration:          time.Minute,
+		},
+	}
+}
+
+func TestVerifyCodeAttempts(t *testing.T) {
+	emailService := newTestEmailService()
+	emailService.saveCode(emailService.resetCodes, "user@example.com", EmailCode{code: 123456, cid: 1000, sendTime: time.Now()})
+
+	for i := 0; i < maxEmailCodeAttempts-1; i++ {
+		if err := emailService.verifyCode(emailService.resetCodes, "user@example.com", 111111, 1000); !errors.Is(err, ErrInvalidEmailCode) {
+			t.Fatalf("attempt %d: expected invalid code, got %v", i, err)
+		}
+	}
+	// 达到最大错误次数后验证码失效, 正确的验证码也无法使用
+	if err := emailService.verifyCode(emailService.resetCodes, "user@example.com", 111111, 1000); !errors.Is(err, ErrInvalidEmailCode) {
+		t.Fatalf("expected invalid code, got %v", err)
+	}
+	if err := emailService.verifyCode(emailService.resetCodes, "user@example.com", 123456, 1000); !errors.Is(err, ErrEmailCodeNotFound) {
+		t.Errorf("expected code removed after too many attempts, got %v", err)
+	}
+}
+
+func TestVerifyCode(t *testing.T) {
+	emailService := newTestEmailService()
+	emailService.saveCode(emailService.resetCodes, "user@example.com", EmailCode{code: 123456, cid: 1000, sendTime: time.Now()})
+	if err := emailService.verifyCode(emailService.resetCodes, "user@example.com", 123456, 1001); !errors.Is(err, ErrCidMismatch) {
+		t.Errorf("expected cid mismatch, got %v", err)
+	}
+	if err := emailService.verifyCode(emailService.resetCodes, "USER@example.com", 123456, 1000); err != nil {
+		t.Errorf("expected success, got %v", err)
+	}
+	if err := emailService.verifyCode(emailService.resetCodes, "user@example.com", 123456, 1000); !errors.Is(err, ErrEmailCodeNotFound) {
+		t.Errorf("code should be used only once, got %v", err)
+	}
+
+	emailService.saveCode(emailService.resetCodes, "user@example.com", EmailCode{code: 123456, cid: 1000, sendTime: time.Now().Add(-time.Hour)})
+	if err := emailService.verifyCode(emailService.resetCodes, "user@example.com", 123456, 1000); !errors.Is(err, ErrEmailCodeExpired) {
+		t.Errorf("expected expired, got %v", err)
+	}
+}
+
+func TestCheckSendIntervalConcurrent(t *testing.T) {
+	emailService := newTestEmailService()
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	passed := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if emailService.checkSendInterval("user@example.com") == nil {
+				lock.Lock()
+				passed++
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if passed != 1 {
+		t.Errorf("expected only one send within interval, got %d", passed)
+	}
+}
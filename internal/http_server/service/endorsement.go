@@ -0,0 +1,174 @@
+// Package service
+package service
+
+import (
+	"fmt"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/service"
+	"github.com/half-nothing/simple-fsd/internal/utils"
+	"strings"
+	"time"
+)
+
+type EndorsementService struct {
+	logger               log.LoggerInterface
+	config               *config.ServerConfig
+	userOperation        operation.UserOperationInterface
+	endorsementOperation operation.PositionEndorsementOperationInterface
+	auditLogOperation    operation.AuditLogOperationInterface
+}
+
+func NewEndorsementService(
+	logger log.LoggerInterface,
+	config *config.ServerConfig,
+	userOperation operation.UserOperationInterface,
+	endorsementOperation operation.PositionEndorsementOperationInterface,
+	auditLogOperation operation.AuditLogOperationInterface,
+) *EndorsementService {
+	return &EndorsementService{
+		logger:               logger,
+		config:               config,
+		userOperation:        userOperation,
+		endorsementOperation: endorsementOperation,
+		auditLogOperation:    auditLogOperation,
+	}
+}
+
+// formatEndorsement 审计日志中席位授权的文本表示
+func formatEndorsement(endorsement *operation.PositionEndorsement) string {
+	if endorsement.ExpiresAt == nil {
+		return endorsement.Pattern
+	}
+	return fmt.Sprintf("%s until %s", endorsement.Pattern, endorsement.ExpiresAt.UTC().Format(time.RFC3339))
+}
+
+func (endorsementService *EndorsementService) saveAuditLog(eventType operation.EventType, req *EchoContentHeader, cid int, endorsement *operation.PositionEndorsement, changeDetail *operation.ChangeDetail) {
+	go func() {
+		auditLog := endorsementService.auditLogOperation.NewAuditLog(eventType, cid, fmt.Sprintf("%04d", endorsement.Cid), req.Ip, req.UserAgent, changeDetail)
+		if err := endorsementService.auditLogOperation.SaveAuditLog(auditLog); err != nil {
+			endorsementService.logger.ErrorF("Fail to create audit log for %s, detail: %v", eventType, err)
+		}
+	}()
+}
+
+var SuccessGetEndorsedPositions = ApiStatus{StatusName: "GET_ENDORSED_POSITIONS", Description: "成功获取需要授权的席位", HttpCode: Ok}
+
+func (endorsementService *EndorsementService) GetEndorsedPositions(_ *RequestGetEndorsedPositions) *ApiResponse[ResponseGetEndorsedPositions] {
+	data := ResponseGetEndorsedPositions(endorsementService.config.FSDServer.EndorsedPositionPatterns())
+	return NewApiResponse(&SuccessGetEndorsedPositions, Unsatisfied, &data)
+}
+
+var SuccessGetEndorsements = ApiStatus{StatusName: "GET_ENDORSEMENTS", Description: "成功获取席位授权", HttpCode: Ok}
+
+func (endorsementService *EndorsementService) GetEndorsements(req *RequestGetEndorsements) *ApiResponse[ResponseGetEndorsements] {
+	endorsements, err := endorsementService.endorsementOperation.GetActivePositionEndorsements(req.Cid)
+	if err != nil {
+		endorsementService.logger.ErrorF("Error getting position endorsements of %04d: %v", req.Cid, err)
+		return NewApiResponse[ResponseGetEndorsements](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+	data := ResponseGetEndorsements(endorsements)
+	return NewApiResponse(&SuccessGetEndorsements, Unsatisfied, &data)
+}
+
+func (endorsementService *EndorsementService) GetEndorsementsPage(req *RequestGetEndorsementsPage) *ApiResponse[ResponseGetEndorsementsPage] {
+	if req.Page <= 0 || req.PageSize <= 0 || req.TargetCid < 0 {
+		return NewApiResponse[ResponseGetEndorsementsPage](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	permission := req.Permission
+	if !permission.HasPermission(operation.EndorsementManage) {
+		return NewApiResponse[ResponseGetEndorsementsPage](&ErrNoPermission, Unsatisfied, nil)
+	}
+	endorsements, total, err := endorsementService.endorsementOperation.GetPositionEndorsements(req.TargetCid, req.Page, req.PageSize)
+	if err != nil {
+		return NewApiResponse[ResponseGetEndorsementsPage](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+	return NewApiResponse(&SuccessGetEndorsements, Unsatisfied, &ResponseGetEndorsementsPage{
+		Items:    endorsements,
+		Page:     req.Page,
+		PageSize: req.PageSize,
+		Total:    total,
+	})
+}
+
+var (
+	ErrEndorsementPattern   = ApiStatus{StatusName: "ENDORSEMENT_PATTERN_INVALID", Description: "席位呼号模式只能包含字母, 数字, 下划线与通配符*和?", HttpCode: BadRequest}
+	ErrEndorsementExpiresAt = ApiStatus{StatusName: "ENDORSEMENT_EXPIRES_AT_INVALID", Description: "席位授权过期时间必须晚于当前时间", HttpCode: BadRequest}
+	SuccessAddEndorsement   = ApiStatus{StatusName: "ADD_ENDORSEMENT", Description: "授予席位授权成功", HttpCode: Ok}
+)
+
+func (endorsementService *EndorsementService) AddEndorsement(req *RequestAddEndorsement) *ApiResponse[ResponseAddEndorsement] {
+	if req.TargetCid <= 0 {
+		return NewApiResponse[ResponseAddEndorsement](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	req.Pattern = strings.ToUpper(strings.TrimSpace(req.Pattern))
+	if !utils.CallsignPatternValid(req.Pattern) {
+		return NewApiResponse[ResponseAddEndorsement](&ErrEndorsementPattern, Unsatisfied, nil)
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return NewApiResponse[ResponseAddEndorsement](&ErrEndorsementExpiresAt, Unsatisfied, nil)
+	}
+	user, res := CallDBFuncAndCheckError[operation.User, ResponseAddEndorsement](func() (*operation.User, error) {
+		return endorsementService.userOperation.GetUserByUid(req.Uid)
+	})
+	if res != nil {
+		return res
+	}
+	if !req.RealtimePermission(user).HasPermission(operation.EndorsementManage) {
+		return NewApiResponse[ResponseAddEndorsement](&ErrNoPermission, Unsatisfied, nil)
+	}
+	target, res := CallDBFuncAndCheckError[operation.User, ResponseAddEndorsement](func() (*operation.User, error) {
+		return endorsementService.userOperation.GetUserByCid(req.TargetCid)
+	})
+	if res != nil {
+		return res
+	}
+	endorsement := endorsementService.endorsementOperation.NewPositionEndorsement(target, req.Pattern, req.ExpiresAt, user)
+	if err := endorsementService.endorsementOperation.SavePositionEndorsement(endorsement); err != nil {
+		endorsementService.logger.ErrorF("Error saving position endorsement: %v", err)
+		return NewApiResponse[ResponseAddEndorsement](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+
+	endorsementService.saveAuditLog(operation.EndorsementGranted, &req.EchoContentHeader, req.Cid, endorsement, &operation.ChangeDetail{
+		OldValue: "",
+		NewValue: formatEndorsement(endorsement),
+	})
+
+	return NewApiResponse(&SuccessAddEndorsement, Unsatisfied, (*ResponseAddEndorsement)(endorsement))
+}
+
+var SuccessDeleteEndorsement = ApiStatus{StatusName: "DELETE_ENDORSEMENT", Description: "撤销席位授权成功", HttpCode: Ok}
+
+func (endorsementService *EndorsementService) DeleteEndorsement(req *RequestDeleteEndorsement) *ApiResponse[ResponseDeleteEndorsement] {
+	if req.EndorsementId <= 0 {
+		return NewApiResponse[ResponseDeleteEndorsement](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	user, res := CallDBFuncAndCheckError[operation.User, ResponseDeleteEndorsement](func() (*operation.User, error) {
+		return endorsementService.userOperation.GetUserByUid(req.Uid)
+	})
+	if res != nil {
+		return res
+	}
+	if !req.RealtimePermission(user).HasPermission(operation.EndorsementManage) {
+		return NewApiResponse[ResponseDeleteEndorsement](&ErrNoPermission, Unsatisfied, nil)
+	}
+	endorsement, res := CallDBFuncAndCheckError[operation.PositionEndorsement, ResponseDeleteEndorsement](func() (*operation.PositionEndorsement, error) {
+		return endorsementService.endorsementOperation.GetPositionEndorsementById(req.EndorsementId)
+	})
+	if res != nil {
+		return res
+	}
+	if err := endorsementService.endorsementOperation.DeletePositionEndorsement(endorsement); err != nil {
+		endorsementService.logger.ErrorF("Error deleting position endorsement: %v", err)
+		return NewApiResponse[ResponseDeleteEndorsement](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+
+	endorsementService.saveAuditLog(operation.EndorsementRevoked, &req.EchoContentHeader, req.Cid, endorsement, &operation.ChangeDetail{
+		OldValue: formatEndorsement(endorsement),
+		NewValue: "",
+	})
+
+	data := ResponseDeleteEndorsement(true)
+	return NewApiResponse(&SuccessDeleteEndorsement, Unsatisfied, &data)
+}
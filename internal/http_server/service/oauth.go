@@ -0,0 +1,470 @@
+// Package service
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/service"
+	"github.com/half-nothing/simple-fsd/internal/utils"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// authorizationCode 授权码只保存在内存中, 使用一次后立即失效
+type authorizationCode struct {
+	clientId      string
+	cid           int
+	redirectUri   string
+	redirectSent  bool // 授权请求是否显式携带了redirect_uri
+	scope         string
+	nonce         string
+	codeChallenge string
+	authTime      time.Time
+	expiresAt     time.Time
+}
+
+type OAuthService struct {
+	logger            log.LoggerInterface
+	config            *config.HttpServerConfig
+	userOperation     operation.UserOperationInterface
+	clientOperation   operation.OAuthClientOperationInterface
+	auditLogOperation operation.AuditLogOperationInterface
+	codesLock         sync.Mutex
+	codes             map[string]*authorizationCode
+}
+
+func NewOAuthService(
+	logger log.LoggerInterface,
+	config *config.HttpServerConfig,
+	userOperation operation.UserOperationInterface,
+	clientOperation operation.OAuthClientOperationInterface,
+	auditLogOperation operation.AuditLogOperationInterface,
+) *OAuthService {
+	return &OAuthService{
+		logger:            logger,
+		config:            config,
+		userOperation:     userOperation,
+		clientOperation:   clientOperation,
+		auditLogOperation: auditLogOperation,
+		codes:             make(map[string]*authorizationCode),
+	}
+}
+
+// issuer 令牌签发者, 与服务发现中的issuer一致
+func (oauthService *OAuthService) issuer() string {
+	return strings.TrimRight(oauthService.config.ServerAddress, "/")
+}
+
+// signToken 访问令牌只由本服务器校验, 使用jwt密钥签名
+func (oauthService *OAuthService) signToken(claims jwt.Claims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
+	tokenString, _ := token.SignedString([]byte(oauthService.config.JWT.Secret))
+	return tokenString
+}
+
+// signIdToken ID令牌使用RSA私钥签名, 第三方应用通过jwks_uri获取公钥校验
+func (oauthService *OAuthService) signIdToken(claims jwt.Claims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = oauthService.config.OAuth.SigningKeyId
+	tokenString, _ := token.SignedString(oauthService.config.OAuth.SigningKey)
+	return tokenString
+}
+
+func newOAuthError(httpCode int, code string, description string) *OAuthError {
+	return &OAuthError{Error: code, Description: description, HttpCode: httpCode}
+}
+
+var (
+	ErrOAuthResponseType = ApiStatus{StatusName: "OAUTH_UNSUPPORTED_RESPONSE_TYPE", Description: "只支持授权码模式", HttpCode: BadRequest}
+	ErrOAuthRedirectUri  = ApiStatus{StatusName: "OAUTH_INVALID_REDIRECT_URI", Description: "回调地址未在应用中登记", HttpCode: BadRequest}
+	ErrOAuthScope        = ApiStatus{StatusName: "OAUTH_INVALID_SCOPE", Description: "申请的权限范围无效", HttpCode: BadRequest}
+	ErrOAuthPkce         = ApiStatus{StatusName: "OAUTH_PKCE_REQUIRED", Description: "必须使用S256方法的PKCE", HttpCode: BadRequest}
+	ErrOAuthUserBanned   = ApiStatus{StatusName: "OAUTH_USER_BANNED", Description: "账户已被封禁, 无法授权第三方应用", HttpCode: PermissionDenied}
+)
+
+// checkAuthorizeRequest 校验授权请求, 返回第三方应用、回调地址与权限范围
+func (oauthService *OAuthService) checkAuthorizeRequest(req *RequestOAuthAuthorize) (*operation.OAuthClient, string, []string, *ApiStatus) {
+	if req.ClientId == "" {
+		return nil, "", nil, &ErrIllegalParam
+	}
+	if req.ResponseType != "code" {
+		return nil, "", nil, &ErrOAuthResponseType
+	}
+	client, err := oauthService.clientOperation.GetOAuthClientByClientId(req.ClientId)
+	if errors.Is(err, operation.ErrOAuthClientNotFound) {
+		return nil, "", nil, &ErrOAuthClientNotFound
+	} else if err != nil {
+		return nil, "", nil, &ErrDatabaseFail
+	}
+	redirectUris := strings.Fields(client.RedirectUris)
+	redirectUri := req.RedirectUri
+	if redirectUri == "" && len(redirectUris) == 1 {
+		redirectUri = redirectUris[0]
+	}
+	if !slices.Contains(redirectUris, redirectUri) {
+		return nil, "", nil, &ErrOAuthRedirectUri
+	}
+	scopes := strings.Fields(req.Scope)
+	allowed := strings.Fields(client.Scopes)
+	if len(scopes) == 0 {
+		return nil, "", nil, &ErrOAuthScope
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(allowed, scope) {
+			return nil, "", nil, &ErrOAuthScope
+		}
+	}
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != "S256" {
+		return nil, "", nil, &ErrOAuthPkce
+	}
+	return client, redirectUri, scopes, nil
+}
+
+var SuccessGetAuthorizeInfo = ApiStatus{StatusName: "GET_AUTHORIZE_INFO", Description: "成功获取授权信息", HttpCode: Ok}
+
+func (oauthService *OAuthService) GetAuthorizeInfo(req *RequestOAuthAuthorize) *ApiResponse[ResponseOAuthAuthorizeInfo] {
+	client, redirectUri, scopes, res := oauthService.checkAuthorizeRequest(req)
+	if res != nil {
+		return NewApiResponse[ResponseOAuthAuthorizeInfo](res, Unsatisfied, nil)
+	}
+	return NewApiResponse(&SuccessGetAuthorizeInfo, Unsatisfied, &ResponseOAuthAuthorizeInfo{
+		ClientId:    client.ClientId,
+		Name:        client.Name,
+		RedirectUri: redirectUri,
+		Scopes:      scopes,
+	})
+}
+
+var SuccessAuthorize = ApiStatus{StatusName: "AUTHORIZE_SUCCESS", Description: "授权成功", HttpCode: Ok}
+
+func (oauthService *OAuthService) Authorize(req *RequestOAuthAuthorize) *ApiResponse[ResponseOAuthAuthorize] {
+	client, redirectUri, scopes, res := oauthService.checkAuthorizeRequest(req)
+	if res != nil {
+		return NewApiResponse[ResponseOAuthAuthorize](res, Unsatisfied, nil)
+	}
+	user, apiRes := CallDBFuncAndCheckError[operation.User, ResponseOAuthAuthorize](func() (*operation.User, error) {
+		return oauthService.userOperation.GetUserByUid(req.Uid)
+	})
+	if apiRes != nil {
+		return apiRes
+	}
+	if userBanned(user) {
+		return NewApiResponse[ResponseOAuthAuthorize](&ErrOAuthUserBanned, Unsatisfied, nil)
+	}
+
+	buf := make([]byte, 32)
+	_, _ = rand.Read(buf)
+	code := hex.EncodeToString(buf)
+	now := time.Now()
+	oauthService.codesLock.Lock()
+	for key, value := range oauthService.codes {
+		if value.expiresAt.Before(now) {
+			delete(oauthService.codes, key)
+		}
+	}
+	oauthService.codes[code] = &authorizationCode{
+		clientId:      client.ClientId,
+		cid:           user.Cid,
+		redirectUri:   redirectUri,
+		redirectSent:  req.RedirectUri != "",
+		scope:         strings.Join(scopes, " "),
+		nonce:         req.Nonce,
+		codeChallenge: req.CodeChallenge,
+		authTime:      now,
+		expiresAt:     now.Add(oauthService.config.OAuth.CodeExpiresDuration),
+	}
+	oauthService.codesLock.Unlock()
+
+	target, _ := url.Parse(redirectUri)
+	query := target.Query()
+	query.Set("code", code)
+	if req.State != "" {
+		query.Set("state", req.State)
+	}
+	target.RawQuery = query.Encode()
+	return NewApiResponse(&SuccessAuthorize, Unsatisfied, &ResponseOAuthAuthorize{RedirectUri: target.String()})
+}
+
+// takeCode 取出授权码, 授权码只能使用一次
+func (oauthService *OAuthService) takeCode(code string) *authorizationCode {
+	oauthService.codesLock.Lock()
+	defer oauthService.codesLock.Unlock()
+	value, ok := oauthService.codes[code]
+	if !ok {
+		return nil
+	}
+	delete(oauthService.codes, code)
+	if value.expiresAt.Before(time.Now()) {
+		return nil
+	}
+	return value
+}
+
+func (oauthService *OAuthService) Token(req *RequestOAuthToken) (*ResponseOAuthToken, *OAuthError) {
+	if req.GrantType != "authorization_code" {
+		return nil, newOAuthError(http.StatusBadRequest, "unsupported_grant_type", "only authorization_code is supported")
+	}
+	if req.ClientId == "" || req.Code == "" || req.CodeVerifier == "" {
+		return nil, newOAuthError(http.StatusBadRequest, "invalid_request", "client_id, code and code_verifier are required")
+	}
+	client, err := oauthService.clientOperation.GetOAuthClientByClientId(req.ClientId)
+	if errors.Is(err, operation.ErrOAuthClientNotFound) {
+		return nil, newOAuthError(http.StatusUnauthorized, "invalid_client", "client not found")
+	} else if err != nil {
+		return nil, newOAuthError(http.StatusInternalServerError, "server_error", "database error")
+	}
+	if client.Confidential && !oauthService.clientOperation.VerifyOAuthClientSecret(client, req.ClientSecret) {
+		return nil, newOAuthError(http.StatusUnauthorized, "invalid_client", "client authentication failed")
+	}
+
+	code := oauthService.takeCode(req.Code)
+	if code == nil || code.clientId != client.ClientId {
+		return nil, newOAuthError(http.StatusBadRequest, "invalid_grant", "authorization code is invalid or expired")
+	}
+	// 授权请求携带了redirect_uri时令牌请求必须携带相同的值(RFC 6749 4.1.3)
+	if (code.redirectSent || req.RedirectUri != "") && code.redirectUri != req.RedirectUri {
+		return nil, newOAuthError(http.StatusBadRequest, "invalid_grant", "redirect_uri does not match")
+	}
+	if !utils.VerifyPkce(req.CodeVerifier, code.codeChallenge) {
+		return nil, newOAuthError(http.StatusBadRequest, "invalid_grant", "code_verifier does not match")
+	}
+
+	user, err := oauthService.userOperation.GetUserByCid(code.cid)
+	if err != nil {
+		return nil, newOAuthError(http.StatusBadRequest, "invalid_grant", "user not found")
+	}
+
+	now := time.Now()
+	expires := oauthService.config.OAuth.AccessTokenExpiresDuration
+	subject := strconv.Itoa(user.Cid)
+	accessToken := oauthService.signToken(&OAuthClaims{
+		ClientId: client.ClientId,
+		Scope:    code.scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    oauthService.issuer(),
+			Subject:   subject,
+			Audience:  jwt.ClaimStrings{client.ClientId},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expires)),
+		},
+	})
+	data := &ResponseOAuthToken{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(expires.Seconds()),
+		Scope:       code.scope,
+	}
+	if slices.Contains(strings.Fields(code.scope), OAuthScopeOpenId) {
+		data.IdToken = oauthService.signIdToken(&OAuthIdTokenClaims{
+			Nonce:    code.nonce,
+			AuthTime: code.authTime.Unix(),
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    oauthService.issuer(),
+				Subject:   subject,
+				Audience:  jwt.ClaimStrings{client.ClientId},
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(expires)),
+			},
+		})
+	}
+	return data, nil
+}
+
+func (oauthService *OAuthService) UserInfo(accessToken string) (ResponseOAuthUserInfo, *OAuthError) {
+	claims := &OAuthClaims{}
+	_, err := jwt.ParseWithClaims(accessToken, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(oauthService.config.JWT.Secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS512.Alg()}), jwt.WithIssuer(oauthService.issuer()))
+	if err != nil || claims.ClientId == "" {
+		return nil, newOAuthError(http.StatusUnauthorized, "invalid_token", "access token is invalid or expired")
+	}
+	// 应用被删除后已签发的令牌立即失效
+	if _, err := oauthService.clientOperation.GetOAuthClientByClientId(claims.ClientId); err != nil {
+		return nil, newOAuthError(http.StatusUnauthorized, "invalid_token", "client no longer exists")
+	}
+	cid, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return nil, newOAuthError(http.StatusUnauthorized, "invalid_token", "invalid subject")
+	}
+	user, err := oauthService.userOperation.GetUserByCid(cid)
+	if err != nil {
+		return nil, newOAuthError(http.StatusUnauthorized, "invalid_token", "user not found")
+	}
+
+	data := ResponseOAuthUserInfo{"sub": claims.Subject, "cid": user.Cid}
+	for _, scope := range strings.Fields(claims.Scope) {
+		switch scope {
+		case OAuthScopeProfile:
+			data["preferred_username"] = user.Username
+			data["name"] = user.Username
+			data["picture"] = user.AvatarUrl
+		case OAuthScopeEmail:
+			data["email"] = user.Email
+			data["email_verified"] = true
+		case OAuthScopeRating:
+			data["rating"] = user.Rating
+		case OAuthScopePermission:
+			data["permission"] = user.EffectivePermission().Nodes()
+		}
+	}
+	return data, nil
+}
+
+func (oauthService *OAuthService) Discovery() *ResponseOAuthDiscovery {
+	issuer := oauthService.issuer()
+	return &ResponseOAuthDiscovery{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             oauthService.config.OAuth.AuthorizeUrl,
+		TokenEndpoint:                     issuer + "/api/oauth/token",
+		UserinfoEndpoint:                  issuer + "/api/oauth/userinfo",
+		JwksUri:                           issuer + "/api/oauth/jwks",
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code"},
+		SubjectTypesSupported:             []string{"public"},
+		IdTokenSigningAlgValuesSupported:  []string{jwt.SigningMethodRS256.Alg()},
+		ScopesSupported:                   OAuthScopes,
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported:                   []string{"sub", "cid", "name", "preferred_username", "picture", "email", "email_verified", "rating", "permission"},
+	}
+}
+
+func (oauthService *OAuthService) Jwks() *ResponseOAuthJwks {
+	publicKey := oauthService.config.OAuth.SigningKey.PublicKey
+	return &ResponseOAuthJwks{Keys: []OAuthJwk{{
+		KeyType:   "RSA",
+		Use:       "sig",
+		Algorithm: jwt.SigningMethodRS256.Alg(),
+		KeyId:     oauthService.config.OAuth.SigningKeyId,
+		Modulus:   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+		Exponent:  base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+	}}}
+}
+
+var SuccessGetOAuthClients = ApiStatus{StatusName: "GET_OAUTH_CLIENTS", Description: "成功获取第三方应用列表", HttpCode: Ok}
+
+func (oauthService *OAuthService) GetClients(req *RequestGetOAuthClients) *ApiResponse[ResponseGetOAuthClients] {
+	permission := req.Permission
+	if !permission.HasPermission(operation.OAuthClientManage) {
+		return NewApiResponse[ResponseGetOAuthClients](&ErrNoPermission, Unsatisfied, nil)
+	}
+	clients, err := oauthService.clientOperation.GetOAuthClients()
+	if err != nil {
+		return NewApiResponse[ResponseGetOAuthClients](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+	data := ResponseGetOAuthClients(clients)
+	return NewApiResponse(&SuccessGetOAuthClients, Unsatisfied, &data)
+}
+
+var oauthClientNameValidator = &FieldValidator{
+	Min:      1,
+	Max:      64,
+	ErrShort: &ApiStatus{StatusName: "OAUTH_CLIENT_NAME_TOO_SHORT", Description: "应用名称不能为空", HttpCode: BadRequest},
+	ErrLong:  &ApiStatus{StatusName: "OAUTH_CLIENT_NAME_TOO_LONG", Description: "应用名称过长", HttpCode: BadRequest},
+}
+
+// checkRedirectUri 回调地址必须是不带片段的http或https绝对地址
+func checkRedirectUri(redirectUri string) bool {
+	target, err := url.Parse(redirectUri)
+	if err != nil || target.Host == "" || target.Fragment != "" {
+		return false
+	}
+	return target.Scheme == "https" || target.Scheme == "http"
+}
+
+var SuccessCreateOAuthClient = ApiStatus{StatusName: "CREATE_OAUTH_CLIENT", Description: "创建第三方应用成功, 请妥善保存客户端密钥", HttpCode: Ok}
+
+func (oauthService *OAuthService) CreateClient(req *RequestCreateOAuthClient) *ApiResponse[ResponseCreateOAuthClient] {
+	if res := oauthClientNameValidator.CheckString(req.Name); res != nil {
+		return NewApiResponse[ResponseCreateOAuthClient](res, Unsatisfied, nil)
+	}
+	if len(req.RedirectUris) == 0 || len(req.Scopes) == 0 {
+		return NewApiResponse[ResponseCreateOAuthClient](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	for _, redirectUri := range req.RedirectUris {
+		if !checkRedirectUri(redirectUri) {
+			return NewApiResponse[ResponseCreateOAuthClient](&ErrOAuthRedirectUri, Unsatisfied, nil)
+		}
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(OAuthScopes, scope) {
+			return NewApiResponse[ResponseCreateOAuthClient](&ErrOAuthScope, Unsatisfied, nil)
+		}
+	}
+	user, res := CallDBFuncAndCheckError[operation.User, ResponseCreateOAuthClient](func() (*operation.User, error) {
+		return oauthService.userOperation.GetUserByUid(req.Uid)
+	})
+	if res != nil {
+		return res
+	}
+	permission := req.RealtimePermission(user)
+	if !permission.HasPermission(operation.OAuthClientManage) {
+		return NewApiResponse[ResponseCreateOAuthClient](&ErrNoPermission, Unsatisfied, nil)
+	}
+	client, secret := oauthService.clientOperation.NewOAuthClient(user, req.Name,
+		strings.Join(req.RedirectUris, " "), strings.Join(req.Scopes, " "), req.Confidential)
+	if _, res := CallDBFuncAndCheckError[interface{}, ResponseCreateOAuthClient](func() (*interface{}, error) {
+		return nil, oauthService.clientOperation.AddOAuthClient(client)
+	}); res != nil {
+		return res
+	}
+	oauthService.saveAuditLog(operation.OAuthClientCreated, &req.EchoContentHeader, req.Cid, client)
+	return NewApiResponse(&SuccessCreateOAuthClient, Unsatisfied, &ResponseCreateOAuthClient{
+		Client:       client,
+		ClientSecret: secret,
+	})
+}
+
+var SuccessDeleteOAuthClient = ApiStatus{StatusName: "DELETE_OAUTH_CLIENT", Description: "删除第三方应用成功", HttpCode: Ok}
+
+func (oauthService *OAuthService) DeleteClient(req *RequestDeleteOAuthClient) *ApiResponse[ResponseDeleteOAuthClient] {
+	if req.ClientId <= 0 {
+		return NewApiResponse[ResponseDeleteOAuthClient](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	user, res := CallDBFuncAndCheckError[operation.User, ResponseDeleteOAuthClient](func() (*operation.User, error) {
+		return oauthService.userOperation.GetUserByUid(req.Uid)
+	})
+	if res != nil {
+		return res
+	}
+	permission := req.RealtimePermission(user)
+	if !permission.HasPermission(operation.OAuthClientManage) {
+		return NewApiResponse[ResponseDeleteOAuthClient](&ErrNoPermission, Unsatisfied, nil)
+	}
+	client, res := CallDBFuncAndCheckError[operation.OAuthClient, ResponseDeleteOAuthClient](func() (*operation.OAuthClient, error) {
+		return oauthService.clientOperation.GetOAuthClientById(req.ClientId)
+	})
+	if res != nil {
+		return res
+	}
+	if _, res := CallDBFuncAndCheckError[interface{}, ResponseDeleteOAuthClient](func() (*interface{}, error) {
+		return nil, oauthService.clientOperation.DeleteOAuthClient(client)
+	}); res != nil {
+		return res
+	}
+	oauthService.saveAuditLog(operation.OAuthClientDeleted, &req.EchoContentHeader, req.Cid, client)
+	data := ResponseDeleteOAuthClient(true)
+	return NewApiResponse(&SuccessDeleteOAuthClient, Unsatisfied, &data)
+}
+
+func (oauthService *OAuthService) saveAuditLog(eventType operation.EventType, req *EchoContentHeader, cid int, client *operation.OAuthClient) {
+	go func() {
+		auditLog := oauthService.auditLogOperation.NewAuditLog(eventType, cid, client.Name+"("+client.ClientId+")", req.Ip, req.UserAgent, nil)
+		if err := oauthService.auditLogOperation.SaveAuditLog(auditLog); err != nil {
+			oauthService.logger.ErrorF("Fail to create audit log for %s, detail: %v", eventType, err)
+		}
+	}()
+}
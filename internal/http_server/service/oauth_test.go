@@ -0,0 +1,136 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/service"
+	"github.com/half-nothing/simple-fsd/internal/utils"
+	"math/big"
+	"testing"
+	"time"
+)
+
+type testOAuthClientOperation struct {
+	operation.OAuthClientOperationInterface
+	client *operation.OAuthClient
+}
+
+func (op *testOAuthClientOperation) GetOAuthClientByClientId(clientId string) (*operation.OAuthClient, error) {
+	if clientId != op.client.ClientId {
+		return nil, operation.ErrOAuthClientNotFound
+	}
+	return op.client, nil
+}
+
+type testOAuthUserOperation struct {
+	operation.UserOperationInterface
+	user *operation.User
+}
+
+func (op *testOAuthUserOperation) GetUserByCid(int) (*operation.User, error) {
+	return op.user, nil
+}
+
+func newTestOAuthService(t *testing.T) *OAuthService {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &OAuthService{
+		config: &config.HttpServerConfig{
+			ServerAddress: "https://fsd.example.com",
+			JWT:           &config.JWTConfig{Secret: "secret"},
+			OAuth: &config.OAuthConfig{
+				CodeExpiresDuration:        time.Minute,
+				AccessTokenExpiresDuration: time.Hour,
+				SigningKey:                 key,
+				SigningKeyId:               "test",
+			},
+		},
+		userOperation:   &testOAuthUserOperation{user: &operation.User{Cid: 1000}},
+		clientOperation: &testOAuthClientOperation{client: &operation.OAuthClient{ClientId: "client"}},
+		codes:           make(map[string]*authorizationCode),
+	}
+}
+
+const testCodeVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+
+func (oauthService *OAuthService) saveTestCode(code string, redirectSent bool) {
+	oauthService.codes[code] = &authorizationCode{
+		clientId:      "client",
+		cid:           1000,
+		redirectUri:   "https://app.example.com/callback",
+		redirectSent:  redirectSent,
+		scope:         "openid",
+		nonce:         "nonce",
+		codeChallenge: utils.PkceChallenge(testCodeVerifier),
+		authTime:      time.Now(),
+		expiresAt:     time.Now().Add(time.Minute),
+	}
+}
+
+func TestOAuthTokenRedirectUri(t *testing.T) {
+	oauthService := newTestOAuthService(t)
+	request := func(code string, redirectUri string) *OAuthError {
+		_, oauthErr := oauthService.Token(&RequestOAuthToken{GrantType: "authorization_code", Code: code,
+			RedirectUri: redirectUri, ClientId: "client", CodeVerifier: testCodeVerifier})
+		return oauthErr
+	}
+
+	// 授权请求未携带redirect_uri时令牌请求可以省略
+	oauthService.saveTestCode("a", false)
+	if oauthErr := request("a", ""); oauthErr != nil {
+		t.Errorf("expected success without redirect_uri, got %v", oauthErr.Description)
+	}
+	oauthService.saveTestCode("b", false)
+	if oauthErr := request("b", "https://other.example.com"); oauthErr == nil {
+		t.Error("expected mismatched redirect_uri rejected")
+	}
+	// 授权请求携带了redirect_uri时令牌请求必须携带相同的值
+	oauthService.saveTestCode("c", true)
+	if oauthErr := request("c", ""); oauthErr == nil {
+		t.Error("expected missing redirect_uri rejected")
+	}
+	oauthService.saveTestCode("d", true)
+	if oauthErr := request("d", "https://app.example.com/callback"); oauthErr != nil {
+		t.Errorf("expected success, got %v", oauthErr.Description)
+	}
+}
+
+func TestOAuthIdTokenVerifiableWithJwks(t *testing.T) {
+	oauthService := newTestOAuthService(t)
+	oauthService.saveTestCode("code", false)
+	data, oauthErr := oauthService.Token(&RequestOAuthToken{GrantType: "authorization_code", Code: "code",
+		ClientId: "client", CodeVerifier: testCodeVerifier})
+	if oauthErr != nil {
+		t.Fatal(oauthErr.Description)
+	}
+
+	jwk := oauthService.Jwks().Keys[0]
+	modulus, _ := base64.RawURLEncoding.DecodeString(jwk.Modulus)
+	exponent, _ := base64.RawURLEncoding.DecodeString(jwk.Exponent)
+	publicKey := &rsa.PublicKey{N: new(big.Int).SetBytes(modulus), E: int(new(big.Int).SetBytes(exponent).Int64())}
+
+	claims := &OAuthIdTokenClaims{}
+	token, err := jwt.ParseWithClaims(data.IdToken, claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Header["kid"] != jwk.KeyId {
+			t.Errorf("unexpected kid %v", token.Header["kid"])
+		}
+		return publicKey, nil
+	}, jwt.WithValidMethods([]string{jwk.Algorithm}), jwt.WithAudience("client"))
+	if err != nil || !token.Valid {
+		t.Fatalf("id token should be verifiable with jwks: %v", err)
+	}
+	if claims.Subject != "1000" || claims.Nonce != "nonce" {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+
+	// ID令牌不能当作访问令牌使用
+	if _, oauthErr := oauthService.UserInfo(data.IdToken); oauthErr == nil {
+		t.Error("id token should not be accepted as access token")
+	}
+}
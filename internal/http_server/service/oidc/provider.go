@@ -0,0 +1,238 @@
+// Package oidc 上游OIDC身份提供者客户端
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrDiscovery = errors.New("fail to discover oidc provider")
+	ErrExchange  = errors.New("fail to exchange authorization code")
+	ErrUserInfo  = errors.New("fail to fetch userinfo")
+	ErrIdToken   = errors.New("invalid id token")
+)
+
+// Discovery 服务发现文档中用到的字段
+type Discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+// Token 令牌接口的返回值
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IdToken     string `json:"id_token"`
+}
+
+// UserInfo 上游用户信息, Claims包含全部原始字段
+type UserInfo struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+	Claims            map[string]interface{}
+}
+
+// Claim 以字符串形式读取原始字段, 支持字符串与数字
+func (info *UserInfo) Claim(name string) string {
+	switch value := info.Claims[name].(type) {
+	case string:
+		return value
+	case float64:
+		return fmt.Sprintf("%.0f", value)
+	case json.Number:
+		return value.String()
+	default:
+		return ""
+	}
+}
+
+type Provider struct {
+	config     *config.ExternalLoginConfig
+	httpClient *http.Client
+	mu         sync.Mutex
+	discovery  *Discovery
+}
+
+func NewProvider(config *config.ExternalLoginConfig) *Provider {
+	return &Provider{
+		config:     config,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (provider *Provider) doJSON(req *http.Request, target interface{}) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := provider.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(body)))
+	decoder.UseNumber()
+	return decoder.Decode(target)
+}
+
+// Discover 获取并缓存服务发现文档
+func (provider *Provider) Discover(ctx context.Context) (*Discovery, error) {
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	if provider.discovery != nil {
+		return provider.discovery, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, provider.config.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDiscovery, err)
+	}
+	discovery := &Discovery{}
+	if err := provider.doJSON(req, discovery); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDiscovery, err)
+	}
+	if strings.TrimRight(discovery.Issuer, "/") != provider.config.Issuer {
+		return nil, fmt.Errorf("%w: issuer mismatch, expect %s, got %s", ErrDiscovery, provider.config.Issuer, discovery.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.UserinfoEndpoint == "" {
+		return nil, fmt.Errorf("%w: missing endpoints", ErrDiscovery)
+	}
+	provider.discovery = discovery
+	return discovery, nil
+}
+
+// AuthCodeUrl 生成跳转到上游的授权地址, 使用S256方法的PKCE
+func (provider *Provider) AuthCodeUrl(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	discovery, err := provider.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	target, err := url.Parse(discovery.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrDiscovery, err)
+	}
+	query := target.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", provider.config.ClientId)
+	query.Set("redirect_uri", provider.config.RedirectUrl)
+	query.Set("scope", strings.Join(provider.config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+	target.RawQuery = query.Encode()
+	return target.String(), nil
+}
+
+// Exchange 使用授权码换取令牌
+func (provider *Provider) Exchange(ctx context.Context, code, codeVerifier string) (*Token, error) {
+	discovery, err := provider.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", provider.config.RedirectUrl)
+	form.Set("client_id", provider.config.ClientId)
+	form.Set("code_verifier", codeVerifier)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExchange, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if provider.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(provider.config.ClientId), url.QueryEscape(provider.config.ClientSecret))
+	}
+	token := &Token{}
+	if err := provider.doJSON(req, token); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExchange, err)
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("%w: empty access token", ErrExchange)
+	}
+	return token, nil
+}
+
+// VerifyIdToken 校验ID令牌中的签发者、受众、有效期与nonce, 返回ID令牌中的用户标识
+// ID令牌由令牌接口通过TLS直接返回, 按照OIDC Core 3.1.3.7可以不校验签名
+// 未申请openid权限范围时上游不返回ID令牌, 直接通过并返回空的用户标识
+func (provider *Provider) VerifyIdToken(token *Token, nonce string) (string, error) {
+	if !slices.Contains(provider.config.Scopes, "openid") {
+		return "", nil
+	}
+	if token.IdToken == "" {
+		return "", fmt.Errorf("%w: missing id_token", ErrIdToken)
+	}
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token.IdToken, claims); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrIdToken, err)
+	}
+	if issuer, _ := claims.GetIssuer(); strings.TrimRight(issuer, "/") != provider.config.Issuer {
+		return "", fmt.Errorf("%w: issuer mismatch", ErrIdToken)
+	}
+	if audience, _ := claims.GetAudience(); !slices.Contains(audience, provider.config.ClientId) {
+		return "", fmt.Errorf("%w: audience mismatch", ErrIdToken)
+	}
+	if expiresAt, _ := claims.GetExpirationTime(); expiresAt == nil || expiresAt.Before(time.Now()) {
+		return "", fmt.Errorf("%w: expired", ErrIdToken)
+	}
+	if value, _ := claims["nonce"].(string); value != nonce {
+		return "", fmt.Errorf("%w: nonce mismatch", ErrIdToken)
+	}
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return "", fmt.Errorf("%w: missing sub", ErrIdToken)
+	}
+	return subject, nil
+}
+
+// UserInfo 使用访问令牌获取用户信息
+func (provider *Provider) UserInfo(ctx context.Context, token *Token) (*UserInfo, error) {
+	discovery, err := provider.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discovery.UserinfoEndpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUserInfo, err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	claims := make(map[string]interface{})
+	if err := provider.doJSON(req, &claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUserInfo, err)
+	}
+	info := &UserInfo{Claims: claims}
+	info.Subject = info.Claim("sub")
+	info.Email = info.Claim("email")
+	info.PreferredUsername = info.Claim("preferred_username")
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		info.EmailVerified = verified
+	case string:
+		info.EmailVerified = verified == "true"
+	}
+	if info.Subject == "" {
+		return nil, fmt.Errorf("%w: missing sub", ErrUserInfo)
+	}
+	return info, nil
+}
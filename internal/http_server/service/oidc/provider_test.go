@@ -0,0 +1,120 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
+	"github.com/half-nothing/simple-fsd/internal/utils"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newMockProvider 启动一个模拟的OIDC身份提供者, 只接受固定的授权码与PKCE
+func newMockProvider(t *testing.T, challenge string) *httptest.Server {
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	discovery := func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 server.URL,
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+			"userinfo_endpoint":      server.URL + "/userinfo",
+		})
+	}
+	mux.HandleFunc("/.well-known/openid-configuration", discovery)
+	// 返回的issuer与请求地址不一致, 用于测试issuer校验
+	mux.HandleFunc("/other/.well-known/openid-configuration", discovery)
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		clientId, clientSecret, ok := r.BasicAuth()
+		if !ok || clientId != "client" || clientSecret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.FormValue("code") != "good-code" || utils.PkceChallenge(r.FormValue("code_verifier")) != challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		idToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"iss":   server.URL,
+			"sub":   "upstream-1",
+			"aud":   "client",
+			"exp":   time.Now().Add(time.Minute).Unix(),
+			"nonce": "nonce",
+		}).SignedString([]byte("upstream"))
+		_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "access", "token_type": "Bearer", "id_token": idToken})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"sub":"upstream-1","email":"user@example.com","email_verified":true,"preferred_username":"user","cid":1234}`))
+	})
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestProviderFlow(t *testing.T) {
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := utils.PkceChallenge(verifier)
+	server := newMockProvider(t, challenge)
+	provider := NewProvider(&config.ExternalLoginConfig{
+		Issuer:       server.URL,
+		ClientId:     "client",
+		ClientSecret: "secret",
+		RedirectUrl:  "https://fsd.example.com/sso/callback",
+		Scopes:       []string{"openid", "email"},
+	})
+	ctx := context.Background()
+
+	authUrl, err := provider.AuthCodeUrl(ctx, "state", "nonce", challenge)
+	if err != nil {
+		t.Fatalf("AuthCodeUrl error: %v", err)
+	}
+	parsed, _ := url.Parse(authUrl)
+	query := parsed.Query()
+	if parsed.Path != "/authorize" || query.Get("state") != "state" || query.Get("code_challenge") != challenge ||
+		query.Get("scope") != "openid email" || query.Get("redirect_uri") != "https://fsd.example.com/sso/callback" {
+		t.Errorf("unexpected authorize url %s", authUrl)
+	}
+
+	if _, err := provider.Exchange(ctx, "good-code", "wrong-verifier-wrong-verifier-wrong-verifier"); err == nil {
+		t.Errorf("Exchange accepted a wrong code_verifier")
+	}
+	token, err := provider.Exchange(ctx, "good-code", verifier)
+	if err != nil {
+		t.Fatalf("Exchange error: %v", err)
+	}
+	if _, err := provider.VerifyIdToken(token, "other-nonce"); err == nil {
+		t.Errorf("VerifyIdToken accepted a mismatched nonce")
+	}
+	if subject, err := provider.VerifyIdToken(token, "nonce"); err != nil || subject != "upstream-1" {
+		t.Errorf("VerifyIdToken error: %v, subject: %s", err, subject)
+	}
+	if _, err := provider.VerifyIdToken(&Token{AccessToken: "access"}, "nonce"); err == nil {
+		t.Errorf("VerifyIdToken accepted a missing id_token")
+	}
+	info, err := provider.UserInfo(ctx, token)
+	if err != nil {
+		t.Fatalf("UserInfo error: %v", err)
+	}
+	if info.Subject != "upstream-1" || info.Email != "user@example.com" || !info.EmailVerified ||
+		info.PreferredUsername != "user" || info.Claim("cid") != "1234" {
+		t.Errorf("unexpected userinfo %+v", info)
+	}
+}
+
+func TestProviderIssuerMismatch(t *testing.T) {
+	server := newMockProvider(t, "")
+	provider := NewProvider(&config.ExternalLoginConfig{Issuer: server.URL + "/other", ClientId: "client"})
+	if _, err := provider.Discover(context.Background()); err == nil || !strings.Contains(err.Error(), "issuer mismatch") {
+		t.Errorf("Discover accepted a mismatched issuer, err: %v", err)
+	}
+}
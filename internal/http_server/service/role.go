@@ -0,0 +1,341 @@
+// Package service
+package service
+
+import (
+	"fmt"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/service"
+	"time"
+)
+
+type RoleService struct {
+	logger            log.LoggerInterface
+	userOperation     operation.UserOperationInterface
+	roleOperation     operation.RoleOperationInterface
+	sessionOperation  operation.UserSessionOperationInterface
+	auditLogOperation operation.AuditLogOperationInterface
+}
+
+func NewRoleService(
+	logger log.LoggerInterface,
+	userOperation operation.UserOperationInterface,
+	roleOperation operation.RoleOperationInterface,
+	sessionOperation operation.UserSessionOperationInterface,
+	auditLogOperation operation.AuditLogOperationInterface,
+) *RoleService {
+	return &RoleService{
+		logger:            logger,
+		userOperation:     userOperation,
+		roleOperation:     roleOperation,
+		sessionOperation:  sessionOperation,
+		auditLogOperation: auditLogOperation,
+	}
+}
+
+var ErrRoleExpiresAt = ApiStatus{StatusName: "ROLE_EXPIRES_AT_INVALID", Description: "角色过期时间必须晚于当前时间", HttpCode: BadRequest}
+
+var roleNameValidator = &FieldValidator{
+	Min:      1,
+	Max:      64,
+	ErrShort: &ApiStatus{StatusName: "ROLE_NAME_TOO_SHORT", Description: "角色名称不能为空", HttpCode: BadRequest},
+	ErrLong:  &ApiStatus{StatusName: "ROLE_NAME_TOO_LONG", Description: "角色名称过长", HttpCode: BadRequest},
+}
+
+var roleDescriptionValidator = &FieldValidator{
+	Min:     0,
+	Max:     256,
+	ErrLong: &ApiStatus{StatusName: "ROLE_DESCRIPTION_TOO_LONG", Description: "角色描述过长", HttpCode: BadRequest},
+}
+
+// getOperator 重新获取操作者并检查权限, 角色相关操作均需要实时权限
+func getOperator[T any](roleService *RoleService, header *JwtHeader, perm operation.Permission) (*operation.User, operation.PermissionSet, *ApiResponse[T]) {
+	user, res := CallDBFuncAndCheckError[operation.User, T](func() (*operation.User, error) {
+		return roleService.userOperation.GetUserByUid(header.Uid)
+	})
+	if res != nil {
+		return nil, nil, res
+	}
+	permission := header.RealtimePermission(user)
+	if !permission.HasPermission(perm) {
+		return nil, nil, NewApiResponse[T](&ErrNoPermission, Unsatisfied, nil)
+	}
+	return user, permission, nil
+}
+
+// parsePermissions 解析权限节点列表, 操作者只能使用自己拥有的权限节点, 防止越权
+func parsePermissions[T any](operatorPermission operation.PermissionSet, nodes []string) (operation.PermissionSet, *ApiResponse[T]) {
+	var permission operation.PermissionSet
+	for _, node := range nodes {
+		perm, ok := operation.PermissionMap[node]
+		if !ok {
+			return nil, NewApiResponse[T](&ErrPermissionNodeNotExists, Unsatisfied, nil)
+		}
+		permission.Grant(perm)
+	}
+	if !operatorPermission.Contains(permission) {
+		return nil, NewApiResponse[T](&ErrNoPermission, Unsatisfied, nil)
+	}
+	return permission, nil
+}
+
+var SuccessGetPermissionNodes = ApiStatus{StatusName: "GET_PERMISSION_NODES", Description: "获取权限节点列表成功", HttpCode: Ok}
+
+func (roleService *RoleService) GetPermissionNodes(req *RequestGetPermissionNodes) *ApiResponse[ResponseGetPermissionNodes] {
+	if !req.Permission.HasPermission(operation.RoleShowList) {
+		return NewApiResponse[ResponseGetPermissionNodes](&ErrNoPermission, Unsatisfied, nil)
+	}
+	data := ResponseGetPermissionNodes(operation.AllPermissions().Nodes())
+	return NewApiResponse(&SuccessGetPermissionNodes, Unsatisfied, &data)
+}
+
+var SuccessGetRoles = ApiStatus{StatusName: "GET_ROLES", Description: "获取角色列表成功", HttpCode: Ok}
+
+func (roleService *RoleService) GetRoles(req *RequestGetRoles) *ApiResponse[ResponseGetRoles] {
+	if !req.Permission.HasPermission(operation.RoleShowList) {
+		return NewApiResponse[ResponseGetRoles](&ErrNoPermission, Unsatisfied, nil)
+	}
+	roles, err := roleService.roleOperation.GetRoles()
+	if err != nil {
+		return NewApiResponse[ResponseGetRoles](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+	data := ResponseGetRoles(roles)
+	return NewApiResponse(&SuccessGetRoles, Unsatisfied, &data)
+}
+
+var SuccessCreateRole = ApiStatus{StatusName: "CREATE_ROLE", Description: "创建角色成功", HttpCode: Ok}
+
+func (roleService *RoleService) CreateRole(req *RequestCreateRole) *ApiResponse[ResponseCreateRole] {
+	if res := roleNameValidator.CheckString(req.Name); res != nil {
+		return NewApiResponse[ResponseCreateRole](res, Unsatisfied, nil)
+	}
+	if res := roleDescriptionValidator.CheckString(req.Description); res != nil {
+		return NewApiResponse[ResponseCreateRole](res, Unsatisfied, nil)
+	}
+	user, operatorPermission, res := getOperator[ResponseCreateRole](roleService, &req.JwtHeader, operation.RoleManage)
+	if res != nil {
+		return res
+	}
+	permission, res := parsePermissions[ResponseCreateRole](operatorPermission, req.Permissions)
+	if res != nil {
+		return res
+	}
+	role := roleService.roleOperation.NewRole(user, req.Name, req.Description, permission)
+	if _, res := CallDBFuncAndCheckError[interface{}, ResponseCreateRole](func() (*interface{}, error) {
+		return nil, roleService.roleOperation.AddRole(role)
+	}); res != nil {
+		return res
+	}
+	roleService.saveAuditLog(operation.RoleCreated, &req.EchoContentHeader, req.Cid, role.Name, nil)
+	return NewApiResponse(&SuccessCreateRole, Unsatisfied, (*ResponseCreateRole)(role))
+}
+
+var SuccessEditRole = ApiStatus{StatusName: "EDIT_ROLE", Description: "编辑角色成功, 移除权限时持有者需要重新登录", HttpCode: Ok}
+
+func (roleService *RoleService) EditRole(req *RequestEditRole) *ApiResponse[ResponseEditRole] {
+	if req.RoleId <= 0 {
+		return NewApiResponse[ResponseEditRole](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	_, operatorPermission, res := getOperator[ResponseEditRole](roleService, &req.JwtHeader, operation.RoleManage)
+	if res != nil {
+		return res
+	}
+	role, res := CallDBFuncAndCheckError[operation.Role, ResponseEditRole](func() (*operation.Role, error) {
+		return roleService.roleOperation.GetRoleById(req.RoleId)
+	})
+	if res != nil {
+		return res
+	}
+	// 不能修改包含自己没有的权限节点的角色
+	if !operatorPermission.Contains(role.PermissionSet()) {
+		return NewApiResponse[ResponseEditRole](&ErrNoPermission, Unsatisfied, nil)
+	}
+
+	updates := make(map[string]interface{})
+	oldPermissions := role.Permissions
+	if req.Name != "" {
+		if res := roleNameValidator.CheckString(req.Name); res != nil {
+			return NewApiResponse[ResponseEditRole](res, Unsatisfied, nil)
+		}
+		updates["name"] = req.Name
+	}
+	if req.Description != nil {
+		if res := roleDescriptionValidator.CheckString(*req.Description); res != nil {
+			return NewApiResponse[ResponseEditRole](res, Unsatisfied, nil)
+		}
+		updates["description"] = *req.Description
+	}
+	// 移除权限节点时, 持有者令牌中仍带有旧的权限, 需要注销其会话
+	narrowed := false
+	if req.Permissions != nil {
+		permission, res := parsePermissions[ResponseEditRole](operatorPermission, req.Permissions)
+		if res != nil {
+			return res
+		}
+		updates["permissions"] = permission.String()
+		narrowed = !permission.Contains(role.PermissionSet())
+	}
+	if len(updates) == 0 {
+		return NewApiResponse[ResponseEditRole](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	var holders []int
+	if narrowed {
+		var err error
+		if holders, err = roleService.roleOperation.GetRoleHolders(role); err != nil {
+			return NewApiResponse[ResponseEditRole](&ErrDatabaseFail, Unsatisfied, nil)
+		}
+	}
+	if _, res := CallDBFuncAndCheckError[interface{}, ResponseEditRole](func() (*interface{}, error) {
+		return nil, roleService.roleOperation.UpdateRole(role, updates)
+	}); res != nil {
+		return res
+	}
+	for _, cid := range holders {
+		roleService.revokeSessions(cid)
+	}
+	roleService.saveAuditLog(operation.RoleUpdated, &req.EchoContentHeader, req.Cid, role.Name, &operation.ChangeDetail{
+		OldValue: oldPermissions,
+		NewValue: role.Permissions,
+	})
+	return NewApiResponse(&SuccessEditRole, Unsatisfied, (*ResponseEditRole)(role))
+}
+
+var SuccessDeleteRole = ApiStatus{StatusName: "DELETE_ROLE", Description: "删除角色成功", HttpCode: Ok}
+
+func (roleService *RoleService) DeleteRole(req *RequestDeleteRole) *ApiResponse[ResponseDeleteRole] {
+	if req.RoleId <= 0 {
+		return NewApiResponse[ResponseDeleteRole](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	_, operatorPermission, res := getOperator[ResponseDeleteRole](roleService, &req.JwtHeader, operation.RoleManage)
+	if res != nil {
+		return res
+	}
+	role, res := CallDBFuncAndCheckError[operation.Role, ResponseDeleteRole](func() (*operation.Role, error) {
+		return roleService.roleOperation.GetRoleById(req.RoleId)
+	})
+	if res != nil {
+		return res
+	}
+	if !operatorPermission.Contains(role.PermissionSet()) {
+		return NewApiResponse[ResponseDeleteRole](&ErrNoPermission, Unsatisfied, nil)
+	}
+	holders, err := roleService.roleOperation.GetRoleHolders(role)
+	if err != nil {
+		return NewApiResponse[ResponseDeleteRole](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+	if _, res := CallDBFuncAndCheckError[interface{}, ResponseDeleteRole](func() (*interface{}, error) {
+		return nil, roleService.roleOperation.DeleteRole(role)
+	}); res != nil {
+		return res
+	}
+	// 持有者令牌中的权限已经失效, 需要重新登录
+	for _, cid := range holders {
+		roleService.revokeSessions(cid)
+	}
+	roleService.saveAuditLog(operation.RoleDeleted, &req.EchoContentHeader, req.Cid, role.Name, nil)
+	data := ResponseDeleteRole(true)
+	return NewApiResponse(&SuccessDeleteRole, Unsatisfied, &data)
+}
+
+var SuccessGetUserRoles = ApiStatus{StatusName: "GET_USER_ROLES", Description: "获取用户角色成功", HttpCode: Ok}
+
+func (roleService *RoleService) GetUserRoles(req *RequestGetUserRoles) *ApiResponse[ResponseGetUserRoles] {
+	if req.TargetUid <= 0 {
+		return NewApiResponse[ResponseGetUserRoles](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	// 用户可以查看自己的角色
+	if req.TargetUid != req.Uid && !req.Permission.HasPermission(operation.RoleShowList) {
+		return NewApiResponse[ResponseGetUserRoles](&ErrNoPermission, Unsatisfied, nil)
+	}
+	target, res := CallDBFuncAndCheckError[operation.User, ResponseGetUserRoles](func() (*operation.User, error) {
+		return roleService.userOperation.GetUserByUid(req.TargetUid)
+	})
+	if res != nil {
+		return res
+	}
+	roles, err := roleService.roleOperation.GetUserRoles(target.Cid)
+	if err != nil {
+		return NewApiResponse[ResponseGetUserRoles](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+	data := ResponseGetUserRoles(roles)
+	return NewApiResponse(&SuccessGetUserRoles, Unsatisfied, &data)
+}
+
+var SuccessAssignUserRole = ApiStatus{StatusName: "ASSIGN_USER_ROLE", Description: "授予角色成功", HttpCode: Ok}
+
+func (roleService *RoleService) AssignUserRole(req *RequestAssignUserRole) *ApiResponse[ResponseAssignUserRole] {
+	if req.TargetUid <= 0 || req.RoleId <= 0 {
+		return NewApiResponse[ResponseAssignUserRole](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return NewApiResponse[ResponseAssignUserRole](&ErrRoleExpiresAt, Unsatisfied, nil)
+	}
+	user, target, res := GetUsersAndCheckPermission[ResponseAssignUserRole](roleService.userOperation, &req.JwtHeader, req.TargetUid, operation.RoleAssign)
+	if res != nil {
+		return res
+	}
+	role, res := CallDBFuncAndCheckError[operation.Role, ResponseAssignUserRole](func() (*operation.Role, error) {
+		return roleService.roleOperation.GetRoleById(req.RoleId)
+	})
+	if res != nil {
+		return res
+	}
+	if !req.RealtimePermission(user).Contains(role.PermissionSet()) {
+		return NewApiResponse[ResponseAssignUserRole](&ErrNoPermission, Unsatisfied, nil)
+	}
+	userRole, res := CallDBFuncAndCheckError[operation.UserRole, ResponseAssignUserRole](func() (*operation.UserRole, error) {
+		return roleService.roleOperation.AssignRole(target, role, req.ExpiresAt, user)
+	})
+	if res != nil {
+		return res
+	}
+	roleService.revokeSessions(target.Cid)
+	roleService.saveAuditLog(operation.UserRoleAssigned, &req.EchoContentHeader, req.Cid, fmt.Sprintf("%04d(%s)", target.Cid, role.Name), nil)
+	return NewApiResponse(&SuccessAssignUserRole, Unsatisfied, (*ResponseAssignUserRole)(userRole))
+}
+
+var SuccessRevokeUserRole = ApiStatus{StatusName: "REVOKE_USER_ROLE", Description: "撤销角色成功", HttpCode: Ok}
+
+func (roleService *RoleService) RevokeUserRole(req *RequestRevokeUserRole) *ApiResponse[ResponseRevokeUserRole] {
+	if req.TargetUid <= 0 || req.RoleId <= 0 {
+		return NewApiResponse[ResponseRevokeUserRole](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	user, target, res := GetUsersAndCheckPermission[ResponseRevokeUserRole](roleService.userOperation, &req.JwtHeader, req.TargetUid, operation.RoleAssign)
+	if res != nil {
+		return res
+	}
+	userRole, res := CallDBFuncAndCheckError[operation.UserRole, ResponseRevokeUserRole](func() (*operation.UserRole, error) {
+		return roleService.roleOperation.GetUserRole(target.Cid, req.RoleId)
+	})
+	if res != nil {
+		return res
+	}
+	if !req.RealtimePermission(user).Contains(userRole.Role.PermissionSet()) {
+		return NewApiResponse[ResponseRevokeUserRole](&ErrNoPermission, Unsatisfied, nil)
+	}
+	if _, res := CallDBFuncAndCheckError[interface{}, ResponseRevokeUserRole](func() (*interface{}, error) {
+		return nil, roleService.roleOperation.RevokeRole(userRole)
+	}); res != nil {
+		return res
+	}
+	roleService.revokeSessions(target.Cid)
+	roleService.saveAuditLog(operation.UserRoleRevoked, &req.EchoContentHeader, req.Cid, fmt.Sprintf("%04d(%s)", target.Cid, userRole.Role.Name), nil)
+	data := ResponseRevokeUserRole(true)
+	return NewApiResponse(&SuccessRevokeUserRole, Unsatisfied, &data)
+}
+
+// revokeSessions 角色变化后撤销用户的所有会话, 使其重新登录以获取新的权限
+func (roleService *RoleService) revokeSessions(cid int) {
+	if err := roleService.sessionOperation.RevokeUserSessions(cid, ""); err != nil {
+		roleService.logger.ErrorF("Fail to revoke sessions of user %04d, detail: %v", cid, err)
+	}
+}
+
+func (roleService *RoleService) saveAuditLog(eventType operation.EventType, req *EchoContentHeader, cid int, object string, changeDetail *operation.ChangeDetail) {
+	go func() {
+		auditLog := roleService.auditLogOperation.NewAuditLog(eventType, cid, object, req.Ip, req.UserAgent, changeDetail)
+		if err := roleService.auditLogOperation.SaveAuditLog(auditLog); err != nil {
+			roleService.logger.ErrorF("Fail to create audit log for %s, detail: %v", eventType, err)
+		}
+	}()
+}
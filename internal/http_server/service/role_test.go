@@ -0,0 +1,116 @@
+package service
+
+import (
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/service"
+	"slices"
+	"sync"
+	"testing"
+)
+
+type testRoleUserOperation struct {
+	operation.UserOperationInterface
+	operator *operation.User
+}
+
+func (op *testRoleUserOperation) GetUserByUid(uint) (*operation.User, error) {
+	return op.operator, nil
+}
+
+type testRoleOperation struct {
+	operation.RoleOperationInterface
+	role    *operation.Role
+	holders []int
+}
+
+func (op *testRoleOperation) GetRoleById(uint) (*operation.Role, error) {
+	return op.role, nil
+}
+
+func (op *testRoleOperation) GetRoleHolders(*operation.Role) ([]int, error) {
+	return op.holders, nil
+}
+
+func (op *testRoleOperation) UpdateRole(role *operation.Role, updates map[string]interface{}) error {
+	if permissions, ok := updates["permissions"]; ok {
+		role.Permissions = permissions.(string)
+	}
+	return nil
+}
+
+type testRevokeSessionOperation struct {
+	operation.UserSessionOperationInterface
+	lock    sync.Mutex
+	revoked []int
+}
+
+func (op *testRevokeSessionOperation) RevokeUserSessions(cid int, _ string) error {
+	op.lock.Lock()
+	defer op.lock.Unlock()
+	op.revoked = append(op.revoked, cid)
+	return nil
+}
+
+func (op *testRevokeSessionOperation) takeRevoked() []int {
+	op.lock.Lock()
+	defer op.lock.Unlock()
+	revoked := op.revoked
+	op.revoked = nil
+	return revoked
+}
+
+type testRoleAuditLogOperation struct {
+	operation.AuditLogOperationInterface
+}
+
+func (op *testRoleAuditLogOperation) NewAuditLog(operation.EventType, int, string, string, string, *operation.ChangeDetail) *operation.AuditLog {
+	return &operation.AuditLog{}
+}
+
+func (op *testRoleAuditLogOperation) SaveAuditLog(*operation.AuditLog) error {
+	return nil
+}
+
+func TestEditRoleRevokesHoldersWhenNarrowed(t *testing.T) {
+	var permission operation.PermissionSet
+	permission.Grant(operation.RoleManage)
+	permission.Grant(operation.ClientKill)
+	permission.Grant(operation.AuditLogShow)
+	legacy, _ := permission.Legacy()
+	operator := &operation.User{ID: 1, Cid: 2001, Permission: legacy}
+	roleOperation := &testRoleOperation{
+		role:    &operation.Role{ID: 1, Name: "test", Permissions: "ClientKill,AuditLogShow"},
+		holders: []int{2002, 2003},
+	}
+	sessionOperation := &testRevokeSessionOperation{}
+	service := NewRoleService(testLogger{}, &testRoleUserOperation{operator: operator}, roleOperation,
+		sessionOperation, &testRoleAuditLogOperation{})
+	edit := func(permissions []string) {
+		t.Helper()
+		res := service.EditRole(&RequestEditRole{
+			JwtHeader:   JwtHeader{Uid: operator.ID, Permission: permission},
+			Cid:         operator.Cid,
+			RoleId:      1,
+			Name:        "test",
+			Permissions: permissions,
+		})
+		if res.Code != SuccessEditRole.StatusName {
+			t.Fatalf("EditRole = %s, expected %s", res.Code, SuccessEditRole.StatusName)
+		}
+	}
+
+	// 只修改名称或新增权限节点时, 持有者在刷新令牌后获得新权限
+	edit(nil)
+	edit([]string{"ClientKill", "AuditLogShow", "RoleManage"})
+	if revoked := sessionOperation.takeRevoked(); len(revoked) != 0 {
+		t.Errorf("sessions revoked without removing permissions: %v", revoked)
+	}
+
+	// 移除权限节点时注销所有持有者的会话
+	edit([]string{"ClientKill"})
+	revoked := sessionOperation.takeRevoked()
+	slices.Sort(revoked)
+	if !slices.Equal(revoked, roleOperation.holders) {
+		t.Errorf("revoked sessions = %v, expected %v", revoked, roleOperation.holders)
+	}
+}
@@ -2,12 +2,15 @@
 package service
 
 import (
+	"github.com/half-nothing/simple-fsd/internal/interfaces"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
 	. "github.com/half-nothing/simple-fsd/internal/interfaces/service"
 	"github.com/half-nothing/simple-fsd/internal/utils"
+	"strings"
+	"time"
 )
 
 type ServerService struct {
@@ -15,6 +18,9 @@ type ServerService struct {
 	config            *config.ServerConfig
 	userOperation     operation.UserOperationInterface
 	activityOperation operation.ActivityOperationInterface
+	auditLogOperation operation.AuditLogOperationInterface
+	configManager     interfaces.ConfigManagerInterface
+	clientManager     fsd.ClientManagerInterface
 	serverConfig      *utils.CachedValue[ResponseGetServerConfig]
 	serverInfo        *utils.CachedValue[ResponseGetServerInfo]
 	serverOnlineTime  *utils.CachedValue[ResponseGetTimeRating]
@@ -25,12 +31,18 @@ func NewServerService(
 	config *config.ServerConfig,
 	userOperation operation.UserOperationInterface,
 	activityOperation operation.ActivityOperationInterface,
+	auditLogOperation operation.AuditLogOperationInterface,
+	configManager interfaces.ConfigManagerInterface,
+	clientManager fsd.ClientManagerInterface,
 ) *ServerService {
 	service := &ServerService{
 		logger:            logger,
 		config:            config,
 		userOperation:     userOperation,
 		activityOperation: activityOperation,
+		auditLogOperation: auditLogOperation,
+		configManager:     configManager,
+		clientManager:     clientManager,
 	}
 	service.serverConfig = utils.NewCachedValue[ResponseGetServerConfig](0, func() *ResponseGetServerConfig { return service.getServerConfig() })
 	service.serverInfo = utils.NewCachedValue[ResponseGetServerInfo](config.HttpServer.CacheDuration, func() *ResponseGetServerInfo { return service.getServerInfo() })
@@ -121,3 +133,88 @@ var SuccessGetTimeRating = ApiStatus{StatusName: "GET_TIME_RATING", Description:
 func (serverService *ServerService) GetTimeRating() *ApiResponse[ResponseGetTimeRating] {
 	return NewApiResponse(&SuccessGetTimeRating, Unsatisfied, serverService.serverOnlineTime.GetValue())
 }
+
+var (
+	ErrReloadConfig     = ApiStatus{StatusName: "RELOAD_CONFIG_FAIL", Description: "配置文件重载失败, 请检查服务器日志", HttpCode: BadRequest}
+	SuccessReloadConfig = ApiStatus{StatusName: "RELOAD_CONFIG", Description: "配置文件重载成功", HttpCode: Ok}
+	ErrDrainDuration    = ApiStatus{StatusName: "DRAIN_DURATION_INVALID", Description: "排空倒计时格式错误", HttpCode: BadRequest}
+	ErrDrainInProgress  = ApiStatus{StatusName: "DRAIN_IN_PROGRESS", Description: "服务器已经在排空或关闭中", HttpCode: Conflict}
+	SuccessDrainServer  = ApiStatus{StatusName: "DRAIN_SERVER", Description: "服务器已进入排空模式", HttpCode: Ok}
+)
+
+func (serverService *ServerService) ReloadConfig(req *RequestReloadConfig) *ApiResponse[ResponseReloadConfig] {
+	if req.Uid <= 0 {
+		return NewApiResponse[ResponseReloadConfig](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	user, res := CallDBFuncAndCheckError[operation.User, ResponseReloadConfig](func() (*operation.User, error) {
+		return serverService.userOperation.GetUserByUid(req.Uid)
+	})
+	if res != nil {
+		return res
+	}
+	permission := req.RealtimePermission(user)
+	if !permission.HasPermission(operation.ServerConfigReload) {
+		return NewApiResponse[ResponseReloadConfig](&ErrNoPermission, Unsatisfied, nil)
+	}
+
+	result, err := serverService.configManager.Reload()
+	if err != nil {
+		serverService.logger.ErrorF("ServerService.ReloadConfig error: %v", err)
+		return NewApiResponse[ResponseReloadConfig](&ErrReloadConfig, Unsatisfied, nil)
+	}
+
+	go func() {
+		changeDetail := &operation.ChangeDetail{
+			NewValue: strings.Join(result.Applied, ","),
+		}
+		auditLog := serverService.auditLogOperation.NewAuditLog(operation.ServerConfigReloaded, req.Cid,
+			"config", req.Ip, req.UserAgent, changeDetail)
+		if err := serverService.auditLogOperation.SaveAuditLog(auditLog); err != nil {
+			serverService.logger.ErrorF("Fail to create audit log for server_config_reloaded, detail: %v", err)
+		}
+	}()
+
+	return NewApiResponse(&SuccessReloadConfig, Unsatisfied, (*ResponseReloadConfig)(result))
+}
+
+func (serverService *ServerService) DrainServer(req *RequestDrainServer) *ApiResponse[ResponseDrainServer] {
+	if req.Uid <= 0 {
+		return NewApiResponse[ResponseDrainServer](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	duration := serverService.config.FSDServer.DrainDuration
+	if req.Duration != "" {
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d < 0 {
+			return NewApiResponse[ResponseDrainServer](&ErrDrainDuration, Unsatisfied, nil)
+		}
+		duration = d
+	}
+	user, res := CallDBFuncAndCheckError[operation.User, ResponseDrainServer](func() (*operation.User, error) {
+		return serverService.userOperation.GetUserByUid(req.Uid)
+	})
+	if res != nil {
+		return res
+	}
+	permission := req.RealtimePermission(user)
+	if !permission.HasPermission(operation.ServerDrain) {
+		return NewApiResponse[ResponseDrainServer](&ErrNoPermission, Unsatisfied, nil)
+	}
+
+	deadline, err := serverService.clientManager.Drain(duration)
+	if err != nil {
+		return NewApiResponse[ResponseDrainServer](&ErrDrainInProgress, Unsatisfied, nil)
+	}
+
+	go func() {
+		changeDetail := &operation.ChangeDetail{
+			NewValue: duration.String(),
+		}
+		auditLog := serverService.auditLogOperation.NewAuditLog(operation.ServerDrainStarted, req.Cid,
+			"server", req.Ip, req.UserAgent, changeDetail)
+		if err := serverService.auditLogOperation.SaveAuditLog(auditLog); err != nil {
+			serverService.logger.ErrorF("Fail to create audit log for server_drain_started, detail: %v", err)
+		}
+	}()
+
+	return NewApiResponse(&SuccessDrainServer, Unsatisfied, &ResponseDrainServer{Deadline: deadline})
+}
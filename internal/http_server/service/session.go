@@ -0,0 +1,71 @@
+// Package service
+package service
+
+import (
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/service"
+)
+
+type SessionService struct {
+	logger           log.LoggerInterface
+	sessionOperation operation.UserSessionOperationInterface
+}
+
+func NewSessionService(logger log.LoggerInterface, sessionOperation operation.UserSessionOperationInterface) *SessionService {
+	return &SessionService{
+		logger:           logger,
+		sessionOperation: sessionOperation,
+	}
+}
+
+var SuccessGetSessions = ApiStatus{StatusName: "GET_SESSIONS", Description: "获取登录会话成功", HttpCode: Ok}
+
+func (sessionService *SessionService) GetSessions(req *RequestGetSessions) *ApiResponse[ResponseGetSessions] {
+	sessions, err := sessionService.sessionOperation.GetActiveUserSessions(req.Cid)
+	if err != nil {
+		return NewApiResponse[ResponseGetSessions](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+	data := make(ResponseGetSessions, 0, len(sessions))
+	for _, session := range sessions {
+		data = append(data, &SessionInfo{UserSession: session, Current: session.Jti == req.SessionId})
+	}
+	return NewApiResponse(&SuccessGetSessions, Unsatisfied, &data)
+}
+
+var SuccessRevokeSession = ApiStatus{StatusName: "REVOKE_SESSION", Description: "登录会话已注销", HttpCode: Ok}
+
+func (sessionService *SessionService) RevokeSession(req *RequestRevokeSession) *ApiResponse[ResponseRevokeSession] {
+	if req.TargetId <= 0 {
+		return NewApiResponse[ResponseRevokeSession](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	session, res := CallDBFuncAndCheckError[operation.UserSession, ResponseRevokeSession](func() (*operation.UserSession, error) {
+		return sessionService.sessionOperation.GetUserSessionById(req.TargetId)
+	})
+	if res != nil {
+		return res
+	}
+	// 只能注销自己的会话, 不暴露其他用户的会话是否存在
+	if session.Cid != req.Cid || session.RevokedAt != nil {
+		return NewApiResponse[ResponseRevokeSession](&ErrUserSessionNotFound, Unsatisfied, nil)
+	}
+	if _, res := CallDBFuncAndCheckError[interface{}, ResponseRevokeSession](func() (*interface{}, error) {
+		return nil, sessionService.sessionOperation.RevokeUserSession(session)
+	}); res != nil {
+		return res
+	}
+	data := ResponseRevokeSession(true)
+	return NewApiResponse(&SuccessRevokeSession, Unsatisfied, &data)
+}
+
+var SuccessRevokeOtherSessions = ApiStatus{StatusName: "REVOKE_OTHER_SESSIONS", Description: "其他登录会话已全部注销", HttpCode: Ok}
+
+func (sessionService *SessionService) RevokeOtherSessions(req *RequestRevokeOtherSessions) *ApiResponse[ResponseRevokeOtherSessions] {
+	if _, res := CallDBFuncAndCheckError[interface{}, ResponseRevokeOtherSessions](func() (*interface{}, error) {
+		return nil, sessionService.sessionOperation.RevokeUserSessions(req.Cid, req.SessionId)
+	}); res != nil {
+		return res
+	}
+	data := ResponseRevokeOtherSessions(true)
+	return NewApiResponse(&SuccessRevokeOtherSessions, Unsatisfied, &data)
+}
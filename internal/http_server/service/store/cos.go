@@ -85,10 +85,10 @@ func (store *TencentCosStoreService) DeleteImageFile(file string) (*StoreInfo, e
 }
 
 func (store *TencentCosStoreService) SaveUploadImages(req *RequestUploadFile) *ApiResponse[ResponseUploadFile] {
-	if req.Permission <= 0 {
+	if !req.Permission.IsStaff() {
 		return NewApiResponse[ResponseUploadFile](&ErrNoPermission, Unsatisfied, nil)
 	}
-	permission := operation.Permission(req.Permission)
+	permission := req.Permission
 	if !permission.HasPermission(operation.ActivityPublish) {
 		return NewApiResponse[ResponseUploadFile](&ErrNoPermission, PermissionDenied, nil)
 	}
@@ -105,3 +105,18 @@ func (store *TencentCosStoreService) SaveUploadImages(req *RequestUploadFile) *A
 		AccessPath: accessUrl,
 	})
 }
+
+// Ping 检查本地存储与远端存储桶是否可用
+func (store *TencentCosStoreService) Ping(ctx context.Context) error {
+	if err := store.localStore.Ping(ctx); err != nil {
+		return err
+	}
+	exist, err := store.client.Bucket.IsExist(ctx)
+	if err != nil {
+		return err
+	}
+	if !exist {
+		return fmt.Errorf("bucket %s does not exist", store.config.Bucket)
+	}
+	return nil
+}
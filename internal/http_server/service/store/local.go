@@ -2,6 +2,7 @@
 package store
 
 import (
+	"context"
 	"fmt"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/global"
@@ -100,10 +101,10 @@ func (store *LocalStoreService) DeleteImageFile(file string) (*StoreInfo, error)
 }
 
 func (store *LocalStoreService) SaveUploadImages(req *RequestUploadFile) *ApiResponse[ResponseUploadFile] {
-	if req.Permission <= 0 {
+	if !req.Permission.IsStaff() {
 		return NewApiResponse[ResponseUploadFile](&ErrNoPermission, Unsatisfied, nil)
 	}
-	permission := operation.Permission(req.Permission)
+	permission := req.Permission
 	if !permission.HasPermission(operation.ActivityPublish) {
 		return NewApiResponse[ResponseUploadFile](&ErrNoPermission, PermissionDenied, nil)
 	}
@@ -116,3 +117,13 @@ func (store *LocalStoreService) SaveUploadImages(req *RequestUploadFile) *ApiRes
 		AccessPath: storeInfo.RemotePath,
 	})
 }
+
+// Ping 检查本地存储目录是否可写
+func (store *LocalStoreService) Ping(_ context.Context) error {
+	file, err := os.CreateTemp(store.config.LocalStorePath, ".health-*")
+	if err != nil {
+		return err
+	}
+	_ = file.Close()
+	return os.Remove(file.Name())
+}
@@ -3,6 +3,7 @@ package store
 
 import (
 	"context"
+	"fmt"
 	"github.com/aliyun/alibabacloud-oss-go-sdk-v2/oss"
 	"github.com/aliyun/alibabacloud-oss-go-sdk-v2/oss/credentials"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
@@ -91,10 +92,10 @@ func (store *ALiYunOssStoreService) DeleteImageFile(file string) (*StoreInfo, er
 }
 
 func (store *ALiYunOssStoreService) SaveUploadImages(req *RequestUploadFile) *ApiResponse[ResponseUploadFile] {
-	if req.Permission <= 0 {
+	if !req.Permission.IsStaff() {
 		return NewApiResponse[ResponseUploadFile](&ErrNoPermission, Unsatisfied, nil)
 	}
-	permission := operation.Permission(req.Permission)
+	permission := req.Permission
 	if !permission.HasPermission(operation.ActivityPublish) {
 		return NewApiResponse[ResponseUploadFile](&ErrNoPermission, PermissionDenied, nil)
 	}
@@ -111,3 +112,18 @@ func (store *ALiYunOssStoreService) SaveUploadImages(req *RequestUploadFile) *Ap
 		AccessPath: accessUrl,
 	})
 }
+
+// Ping 检查本地存储与远端存储桶是否可用
+func (store *ALiYunOssStoreService) Ping(ctx context.Context) error {
+	if err := store.localStore.Ping(ctx); err != nil {
+		return err
+	}
+	exist, err := store.client.IsBucketExist(ctx, store.config.Bucket)
+	if err != nil {
+		return err
+	}
+	if !exist {
+		return fmt.Errorf("bucket %s does not exist", store.config.Bucket)
+	}
+	return nil
+}
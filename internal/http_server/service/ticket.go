@@ -0,0 +1,315 @@
+// Package service
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/service"
+	"strconv"
+	"strings"
+)
+
+type TicketService struct {
+	logger            log.LoggerInterface
+	config            *config.HttpServerConfig
+	userOperation     operation.UserOperationInterface
+	ticketOperation   operation.TicketOperationInterface
+	auditLogOperation operation.AuditLogOperationInterface
+	emailService      EmailServiceInterface
+}
+
+func NewTicketService(
+	logger log.LoggerInterface,
+	config *config.HttpServerConfig,
+	userOperation operation.UserOperationInterface,
+	ticketOperation operation.TicketOperationInterface,
+	auditLogOperation operation.AuditLogOperationInterface,
+	emailService EmailServiceInterface,
+) *TicketService {
+	return &TicketService{
+		logger:            logger,
+		config:            config,
+		userOperation:     userOperation,
+		ticketOperation:   ticketOperation,
+		auditLogOperation: auditLogOperation,
+		emailService:      emailService,
+	}
+}
+
+var (
+	ticketTitleValidator = &FieldValidator{
+		Min:      1,
+		Max:      128,
+		ErrShort: &ApiStatus{StatusName: "TICKET_TITLE_TOO_SHORT", Description: "工单标题不能为空", HttpCode: BadRequest},
+		ErrLong:  &ApiStatus{StatusName: "TICKET_TITLE_TOO_LONG", Description: "工单标题过长", HttpCode: BadRequest},
+	}
+	ticketContentValidator = &FieldValidator{
+		Min:      1,
+		Max:      4096,
+		ErrShort: &ApiStatus{StatusName: "TICKET_CONTENT_TOO_SHORT", Description: "工单内容不能为空", HttpCode: BadRequest},
+		ErrLong:  &ApiStatus{StatusName: "TICKET_CONTENT_TOO_LONG", Description: "工单内容过长", HttpCode: BadRequest},
+	}
+)
+
+// sendTicketEmail 发送工单更新邮件, 接收者与操作人相同时不发送
+func (ticketService *TicketService) sendTicketEmail(cid int, operator *operation.User, ticket *operation.Ticket, event string, content string) {
+	if cid <= 0 || cid == operator.Cid || !ticketService.config.Email.Templates().EnableTicketUpdateEmail {
+		return
+	}
+	go func() {
+		user, err := ticketService.userOperation.GetUserByCid(cid)
+		if err != nil {
+			ticketService.logger.ErrorF("Fail to get user %04d for ticket email: %v", cid, err)
+			return
+		}
+		if err := ticketService.emailService.SendTicketUpdateEmail(user, operator, ticket, event, content); err != nil {
+			ticketService.logger.ErrorF("SendTicketUpdateEmail Failed: %v", err)
+		}
+	}()
+}
+
+func (ticketService *TicketService) saveAuditLog(eventType operation.EventType, req *EchoContentHeader, cid int, ticket *operation.Ticket, changeDetail *operation.ChangeDetail) {
+	go func() {
+		auditLog := ticketService.auditLogOperation.NewAuditLog(eventType, cid, strconv.Itoa(int(ticket.ID)), req.Ip, req.UserAgent, changeDetail)
+		if err := ticketService.auditLogOperation.SaveAuditLog(auditLog); err != nil {
+			ticketService.logger.ErrorF("Fail to create audit log for %s, detail: %v", eventType, err)
+		}
+	}()
+}
+
+var SuccessGetTickets = ApiStatus{StatusName: "GET_TICKETS", Description: "成功获取工单", HttpCode: Ok}
+
+func (ticketService *TicketService) GetTickets(req *RequestGetTickets) *ApiResponse[ResponseGetTickets] {
+	if req.Page <= 0 || req.PageSize <= 0 {
+		return NewApiResponse[ResponseGetTickets](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	tickets, total, err := ticketService.ticketOperation.GetUserTickets(req.Cid, req.Page, req.PageSize)
+	if err != nil {
+		return NewApiResponse[ResponseGetTickets](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+	return NewApiResponse(&SuccessGetTickets, Unsatisfied, &ResponseGetTickets{
+		Items:    tickets,
+		Page:     req.Page,
+		PageSize: req.PageSize,
+		Total:    total,
+	})
+}
+
+func (ticketService *TicketService) GetTicketsPage(req *RequestGetTicketsPage) *ApiResponse[ResponseGetTickets] {
+	if req.Page <= 0 || req.PageSize <= 0 {
+		return NewApiResponse[ResponseGetTickets](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	permission := req.Permission
+	if !permission.HasPermission(operation.TicketShowList) {
+		return NewApiResponse[ResponseGetTickets](&ErrNoPermission, Unsatisfied, nil)
+	}
+	tickets, total, err := ticketService.ticketOperation.GetTickets(req.Page, req.PageSize)
+	if err != nil {
+		return NewApiResponse[ResponseGetTickets](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+	return NewApiResponse(&SuccessGetTickets, Unsatisfied, &ResponseGetTickets{
+		Items:    tickets,
+		Page:     req.Page,
+		PageSize: req.PageSize,
+		Total:    total,
+	})
+}
+
+var SuccessGetTicket = ApiStatus{StatusName: "GET_TICKET", Description: "成功获取工单", HttpCode: Ok}
+
+func (ticketService *TicketService) GetTicket(req *RequestGetTicket) *ApiResponse[ResponseGetTicket] {
+	if req.TicketId <= 0 {
+		return NewApiResponse[ResponseGetTicket](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	ticket, res := CallDBFuncAndCheckError[operation.Ticket, ResponseGetTicket](func() (*operation.Ticket, error) {
+		return ticketService.ticketOperation.GetTicketById(req.TicketId)
+	})
+	if res != nil {
+		return res
+	}
+	permission := req.Permission
+	if ticket.Cid != req.Cid && ticket.AssignedTo != req.Cid && !permission.HasPermission(operation.TicketShowList) {
+		return NewApiResponse[ResponseGetTicket](&ErrNoPermission, Unsatisfied, nil)
+	}
+	return NewApiResponse(&SuccessGetTicket, Unsatisfied, (*ResponseGetTicket)(ticket))
+}
+
+var SuccessAddTicket = ApiStatus{StatusName: "ADD_TICKET", Description: "工单提交成功", HttpCode: Ok}
+
+func (ticketService *TicketService) AddTicket(req *RequestAddTicket) *ApiResponse[ResponseAddTicket] {
+	req.Title = strings.TrimSpace(req.Title)
+	req.Content = strings.TrimSpace(req.Content)
+	if res := ticketTitleValidator.CheckString(req.Title); res != nil {
+		return NewApiResponse[ResponseAddTicket](res, Unsatisfied, nil)
+	}
+	if res := ticketContentValidator.CheckString(req.Content); res != nil {
+		return NewApiResponse[ResponseAddTicket](res, Unsatisfied, nil)
+	}
+	user, res := CallDBFuncAndCheckError[operation.User, ResponseAddTicket](func() (*operation.User, error) {
+		return ticketService.userOperation.GetUserByUid(req.Uid)
+	})
+	if res != nil {
+		return res
+	}
+	ticket := ticketService.ticketOperation.NewTicket(user, req.Title, req.Content)
+	if err := ticketService.ticketOperation.AddTicket(ticket); err != nil {
+		ticketService.logger.ErrorF("Error adding ticket: %v", err)
+		return NewApiResponse[ResponseAddTicket](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+
+	newValue, _ := json.Marshal(ticket)
+	ticketService.saveAuditLog(operation.TicketCreated, &req.EchoContentHeader, req.Cid, ticket, &operation.ChangeDetail{
+		OldValue: "",
+		NewValue: string(newValue),
+	})
+
+	return NewApiResponse(&SuccessAddTicket, Unsatisfied, (*ResponseAddTicket)(ticket))
+}
+
+var (
+	ErrTicketClosed    = ApiStatus{StatusName: "TICKET_CLOSED", Description: "工单已关闭", HttpCode: Conflict}
+	SuccessReplyTicket = ApiStatus{StatusName: "REPLY_TICKET", Description: "回复成功", HttpCode: Ok}
+)
+
+func (ticketService *TicketService) ReplyTicket(req *RequestReplyTicket) *ApiResponse[ResponseReplyTicket] {
+	if req.TicketId <= 0 {
+		return NewApiResponse[ResponseReplyTicket](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	req.Content = strings.TrimSpace(req.Content)
+	if res := ticketContentValidator.CheckString(req.Content); res != nil {
+		return NewApiResponse[ResponseReplyTicket](res, Unsatisfied, nil)
+	}
+	ticket, res := CallDBFuncAndCheckError[operation.Ticket, ResponseReplyTicket](func() (*operation.Ticket, error) {
+		return ticketService.ticketOperation.GetTicketById(req.TicketId)
+	})
+	if res != nil {
+		return res
+	}
+	user, res := CallDBFuncAndCheckError[operation.User, ResponseReplyTicket](func() (*operation.User, error) {
+		return ticketService.userOperation.GetUserByUid(req.Uid)
+	})
+	if res != nil {
+		return res
+	}
+	permission := req.RealtimePermission(user)
+	if ticket.Cid != user.Cid && ticket.AssignedTo != user.Cid && !permission.HasPermission(operation.TicketRespond) {
+		return NewApiResponse[ResponseReplyTicket](&ErrNoPermission, Unsatisfied, nil)
+	}
+	reply := ticketService.ticketOperation.NewTicketReply(ticket, user, req.Content)
+	if err := ticketService.ticketOperation.AddTicketReply(ticket, reply); err != nil {
+		if errors.Is(err, operation.ErrTicketClosed) {
+			return NewApiResponse[ResponseReplyTicket](&ErrTicketClosed, Unsatisfied, nil)
+		}
+		ticketService.logger.ErrorF("Error adding ticket reply: %v", err)
+		return NewApiResponse[ResponseReplyTicket](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+
+	ticketService.saveAuditLog(operation.TicketReply, &req.EchoContentHeader, req.Cid, ticket, &operation.ChangeDetail{
+		OldValue: "",
+		NewValue: req.Content,
+	})
+
+	// 工单创建者回复时通知负责人, 其他人回复时通知工单创建者
+	if ticket.Cid == user.Cid {
+		ticketService.sendTicketEmail(ticket.AssignedTo, user, ticket, "收到了新的回复", req.Content)
+	} else {
+		ticketService.sendTicketEmail(ticket.Cid, user, ticket, "收到了新的回复", req.Content)
+	}
+
+	return NewApiResponse(&SuccessReplyTicket, Unsatisfied, (*ResponseReplyTicket)(reply))
+}
+
+var (
+	ErrTicketAssignee   = ApiStatus{StatusName: "TICKET_ASSIGNEE_INVALID", Description: "指派的用户没有回复工单的权限", HttpCode: BadRequest}
+	SuccessAssignTicket = ApiStatus{StatusName: "ASSIGN_TICKET", Description: "指派成功", HttpCode: Ok}
+)
+
+func (ticketService *TicketService) AssignTicket(req *RequestAssignTicket) *ApiResponse[ResponseAssignTicket] {
+	if req.TicketId <= 0 || req.TargetCid <= 0 {
+		return NewApiResponse[ResponseAssignTicket](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	user, res := CallDBFuncAndCheckError[operation.User, ResponseAssignTicket](func() (*operation.User, error) {
+		return ticketService.userOperation.GetUserByUid(req.Uid)
+	})
+	if res != nil {
+		return res
+	}
+	permission := req.RealtimePermission(user)
+	if !permission.HasPermission(operation.TicketManage) {
+		return NewApiResponse[ResponseAssignTicket](&ErrNoPermission, Unsatisfied, nil)
+	}
+	target, res := CallDBFuncAndCheckError[operation.User, ResponseAssignTicket](func() (*operation.User, error) {
+		return ticketService.userOperation.GetUserByCid(req.TargetCid)
+	})
+	if res != nil {
+		return res
+	}
+	targetPermission := target.EffectivePermission()
+	if !targetPermission.HasPermission(operation.TicketRespond) {
+		return NewApiResponse[ResponseAssignTicket](&ErrTicketAssignee, Unsatisfied, nil)
+	}
+	ticket, res := CallDBFuncAndCheckError[operation.Ticket, ResponseAssignTicket](func() (*operation.Ticket, error) {
+		return ticketService.ticketOperation.GetTicketById(req.TicketId)
+	})
+	if res != nil {
+		return res
+	}
+	oldAssignee := ticket.AssignedTo
+	if err := ticketService.ticketOperation.AssignTicket(ticket, target.Cid); err != nil {
+		if errors.Is(err, operation.ErrTicketClosed) {
+			return NewApiResponse[ResponseAssignTicket](&ErrTicketClosed, Unsatisfied, nil)
+		}
+		ticketService.logger.ErrorF("Error assigning ticket: %v", err)
+		return NewApiResponse[ResponseAssignTicket](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+
+	ticketService.saveAuditLog(operation.TicketAssigned, &req.EchoContentHeader, req.Cid, ticket, &operation.ChangeDetail{
+		OldValue: fmt.Sprintf("%04d", oldAssignee),
+		NewValue: fmt.Sprintf("%04d", target.Cid),
+	})
+	ticketService.sendTicketEmail(target.Cid, user, ticket, "被指派给你处理", "")
+
+	data := ResponseAssignTicket(true)
+	return NewApiResponse(&SuccessAssignTicket, Unsatisfied, &data)
+}
+
+var SuccessCloseTicket = ApiStatus{StatusName: "CLOSE_TICKET", Description: "工单已关闭", HttpCode: Ok}
+
+func (ticketService *TicketService) CloseTicket(req *RequestCloseTicket) *ApiResponse[ResponseCloseTicket] {
+	if req.TicketId <= 0 {
+		return NewApiResponse[ResponseCloseTicket](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	ticket, res := CallDBFuncAndCheckError[operation.Ticket, ResponseCloseTicket](func() (*operation.Ticket, error) {
+		return ticketService.ticketOperation.GetTicketById(req.TicketId)
+	})
+	if res != nil {
+		return res
+	}
+	user, res := CallDBFuncAndCheckError[operation.User, ResponseCloseTicket](func() (*operation.User, error) {
+		return ticketService.userOperation.GetUserByUid(req.Uid)
+	})
+	if res != nil {
+		return res
+	}
+	permission := req.RealtimePermission(user)
+	if ticket.Cid != user.Cid && !permission.HasPermission(operation.TicketManage) {
+		return NewApiResponse[ResponseCloseTicket](&ErrNoPermission, Unsatisfied, nil)
+	}
+	if err := ticketService.ticketOperation.CloseTicket(ticket); err != nil {
+		if errors.Is(err, operation.ErrTicketClosed) {
+			return NewApiResponse[ResponseCloseTicket](&ErrTicketClosed, Unsatisfied, nil)
+		}
+		ticketService.logger.ErrorF("Error closing ticket: %v", err)
+		return NewApiResponse[ResponseCloseTicket](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+
+	ticketService.saveAuditLog(operation.TicketClosed, &req.EchoContentHeader, req.Cid, ticket, nil)
+	ticketService.sendTicketEmail(ticket.Cid, user, ticket, "已被关闭", "")
+
+	data := ResponseCloseTicket(true)
+	return NewApiResponse(&SuccessCloseTicket, Unsatisfied, &data)
+}
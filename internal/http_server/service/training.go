@@ -0,0 +1,506 @@
+// Package service
+package service
+
+import (
+	"errors"
+	"fmt"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/service"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSoloDuration 单飞授权的最长有效期
+const maxSoloDuration = 30 * 24 * time.Hour
+
+type TrainingService struct {
+	logger            log.LoggerInterface
+	config            *config.HttpServerConfig
+	userOperation     operation.UserOperationInterface
+	historyOperation  operation.HistoryOperationInterface
+	trainingOperation operation.TrainingOperationInterface
+	sessionOperation  operation.UserSessionOperationInterface
+	auditLogOperation operation.AuditLogOperationInterface
+	emailService      EmailServiceInterface
+}
+
+func NewTrainingService(
+	logger log.LoggerInterface,
+	config *config.HttpServerConfig,
+	userOperation operation.UserOperationInterface,
+	historyOperation operation.HistoryOperationInterface,
+	trainingOperation operation.TrainingOperationInterface,
+	sessionOperation operation.UserSessionOperationInterface,
+	auditLogOperation operation.AuditLogOperationInterface,
+	emailService EmailServiceInterface,
+) *TrainingService {
+	return &TrainingService{
+		logger:            logger,
+		config:            config,
+		userOperation:     userOperation,
+		historyOperation:  historyOperation,
+		trainingOperation: trainingOperation,
+		sessionOperation:  sessionOperation,
+		auditLogOperation: auditLogOperation,
+		emailService:      emailService,
+	}
+}
+
+var (
+	trainingRemarkValidator = &FieldValidator{
+		Min:     0,
+		Max:     1024,
+		ErrLong: &ApiStatus{StatusName: "TRAINING_REMARK_TOO_LONG", Description: "培训申请备注过长", HttpCode: BadRequest},
+	}
+	trainingReportValidator = &FieldValidator{
+		Min:      1,
+		Max:      4096,
+		ErrShort: &ApiStatus{StatusName: "TRAINING_REPORT_TOO_SHORT", Description: "培训报告不能为空", HttpCode: BadRequest},
+		ErrLong:  &ApiStatus{StatusName: "TRAINING_REPORT_TOO_LONG", Description: "培训报告过长", HttpCode: BadRequest},
+	}
+)
+
+var ErrTrainingClosed = ApiStatus{StatusName: "TRAINING_CLOSED", Description: "培训申请已结束", HttpCode: Conflict}
+
+// isMentorRating 只有I1至I3的教员可以担任培训教员
+func isMentorRating(rating int) bool {
+	return rating >= fsd.Instructor1.Index() && rating <= fsd.Instructor3.Index()
+}
+
+// getTrainingOperator 重新获取操作者与培训申请, 培训相关的写操作均使用实时权限
+func getTrainingOperator[T any](trainingService *TrainingService, header *JwtHeader, trainingId uint) (*operation.User, operation.PermissionSet, *operation.TrainingRequest, *ApiResponse[T]) {
+	if trainingId <= 0 {
+		return nil, nil, nil, NewApiResponse[T](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	user, res := CallDBFuncAndCheckError[operation.User, T](func() (*operation.User, error) {
+		return trainingService.userOperation.GetUserByUid(header.Uid)
+	})
+	if res != nil {
+		return nil, nil, nil, res
+	}
+	training, res := CallDBFuncAndCheckError[operation.TrainingRequest, T](func() (*operation.TrainingRequest, error) {
+		return trainingService.trainingOperation.GetTrainingRequestById(trainingId)
+	})
+	if res != nil {
+		return nil, nil, nil, res
+	}
+	return user, header.RealtimePermission(user), training, nil
+}
+
+// canMentor 操作者是否可以以教员身份处理培训申请, 指派的教员失去教员权限后不能继续操作
+func canMentor(user *operation.User, permission operation.PermissionSet, training *operation.TrainingRequest) bool {
+	if permission.HasPermission(operation.TrainingManage) {
+		return true
+	}
+	return training.MentorCid == user.Cid && isMentorRating(user.Rating)
+}
+
+func (trainingService *TrainingService) saveAuditLog(eventType operation.EventType, req *EchoContentHeader, cid int, object string, changeDetail *operation.ChangeDetail) {
+	go func() {
+		auditLog := trainingService.auditLogOperation.NewAuditLog(eventType, cid, object, req.Ip, req.UserAgent, changeDetail)
+		if err := trainingService.auditLogOperation.SaveAuditLog(auditLog); err != nil {
+			trainingService.logger.ErrorF("Fail to create audit log for %s, detail: %v", eventType, err)
+		}
+	}()
+}
+
+var SuccessGetTrainings = ApiStatus{StatusName: "GET_TRAININGS", Description: "成功获取培训申请", HttpCode: Ok}
+
+func (trainingService *TrainingService) GetTrainings(req *RequestGetTrainings) *ApiResponse[ResponseGetTrainings] {
+	if req.Page <= 0 || req.PageSize <= 0 {
+		return NewApiResponse[ResponseGetTrainings](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	trainings, total, err := trainingService.trainingOperation.GetUserTrainingRequests(req.Cid, req.Page, req.PageSize)
+	if err != nil {
+		return NewApiResponse[ResponseGetTrainings](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+	return NewApiResponse(&SuccessGetTrainings, Unsatisfied, &ResponseGetTrainings{
+		Items:    trainings,
+		Page:     req.Page,
+		PageSize: req.PageSize,
+		Total:    total,
+	})
+}
+
+func (trainingService *TrainingService) GetTrainingsPage(req *RequestGetTrainingsPage) *ApiResponse[ResponseGetTrainings] {
+	if req.Page <= 0 || req.PageSize <= 0 {
+		return NewApiResponse[ResponseGetTrainings](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	permission := req.Permission
+	if !permission.HasPermission(operation.TrainingManage) {
+		return NewApiResponse[ResponseGetTrainings](&ErrNoPermission, Unsatisfied, nil)
+	}
+	trainings, total, err := trainingService.trainingOperation.GetTrainingRequests(req.Page, req.PageSize)
+	if err != nil {
+		return NewApiResponse[ResponseGetTrainings](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+	return NewApiResponse(&SuccessGetTrainings, Unsatisfied, &ResponseGetTrainings{
+		Items:    trainings,
+		Page:     req.Page,
+		PageSize: req.PageSize,
+		Total:    total,
+	})
+}
+
+var SuccessGetTraining = ApiStatus{StatusName: "GET_TRAINING", Description: "成功获取培训申请", HttpCode: Ok}
+
+func (trainingService *TrainingService) GetTraining(req *RequestGetTraining) *ApiResponse[ResponseGetTraining] {
+	if req.TrainingId <= 0 {
+		return NewApiResponse[ResponseGetTraining](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	training, res := CallDBFuncAndCheckError[operation.TrainingRequest, ResponseGetTraining](func() (*operation.TrainingRequest, error) {
+		return trainingService.trainingOperation.GetTrainingRequestById(req.TrainingId)
+	})
+	if res != nil {
+		return res
+	}
+	permission := req.Permission
+	if training.Cid != req.Cid && training.MentorCid != req.Cid && !permission.HasPermission(operation.TrainingManage) {
+		return NewApiResponse[ResponseGetTraining](&ErrNoPermission, Unsatisfied, nil)
+	}
+	return NewApiResponse(&SuccessGetTraining, Unsatisfied, (*ResponseGetTraining)(training))
+}
+
+var SuccessGetTrainingHistory = ApiStatus{StatusName: "GET_TRAINING_HISTORY", Description: "成功获取学员管制记录", HttpCode: Ok}
+
+// GetTrainingHistory 获取学员最近的管制联飞记录, 供教员提交培训报告时选择
+func (trainingService *TrainingService) GetTrainingHistory(req *RequestGetTraining) *ApiResponse[ResponseGetTrainingHistory] {
+	if req.TrainingId <= 0 {
+		return NewApiResponse[ResponseGetTrainingHistory](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	training, res := CallDBFuncAndCheckError[operation.TrainingRequest, ResponseGetTrainingHistory](func() (*operation.TrainingRequest, error) {
+		return trainingService.trainingOperation.GetTrainingRequestById(req.TrainingId)
+	})
+	if res != nil {
+		return res
+	}
+	permission := req.Permission
+	if training.Cid != req.Cid && training.MentorCid != req.Cid && !permission.HasPermission(operation.TrainingManage) {
+		return NewApiResponse[ResponseGetTrainingHistory](&ErrNoPermission, Unsatisfied, nil)
+	}
+	userHistory, res := CallDBFuncAndCheckError[operation.UserHistory, ResponseGetTrainingHistory](func() (*operation.UserHistory, error) {
+		return trainingService.historyOperation.GetUserHistory(training.Cid)
+	})
+	if res != nil {
+		return res
+	}
+	data := ResponseGetTrainingHistory(userHistory.Controllers)
+	return NewApiResponse(&SuccessGetTrainingHistory, Unsatisfied, &data)
+}
+
+var (
+	ErrTrainingRating  = ApiStatus{StatusName: "TRAINING_RATING_INVALID", Description: "目标管制权限必须高于当前权限且不高于C3", HttpCode: BadRequest}
+	ErrTrainingExists  = ApiStatus{StatusName: "TRAINING_EXISTS", Description: "已有未结束的培训申请", HttpCode: Conflict}
+	SuccessAddTraining = ApiStatus{StatusName: "ADD_TRAINING", Description: "培训申请提交成功", HttpCode: Ok}
+)
+
+func (trainingService *TrainingService) AddTraining(req *RequestAddTraining) *ApiResponse[ResponseAddTraining] {
+	req.Remark = strings.TrimSpace(req.Remark)
+	if res := trainingRemarkValidator.CheckString(req.Remark); res != nil {
+		return NewApiResponse[ResponseAddTraining](res, Unsatisfied, nil)
+	}
+	// 管制权限可能在签发令牌后变化, 这里获取实时数据
+	user, res := CallDBFuncAndCheckError[operation.User, ResponseAddTraining](func() (*operation.User, error) {
+		return trainingService.userOperation.GetUserByUid(req.Uid)
+	})
+	if res != nil {
+		return res
+	}
+	if req.TargetRating <= user.Rating || req.TargetRating < fsd.STU1.Index() || req.TargetRating > fsd.CTR3.Index() {
+		return NewApiResponse[ResponseAddTraining](&ErrTrainingRating, Unsatisfied, nil)
+	}
+	training := trainingService.trainingOperation.NewTrainingRequest(user, req.TargetRating, req.Remark)
+	if err := trainingService.trainingOperation.AddTrainingRequest(training); err != nil {
+		if errors.Is(err, operation.ErrTrainingExists) {
+			return NewApiResponse[ResponseAddTraining](&ErrTrainingExists, Unsatisfied, nil)
+		}
+		trainingService.logger.ErrorF("Error adding training request: %v", err)
+		return NewApiResponse[ResponseAddTraining](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+
+	trainingService.saveAuditLog(operation.TrainingRequested, &req.EchoContentHeader, req.Cid, strconv.Itoa(int(training.ID)), &operation.ChangeDetail{
+		OldValue: fsd.Rating(user.Rating).String(),
+		NewValue: fsd.Rating(training.TargetRating).String(),
+	})
+
+	return NewApiResponse(&SuccessAddTraining, Unsatisfied, (*ResponseAddTraining)(training))
+}
+
+var (
+	ErrTrainingMentor   = ApiStatus{StatusName: "TRAINING_MENTOR_INVALID", Description: "教员必须拥有I1至I3管制权限且不能是学员本人", HttpCode: BadRequest}
+	SuccessAssignMentor = ApiStatus{StatusName: "ASSIGN_MENTOR", Description: "指派教员成功", HttpCode: Ok}
+)
+
+func (trainingService *TrainingService) AssignMentor(req *RequestAssignMentor) *ApiResponse[ResponseAssignMentor] {
+	if req.MentorCid <= 0 {
+		return NewApiResponse[ResponseAssignMentor](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	_, permission, training, res := getTrainingOperator[ResponseAssignMentor](trainingService, &req.JwtHeader, req.TrainingId)
+	if res != nil {
+		return res
+	}
+	if !permission.HasPermission(operation.TrainingManage) {
+		return NewApiResponse[ResponseAssignMentor](&ErrNoPermission, Unsatisfied, nil)
+	}
+	mentor, res := CallDBFuncAndCheckError[operation.User, ResponseAssignMentor](func() (*operation.User, error) {
+		return trainingService.userOperation.GetUserByCid(req.MentorCid)
+	})
+	if res != nil {
+		return res
+	}
+	if mentor.Cid == training.Cid || !isMentorRating(mentor.Rating) {
+		return NewApiResponse[ResponseAssignMentor](&ErrTrainingMentor, Unsatisfied, nil)
+	}
+	oldMentor := training.MentorCid
+	if err := trainingService.trainingOperation.AssignMentor(training, mentor.Cid); err != nil {
+		if errors.Is(err, operation.ErrTrainingClosed) {
+			return NewApiResponse[ResponseAssignMentor](&ErrTrainingClosed, Unsatisfied, nil)
+		}
+		trainingService.logger.ErrorF("Error assigning mentor: %v", err)
+		return NewApiResponse[ResponseAssignMentor](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+
+	trainingService.saveAuditLog(operation.TrainingAssigned, &req.EchoContentHeader, req.Cid, strconv.Itoa(int(training.ID)), &operation.ChangeDetail{
+		OldValue: fmt.Sprintf("%04d", oldMentor),
+		NewValue: fmt.Sprintf("%04d", mentor.Cid),
+	})
+
+	data := ResponseAssignMentor(true)
+	return NewApiResponse(&SuccessAssignMentor, Unsatisfied, &data)
+}
+
+var (
+	ErrTrainingHistory       = ApiStatus{StatusName: "TRAINING_HISTORY_INVALID", Description: "联飞记录不是该学员的管制记录", HttpCode: BadRequest}
+	SuccessAddTrainingReport = ApiStatus{StatusName: "ADD_TRAINING_REPORT", Description: "培训报告提交成功", HttpCode: Ok}
+)
+
+func (trainingService *TrainingService) AddTrainingReport(req *RequestAddTrainingReport) *ApiResponse[ResponseAddTrainingReport] {
+	if req.HistoryId <= 0 {
+		return NewApiResponse[ResponseAddTrainingReport](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	req.Content = strings.TrimSpace(req.Content)
+	if res := trainingReportValidator.CheckString(req.Content); res != nil {
+		return NewApiResponse[ResponseAddTrainingReport](res, Unsatisfied, nil)
+	}
+	user, permission, training, res := getTrainingOperator[ResponseAddTrainingReport](trainingService, &req.JwtHeader, req.TrainingId)
+	if res != nil {
+		return res
+	}
+	if !canMentor(user, permission, training) {
+		return NewApiResponse[ResponseAddTrainingReport](&ErrNoPermission, Unsatisfied, nil)
+	}
+	history, res := CallDBFuncAndCheckError[operation.History, ResponseAddTrainingReport](func() (*operation.History, error) {
+		return trainingService.historyOperation.GetHistoryById(req.HistoryId)
+	})
+	if res != nil {
+		return res
+	}
+	if history.Cid != training.Cid || !history.IsAtc {
+		return NewApiResponse[ResponseAddTrainingReport](&ErrTrainingHistory, Unsatisfied, nil)
+	}
+	report := trainingService.trainingOperation.NewTrainingReport(training, user, history, req.Content)
+	if err := trainingService.trainingOperation.AddTrainingReport(training, report); err != nil {
+		if errors.Is(err, operation.ErrTrainingClosed) {
+			return NewApiResponse[ResponseAddTrainingReport](&ErrTrainingClosed, Unsatisfied, nil)
+		}
+		trainingService.logger.ErrorF("Error adding training report: %v", err)
+		return NewApiResponse[ResponseAddTrainingReport](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+
+	trainingService.saveAuditLog(operation.TrainingReported, &req.EchoContentHeader, req.Cid, strconv.Itoa(int(training.ID)), &operation.ChangeDetail{
+		OldValue: "",
+		NewValue: fmt.Sprintf("%s(%d)", history.Callsign, history.ID),
+	})
+
+	return NewApiResponse(&SuccessAddTrainingReport, Unsatisfied, (*ResponseAddTrainingReport)(report))
+}
+
+var (
+	ErrSoloFacility  = ApiStatus{StatusName: "SOLO_FACILITY_INVALID", Description: "单飞席位必须高于学员当前权限且不高于培训目标权限", HttpCode: BadRequest}
+	ErrSoloExpiresAt = ApiStatus{StatusName: "SOLO_EXPIRES_AT_INVALID", Description: "单飞授权过期时间必须晚于当前时间且不超过30天", HttpCode: BadRequest}
+	SuccessGrantSolo = ApiStatus{StatusName: "GRANT_SOLO", Description: "单飞授权成功", HttpCode: Ok}
+)
+
+func (trainingService *TrainingService) GrantSolo(req *RequestGrantSolo) *ApiResponse[ResponseGrantSolo] {
+	if req.Facility <= 0 || req.Facility >= len(fsd.Facilities) {
+		return NewApiResponse[ResponseGrantSolo](&ErrSoloFacility, Unsatisfied, nil)
+	}
+	now := time.Now()
+	if !req.ExpiresAt.After(now) || req.ExpiresAt.Sub(now) > maxSoloDuration {
+		return NewApiResponse[ResponseGrantSolo](&ErrSoloExpiresAt, Unsatisfied, nil)
+	}
+	user, permission, training, res := getTrainingOperator[ResponseGrantSolo](trainingService, &req.JwtHeader, req.TrainingId)
+	if res != nil {
+		return res
+	}
+	if !canMentor(user, permission, training) {
+		return NewApiResponse[ResponseGrantSolo](&ErrNoPermission, Unsatisfied, nil)
+	}
+	student, res := CallDBFuncAndCheckError[operation.User, ResponseGrantSolo](func() (*operation.User, error) {
+		return trainingService.userOperation.GetUserByCid(training.Cid)
+	})
+	if res != nil {
+		return res
+	}
+	// 单飞只用于学员当前权限不允许, 但培训目标权限允许的管制席位
+	facility := fsd.Facility(1 << req.Facility)
+	if !fsd.AllowAtcFacility.CheckFacility(facility) ||
+		fsd.Rating(student.Rating).CheckRatingFacility(facility) ||
+		!fsd.Rating(training.TargetRating).CheckRatingFacility(facility) {
+		return NewApiResponse[ResponseGrantSolo](&ErrSoloFacility, Unsatisfied, nil)
+	}
+	solo := trainingService.trainingOperation.NewSoloEndorsement(training, user, req.Facility, req.ExpiresAt)
+	if err := trainingService.trainingOperation.AddSoloEndorsement(training, solo); err != nil {
+		if errors.Is(err, operation.ErrTrainingClosed) {
+			return NewApiResponse[ResponseGrantSolo](&ErrTrainingClosed, Unsatisfied, nil)
+		}
+		trainingService.logger.ErrorF("Error adding solo endorsement: %v", err)
+		return NewApiResponse[ResponseGrantSolo](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+
+	trainingService.saveAuditLog(operation.SoloGranted, &req.EchoContentHeader, req.Cid, fmt.Sprintf("%04d", student.Cid), &operation.ChangeDetail{
+		OldValue: "",
+		NewValue: fmt.Sprintf("%s until %s", facility.String(), solo.ExpiresAt.UTC().Format(time.RFC3339)),
+	})
+
+	return NewApiResponse(&SuccessGrantSolo, Unsatisfied, (*ResponseGrantSolo)(solo))
+}
+
+var (
+	ErrSoloNotFound   = ApiStatus{StatusName: "SOLO_NOT_FOUND", Description: "单飞授权不存在", HttpCode: NotFound}
+	ErrSoloRevoked    = ApiStatus{StatusName: "SOLO_REVOKED", Description: "单飞授权已撤销", HttpCode: Conflict}
+	SuccessRevokeSolo = ApiStatus{StatusName: "REVOKE_SOLO", Description: "单飞授权已撤销", HttpCode: Ok}
+)
+
+func (trainingService *TrainingService) RevokeSolo(req *RequestRevokeSolo) *ApiResponse[ResponseRevokeSolo] {
+	if req.SoloId <= 0 {
+		return NewApiResponse[ResponseRevokeSolo](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	user, permission, training, res := getTrainingOperator[ResponseRevokeSolo](trainingService, &req.JwtHeader, req.TrainingId)
+	if res != nil {
+		return res
+	}
+	if !canMentor(user, permission, training) {
+		return NewApiResponse[ResponseRevokeSolo](&ErrNoPermission, Unsatisfied, nil)
+	}
+	var solo *operation.SoloEndorsement
+	for _, s := range training.Solos {
+		if s.ID == req.SoloId {
+			solo = s
+			break
+		}
+	}
+	if solo == nil {
+		return NewApiResponse[ResponseRevokeSolo](&ErrSoloNotFound, Unsatisfied, nil)
+	}
+	if solo.RevokedAt != nil {
+		return NewApiResponse[ResponseRevokeSolo](&ErrSoloRevoked, Unsatisfied, nil)
+	}
+	if err := trainingService.trainingOperation.RevokeSoloEndorsement(solo); err != nil {
+		trainingService.logger.ErrorF("Error revoking solo endorsement: %v", err)
+		return NewApiResponse[ResponseRevokeSolo](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+
+	trainingService.saveAuditLog(operation.SoloRevoked, &req.EchoContentHeader, req.Cid, fmt.Sprintf("%04d", solo.Cid), &operation.ChangeDetail{
+		OldValue: fmt.Sprintf("%s until %s", fsd.Facility(1<<solo.Facility).String(), solo.ExpiresAt.UTC().Format(time.RFC3339)),
+		NewValue: "",
+	})
+
+	data := ResponseRevokeSolo(true)
+	return NewApiResponse(&SuccessRevokeSolo, Unsatisfied, &data)
+}
+
+var (
+	ErrTrainingStudentBanned = ApiStatus{StatusName: "TRAINING_STUDENT_BANNED", Description: "学员已被封禁, 不能完成培训", HttpCode: Conflict}
+	SuccessCompleteTraining  = ApiStatus{StatusName: "COMPLETE_TRAINING", Description: "培训完成, 已升级管制权限", HttpCode: Ok}
+)
+
+func (trainingService *TrainingService) CompleteTraining(req *RequestCompleteTraining) *ApiResponse[ResponseCompleteTraining] {
+	user, permission, training, res := getTrainingOperator[ResponseCompleteTraining](trainingService, &req.JwtHeader, req.TrainingId)
+	if res != nil {
+		return res
+	}
+	if !canMentor(user, permission, training) {
+		return NewApiResponse[ResponseCompleteTraining](&ErrNoPermission, Unsatisfied, nil)
+	}
+	if training.Closed() {
+		return NewApiResponse[ResponseCompleteTraining](&ErrTrainingClosed, Unsatisfied, nil)
+	}
+	student, res := CallDBFuncAndCheckError[operation.User, ResponseCompleteTraining](func() (*operation.User, error) {
+		return trainingService.userOperation.GetUserByCid(training.Cid)
+	})
+	if res != nil {
+		return res
+	}
+	// 升级管制权限会覆盖封禁等级, 因此不能完成被封禁学员的培训
+	if userBanned(student) {
+		return NewApiResponse[ResponseCompleteTraining](&ErrTrainingStudentBanned, Unsatisfied, nil)
+	}
+	// 学员权限在培训期间可能已被其他途径修改
+	if student.Rating >= training.TargetRating {
+		return NewApiResponse[ResponseCompleteTraining](&ErrTrainingRating, Unsatisfied, nil)
+	}
+	oldRating := fsd.Rating(student.Rating)
+	newRating := fsd.Rating(training.TargetRating)
+
+	if _, res := CallDBFuncAndCheckError[interface{}, ResponseCompleteTraining](func() (*interface{}, error) {
+		return nil, trainingService.userOperation.UpdateUserRating(student, newRating.Index())
+	}); res != nil {
+		return res
+	}
+	if err := trainingService.sessionOperation.RevokeUserSessions(student.Cid, ""); err != nil {
+		trainingService.logger.ErrorF("Fail to revoke sessions of user %04d, detail: %v", student.Cid, err)
+	}
+	if err := trainingService.trainingOperation.CloseTrainingRequest(training, operation.TrainingStatusCompleted); err != nil {
+		trainingService.logger.ErrorF("Error closing training request %d: %v", training.ID, err)
+		return NewApiResponse[ResponseCompleteTraining](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+
+	trainingService.saveAuditLog(operation.UserRatingChange, &req.EchoContentHeader, req.Cid, strconv.Itoa(student.Cid), &operation.ChangeDetail{
+		OldValue: oldRating.String(),
+		NewValue: newRating.String(),
+	})
+	trainingService.saveAuditLog(operation.TrainingClosed, &req.EchoContentHeader, req.Cid, strconv.Itoa(int(training.ID)), &operation.ChangeDetail{
+		OldValue: "",
+		NewValue: "completed",
+	})
+
+	if trainingService.config.Email.Templates().EnableRatingChangeEmail {
+		if err := trainingService.emailService.SendRatingChangeEmail(student, user, oldRating, newRating); err != nil {
+			trainingService.logger.ErrorF("SendRatingChangeEmail Failed: %v", err)
+		}
+	}
+
+	return NewApiResponse(&SuccessCompleteTraining, Unsatisfied, (*ResponseCompleteTraining)(training))
+}
+
+var SuccessCancelTraining = ApiStatus{StatusName: "CANCEL_TRAINING", Description: "培训申请已取消", HttpCode: Ok}
+
+func (trainingService *TrainingService) CancelTraining(req *RequestCancelTraining) *ApiResponse[ResponseCancelTraining] {
+	user, permission, training, res := getTrainingOperator[ResponseCancelTraining](trainingService, &req.JwtHeader, req.TrainingId)
+	if res != nil {
+		return res
+	}
+	if training.Cid != user.Cid && !permission.HasPermission(operation.TrainingManage) {
+		return NewApiResponse[ResponseCancelTraining](&ErrNoPermission, Unsatisfied, nil)
+	}
+	if err := trainingService.trainingOperation.CloseTrainingRequest(training, operation.TrainingStatusCancelled); err != nil {
+		if errors.Is(err, operation.ErrTrainingClosed) {
+			return NewApiResponse[ResponseCancelTraining](&ErrTrainingClosed, Unsatisfied, nil)
+		}
+		trainingService.logger.ErrorF("Error closing training request %d: %v", training.ID, err)
+		return NewApiResponse[ResponseCancelTraining](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+
+	trainingService.saveAuditLog(operation.TrainingClosed, &req.EchoContentHeader, req.Cid, strconv.Itoa(int(training.ID)), &operation.ChangeDetail{
+		OldValue: "",
+		NewValue: "cancelled",
+	})
+
+	data := ResponseCancelTraining(true)
+	return NewApiResponse(&SuccessCancelTraining, Unsatisfied, &data)
+}
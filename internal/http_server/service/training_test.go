@@ -0,0 +1,73 @@
+package service
+
+import (
+	"github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/service"
+	"testing"
+	"time"
+)
+
+type testTrainingUserOperation struct {
+	operation.UserOperationInterface
+	mentor  *operation.User
+	student *operation.User
+	updated bool
+}
+
+func (op *testTrainingUserOperation) GetUserByUid(uint) (*operation.User, error) {
+	return op.mentor, nil
+}
+
+func (op *testTrainingUserOperation) GetUserByCid(int) (*operation.User, error) {
+	return op.student, nil
+}
+
+func (op *testTrainingUserOperation) UpdateUserRating(*operation.User, int) error {
+	op.updated = true
+	return nil
+}
+
+type testTrainingOperation struct {
+	operation.TrainingOperationInterface
+	training *operation.TrainingRequest
+}
+
+func (op *testTrainingOperation) GetTrainingRequestById(uint) (*operation.TrainingRequest, error) {
+	return op.training, nil
+}
+
+func TestCompleteTrainingRejectsBannedStudent(t *testing.T) {
+	until := time.Now().Add(time.Hour)
+	tests := []struct {
+		name    string
+		student *operation.User
+	}{
+		{"ban rating", &operation.User{Cid: 2002, Rating: fsd.Ban.Index()}},
+		{"temporary ban", &operation.User{Cid: 2002, Rating: fsd.Observer.Index(), BannedUntil: &until}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mentor := &operation.User{ID: 1, Cid: 2001, Rating: fsd.Instructor1.Index()}
+			userOperation := &testTrainingUserOperation{mentor: mentor, student: tt.student}
+			trainingOperation := &testTrainingOperation{training: &operation.TrainingRequest{
+				ID:           1,
+				Cid:          tt.student.Cid,
+				TargetRating: fsd.STU1.Index(),
+				Status:       operation.TrainingStatusInProgress,
+				MentorCid:    mentor.Cid,
+			}}
+			service := NewTrainingService(testLogger{}, nil, userOperation, nil, trainingOperation, nil, nil, nil)
+			res := service.CompleteTraining(&RequestCompleteTraining{
+				JwtHeader:  JwtHeader{Uid: mentor.ID},
+				TrainingId: 1,
+			})
+			if res.Code != ErrTrainingStudentBanned.StatusName {
+				t.Errorf("CompleteTraining = %s, expected %s", res.Code, ErrTrainingStudentBanned.StatusName)
+			}
+			if userOperation.updated {
+				t.Error("rating of banned student updated")
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/half-nothing/simple-fsd/internal/http_server/service/oidc"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
@@ -13,6 +14,7 @@ import (
 	"github.com/half-nothing/simple-fsd/internal/utils"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -24,6 +26,13 @@ type UserService struct {
 	historyOperation  operation.HistoryOperationInterface
 	storeService      StoreServiceInterface
 	auditLogOperation operation.AuditLogOperationInterface
+	sessionOperation  operation.UserSessionOperationInterface
+	identityOperation operation.ExternalIdentityOperationInterface
+	trainingOperation operation.TrainingOperationInterface
+	externalProvider  *oidc.Provider
+	// 外部登录跳转时生成的state
+	externalStatesLock sync.Mutex
+	externalStates     map[string]*externalLoginState
 }
 
 func NewUserService(
@@ -32,9 +41,16 @@ func NewUserService(
 	userOperation operation.UserOperationInterface,
 	historyOperation operation.HistoryOperationInterface,
 	auditLogOperation operation.AuditLogOperationInterface,
+	sessionOperation operation.UserSessionOperationInterface,
+	identityOperation operation.ExternalIdentityOperationInterface,
+	trainingOperation operation.TrainingOperationInterface,
 	storeService StoreServiceInterface,
 	emailService EmailServiceInterface,
 ) *UserService {
+	var externalProvider *oidc.Provider
+	if config.ExternalLogin.Enabled {
+		externalProvider = oidc.NewProvider(config.ExternalLogin)
+	}
 	return &UserService{
 		logger:            logger,
 		emailService:      emailService,
@@ -43,6 +59,33 @@ func NewUserService(
 		historyOperation:  historyOperation,
 		storeService:      storeService,
 		auditLogOperation: auditLogOperation,
+		sessionOperation:  sessionOperation,
+		identityOperation: identityOperation,
+		trainingOperation: trainingOperation,
+		externalProvider:  externalProvider,
+		externalStates:    make(map[string]*externalLoginState),
+	}
+}
+
+// createSession 为用户创建新的登录会话, 返回访问令牌与刷新令牌
+// totpPending 为true时会话只能访问启用两步验证相关的接口
+func (userService *UserService) createSession(user *operation.User, ip string, userAgent string, totpPending bool) (token string, flushToken string, err error) {
+	jwtConfig := userService.config.JWT
+	expiresAt := time.Now().Add(jwtConfig.Expires() + jwtConfig.Refresh())
+	session := userService.sessionOperation.NewUserSession(user, ip, userAgent, expiresAt)
+	session.TotpPending = totpPending
+	if err := userService.sessionOperation.AddUserSession(session); err != nil {
+		return "", "", err
+	}
+	token = NewClaims(jwtConfig, user, false, session.Jti).GenerateKey()
+	flushToken = NewClaims(jwtConfig, user, true, session.Jti).GenerateKey()
+	return token, flushToken, nil
+}
+
+// revokeSessions 撤销用户除exceptJti外的所有会话, 用于密码、权限与管制权限变更后强制重新登录
+func (userService *UserService) revokeSessions(user *operation.User, exceptJti string) {
+	if err := userService.sessionOperation.RevokeUserSessions(user.Cid, exceptJti); err != nil {
+		userService.logger.ErrorF("Fail to revoke sessions of user %04d, detail: %v", user.Cid, err)
 	}
 }
 
@@ -98,20 +141,29 @@ func (userService *UserService) UserRegister(req *RequestUserRegister) *ApiRespo
 	}); res != nil {
 		return res
 	}
-	token := NewClaims(userService.config.JWT, user, false)
-	flushToken := NewClaims(userService.config.JWT, user, true)
+	token, flushToken, err := userService.createSession(user, req.Ip, req.UserAgent, false)
+	if err != nil {
+		userService.logger.ErrorF("Fail to create session for user %04d, detail: %v", user.Cid, err)
+		return NewApiResponse[ResponseUserRegister](&ErrDatabaseFail, Unsatisfied, nil)
+	}
 	return NewApiResponse(&SuccessRegister, Unsatisfied, &ResponseUserRegister{
 		User:       user,
-		Token:      token.GenerateKey(),
-		FlushToken: flushToken.GenerateKey(),
+		Token:      token,
+		FlushToken: flushToken,
 	})
 }
 
 var (
 	ErrUsernameOrPassword = ApiStatus{StatusName: "WRONG_USERNAME_OR_PASSWORD", Description: "用户名或密码错误", HttpCode: BadRequest}
+	ErrUserBanned         = ApiStatus{StatusName: "USER_BANNED", Description: "账户已被封禁", HttpCode: PermissionDenied}
 	SuccessLogin          = ApiStatus{StatusName: "LOGIN_SUCCESS", Description: "登陆成功", HttpCode: Ok}
 )
 
+// userBanned 用户是否被封禁, 包括管制权限为Ban与处于临时封禁期间
+func userBanned(user *operation.User) bool {
+	return user.Rating == fsd.Ban.Index() || user.Banned()
+}
+
 func (userService *UserService) UserLogin(req *RequestUserLogin) *ApiResponse[ResponseUserLogin] {
 	if req.Username == "" || req.Password == "" {
 		return NewApiResponse[ResponseUserLogin](&ErrIllegalParam, Unsatisfied, nil)
@@ -125,17 +177,33 @@ func (userService *UserService) UserLogin(req *RequestUserLogin) *ApiResponse[Re
 		return res
 	}
 
-	if pass := userService.userOperation.VerifyUserPassword(user, req.Password); pass {
-		token := NewClaims(userService.config.JWT, user, false)
-		flushToken := NewClaims(userService.config.JWT, user, true)
-		return NewApiResponse(&SuccessLogin, Unsatisfied, &ResponseUserLogin{
-			User:       user,
-			Token:      token.GenerateKey(),
-			FlushToken: flushToken.GenerateKey(),
-		})
-	} else {
+	if pass := userService.userOperation.VerifyUserPassword(user, req.Password); !pass {
 		return NewApiResponse[ResponseUserLogin](&ErrUsernameOrPassword, Unsatisfied, nil)
 	}
+
+	if userBanned(user) {
+		return NewApiResponse[ResponseUserLogin](&ErrUserBanned, Unsatisfied, nil)
+	}
+
+	if user.TotpEnabled {
+		if res := userService.verifySecondFactor(user, req.TotpCode); res != nil {
+			return NewApiResponse[ResponseUserLogin](res, Unsatisfied, nil)
+		}
+	}
+
+	// 策略要求启用两步验证但用户尚未启用时, 会话只能用于启用两步验证
+	totpPending := userService.totpEnrolmentRequired(user)
+	token, flushToken, err := userService.createSession(user, req.Ip, req.UserAgent, totpPending)
+	if err != nil {
+		userService.logger.ErrorF("Fail to create session for user %04d, detail: %v", user.Cid, err)
+		return NewApiResponse[ResponseUserLogin](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+	return NewApiResponse(&SuccessLogin, Unsatisfied, &ResponseUserLogin{
+		User:                  user,
+		Token:                 token,
+		FlushToken:            flushToken,
+		TotpEnrolmentRequired: totpPending,
+	})
 }
 
 var (
@@ -288,6 +356,11 @@ func (userService *UserService) editUserProfile(req *RequestUserEditCurrentProfi
 		}
 	}
 
+	// 修改密码后使其他设备上的登录失效, 保留当前会话
+	if _, ok := updateInfo["password"]; ok {
+		userService.revokeSessions(user, req.SessionId)
+	}
+
 	return nil, user, string(oldValue)
 }
 
@@ -307,10 +380,10 @@ func (userService *UserService) GetUserProfile(req *RequestUserProfile) *ApiResp
 	if req.TargetUid <= 0 {
 		return NewApiResponse[ResponseUserProfile](&ErrIllegalParam, Unsatisfied, nil)
 	}
-	if req.Permission <= 0 {
+	if !req.Permission.IsStaff() {
 		return NewApiResponse[ResponseUserProfile](&ErrNoPermission, Unsatisfied, nil)
 	}
-	permission := operation.Permission(req.Permission)
+	permission := req.Permission
 	if !permission.HasPermission(operation.UserGetProfile) {
 		return NewApiResponse[ResponseUserProfile](&ErrNoPermission, Unsatisfied, nil)
 	}
@@ -328,10 +401,10 @@ var (
 )
 
 func (userService *UserService) EditUserProfile(req *RequestUserEditProfile) *ApiResponse[ResponseUserEditProfile] {
-	if req.Permission <= 0 {
+	if !req.Permission.IsStaff() {
 		return NewApiResponse[ResponseUserEditProfile](&ErrNoPermission, Unsatisfied, nil)
 	}
-	permission := operation.Permission(req.Permission)
+	permission := req.Permission
 	if !permission.HasPermission(operation.UserEditBaseInfo) {
 		return NewApiResponse[ResponseUserEditProfile](&ErrNoPermission, Unsatisfied, nil)
 	}
@@ -367,10 +440,10 @@ func (userService *UserService) GetUserList(req *RequestUserList) *ApiResponse[R
 	if req.Page <= 0 || req.PageSize <= 0 {
 		return NewApiResponse[ResponseUserList](&ErrIllegalParam, Unsatisfied, nil)
 	}
-	if req.Permission <= 0 {
+	if !req.Permission.IsStaff() {
 		return NewApiResponse[ResponseUserList](&ErrNoPermission, Unsatisfied, nil)
 	}
-	permission := operation.Permission(req.Permission)
+	permission := req.Permission
 	if !permission.HasPermission(operation.UserShowList) {
 		return NewApiResponse[ResponseUserList](&ErrNoPermission, Unsatisfied, nil)
 	}
@@ -392,10 +465,10 @@ func (userService *UserService) GetControllerList(req *RequestControllerList) *A
 	if req.Page <= 0 || req.PageSize <= 0 {
 		return NewApiResponse[ResponseControllerList](&ErrIllegalParam, Unsatisfied, nil)
 	}
-	if req.Permission <= 0 {
+	if !req.Permission.IsStaff() {
 		return NewApiResponse[ResponseControllerList](&ErrNoPermission, Unsatisfied, nil)
 	}
-	permission := operation.Permission(req.Permission)
+	permission := req.Permission
 	if !permission.HasPermission(operation.UserShowList) {
 		return NewApiResponse[ResponseControllerList](&ErrNoPermission, Unsatisfied, nil)
 	}
@@ -413,6 +486,7 @@ func (userService *UserService) GetControllerList(req *RequestControllerList) *A
 
 var (
 	ErrPermissionNodeNotExists = ApiStatus{StatusName: "PERMISSION_NODE_NOT_EXISTS", Description: "无效权限节点", HttpCode: BadRequest}
+	ErrPermissionNodeRoleOnly  = ApiStatus{StatusName: "PERMISSION_NODE_ROLE_ONLY", Description: "该权限节点只能通过角色授予", HttpCode: BadRequest}
 	SuccessEditUserPermission  = ApiStatus{StatusName: "EDIT_USER_PERMISSION", Description: "编辑用户权限成功", HttpCode: Ok}
 )
 
@@ -420,18 +494,22 @@ func (userService *UserService) EditUserPermission(req *RequestUserEditPermissio
 	if req.Uid <= 0 {
 		return NewApiResponse[ResponseUserEditPermission](&ErrIllegalParam, Unsatisfied, nil)
 	}
-	user, targetUser, res := GetUsersAndCheckPermission[ResponseUserEditPermission](userService.userOperation, req.Uid, req.TargetUid, operation.UserEditPermission)
+	user, targetUser, res := GetUsersAndCheckPermission[ResponseUserEditPermission](userService.userOperation, &req.JwtHeader, req.TargetUid, operation.UserEditPermission)
 	if res != nil {
 		return res
 	}
-	permission := operation.Permission(user.Permission)
-	targetPermission := operation.Permission(targetUser.Permission)
+	permission := req.RealtimePermission(user)
+	// 只编辑直接授予的权限, 角色授予的权限通过角色管理
+	targetPermission := operation.NewPermissionSet(targetUser.Permission)
 	auditLogs := make([]*operation.AuditLog, 0, len(req.Permissions))
 	for key, value := range req.Permissions {
 		if per, ok := operation.PermissionMap[key]; ok {
 			if !permission.HasPermission(per) {
 				return NewApiResponse[ResponseUserEditPermission](&ErrNoPermission, Unsatisfied, nil)
 			}
+			if per >= operation.LegacyPermissionNodes {
+				return NewApiResponse[ResponseUserEditPermission](&ErrPermissionNodeRoleOnly, Unsatisfied, nil)
+			}
 			if value, ok := value.(bool); ok {
 				if value {
 					targetPermission.Grant(per)
@@ -455,8 +533,9 @@ func (userService *UserService) EditUserPermission(req *RequestUserEditPermissio
 	}); res != nil {
 		return res
 	}
+	userService.revokeSessions(targetUser, "")
 
-	if userService.config.Email.Template.EnablePermissionChangeEmail {
+	if userService.config.Email.Templates().EnablePermissionChangeEmail {
 		if err := userService.emailService.SendPermissionChangeEmail(targetUser, user); err != nil {
 			userService.logger.ErrorF("SendPermissionChangeEmail Failed: %v", err)
 		}
@@ -480,7 +559,7 @@ func (userService *UserService) EditUserRating(req *RequestUserEditRating) *ApiR
 	if req.Uid <= 0 || req.Rating < fsd.Ban.Index() || req.Rating > fsd.Administrator.Index() {
 		return NewApiResponse[ResponseUserEditRating](&ErrIllegalParam, Unsatisfied, nil)
 	}
-	user, targetUser, res := GetUsersAndCheckPermission[ResponseUserEditRating](userService.userOperation, req.Uid, req.TargetUid, operation.UserEditRating)
+	user, targetUser, res := GetUsersAndCheckPermission[ResponseUserEditRating](userService.userOperation, &req.JwtHeader, req.TargetUid, operation.UserEditRating)
 	if res != nil {
 		return res
 	}
@@ -495,6 +574,13 @@ func (userService *UserService) EditUserRating(req *RequestUserEditRating) *ApiR
 	}); res != nil {
 		return res
 	}
+	userService.revokeSessions(targetUser, "")
+	// 被封禁的用户不能继续培训
+	if newRating == fsd.Ban {
+		if err := userService.trainingOperation.CancelUserTrainingRequests(targetUser.Cid); err != nil {
+			userService.logger.ErrorF("Fail to cancel training requests of user %04d, detail: %v", targetUser.Cid, err)
+		}
+	}
 
 	go func() {
 		changeDetail := &operation.ChangeDetail{
@@ -509,7 +595,7 @@ func (userService *UserService) EditUserRating(req *RequestUserEditRating) *ApiR
 		}
 	}()
 
-	if userService.config.Email.Template.EnableRatingChangeEmail {
+	if userService.config.Email.Templates().EnableRatingChangeEmail {
 		if err := userService.emailService.SendRatingChangeEmail(targetUser, user, oldRating, newRating); err != nil {
 			userService.logger.ErrorF("SendRatingChangeEmail Failed: %v", err)
 		}
@@ -561,17 +647,125 @@ func (userService *UserService) GetTokenWithFlushToken(req *RequestGetToken) *Ap
 		return res
 	}
 
+	session, res := CallDBFuncAndCheckError[operation.UserSession, ResponseGetToken](func() (*operation.UserSession, error) {
+		return userService.sessionOperation.GetUserSessionByJti(req.ID)
+	})
+	if res != nil {
+		return res
+	}
+	if !session.Active() || session.Cid != user.Cid {
+		return NewApiResponse[ResponseGetToken](&ErrSessionRevoked, Unsatisfied, nil)
+	}
+	if userBanned(user) {
+		return NewApiResponse[ResponseGetToken](&ErrUserBanned, Unsatisfied, nil)
+	}
+
 	var flushToken string
-	if req.ExpiresAt.Add(-2 * userService.config.JWT.ExpiresDuration).After(time.Now()) {
+	var expiresAt time.Time
+	if req.ExpiresAt.Add(-2 * userService.config.JWT.Expires()).After(time.Now()) {
 		flushToken = ""
 	} else {
-		flushToken = NewClaims(userService.config.JWT, user, true).GenerateKey()
+		// 续发刷新令牌时沿用原会话, 同时延长会话有效期
+		refreshClaims := NewClaims(userService.config.JWT, user, true, session.Jti)
+		flushToken = refreshClaims.GenerateKey()
+		expiresAt = refreshClaims.ExpiresAt.Time
+	}
+	if err := userService.sessionOperation.TouchUserSession(session, req.Ip, expiresAt); err != nil {
+		userService.logger.ErrorF("Fail to update session of user %04d, detail: %v", user.Cid, err)
 	}
 
-	token := NewClaims(userService.config.JWT, user, false)
+	token := NewClaims(userService.config.JWT, user, false, session.Jti)
 	return NewApiResponse(&SuccessGetToken, Unsatisfied, &ResponseGetToken{
 		User:       user,
 		Token:      token.GenerateKey(),
 		FlushToken: flushToken,
 	})
 }
+
+var (
+	ErrPasswordResetDisabled = ApiStatus{StatusName: "PASSWORD_RESET_DISABLED", Description: "未启用邮件服务, 无法重置密码", HttpCode: BadRequest}
+	SuccessSendResetCode     = ApiStatus{StatusName: "SEND_RESET_CODE_SUCCESS", Description: "如果该邮箱已注册, 验证码已发送", HttpCode: Ok}
+)
+
+func (userService *UserService) SendPasswordResetCode(req *RequestPasswordResetCode) *ApiResponse[ResponsePasswordResetCode] {
+	if userService.config.Email.EmailServer == nil {
+		return NewApiResponse[ResponsePasswordResetCode](&ErrPasswordResetDisabled, Unsatisfied, nil)
+	}
+	if req.Email == "" {
+		return NewApiResponse[ResponsePasswordResetCode](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	data := &ResponsePasswordResetCode{Email: req.Email}
+	user, err := userService.userOperation.GetUserByEmail(req.Email)
+	if errors.Is(err, operation.ErrUserNotFound) {
+		// 不告诉请求者邮箱是否已注册, 未注册的邮箱同样受发送间隔限制
+		user = nil
+	} else if err != nil {
+		return NewApiResponse[ResponsePasswordResetCode](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+	err = userService.emailService.SendPasswordResetCode(req.Email, user)
+	switch {
+	case err == nil:
+		return NewApiResponse(&SuccessSendResetCode, Unsatisfied, data)
+	case errors.Is(err, ErrEmailSendInterval):
+		return NewApiResponse[ResponsePasswordResetCode](&ApiStatus{
+			StatusName: "EMAIL_SEND_INTERVAL",
+			Description: fmt.Sprintf("邮件已发送, 请在%d秒后重试",
+				int(userService.config.Email.SendDuration.Seconds())),
+			HttpCode: BadRequest,
+		}, Unsatisfied, nil)
+	case errors.Is(err, ErrRenderingTemplate):
+		return NewApiResponse[ResponsePasswordResetCode](&ErrRenderTemplate, Unsatisfied, nil)
+	default:
+		userService.logger.ErrorF("Fail to send password reset code to %s: %v", req.Email, err)
+		return NewApiResponse[ResponsePasswordResetCode](&ErrSendEmail, Unsatisfied, nil)
+	}
+}
+
+var SuccessResetPassword = ApiStatus{StatusName: "RESET_PASSWORD_SUCCESS", Description: "密码重置成功", HttpCode: Ok}
+
+func (userService *UserService) ResetPassword(req *RequestResetPassword) *ApiResponse[ResponseResetPassword] {
+	if userService.config.Email.EmailServer == nil {
+		return NewApiResponse[ResponseResetPassword](&ErrPasswordResetDisabled, Unsatisfied, nil)
+	}
+	if req.Email == "" || req.NewPassword == "" || req.EmailCode < 1e5 {
+		return NewApiResponse[ResponseResetPassword](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	if err := passwordValidator.CheckString(req.NewPassword); err != nil {
+		return NewApiResponse[ResponseResetPassword](err, Unsatisfied, nil)
+	}
+	user, err := userService.userOperation.GetUserByEmail(req.Email)
+	if errors.Is(err, operation.ErrUserNotFound) {
+		return NewApiResponse[ResponseResetPassword](&ErrEmailNotFound, Unsatisfied, nil)
+	}
+	if err != nil {
+		return NewApiResponse[ResponseResetPassword](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+	err = userService.emailService.VerifyPasswordResetCode(req.Email, req.EmailCode, user.Cid)
+	switch {
+	case errors.Is(err, ErrEmailCodeNotFound):
+		return NewApiResponse[ResponseResetPassword](&ErrEmailNotFound, Unsatisfied, nil)
+	case errors.Is(err, ErrEmailCodeExpired):
+		return NewApiResponse[ResponseResetPassword](&ErrEmailExpired, Unsatisfied, nil)
+	case err != nil:
+		return NewApiResponse[ResponseResetPassword](&ErrEmailCodeInvalid, Unsatisfied, nil)
+	}
+	password, err := userService.userOperation.UpdateUserPassword(user, "", req.NewPassword, true)
+	if err != nil {
+		return NewApiResponse[ResponseResetPassword](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+	if err := userService.userOperation.UpdateUserInfo(user, map[string]interface{}{"password": password}); err != nil {
+		return NewApiResponse[ResponseResetPassword](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+	userService.revokeSessions(user, "")
+
+	go func() {
+		auditLog := userService.auditLogOperation.NewAuditLog(operation.UserPasswordReset, user.Cid,
+			fmt.Sprintf("%04d", user.Cid), req.Ip, req.UserAgent, nil)
+		if err := userService.auditLogOperation.SaveAuditLog(auditLog); err != nil {
+			userService.logger.ErrorF("Fail to create audit log for user_password_reset, detail: %v", err)
+		}
+	}()
+
+	data := ResponseResetPassword(true)
+	return NewApiResponse(&SuccessResetPassword, Unsatisfied, &data)
+}
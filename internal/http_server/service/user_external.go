@@ -0,0 +1,312 @@
+// Package service
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/half-nothing/simple-fsd/internal/http_server/service/oidc"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/service"
+	"github.com/half-nothing/simple-fsd/internal/utils"
+	"strconv"
+	"time"
+)
+
+// externalLoginTimeout 跳转到上游后完成登录的最长时间
+const externalLoginTimeout = 10 * time.Minute
+
+// maxExternalTotpAttempts 外部登录时两步验证码允许错误的次数
+const maxExternalTotpAttempts = 5
+
+// externalLoginState 跳转到上游时生成的state, 只保存在内存中, 使用一次后立即失效
+// 已启用两步验证的用户在上游登录成功后保留state并记录cid, 等待用户提交两步验证码
+type externalLoginState struct {
+	codeVerifier string
+	nonce        string
+	binding      string // 与发起登录的浏览器绑定, 保存在Cookie中
+	cid          int
+	attempts     int
+	expiresAt    time.Time
+}
+
+func randomToken() string {
+	buf := make([]byte, 32)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+var (
+	ErrExternalLoginDisabled    = ApiStatus{StatusName: "EXTERNAL_LOGIN_DISABLED", Description: "未启用外部登录", HttpCode: NotFound}
+	ErrExternalLoginState       = ApiStatus{StatusName: "EXTERNAL_LOGIN_STATE_INVALID", Description: "登录请求无效或已过期, 请重新登录", HttpCode: BadRequest}
+	ErrExternalLinkRefused      = ApiStatus{StatusName: "EXTERNAL_LINK_REFUSED", Description: "该邮箱对应的账户已启用两步验证或拥有管理权限, 不能通过邮箱自动关联", HttpCode: PermissionDenied}
+	ErrExternalLoginUpstream    = ApiStatus{StatusName: "EXTERNAL_LOGIN_UPSTREAM_ERROR", Description: "无法从外部身份提供者获取用户信息", HttpCode: BadGateway}
+	ErrExternalAccountNotLinked = ApiStatus{StatusName: "EXTERNAL_ACCOUNT_NOT_LINKED", Description: "该外部账户未关联本站账户", HttpCode: PermissionDenied}
+	ErrExternalEmailRequired    = ApiStatus{StatusName: "EXTERNAL_EMAIL_REQUIRED", Description: "外部账户未提供已验证的邮箱, 无法创建账户", HttpCode: BadRequest}
+	ErrExternalEmailTaken       = ApiStatus{StatusName: "EXTERNAL_EMAIL_TAKEN", Description: "该邮箱已被本站账户使用", HttpCode: Conflict}
+	ErrExternalCidInvalid       = ApiStatus{StatusName: "EXTERNAL_CID_INVALID", Description: "外部账户提供的cid无效或已被使用", HttpCode: Conflict}
+	ErrExternalNoUnusedCid      = ApiStatus{StatusName: "EXTERNAL_NO_UNUSED_CID", Description: "没有可分配的cid", HttpCode: ServerInternalError}
+	SuccessGetExternalLoginUrl  = ApiStatus{StatusName: "GET_EXTERNAL_LOGIN_URL", Description: "成功生成外部登录地址", HttpCode: Ok}
+)
+
+func (userService *UserService) GetExternalLoginUrl(_ *RequestExternalLoginUrl) *ApiResponse[ResponseExternalLoginUrl] {
+	if userService.externalProvider == nil {
+		return NewApiResponse[ResponseExternalLoginUrl](&ErrExternalLoginDisabled, Unsatisfied, nil)
+	}
+	state := randomToken()
+	codeVerifier := randomToken()
+	nonce := randomToken()
+	binding := randomToken()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	url, err := userService.externalProvider.AuthCodeUrl(ctx, state, nonce, utils.PkceChallenge(codeVerifier))
+	if err != nil {
+		userService.logger.ErrorF("Fail to build external login url, %v", err)
+		return NewApiResponse[ResponseExternalLoginUrl](&ErrExternalLoginUpstream, Unsatisfied, nil)
+	}
+
+	now := time.Now()
+	userService.externalStatesLock.Lock()
+	for key, value := range userService.externalStates {
+		if value.expiresAt.Before(now) {
+			delete(userService.externalStates, key)
+		}
+	}
+	userService.externalStates[state] = &externalLoginState{
+		codeVerifier: codeVerifier,
+		nonce:        nonce,
+		binding:      binding,
+		expiresAt:    now.Add(externalLoginTimeout),
+	}
+	userService.externalStatesLock.Unlock()
+
+	return NewApiResponse(&SuccessGetExternalLoginUrl, Unsatisfied, &ResponseExternalLoginUrl{
+		Name:    userService.config.ExternalLogin.Name,
+		Url:     url,
+		State:   state,
+		Binding: binding,
+	})
+}
+
+// takeExternalState 取出state, state只能使用一次, 浏览器绑定不一致时同样视为无效
+func (userService *UserService) takeExternalState(state string, binding string) *externalLoginState {
+	userService.externalStatesLock.Lock()
+	defer userService.externalStatesLock.Unlock()
+	value, ok := userService.externalStates[state]
+	if !ok {
+		return nil
+	}
+	delete(userService.externalStates, state)
+	if value.expiresAt.Before(time.Now()) || subtle.ConstantTimeCompare([]byte(value.binding), []byte(binding)) != 1 {
+		return nil
+	}
+	return value
+}
+
+// keepExternalState 两步验证未通过时放回state, 错误次数过多后不再放回
+func (userService *UserService) keepExternalState(state string, value *externalLoginState) {
+	value.attempts++
+	if value.attempts >= maxExternalTotpAttempts {
+		return
+	}
+	userService.externalStatesLock.Lock()
+	userService.externalStates[state] = value
+	userService.externalStatesLock.Unlock()
+}
+
+// resolveExternalUser 将上游用户映射到本地用户
+// 依次尝试已关联的身份、通过已验证的邮箱关联已有账户、自动创建账户
+func (userService *UserService) resolveExternalUser(info *oidc.UserInfo) (*operation.User, *ApiStatus) {
+	loginConfig := userService.config.ExternalLogin
+	identity, err := userService.identityOperation.GetExternalIdentity(loginConfig.Issuer, info.Subject)
+	if err == nil {
+		user, err := userService.userOperation.GetUserByCid(identity.Cid)
+		if errors.Is(err, operation.ErrUserNotFound) {
+			return nil, &ErrExternalAccountNotLinked
+		} else if err != nil {
+			return nil, &ErrDatabaseFail
+		}
+		return user, nil
+	} else if !errors.Is(err, operation.ErrExternalIdentityNotFound) {
+		return nil, &ErrDatabaseFail
+	}
+
+	var user *operation.User
+	if info.Email != "" {
+		existing, err := userService.userOperation.GetUserByEmail(info.Email)
+		switch {
+		case err == nil && loginConfig.LinkByEmail && info.EmailVerified:
+			// 通过邮箱关联会绕过本地的两步验证, 不为启用两步验证或拥有管理权限的账户自动关联
+			permission := existing.EffectivePermission()
+			if existing.TotpEnabled || permission.IsStaff() {
+				return nil, &ErrExternalLinkRefused
+			}
+			user = existing
+		case err == nil:
+			return nil, &ErrExternalEmailTaken
+		case !errors.Is(err, operation.ErrUserNotFound):
+			return nil, &ErrDatabaseFail
+		}
+	}
+
+	if user == nil {
+		if !loginConfig.AutoCreate {
+			return nil, &ErrExternalAccountNotLinked
+		}
+		created, res := userService.createExternalUser(info)
+		if res != nil {
+			return nil, res
+		}
+		user = created
+	}
+
+	if _, err := userService.identityOperation.AddExternalIdentity(loginConfig.Issuer, info.Subject, user); err != nil {
+		userService.logger.ErrorF("Fail to link external identity %s to user %04d, %v", info.Subject, user.Cid, err)
+		return nil, &ErrDatabaseFail
+	}
+	userService.logger.InfoF("External identity %s linked to user %04d", info.Subject, user.Cid)
+	return user, nil
+}
+
+// createExternalUser 为上游用户创建本地账户, cid在CidMin与CidMax之间
+func (userService *UserService) createExternalUser(info *oidc.UserInfo) (*operation.User, *ApiStatus) {
+	if info.Email == "" || !info.EmailVerified || emailValidator.CheckString(info.Email) != nil {
+		return nil, &ErrExternalEmailRequired
+	}
+	limits := userService.config.Limits
+	var cid int
+	if claim := userService.config.ExternalLogin.CidClaim; claim != "" {
+		value, err := strconv.Atoi(info.Claim(claim))
+		if err != nil || cidValidator.CheckInt(value) != nil {
+			return nil, &ErrExternalCidInvalid
+		}
+		if _, err := userService.userOperation.GetUserByCid(value); err == nil {
+			return nil, &ErrExternalCidInvalid
+		} else if !errors.Is(err, operation.ErrUserNotFound) {
+			return nil, &ErrDatabaseFail
+		}
+		cid = value
+	} else {
+		value, err := userService.userOperation.GetUnusedCid(limits.CidMin, limits.CidMax)
+		if errors.Is(err, operation.ErrNoUnusedCid) {
+			return nil, &ErrExternalNoUnusedCid
+		} else if err != nil {
+			return nil, &ErrDatabaseFail
+		}
+		cid = value
+	}
+
+	// 上游用户名不可用时使用cid生成用户名
+	username := info.PreferredUsername
+	if usernameValidator.CheckString(username) != nil {
+		username = fmt.Sprintf("user%04d", cid)
+	} else if _, err := userService.userOperation.GetUserByUsername(username); err == nil {
+		username = fmt.Sprintf("%s%04d", username, cid)
+		if usernameValidator.CheckString(username) != nil {
+			username = fmt.Sprintf("user%04d", cid)
+		}
+	}
+
+	// 外部账户使用随机密码, 用户可以通过重置密码设置本地密码
+	user, err := userService.userOperation.NewUser(username, info.Email, cid, randomToken())
+	if err != nil {
+		return nil, &ErrRegisterFail
+	}
+	if err := userService.userOperation.AddUser(user); errors.Is(err, operation.ErrIdentifierTaken) {
+		return nil, &ErrIdentifierTaken
+	} else if err != nil {
+		return nil, &ErrRegisterFail
+	}
+	userService.logger.InfoF("Created user %04d(%s) for external identity %s", user.Cid, user.Username, info.Subject)
+	return user, nil
+}
+
+// exchangeExternalUser 使用授权码向上游换取用户信息并映射到本地用户
+func (userService *UserService) exchangeExternalUser(code string, state *externalLoginState) (*operation.User, *ApiStatus) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	token, err := userService.externalProvider.Exchange(ctx, code, state.codeVerifier)
+	if err != nil {
+		userService.logger.WarnF("External login failed, %v", err)
+		return nil, &ErrExternalLoginUpstream
+	}
+	subject, err := userService.externalProvider.VerifyIdToken(token, state.nonce)
+	if err != nil {
+		userService.logger.WarnF("External login failed, %v", err)
+		return nil, &ErrExternalLoginUpstream
+	}
+	info, err := userService.externalProvider.UserInfo(ctx, token)
+	if err != nil {
+		userService.logger.WarnF("External login failed, %v", err)
+		return nil, &ErrExternalLoginUpstream
+	}
+	if subject != "" && subject != info.Subject {
+		userService.logger.WarnF("External login failed, id token subject %s does not match userinfo subject %s", subject, info.Subject)
+		return nil, &ErrExternalLoginUpstream
+	}
+	return userService.resolveExternalUser(info)
+}
+
+func (userService *UserService) ExternalLogin(req *RequestExternalLogin) *ApiResponse[ResponseUserLogin] {
+	if userService.externalProvider == nil {
+		return NewApiResponse[ResponseUserLogin](&ErrExternalLoginDisabled, Unsatisfied, nil)
+	}
+	if req.State == "" {
+		return NewApiResponse[ResponseUserLogin](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	state := userService.takeExternalState(req.State, req.Binding)
+	if state == nil {
+		return NewApiResponse[ResponseUserLogin](&ErrExternalLoginState, Unsatisfied, nil)
+	}
+
+	var user *operation.User
+	if state.cid != 0 {
+		// 上游登录已完成, 只等待两步验证码
+		value, res := CallDBFuncAndCheckError[operation.User, ResponseUserLogin](func() (*operation.User, error) {
+			return userService.userOperation.GetUserByCid(state.cid)
+		})
+		if res != nil {
+			return res
+		}
+		user = value
+	} else {
+		if req.Code == "" {
+			return NewApiResponse[ResponseUserLogin](&ErrIllegalParam, Unsatisfied, nil)
+		}
+		value, res := userService.exchangeExternalUser(req.Code, state)
+		if res != nil {
+			return NewApiResponse[ResponseUserLogin](res, Unsatisfied, nil)
+		}
+		user = value
+	}
+
+	if userBanned(user) {
+		return NewApiResponse[ResponseUserLogin](&ErrUserBanned, Unsatisfied, nil)
+	}
+
+	// 上游登录不能代替本地的两步验证, 验证码错误时保留state, 用户无需重新跳转到上游
+	if user.TotpEnabled {
+		if res := userService.verifySecondFactor(user, req.TotpCode); res != nil {
+			state.cid = user.Cid
+			userService.keepExternalState(req.State, state)
+			return NewApiResponse[ResponseUserLogin](res, Unsatisfied, nil)
+		}
+	}
+
+	// 仍然遵守管理员必须启用两步验证的策略
+	totpPending := userService.totpEnrolmentRequired(user)
+	accessToken, flushToken, err := userService.createSession(user, req.Ip, req.UserAgent, totpPending)
+	if err != nil {
+		userService.logger.ErrorF("Fail to create session for user %04d, detail: %v", user.Cid, err)
+		return NewApiResponse[ResponseUserLogin](&ErrDatabaseFail, Unsatisfied, nil)
+	}
+	return NewApiResponse(&SuccessLogin, Unsatisfied, &ResponseUserLogin{
+		User:                  user,
+		Token:                 accessToken,
+		FlushToken:            flushToken,
+		TotpEnrolmentRequired: totpPending,
+	})
+}
@@ -0,0 +1,250 @@
+package service
+
+import (
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/half-nothing/simple-fsd/internal/http_server/service/oidc"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/service"
+	"github.com/half-nothing/simple-fsd/internal/utils"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// testLogger 只实现外部登录过程中用到的方法
+type testLogger struct {
+	log.LoggerInterface
+}
+
+func (testLogger) InfoF(string, ...interface{})  {}
+func (testLogger) WarnF(string, ...interface{})  {}
+func (testLogger) ErrorF(string, ...interface{}) {}
+
+type testExternalUserOperation struct {
+	operation.UserOperationInterface
+	users map[int]*operation.User
+}
+
+func (op *testExternalUserOperation) GetUserByCid(cid int) (*operation.User, error) {
+	if user, ok := op.users[cid]; ok {
+		return user, nil
+	}
+	return nil, operation.ErrUserNotFound
+}
+
+func (op *testExternalUserOperation) GetUserByEmail(email string) (*operation.User, error) {
+	for _, user := range op.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return nil, operation.ErrUserNotFound
+}
+
+func (op *testExternalUserOperation) UpdateUserInfo(*operation.User, map[string]interface{}) error {
+	return nil
+}
+
+type testIdentityOperation struct {
+	operation.ExternalIdentityOperationInterface
+	identities map[string]int
+}
+
+func (op *testIdentityOperation) GetExternalIdentity(_ string, subject string) (*operation.ExternalIdentity, error) {
+	if cid, ok := op.identities[subject]; ok {
+		return &operation.ExternalIdentity{Subject: subject, Cid: cid}, nil
+	}
+	return nil, operation.ErrExternalIdentityNotFound
+}
+
+func (op *testIdentityOperation) AddExternalIdentity(_ string, subject string, user *operation.User) (*operation.ExternalIdentity, error) {
+	op.identities[subject] = user.Cid
+	return &operation.ExternalIdentity{Subject: subject, Cid: user.Cid}, nil
+}
+
+type testSessionOperation struct {
+	operation.UserSessionOperationInterface
+}
+
+func (op *testSessionOperation) NewUserSession(user *operation.User, ip string, userAgent string, expiresAt time.Time) *operation.UserSession {
+	return &operation.UserSession{Jti: "jti", Cid: user.Cid, ExpiresAt: expiresAt}
+}
+
+func (op *testSessionOperation) AddUserSession(*operation.UserSession) error {
+	return nil
+}
+
+// externalTestEnv 模拟的上游身份提供者, 每次换取令牌时签发包含最近一次nonce的ID令牌
+type externalTestEnv struct {
+	service   *UserService
+	users     *testExternalUserOperation
+	nonce     string
+	exchanges int
+}
+
+func newExternalTestEnv(t *testing.T, linkByEmail bool) *externalTestEnv {
+	env := &externalTestEnv{users: &testExternalUserOperation{users: make(map[int]*operation.User)}}
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 server.URL,
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+			"userinfo_endpoint":      server.URL + "/userinfo",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		env.exchanges++
+		idToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"iss":   server.URL,
+			"sub":   "upstream-1",
+			"aud":   "client",
+			"exp":   time.Now().Add(time.Minute).Unix(),
+			"nonce": env.nonce,
+		}).SignedString([]byte("upstream"))
+		_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "access", "token_type": "Bearer", "id_token": idToken})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"sub":"upstream-1","email":"user@example.com","email_verified":true}`))
+	})
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	loginConfig := &config.ExternalLoginConfig{
+		Enabled:     true,
+		Issuer:      server.URL,
+		ClientId:    "client",
+		RedirectUrl: "https://fsd.example.com/sso/callback",
+		Scopes:      []string{"openid", "email"},
+		LinkByEmail: linkByEmail,
+	}
+	env.service = &UserService{
+		logger: testLogger{},
+		config: &config.HttpServerConfig{
+			JWT:           &config.JWTConfig{Secret: "secret"},
+			Totp:          &config.TotpConfig{},
+			ExternalLogin: loginConfig,
+		},
+		userOperation:     env.users,
+		sessionOperation:  &testSessionOperation{},
+		identityOperation: &testIdentityOperation{identities: make(map[string]int)},
+		externalProvider:  oidc.NewProvider(loginConfig),
+		externalStates:    make(map[string]*externalLoginState),
+	}
+	return env
+}
+
+// begin 获取上游授权地址, 记录其中的nonce, 返回state与浏览器绑定
+func (env *externalTestEnv) begin(t *testing.T) (string, string) {
+	res := env.service.GetExternalLoginUrl(&RequestExternalLoginUrl{})
+	if res.Data == nil {
+		t.Fatalf("GetExternalLoginUrl failed: %s", res.Code)
+	}
+	target, _ := url.Parse(res.Data.Url)
+	env.nonce = target.Query().Get("nonce")
+	return res.Data.State, res.Data.Binding
+}
+
+func (env *externalTestEnv) login(state string, binding string, totpCode string) *ApiResponse[ResponseUserLogin] {
+	return env.service.ExternalLogin(&RequestExternalLogin{Code: "code", State: state, Binding: binding, TotpCode: totpCode})
+}
+
+func TestExternalLoginStateBinding(t *testing.T) {
+	env := newExternalTestEnv(t, true)
+	env.users.users[1000] = &operation.User{Cid: 1000, Email: "user@example.com"}
+
+	state, binding := env.begin(t)
+	if res := env.login(state, "other-browser", ""); res.Code != ErrExternalLoginState.StatusName {
+		t.Errorf("expected state rejected for another browser, got %s", res.Code)
+	}
+	// state只能使用一次, 绑定校验失败后同样失效
+	if res := env.login(state, binding, ""); res.Code != ErrExternalLoginState.StatusName {
+		t.Errorf("expected state consumed, got %s", res.Code)
+	}
+
+	state, binding = env.begin(t)
+	env.nonce = "other-nonce"
+	if res := env.login(state, binding, ""); res.Code != ErrExternalLoginUpstream.StatusName {
+		t.Errorf("expected mismatched nonce rejected, got %s", res.Code)
+	}
+
+	state, binding = env.begin(t)
+	if res := env.login(state, binding, ""); res.Code != SuccessLogin.StatusName {
+		t.Errorf("expected success, got %s", res.Code)
+	}
+}
+
+func TestExternalLoginLinkByEmailRefused(t *testing.T) {
+	env := newExternalTestEnv(t, true)
+	env.users.users[1000] = &operation.User{Cid: 1000, Email: "user@example.com", TotpEnabled: true}
+	state, binding := env.begin(t)
+	if res := env.login(state, binding, ""); res.Code != ErrExternalLinkRefused.StatusName {
+		t.Errorf("expected link refused for totp account, got %s", res.Code)
+	}
+
+	permission := operation.NewPermissionSet(0)
+	permission.Grant(operation.UserEditRating)
+	legacy, _ := permission.Legacy()
+	env.users.users[1000] = &operation.User{Cid: 1000, Email: "user@example.com", Permission: legacy}
+	state, binding = env.begin(t)
+	if res := env.login(state, binding, ""); res.Code != ErrExternalLinkRefused.StatusName {
+		t.Errorf("expected link refused for staff account, got %s", res.Code)
+	}
+}
+
+func TestExternalLoginRequiresTotp(t *testing.T) {
+	env := newExternalTestEnv(t, false)
+	secret := utils.GenerateTotpSecret()
+	env.users.users[1000] = &operation.User{Cid: 1000, Email: "user@example.com", TotpEnabled: true, TotpSecret: secret}
+	env.service.identityOperation.(*testIdentityOperation).identities["upstream-1"] = 1000
+
+	state, binding := env.begin(t)
+	if res := env.login(state, binding, ""); res.Code != ErrTotpRequired.StatusName {
+		t.Fatalf("expected totp required, got %s", res.Code)
+	}
+	if res := env.login(state, binding, "000000"); res.Code != ErrTotpCodeInvalid.StatusName {
+		t.Fatalf("expected invalid totp code, got %s", res.Code)
+	}
+	code, _ := utils.TotpCode(secret, utils.TotpCounter(time.Now()))
+	if res := env.login(state, binding, code); res.Code != SuccessLogin.StatusName {
+		t.Fatalf("expected success, got %s", res.Code)
+	}
+	// 等待两步验证码期间不会重复向上游换取令牌
+	if env.exchanges != 1 {
+		t.Errorf("expected exactly one upstream exchange, got %d", env.exchanges)
+	}
+}
+
+func TestExternalLoginTotpAttempts(t *testing.T) {
+	env := newExternalTestEnv(t, false)
+	env.users.users[1000] = &operation.User{Cid: 1000, Email: "user@example.com", TotpEnabled: true, TotpSecret: utils.GenerateTotpSecret()}
+	env.service.identityOperation.(*testIdentityOperation).identities["upstream-1"] = 1000
+
+	state, binding := env.begin(t)
+	for i := 0; i < maxExternalTotpAttempts; i++ {
+		if res := env.login(state, binding, ""); res.Code != ErrTotpRequired.StatusName {
+			t.Fatalf("attempt %d: expected totp required, got %s", i, res.Code)
+		}
+	}
+	if res := env.login(state, binding, ""); res.Code != ErrExternalLoginState.StatusName {
+		t.Errorf("expected state dropped after too many attempts, got %s", res.Code)
+	}
+}
+
+func TestExternalLoginBanned(t *testing.T) {
+	env := newExternalTestEnv(t, false)
+	bannedUntil := time.Now().Add(time.Hour)
+	env.users.users[1000] = &operation.User{Cid: 1000, Email: "user@example.com", BannedUntil: &bannedUntil}
+	env.service.identityOperation.(*testIdentityOperation).identities["upstream-1"] = 1000
+
+	state, binding := env.begin(t)
+	if res := env.login(state, binding, ""); res.Code != ErrUserBanned.StatusName {
+		t.Errorf("expected banned user rejected, got %s", res.Code)
+	}
+}
@@ -0,0 +1,214 @@
+// Package service
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces/service"
+	"github.com/half-nothing/simple-fsd/internal/utils"
+	"strings"
+	"time"
+)
+
+// recoveryCodeCount 每次生成的恢复码数量
+const recoveryCodeCount = 10
+
+var (
+	ErrTotpRequired         = ApiStatus{StatusName: "TOTP_REQUIRED", Description: "该账户已启用两步验证, 请输入验证码", HttpCode: Unauthorized}
+	ErrTotpCodeInvalid      = ApiStatus{StatusName: "TOTP_CODE_INVALID", Description: "两步验证码错误", HttpCode: BadRequest}
+	ErrTotpAlreadyEnabled   = ApiStatus{StatusName: "TOTP_ALREADY_ENABLED", Description: "已启用两步验证", HttpCode: BadRequest}
+	ErrTotpNotEnabled       = ApiStatus{StatusName: "TOTP_NOT_ENABLED", Description: "未启用两步验证", HttpCode: BadRequest}
+	ErrTotpNotSetup         = ApiStatus{StatusName: "TOTP_NOT_SETUP", Description: "请先获取两步验证密钥", HttpCode: BadRequest}
+	ErrTotpRequiredByPolicy = ApiStatus{StatusName: "TOTP_REQUIRED_BY_POLICY", Description: "拥有管理权限的用户必须启用两步验证", HttpCode: PermissionDenied}
+)
+
+// hashRecoveryCode 计算恢复码的摘要, 忽略大小写与分隔符
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateRecoveryCodes 生成一组恢复码, 返回明文与用于存储的摘要
+func generateRecoveryCodes() (codes []string, hashed string) {
+	codes = make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	buf := make([]byte, 5)
+	for i := 0; i < recoveryCodeCount; i++ {
+		_, _ = rand.Read(buf)
+		code := hex.EncodeToString(buf)
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, strings.Join(hashes, ",")
+}
+
+// verifySecondFactor 校验两步验证码或恢复码, 恢复码使用后立即失效
+func (userService *UserService) verifySecondFactor(user *operation.User, code string) *ApiStatus {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return &ErrTotpRequired
+	}
+	if counter, ok := utils.VerifyTotp(user.TotpSecret, code, time.Now(), 1, user.TotpLastCounter); ok {
+		if err := userService.userOperation.UpdateUserInfo(user, map[string]interface{}{"totp_last_counter": counter}); err != nil {
+			return &ErrDatabaseFail
+		}
+		return nil
+	}
+	if user.RecoveryCodes == "" {
+		return &ErrTotpCodeInvalid
+	}
+	hashed := hashRecoveryCode(code)
+	hashes := strings.Split(user.RecoveryCodes, ",")
+	for i, hash := range hashes {
+		if hash != hashed {
+			continue
+		}
+		remain := strings.Join(append(hashes[:i:i], hashes[i+1:]...), ",")
+		if err := userService.userOperation.UpdateUserInfo(user, map[string]interface{}{"recovery_codes": remain}); err != nil {
+			return &ErrDatabaseFail
+		}
+		userService.logger.InfoF("User %04d logged in with a recovery code, %d codes left", user.Cid, len(hashes)-1)
+		return nil
+	}
+	return &ErrTotpCodeInvalid
+}
+
+// totpEnrolmentRequired 用户是否因策略要求必须启用两步验证
+func (userService *UserService) totpEnrolmentRequired(user *operation.User) bool {
+	permission := user.EffectivePermission()
+	return userService.config.Totp.RequireForStaff && !user.TotpEnabled && permission.IsStaff()
+}
+
+func (userService *UserService) saveTotpAuditLog(eventType operation.EventType, user *operation.User, ip, userAgent string) {
+	go func() {
+		auditLog := userService.auditLogOperation.NewAuditLog(eventType, user.Cid, fmt.Sprintf("%04d", user.Cid), ip, userAgent, nil)
+		if err := userService.auditLogOperation.SaveAuditLog(auditLog); err != nil {
+			userService.logger.ErrorF("Fail to create audit log for %s, detail: %v", eventType, err)
+		}
+	}()
+}
+
+var SuccessSetupTotp = ApiStatus{StatusName: "SETUP_TOTP", Description: "已生成两步验证密钥, 请使用验证码确认启用", HttpCode: Ok}
+
+func (userService *UserService) SetupTotp(req *RequestSetupTotp) *ApiResponse[ResponseSetupTotp] {
+	user, res := CallDBFuncAndCheckError[operation.User, ResponseSetupTotp](func() (*operation.User, error) {
+		return userService.userOperation.GetUserByUid(req.Uid)
+	})
+	if res != nil {
+		return res
+	}
+	if user.TotpEnabled {
+		return NewApiResponse[ResponseSetupTotp](&ErrTotpAlreadyEnabled, Unsatisfied, nil)
+	}
+	secret := utils.GenerateTotpSecret()
+	if _, res := CallDBFuncAndCheckError[interface{}, ResponseSetupTotp](func() (*interface{}, error) {
+		return nil, userService.userOperation.UpdateUserInfo(user, map[string]interface{}{"totp_secret": secret, "totp_last_counter": 0})
+	}); res != nil {
+		return res
+	}
+	return NewApiResponse(&SuccessSetupTotp, Unsatisfied, &ResponseSetupTotp{
+		Secret:          secret,
+		ProvisioningUri: utils.TotpProvisioningUri(userService.config.Totp.Issuer, user.Username, secret),
+	})
+}
+
+var SuccessConfirmTotp = ApiStatus{StatusName: "CONFIRM_TOTP", Description: "两步验证已启用, 请妥善保存恢复码", HttpCode: Ok}
+
+func (userService *UserService) ConfirmTotp(req *RequestConfirmTotp) *ApiResponse[ResponseConfirmTotp] {
+	if req.Code == "" {
+		return NewApiResponse[ResponseConfirmTotp](&ErrIllegalParam, Unsatisfied, nil)
+	}
+	user, res := CallDBFuncAndCheckError[operation.User, ResponseConfirmTotp](func() (*operation.User, error) {
+		return userService.userOperation.GetUserByUid(req.Uid)
+	})
+	if res != nil {
+		return res
+	}
+	if user.TotpEnabled {
+		return NewApiResponse[ResponseConfirmTotp](&ErrTotpAlreadyEnabled, Unsatisfied, nil)
+	}
+	if user.TotpSecret == "" {
+		return NewApiResponse[ResponseConfirmTotp](&ErrTotpNotSetup, Unsatisfied, nil)
+	}
+	counter, ok := utils.VerifyTotp(user.TotpSecret, strings.TrimSpace(req.Code), time.Now(), 1, user.TotpLastCounter)
+	if !ok {
+		return NewApiResponse[ResponseConfirmTotp](&ErrTotpCodeInvalid, Unsatisfied, nil)
+	}
+	codes, hashed := generateRecoveryCodes()
+	if _, res := CallDBFuncAndCheckError[interface{}, ResponseConfirmTotp](func() (*interface{}, error) {
+		return nil, userService.userOperation.UpdateUserInfo(user, map[string]interface{}{
+			"totp_enabled":      true,
+			"totp_last_counter": counter,
+			"recovery_codes":    hashed,
+		})
+	}); res != nil {
+		return res
+	}
+	if err := userService.sessionOperation.ClearUserSessionsTotpPending(user.Cid); err != nil {
+		userService.logger.ErrorF("Fail to clear totp pending sessions of user %04d, detail: %v", user.Cid, err)
+	}
+	userService.saveTotpAuditLog(operation.UserTotpEnabled, user, req.Ip, req.UserAgent)
+	return NewApiResponse(&SuccessConfirmTotp, Unsatisfied, &ResponseConfirmTotp{RecoveryCodes: codes})
+}
+
+var SuccessDisableTotp = ApiStatus{StatusName: "DISABLE_TOTP", Description: "两步验证已关闭", HttpCode: Ok}
+
+func (userService *UserService) DisableTotp(req *RequestDisableTotp) *ApiResponse[ResponseDisableTotp] {
+	user, res := CallDBFuncAndCheckError[operation.User, ResponseDisableTotp](func() (*operation.User, error) {
+		return userService.userOperation.GetUserByUid(req.Uid)
+	})
+	if res != nil {
+		return res
+	}
+	if !user.TotpEnabled {
+		return NewApiResponse[ResponseDisableTotp](&ErrTotpNotEnabled, Unsatisfied, nil)
+	}
+	permission := user.EffectivePermission()
+	if userService.config.Totp.RequireForStaff && permission.IsStaff() {
+		return NewApiResponse[ResponseDisableTotp](&ErrTotpRequiredByPolicy, Unsatisfied, nil)
+	}
+	if res := userService.verifySecondFactor(user, req.Code); res != nil {
+		return NewApiResponse[ResponseDisableTotp](res, Unsatisfied, nil)
+	}
+	if _, res := CallDBFuncAndCheckError[interface{}, ResponseDisableTotp](func() (*interface{}, error) {
+		return nil, userService.userOperation.UpdateUserInfo(user, map[string]interface{}{
+			"totp_enabled":      false,
+			"totp_secret":       "",
+			"totp_last_counter": 0,
+			"recovery_codes":    "",
+		})
+	}); res != nil {
+		return res
+	}
+	userService.saveTotpAuditLog(operation.UserTotpDisabled, user, req.Ip, req.UserAgent)
+	data := ResponseDisableTotp(true)
+	return NewApiResponse(&SuccessDisableTotp, Unsatisfied, &data)
+}
+
+var SuccessRegenerateRecoveryCodes = ApiStatus{StatusName: "REGENERATE_RECOVERY_CODES", Description: "已重新生成恢复码, 旧的恢复码全部失效", HttpCode: Ok}
+
+func (userService *UserService) RegenerateRecoveryCodes(req *RequestRegenerateRecoveryCodes) *ApiResponse[ResponseRegenerateRecoveryCodes] {
+	user, res := CallDBFuncAndCheckError[operation.User, ResponseRegenerateRecoveryCodes](func() (*operation.User, error) {
+		return userService.userOperation.GetUserByUid(req.Uid)
+	})
+	if res != nil {
+		return res
+	}
+	if !user.TotpEnabled {
+		return NewApiResponse[ResponseRegenerateRecoveryCodes](&ErrTotpNotEnabled, Unsatisfied, nil)
+	}
+	if res := userService.verifySecondFactor(user, req.Code); res != nil {
+		return NewApiResponse[ResponseRegenerateRecoveryCodes](res, Unsatisfied, nil)
+	}
+	codes, hashed := generateRecoveryCodes()
+	if _, res := CallDBFuncAndCheckError[interface{}, ResponseRegenerateRecoveryCodes](func() (*interface{}, error) {
+		return nil, userService.userOperation.UpdateUserInfo(user, map[string]interface{}{"recovery_codes": hashed})
+	}); res != nil {
+		return res
+	}
+	return NewApiResponse(&SuccessRegenerateRecoveryCodes, Unsatisfied, &ResponseRegenerateRecoveryCodes{RecoveryCodes: codes})
+}
@@ -8,4 +8,8 @@ import (
 type ConfigManagerInterface interface {
 	Config() *Config
 	SaveConfig() error
+	// Reload 重新读取配置文件并替换可热重载的配置项
+	Reload() (*ReloadResult, error)
+	// OnReload 注册配置重载成功后的回调
+	OnReload(listener func(config *Config))
 }
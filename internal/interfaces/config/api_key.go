@@ -0,0 +1,50 @@
+// Package config
+package config
+
+import (
+	"errors"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	"time"
+)
+
+type ApiKeyConfig struct {
+	Enabled           bool          `json:"enabled"`
+	RateLimit         int           `json:"rate_limit"`        // 未单独设置限流的密钥在每个窗口内允许的请求数
+	MaxRateLimit      int           `json:"max_rate_limit"`    // 创建密钥时允许设置的最大限流值
+	RateLimitWindow   string        `json:"rate_limit_window"` // 限流窗口
+	RateLimitDuration time.Duration `json:"-"`
+	MaxKeysPerUser    int           `json:"max_keys_per_user"` // 每个用户最多持有的有效密钥数量
+}
+
+func defaultApiKeyConfig() *ApiKeyConfig {
+	return &ApiKeyConfig{
+		Enabled:         false,
+		RateLimit:       60,
+		MaxRateLimit:    600,
+		RateLimitWindow: "1m",
+		MaxKeysPerUser:  5,
+	}
+}
+
+func (config *ApiKeyConfig) checkValid(_ log.LoggerInterface) *ValidResult {
+	if !config.Enabled {
+		return ValidPass()
+	}
+	if duration, err := time.ParseDuration(config.RateLimitWindow); err != nil {
+		return ValidFailWith(errors.New("invalid json field http_server.api_key.rate_limit_window"), err)
+	} else if duration <= 0 {
+		return ValidFail(errors.New("invalid json field http_server.api_key.rate_limit_window, value must larger than 0"))
+	} else {
+		config.RateLimitDuration = duration
+	}
+	if config.RateLimit <= 0 {
+		return ValidFail(errors.New("invalid json field http_server.api_key.rate_limit, value must larger than 0"))
+	}
+	if config.MaxRateLimit < config.RateLimit {
+		return ValidFail(errors.New("invalid json field http_server.api_key.max_rate_limit, value must not less than rate_limit"))
+	}
+	if config.MaxKeysPerUser <= 0 {
+		return ValidFail(errors.New("invalid json field http_server.api_key.max_keys_per_user, value must larger than 0"))
+	}
+	return ValidPass()
+}
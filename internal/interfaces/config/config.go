@@ -2,9 +2,11 @@
 package config
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	"sync"
 )
 
 type Config struct {
@@ -12,6 +14,18 @@ type Config struct {
 	Server        *ServerConfig   `json:"server"`
 	Database      *DatabaseConfig `json:"database"`
 	Rating        map[string]int  `json:"rating"`
+	// mu 保护热重载时直接替换的导出字段, 运行时请通过原子访问器读取可热重载的配置项
+	mu sync.RWMutex
+}
+
+// plainConfig 与 Config 结构相同但不带 MarshalJSON, 用于避免递归
+type plainConfig Config
+
+// MarshalJSON 在读锁下序列化配置, 避免与热重载的写入并发
+func (c *Config) MarshalJSON() ([]byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return json.Marshal((*plainConfig)(c))
 }
 
 func DefaultConfig() *Config {
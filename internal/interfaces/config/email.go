@@ -6,6 +6,7 @@ import (
 	"github.com/half-nothing/simple-fsd/internal/interfaces/global"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
 	"gopkg.in/gomail.v2"
+	"sync/atomic"
 	"time"
 )
 
@@ -20,6 +21,7 @@ type EmailConfig struct {
 	SendInterval          string               `json:"send_interval"`
 	SendDuration          time.Duration        `json:"-"`
 	Template              *EmailTemplateConfig `json:"template"`
+	templates             atomic.Pointer[EmailTemplateConfig]
 }
 
 func defaultEmailConfig() *EmailConfig {
@@ -50,6 +52,7 @@ func (config *EmailConfig) checkValid(logger log.LoggerInterface) *ValidResult {
 	if result := config.Template.checkValid(logger); result.IsFail() {
 		return result
 	}
+	config.templates.Store(config.Template)
 
 	if *global.SkipEmailVerification {
 		config.EmailServer = nil
@@ -64,3 +67,11 @@ func (config *EmailConfig) checkValid(logger log.LoggerInterface) *ValidResult {
 
 	return ValidPass()
 }
+
+// Templates 返回当前生效的邮件模板配置, 支持热重载
+func (config *EmailConfig) Templates() *EmailTemplateConfig {
+	if templates := config.templates.Load(); templates != nil {
+		return templates
+	}
+	return config.Template
+}
@@ -11,6 +11,8 @@ import (
 type EmailTemplateConfig struct {
 	EmailVerifyTemplateFile      string             `json:"email_verify_template_file"`
 	EmailVerifyTemplate          *template.Template `json:"-"`
+	PasswordResetTemplateFile    string             `json:"password_reset_template_file"`
+	PasswordResetTemplate        *template.Template `json:"-"`
 	ATCRatingChangeTemplateFile  string             `json:"atc_rating_change_template_file"`
 	ATCRatingChangeTemplate      *template.Template `json:"-"`
 	EnableRatingChangeEmail      bool               `json:"enable_rating_change_email"`
@@ -20,22 +22,38 @@ type EmailTemplateConfig struct {
 	KickedFromServerTemplateFile string             `json:"kicked_from_server_template_file"`
 	KickedFromServerTemplate     *template.Template `json:"-"`
 	EnableKickedFromServerEmail  bool               `json:"enable_kicked_from_server_email"`
+	HelpRequestTemplateFile      string             `json:"help_request_template_file"`
+	HelpRequestTemplate          *template.Template `json:"-"`
+	EnableHelpRequestEmail       bool               `json:"enable_help_request_email"`
+	TicketUpdateTemplateFile     string             `json:"ticket_update_template_file"`
+	TicketUpdateTemplate         *template.Template `json:"-"`
+	EnableTicketUpdateEmail      bool               `json:"enable_ticket_update_email"`
+	InactivityTemplateFile       string             `json:"inactivity_template_file"`
+	InactivityTemplate           *template.Template `json:"-"`
+	EnableInactivityEmail        bool               `json:"enable_inactivity_email"`
 }
 
 func defaultEmailTemplateConfig() *EmailTemplateConfig {
 	return &EmailTemplateConfig{
 		EmailVerifyTemplateFile:      "template/email_verify.template",
+		PasswordResetTemplateFile:    "template/password_reset.template",
 		ATCRatingChangeTemplateFile:  "template/atc_rating_change.template",
 		EnableRatingChangeEmail:      true,
 		PermissionChangeTemplateFile: "template/permission_change.template",
 		EnablePermissionChangeEmail:  true,
 		KickedFromServerTemplateFile: "template/kicked_from_server.template",
 		EnableKickedFromServerEmail:  true,
+		HelpRequestTemplateFile:      "template/help_request.template",
+		EnableHelpRequestEmail:       true,
+		TicketUpdateTemplateFile:     "template/ticket_update.template",
+		EnableTicketUpdateEmail:      true,
+		InactivityTemplateFile:       "template/inactivity.template",
+		EnableInactivityEmail:        true,
 	}
 }
 
 func (config *EmailTemplateConfig) checkValid(logger log.LoggerInterface) *ValidResult {
-	if bytes, err := cachedContent(logger, config.EmailVerifyTemplateFile, global.EmailVerifyTemplateFileUrl); err != nil {
+	if bytes, err := cachedContent(logger, config.EmailVerifyTemplateFile, global.EmailVerifyTemplateFileUrl, global.EmailVerifyTemplateBundledFile); err != nil {
 		return ValidFailWith(errors.New("fail to load email_verify_template_file"), err)
 	} else if parse, err := template.New("email_verify").Parse(string(bytes)); err != nil {
 		return ValidFailWith(errors.New("fail to parse email_verify_template"), err)
@@ -43,8 +61,16 @@ func (config *EmailTemplateConfig) checkValid(logger log.LoggerInterface) *Valid
 		config.EmailVerifyTemplate = parse
 	}
 
+	if bytes, err := cachedContent(logger, config.PasswordResetTemplateFile, global.PasswordResetTemplateFileUrl, global.PasswordResetTemplateBundledFile); err != nil {
+		return ValidFailWith(errors.New("fail to load password_reset_template_file"), err)
+	} else if parse, err := template.New("password_reset").Parse(string(bytes)); err != nil {
+		return ValidFailWith(errors.New("fail to parse password_reset_template"), err)
+	} else {
+		config.PasswordResetTemplate = parse
+	}
+
 	if config.EnableRatingChangeEmail {
-		if bytes, err := cachedContent(logger, config.ATCRatingChangeTemplateFile, global.ATCRatingChangeTemplateFileUrl); err != nil {
+		if bytes, err := cachedContent(logger, config.ATCRatingChangeTemplateFile, global.ATCRatingChangeTemplateFileUrl, global.ATCRatingChangeTemplateBundledFile); err != nil {
 			return ValidFailWith(errors.New("fail to load atc_rating_change_template_file"), err)
 		} else if parse, err := template.New("atc_rating_change").Parse(string(bytes)); err != nil {
 			return ValidFailWith(errors.New("fail to parse atc_rating_change_template"), err)
@@ -54,7 +80,7 @@ func (config *EmailTemplateConfig) checkValid(logger log.LoggerInterface) *Valid
 	}
 
 	if config.EnablePermissionChangeEmail {
-		if bytes, err := cachedContent(logger, config.PermissionChangeTemplateFile, global.PermissionChangeTemplateFileUrl); err != nil {
+		if bytes, err := cachedContent(logger, config.PermissionChangeTemplateFile, global.PermissionChangeTemplateFileUrl, global.PermissionChangeTemplateBundledFile); err != nil {
 			return ValidFailWith(errors.New("fail to load permission_change_template_file"), err)
 		} else if parse, err := template.New("permission_change").Parse(string(bytes)); err != nil {
 			return ValidFailWith(errors.New("fail to parse permission_change_template"), err)
@@ -64,7 +90,7 @@ func (config *EmailTemplateConfig) checkValid(logger log.LoggerInterface) *Valid
 	}
 
 	if config.EnableKickedFromServerEmail {
-		if bytes, err := cachedContent(logger, config.KickedFromServerTemplateFile, global.KickedFromServerTemplateFileUrl); err != nil {
+		if bytes, err := cachedContent(logger, config.KickedFromServerTemplateFile, global.KickedFromServerTemplateFileUrl, global.KickedFromServerTemplateBundledFile); err != nil {
 			return ValidFailWith(errors.New("fail to load permission_change_template_file"), err)
 		} else if parse, err := template.New("kicked_from_server").Parse(string(bytes)); err != nil {
 			return ValidFailWith(errors.New("fail to parse permission_change_template"), err)
@@ -73,5 +99,35 @@ func (config *EmailTemplateConfig) checkValid(logger log.LoggerInterface) *Valid
 		}
 	}
 
+	if config.EnableHelpRequestEmail {
+		if bytes, err := cachedContent(logger, config.HelpRequestTemplateFile, global.HelpRequestTemplateFileUrl, global.HelpRequestTemplateBundledFile); err != nil {
+			return ValidFailWith(errors.New("fail to load help_request_template_file"), err)
+		} else if parse, err := template.New("help_request").Parse(string(bytes)); err != nil {
+			return ValidFailWith(errors.New("fail to parse help_request_template"), err)
+		} else {
+			config.HelpRequestTemplate = parse
+		}
+	}
+
+	if config.EnableTicketUpdateEmail {
+		if bytes, err := cachedContent(logger, config.TicketUpdateTemplateFile, global.TicketUpdateTemplateFileUrl, global.TicketUpdateTemplateBundledFile); err != nil {
+			return ValidFailWith(errors.New("fail to load ticket_update_template_file"), err)
+		} else if parse, err := template.New("ticket_update").Parse(string(bytes)); err != nil {
+			return ValidFailWith(errors.New("fail to parse ticket_update_template"), err)
+		} else {
+			config.TicketUpdateTemplate = parse
+		}
+	}
+
+	if config.EnableInactivityEmail {
+		if bytes, err := cachedContent(logger, config.InactivityTemplateFile, global.InactivityTemplateFileUrl, global.InactivityTemplateBundledFile); err != nil {
+			return ValidFailWith(errors.New("fail to load inactivity_template_file"), err)
+		} else if parse, err := template.New("inactivity").Parse(string(bytes)); err != nil {
+			return ValidFailWith(errors.New("fail to parse inactivity_template"), err)
+		} else {
+			config.InactivityTemplate = parse
+		}
+	}
+
 	return ValidPass()
 }
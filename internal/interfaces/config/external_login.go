@@ -0,0 +1,59 @@
+// Package config
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	"net/url"
+	"strings"
+)
+
+type ExternalLoginConfig struct {
+	Enabled      bool     `json:"enabled"`
+	Name         string   `json:"name"`          // 登录按钮上显示的名称
+	Issuer       string   `json:"issuer"`        // 上游OIDC身份提供者地址, 会从issuer/.well-known/openid-configuration获取端点
+	ClientId     string   `json:"client_id"`     // 在上游注册的客户端Id
+	ClientSecret string   `json:"client_secret"` // 在上游注册的客户端密钥
+	RedirectUrl  string   `json:"redirect_url"`  // 前端回调页面地址, 需要在上游登记
+	Scopes       []string `json:"scopes"`
+	CidClaim     string   `json:"cid_claim"`     // 从该字段读取用户cid, 为空则自动分配
+	LinkByEmail  bool     `json:"link_by_email"` // 是否通过已验证的邮箱关联已有账户
+	AutoCreate   bool     `json:"auto_create"`   // 是否自动创建账户
+}
+
+func defaultExternalLoginConfig() *ExternalLoginConfig {
+	return &ExternalLoginConfig{
+		Enabled:      false,
+		Name:         "SSO",
+		Issuer:       "",
+		ClientId:     "",
+		ClientSecret: "",
+		RedirectUrl:  "",
+		Scopes:       []string{"openid", "profile", "email"},
+		CidClaim:     "",
+		LinkByEmail:  false,
+		AutoCreate:   false,
+	}
+}
+
+func (config *ExternalLoginConfig) checkValid(_ log.LoggerInterface) *ValidResult {
+	if !config.Enabled {
+		return ValidPass()
+	}
+	if config.Issuer == "" || config.ClientId == "" || config.RedirectUrl == "" {
+		return ValidFail(errors.New("http_server.external_login requires issuer, client_id and redirect_url"))
+	}
+	for field, value := range map[string]string{"issuer": config.Issuer, "redirect_url": config.RedirectUrl} {
+		if target, err := url.Parse(value); err != nil {
+			return ValidFail(fmt.Errorf("invalid json field http_server.external_login.%s, %v", field, err))
+		} else if target.Scheme != "http" && target.Scheme != "https" {
+			return ValidFail(fmt.Errorf("invalid json field http_server.external_login.%s, only support http and https", field))
+		}
+	}
+	config.Issuer = strings.TrimRight(config.Issuer, "/")
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "profile", "email"}
+	}
+	return ValidPass()
+}
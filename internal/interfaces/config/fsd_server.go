@@ -7,26 +7,63 @@ import (
 	"fmt"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/global"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	"github.com/half-nothing/simple-fsd/internal/utils"
+	"net/url"
+	"os"
 	"runtime"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	AtcBookingCheckDisable = iota // 不检查席位预约
+	AtcBookingCheckWarn           // 席位已被他人预约时发送警告
+	AtcBookingCheckRefuse         // 席位已被他人预约时拒绝登录
+)
+
+const (
+	SendQueueDropOldest = iota // 发送队列满时丢弃最旧的消息
+	SendQueueDisconnect        // 发送队列满时断开客户端
+)
+
 type FSDServerConfig struct {
-	FSDName              string                  `json:"fsd_name"` // FSD名称
-	Host                 string                  `json:"host"`
-	Port                 uint                    `json:"port"`
-	Address              string                  `json:"-"`
-	AirportDataFile      string                  `json:"airport_data_file"`
-	AirportData          map[string]*AirportData `json:"-"`
-	PosUpdatePoints      int                     `json:"pos_update_points"`
-	HeartbeatInterval    string                  `json:"heartbeat_interval"`
-	HeartbeatDuration    time.Duration           `json:"-"`
-	SessionCleanTime     string                  `json:"session_clean_time"`    // 会话保留时间
-	SessionCleanDuration time.Duration           `json:"-"`                     // 内部使用字段
-	MaxWorkers           int                     `json:"max_workers"`           // 并发线程数
-	MaxBroadcastWorkers  int                     `json:"max_broadcast_workers"` // 广播并发线程数
-	FirstMotdLine        string                  `json:"first_motd_line"`
-	Motd                 []string                `json:"motd"`
+	FSDName                string                  `json:"fsd_name"` // FSD名称
+	Host                   string                  `json:"host"`
+	Port                   uint                    `json:"port"`
+	Address                string                  `json:"-"`
+	AirportDataFile        string                  `json:"airport_data_file"`
+	AirportData            map[string]*AirportData `json:"-"`
+	SectorDataFile         string                  `json:"sector_data_file"` // 扇区数据文件, GeoJSON格式, 为空则不启用
+	SectorData             []*SectorData           `json:"-"`
+	PosUpdatePoints        int                     `json:"pos_update_points"`
+	HeartbeatInterval      string                  `json:"heartbeat_interval"`
+	HeartbeatDuration      time.Duration           `json:"-"`
+	SessionCleanTime       string                  `json:"session_clean_time"`    // 会话保留时间
+	SessionCleanDuration   time.Duration           `json:"-"`                     // 内部使用字段
+	SessionSnapshotFile    string                  `json:"session_snapshot_file"` // 会话快照文件, 为空则不保存会话
+	SessionRestoreTime     string                  `json:"session_restore_time"`  // 重启后恢复的会话保留时间
+	SessionRestoreDuration time.Duration           `json:"-"`
+	MaxWorkers             int                     `json:"max_workers"`           // 并发线程数
+	MaxBroadcastWorkers    int                     `json:"max_broadcast_workers"` // 并发断开连接的线程数
+	AtcBookingCheck        int                     `json:"atc_booking_check"`     // 席位预约检查, 0: 不检查, 1: 警告, 2: 拒绝登录
+	SendQueueSize          int                     `json:"send_queue_size"`       // 每个客户端发送队列长度
+	SendQueuePolicy        int                     `json:"send_queue_policy"`     // 发送队列满时的策略, 0: 丢弃最旧消息, 1: 断开连接
+	WriteTimeout           string                  `json:"write_timeout"`         // 单次写入超时时间
+	WriteTimeoutDuration   time.Duration           `json:"-"`
+	DrainTime              string                  `json:"drain_time"` // 排空模式默认倒计时
+	DrainDuration          time.Duration           `json:"-"`
+	ShutdownTime           string                  `json:"shutdown_time"` // 关闭时保存飞行计划与连线记录并断开客户端的最长时间
+	ShutdownDuration       time.Duration           `json:"-"`
+	HelpRequestWebhook     string                  `json:"help_request_webhook"`  // 没有管理员在线时接收求助请求的Webhook地址, 为空则不启用
+	HelpRequestEmails      []string                `json:"help_request_emails"`   // 没有管理员在线时接收求助请求的邮箱
+	HelpRequestInterval    string                  `json:"help_request_interval"` // 同一用户两次求助请求的最小间隔
+	HelpRequestDuration    time.Duration           `json:"-"`
+	EndorsedPositions      []string                `json:"endorsed_positions"` // 需要席位授权才能登录的管制席位呼号模式
+	FirstMotdLine          string                  `json:"first_motd_line"`
+	Motd                   []string                `json:"motd"`
+	motdLines              atomic.Pointer[[]string]
+	endorsedPositions      atomic.Pointer[[]string]
 }
 
 func defaultFSDServerConfig() *FSDServerConfig {
@@ -35,11 +72,24 @@ func defaultFSDServerConfig() *FSDServerConfig {
 		Host:                "0.0.0.0",
 		Port:                6809,
 		AirportDataFile:     "data/airport.json",
+		SectorDataFile:      "",
 		PosUpdatePoints:     1,
 		HeartbeatInterval:   "60s",
 		SessionCleanTime:    "40s",
+		SessionSnapshotFile: "data/sessions.json",
+		SessionRestoreTime:  "2m",
 		MaxWorkers:          128,
 		MaxBroadcastWorkers: 128,
+		AtcBookingCheck:     AtcBookingCheckWarn,
+		SendQueueSize:       512,
+		SendQueuePolicy:     SendQueueDropOldest,
+		WriteTimeout:        "10s",
+		DrainTime:           "5m",
+		ShutdownTime:        "10s",
+		HelpRequestWebhook:  "",
+		HelpRequestEmails:   make([]string, 0),
+		HelpRequestInterval: "1m",
+		EndorsedPositions:   make([]string, 0),
 		FirstMotdLine:       "Welcome to use %[1]s v%[2]s",
 		Motd:                make([]string, 0),
 	}
@@ -59,6 +109,7 @@ func (config *FSDServerConfig) checkValid(logger log.LoggerInterface) *ValidResu
 	data = append(data, config.FirstMotdLine)
 	data = append(data, config.Motd...)
 	config.Motd = data
+	config.motdLines.Store(&data)
 
 	config.Address = fmt.Sprintf("%s:%d", config.Host, config.Port)
 
@@ -66,7 +117,68 @@ func (config *FSDServerConfig) checkValid(logger log.LoggerInterface) *ValidResu
 		return ValidFail(errors.New("invalid json field pos_update_points, pos_update_points must larger than 0"))
 	}
 
-	if bytes, err := cachedContent(logger, config.AirportDataFile, global.AirportDataFileUrl); err != nil {
+	if config.AtcBookingCheck < AtcBookingCheckDisable || config.AtcBookingCheck > AtcBookingCheckRefuse {
+		return ValidFail(fmt.Errorf("invalid json field atc_booking_check %d, only support 0, 1, 2", config.AtcBookingCheck))
+	}
+
+	if config.SendQueueSize <= 0 {
+		return ValidFail(errors.New("invalid json field send_queue_size, send_queue_size must larger than 0"))
+	}
+
+	if config.SendQueuePolicy < SendQueueDropOldest || config.SendQueuePolicy > SendQueueDisconnect {
+		return ValidFail(fmt.Errorf("invalid json field send_queue_policy %d, only support 0, 1", config.SendQueuePolicy))
+	}
+
+	if duration, err := time.ParseDuration(config.WriteTimeout); err != nil {
+		return ValidFail(fmt.Errorf("invalid json field write_timeout, duration parse error, %v", err))
+	} else {
+		config.WriteTimeoutDuration = duration
+	}
+
+	if duration, err := time.ParseDuration(config.DrainTime); err != nil {
+		return ValidFail(fmt.Errorf("invalid json field drain_time, duration parse error, %v", err))
+	} else if duration < 0 {
+		return ValidFail(errors.New("invalid json field drain_time, drain_time must not be negative"))
+	} else {
+		config.DrainDuration = duration
+	}
+
+	if duration, err := time.ParseDuration(config.ShutdownTime); err != nil {
+		return ValidFail(fmt.Errorf("invalid json field shutdown_time, duration parse error, %v", err))
+	} else if duration <= 0 {
+		return ValidFail(errors.New("invalid json field shutdown_time, shutdown_time must larger than 0"))
+	} else {
+		config.ShutdownDuration = duration
+	}
+
+	if duration, err := time.ParseDuration(config.HelpRequestInterval); err != nil {
+		return ValidFail(fmt.Errorf("invalid json field help_request_interval, duration parse error, %v", err))
+	} else if duration < 0 {
+		return ValidFail(errors.New("invalid json field help_request_interval, help_request_interval must not be negative"))
+	} else {
+		config.HelpRequestDuration = duration
+	}
+
+	positions := make([]string, 0, len(config.EndorsedPositions))
+	for _, pattern := range config.EndorsedPositions {
+		pattern = strings.ToUpper(pattern)
+		if !utils.CallsignPatternValid(pattern) {
+			return ValidFail(fmt.Errorf("invalid json field endorsed_positions, illegal callsign pattern %s", pattern))
+		}
+		positions = append(positions, pattern)
+	}
+	config.EndorsedPositions = positions
+	config.endorsedPositions.Store(&positions)
+
+	if config.HelpRequestWebhook != "" {
+		if webhook, err := url.Parse(config.HelpRequestWebhook); err != nil {
+			return ValidFail(fmt.Errorf("invalid json field help_request_webhook, %v", err))
+		} else if webhook.Scheme != "http" && webhook.Scheme != "https" {
+			return ValidFail(errors.New("invalid json field help_request_webhook, only support http and https"))
+		}
+	}
+
+	if bytes, err := cachedContent(logger, config.AirportDataFile, global.AirportDataFileUrl, global.AirportDataBundledFile); err != nil {
 		logger.WarnF("fail to load airport data, airport check disable, %v", err)
 		config.AirportData = nil
 	} else if err := json.Unmarshal(bytes, &config.AirportData); err != nil {
@@ -75,12 +187,30 @@ func (config *FSDServerConfig) checkValid(logger log.LoggerInterface) *ValidResu
 		logger.InfoF("Airport data loaded, found %d airports", len(config.AirportData))
 	}
 
+	if config.SectorDataFile != "" {
+		if bytes, err := os.ReadFile(config.SectorDataFile); err != nil {
+			logger.WarnF("fail to load sector data, sector feature disable, %v", err)
+			config.SectorData = nil
+		} else if sectors, err := parseSectorData(bytes); err != nil {
+			return ValidFail(fmt.Errorf("invalid sector data file %s, %v", config.SectorDataFile, err))
+		} else {
+			config.SectorData = sectors
+			logger.InfoF("Sector data loaded, found %d sectors", len(sectors))
+		}
+	}
+
 	if duration, err := time.ParseDuration(config.SessionCleanTime); err != nil {
 		return ValidFail(fmt.Errorf("invalid json field session_clean_time, duration parse error, %v", err))
 	} else {
 		config.SessionCleanDuration = duration
 	}
 
+	if duration, err := time.ParseDuration(config.SessionRestoreTime); err != nil {
+		return ValidFail(fmt.Errorf("invalid json field session_restore_time, duration parse error, %v", err))
+	} else {
+		config.SessionRestoreDuration = duration
+	}
+
 	if duration, err := time.ParseDuration(config.HeartbeatInterval); err != nil {
 		return ValidFail(fmt.Errorf("invalid json field heartbead_interval, duration parse error, %v", err))
 	} else {
@@ -89,3 +219,19 @@ func (config *FSDServerConfig) checkValid(logger log.LoggerInterface) *ValidResu
 
 	return ValidPass()
 }
+
+// MotdLines 返回当前生效的MOTD, 支持热重载
+func (config *FSDServerConfig) MotdLines() []string {
+	if lines := config.motdLines.Load(); lines != nil {
+		return *lines
+	}
+	return config.Motd
+}
+
+// EndorsedPositionPatterns 返回当前需要席位授权的呼号模式, 支持热重载
+func (config *FSDServerConfig) EndorsedPositionPatterns() []string {
+	if positions := config.endorsedPositions.Load(); positions != nil {
+		return *positions
+	}
+	return config.EndorsedPositions
+}
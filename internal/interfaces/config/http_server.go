@@ -9,21 +9,26 @@ import (
 )
 
 type HttpServerConfig struct {
-	Enabled       bool             `json:"enabled"`
-	ServerAddress string           `json:"server_address"`
-	Host          string           `json:"host"`
-	Port          uint             `json:"port"`
-	Address       string           `json:"-"`
-	MaxWorkers    int              `json:"max_workers"` // 并发线程数
-	CacheTime     string           `json:"whazzup_cache_time"`
-	CacheDuration time.Duration    `json:"-"`
-	ProxyType     int              `json:"proxy_type"`
-	BodyLimit     string           `json:"body_limit"`
-	Store         *HttpServerStore `json:"store"`
-	Limits        *HttpServerLimit `json:"limits"`
-	Email         *EmailConfig     `json:"email"`
-	JWT           *JWTConfig       `json:"jwt"`
-	SSL           *SSLConfig       `json:"ssl"`
+	Enabled       bool                 `json:"enabled"`
+	ServerAddress string               `json:"server_address"`
+	Host          string               `json:"host"`
+	Port          uint                 `json:"port"`
+	Address       string               `json:"-"`
+	MaxWorkers    int                  `json:"max_workers"` // 并发线程数
+	CacheTime     string               `json:"whazzup_cache_time"`
+	CacheDuration time.Duration        `json:"-"`
+	ProxyType     int                  `json:"proxy_type"`
+	BodyLimit     string               `json:"body_limit"`
+	Store         *HttpServerStore     `json:"store"`
+	Limits        *HttpServerLimit     `json:"limits"`
+	Email         *EmailConfig         `json:"email"`
+	JWT           *JWTConfig           `json:"jwt"`
+	SSL           *SSLConfig           `json:"ssl"`
+	Totp          *TotpConfig          `json:"totp"`
+	OAuth         *OAuthConfig         `json:"oauth"`
+	ExternalLogin *ExternalLoginConfig `json:"external_login"`
+	ApiKey        *ApiKeyConfig        `json:"api_key"`
+	Inactivity    *InactivityConfig    `json:"inactivity"`
 }
 
 func defaultHttpServerConfig() *HttpServerConfig {
@@ -41,6 +46,11 @@ func defaultHttpServerConfig() *HttpServerConfig {
 		Email:         defaultEmailConfig(),
 		JWT:           defaultJWTConfig(),
 		SSL:           defaultSSLConfig(),
+		Totp:          defaultTotpConfig(),
+		OAuth:         defaultOAuthConfig(),
+		ExternalLogin: defaultExternalLoginConfig(),
+		ApiKey:        defaultApiKeyConfig(),
+		Inactivity:    defaultInactivityConfig(),
 	}
 }
 
@@ -80,6 +90,21 @@ func (config *HttpServerConfig) checkValid(logger log.LoggerInterface) *ValidRes
 		if result := config.Store.checkValid(logger); result.IsFail() {
 			return result
 		}
+		if result := config.Totp.checkValid(logger); result.IsFail() {
+			return result
+		}
+		if result := config.OAuth.checkValid(logger, config.ServerAddress); result.IsFail() {
+			return result
+		}
+		if result := config.ExternalLogin.checkValid(logger); result.IsFail() {
+			return result
+		}
+		if result := config.ApiKey.checkValid(logger); result.IsFail() {
+			return result
+		}
+		if result := config.Inactivity.checkValid(logger); result.IsFail() {
+			return result
+		}
 	}
 	return ValidPass()
 }
@@ -0,0 +1,81 @@
+// Package config
+package config
+
+import (
+	"errors"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	"time"
+)
+
+type InactivityConfig struct {
+	Enabled       bool          `json:"enabled"`
+	PeriodMonths  int           `json:"period_months"` // 考核周期的月数, 必须能整除12, 3即为按季度考核
+	MinimumHours  int           `json:"minimum_hours"` // 每个考核周期要求的最少管制小时数
+	MinRating     int           `json:"min_rating"`    // 参与考核的最低管制权限
+	MaxRating     int           `json:"max_rating"`    // 参与考核的最高管制权限
+	CheckInterval string        `json:"check_interval"`
+	CheckDuration time.Duration `json:"-"`
+	GracePeriod   string        `json:"grace_period"` // 发出警告后的宽限期
+	GraceDuration time.Duration `json:"-"`
+	Demote        bool          `json:"demote"`        // 宽限期结束仍未达标时是否降级, 否则只标记为不活跃
+	DemoteRating  int           `json:"demote_rating"` // 降级后的管制权限
+}
+
+func defaultInactivityConfig() *InactivityConfig {
+	return &InactivityConfig{
+		Enabled:       false,
+		PeriodMonths:  3,
+		MinimumHours:  3,
+		MinRating:     2,
+		MaxRating:     10,
+		CheckInterval: "6h",
+		GracePeriod:   "720h",
+		Demote:        false,
+		DemoteRating:  1,
+	}
+}
+
+// MinimumTime 每个考核周期要求的最少管制时长
+func (config *InactivityConfig) MinimumTime() time.Duration {
+	return time.Duration(config.MinimumHours) * time.Hour
+}
+
+// PeriodStart 返回t所在考核周期的开始时间, 考核周期从每年一月一日起按自然月划分
+func (config *InactivityConfig) PeriodStart(t time.Time) time.Time {
+	month := (int(t.Month())-1)/config.PeriodMonths*config.PeriodMonths + 1
+	return time.Date(t.Year(), time.Month(month), 1, 0, 0, 0, 0, t.Location())
+}
+
+func (config *InactivityConfig) checkValid(_ log.LoggerInterface) *ValidResult {
+	if config.PeriodMonths <= 0 || 12%config.PeriodMonths != 0 {
+		return ValidFail(errors.New("invalid json field http_server.inactivity.period_months, value must be a divisor of 12"))
+	}
+	if config.MinimumHours <= 0 {
+		return ValidFail(errors.New("invalid json field http_server.inactivity.minimum_hours, value must larger than 0"))
+	}
+	// 管制权限编号范围为1(OBS)至12(ADM)
+	if config.MinRating < 1 || config.MaxRating > 12 || config.MinRating > config.MaxRating {
+		return ValidFail(errors.New("invalid json field http_server.inactivity.min_rating or max_rating, value must between 1 and 12 and min_rating must not larger than max_rating"))
+	}
+	if !config.Enabled {
+		return ValidPass()
+	}
+	if duration, err := time.ParseDuration(config.CheckInterval); err != nil {
+		return ValidFailWith(errors.New("invalid json field http_server.inactivity.check_interval"), err)
+	} else if duration <= 0 {
+		return ValidFail(errors.New("invalid json field http_server.inactivity.check_interval, value must larger than 0"))
+	} else {
+		config.CheckDuration = duration
+	}
+	if duration, err := time.ParseDuration(config.GracePeriod); err != nil {
+		return ValidFailWith(errors.New("invalid json field http_server.inactivity.grace_period"), err)
+	} else if duration < 0 {
+		return ValidFail(errors.New("invalid json field http_server.inactivity.grace_period, value must not less than 0"))
+	} else {
+		config.GraceDuration = duration
+	}
+	if config.Demote && (config.DemoteRating < 0 || config.DemoteRating >= config.MinRating) {
+		return ValidFail(errors.New("invalid json field http_server.inactivity.demote_rating, value must not less than 0 and less than min_rating"))
+	}
+	return ValidPass()
+}
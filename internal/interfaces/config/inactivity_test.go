@@ -0,0 +1,27 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInactivityPeriodStart(t *testing.T) {
+	tests := []struct {
+		months int
+		time   time.Time
+		want   time.Time
+	}{
+		{3, time.Date(2025, time.August, 15, 12, 0, 0, 0, time.UTC), time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC)},
+		{3, time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC)},
+		{3, time.Date(2025, time.March, 31, 23, 59, 59, 0, time.UTC), time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{1, time.Date(2025, time.February, 28, 8, 0, 0, 0, time.UTC), time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{6, time.Date(2025, time.December, 31, 8, 0, 0, 0, time.UTC), time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC)},
+		{12, time.Date(2025, time.June, 1, 8, 0, 0, 0, time.UTC), time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		inactivityConfig := &InactivityConfig{PeriodMonths: test.months}
+		if got := inactivityConfig.PeriodStart(test.time); !got.Equal(test.want) {
+			t.Errorf("PeriodStart(%d months, %s) = %s, want %s", test.months, test.time, got, test.want)
+		}
+	}
+}
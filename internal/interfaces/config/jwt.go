@@ -5,6 +5,7 @@ import (
 	"errors"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
 	"github.com/thanhpk/randstr"
+	"sync/atomic"
 	"time"
 )
 
@@ -14,6 +15,9 @@ type JWTConfig struct {
 	ExpiresDuration time.Duration `json:"-"`
 	RefreshTime     string        `json:"refresh_time"`
 	RefreshDuration time.Duration `json:"-"`
+	expires         atomic.Int64
+	refresh         atomic.Int64
+	generatedSecret bool // 配置文件中的密钥为空, 使用了随机生成的密钥
 }
 
 func defaultJWTConfig() *JWTConfig {
@@ -29,18 +33,31 @@ func (config *JWTConfig) checkValid(logger log.LoggerInterface) *ValidResult {
 		return ValidFailWith(errors.New("invalid json field http_server.email.jwt_expires_time"), err)
 	} else {
 		config.ExpiresDuration = duration
+		config.expires.Store(int64(duration))
 	}
 
 	if duration, err := time.ParseDuration(config.RefreshTime); err != nil {
 		return ValidFailWith(errors.New("invalid json field http_server.email.jwt_refresh_time"), err)
 	} else {
 		config.RefreshDuration = duration
+		config.refresh.Store(int64(duration))
 	}
 
 	if config.Secret == "" {
 		config.Secret = randstr.String(64)
+		config.generatedSecret = true
 		logger.DebugF("Generate random JWT Secret: %s", config.Secret)
 	}
 
 	return ValidPass()
 }
+
+// Expires 返回当前生效的令牌有效期, 支持热重载
+func (config *JWTConfig) Expires() time.Duration {
+	return time.Duration(config.expires.Load())
+}
+
+// Refresh 返回当前生效的刷新令牌额外有效期, 支持热重载
+func (config *JWTConfig) Refresh() time.Duration {
+	return time.Duration(config.refresh.Load())
+}
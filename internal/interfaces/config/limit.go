@@ -4,6 +4,7 @@ package config
 import (
 	"errors"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	"sync/atomic"
 	"time"
 )
 
@@ -19,6 +20,13 @@ type HttpServerLimit struct {
 	PasswordLengthMax int           `json:"password_length_max"`
 	CidMin            int           `json:"cid_min"`
 	CidMax            int           `json:"cid_max"`
+	rateLimit         atomic.Pointer[RateLimitRule]
+}
+
+// RateLimitRule 按IP与路径限流的规则, 在 Window 内最多允许 Limit 次请求
+type RateLimitRule struct {
+	Limit  int
+	Window time.Duration
 }
 
 func defaultHttpServerLimit() *HttpServerLimit {
@@ -36,12 +44,30 @@ func defaultHttpServerLimit() *HttpServerLimit {
 	}
 }
 
-func (config *HttpServerLimit) checkValid(_ log.LoggerInterface) *ValidResult {
+// CurrentRateLimit 返回当前生效的限流规则, 支持热重载
+func (config *HttpServerLimit) CurrentRateLimit() RateLimitRule {
+	if rule := config.rateLimit.Load(); rule != nil {
+		return *rule
+	}
+	return RateLimitRule{Limit: config.RateLimit, Window: config.RateLimitDuration}
+}
+
+func (config *HttpServerLimit) checkValid(logger log.LoggerInterface) *ValidResult {
 	if duration, err := time.ParseDuration(config.RateLimitWindow); err != nil {
 		return ValidFailWith(errors.New("invalid json field http_server.rate_limit_window, %v"), err)
 	} else {
 		config.RateLimitDuration = duration
 	}
+	rule := RateLimitRule{Limit: config.RateLimit, Window: config.RateLimitDuration}
+	if rule.Limit <= 0 {
+		logger.WarnF("Invalid rate limit value %d, using default 15", rule.Limit)
+		rule.Limit = 15
+	}
+	if rule.Window <= 0 {
+		logger.WarnF("Invalid rate limit duration %v, using default 1m", rule.Window)
+		rule.Window = time.Minute
+	}
+	config.rateLimit.Store(&rule)
 
 	if config.UsernameLengthMin <= 0 {
 		return ValidFail(errors.New("invalid json field http_server.limits.username_length_min, value must larger than 0"))
@@ -0,0 +1,98 @@
+// Package config
+package config
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	"net"
+	"strings"
+)
+
+type MetricsServerConfig struct {
+	Enabled     bool     `json:"enabled"`
+	Host        string   `json:"host"`
+	Port        uint     `json:"port"` // 为0时挂载到Http服务器上
+	Address     string   `json:"-"`
+	Path        string   `json:"path"`
+	Token       string   `json:"token"`       // 访问指标接口需要携带的Bearer令牌, 为空时不校验
+	AllowedIps  []string `json:"allowed_ips"` // 允许访问指标接口的IP或CIDR, 为空时不限制
+	allowedNets []*net.IPNet
+}
+
+func defaultMetricsServerConfig() *MetricsServerConfig {
+	return &MetricsServerConfig{
+		Enabled:    false,
+		Host:       "127.0.0.1",
+		Port:       6812,
+		Path:       "/metrics",
+		Token:      "",
+		AllowedIps: make([]string, 0),
+	}
+}
+
+// Standalone 是否使用独立端口提供指标
+func (config *MetricsServerConfig) Standalone() bool {
+	return config.Port != 0
+}
+
+// Authorized 判断请求是否可以访问指标接口, authorization为请求的Authorization头
+func (config *MetricsServerConfig) Authorized(ip string, authorization string) bool {
+	if len(config.allowedNets) > 0 {
+		address := net.ParseIP(ip)
+		if address == nil {
+			return false
+		}
+		allowed := false
+		for _, ipNet := range config.allowedNets {
+			if ipNet.Contains(address) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	if config.Token != "" {
+		token, found := strings.CutPrefix(authorization, "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(token), []byte(config.Token)) != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func (config *MetricsServerConfig) checkValid(_ log.LoggerInterface) *ValidResult {
+	if config.Enabled {
+		if !strings.HasPrefix(config.Path, "/") {
+			return ValidFail(errors.New("invalid json field metrics_server.path, path must start with /"))
+		}
+		config.allowedNets = make([]*net.IPNet, 0, len(config.AllowedIps))
+		for _, allowedIp := range config.AllowedIps {
+			if !strings.Contains(allowedIp, "/") {
+				if ip := net.ParseIP(allowedIp); ip != nil && ip.To4() != nil {
+					allowedIp += "/32"
+				} else {
+					allowedIp += "/128"
+				}
+			}
+			_, ipNet, err := net.ParseCIDR(allowedIp)
+			if err != nil {
+				return ValidFailWith(fmt.Errorf("invalid json field metrics_server.allowed_ips, %s is not a valid ip or cidr", allowedIp), err)
+			}
+			config.allowedNets = append(config.allowedNets, ipNet)
+		}
+		if config.Standalone() {
+			if result := checkPort(config.Port); result.IsFail() {
+				return result
+			}
+			config.Address = fmt.Sprintf("%s:%d", config.Host, config.Port)
+		} else if config.Token == "" && len(config.allowedNets) == 0 {
+			// 挂载到公开的Http服务器上时必须限制访问
+			return ValidFail(errors.New("metrics_server.token or metrics_server.allowed_ips is required when metrics is served on http server"))
+		}
+	}
+	return ValidPass()
+}
@@ -0,0 +1,51 @@
+// Package config
+package config
+
+import "testing"
+
+func TestMetricsServerConfigCheckValid(t *testing.T) {
+	config := defaultMetricsServerConfig()
+	config.Enabled = true
+	config.Port = 0
+	if result := config.checkValid(nil); !result.IsFail() {
+		t.Error("metrics on http server without token or allowed_ips should be rejected")
+	}
+	config.AllowedIps = []string{"not an ip"}
+	if result := config.checkValid(nil); !result.IsFail() {
+		t.Error("invalid allowed_ips should be rejected")
+	}
+	config.AllowedIps = []string{"10.0.0.0/8"}
+	if result := config.checkValid(nil); result.IsFail() {
+		t.Errorf("valid allowed_ips rejected: %v", result.Error())
+	}
+}
+
+func TestMetricsServerConfigAuthorized(t *testing.T) {
+	config := defaultMetricsServerConfig()
+	config.Enabled = true
+	config.Port = 0
+	config.Token = "secret"
+	config.AllowedIps = []string{"10.0.0.0/8", "192.168.1.1", "::1"}
+	if result := config.checkValid(nil); result.IsFail() {
+		t.Fatalf("checkValid failed: %v", result.Error())
+	}
+	tests := []struct {
+		ip            string
+		authorization string
+		want          bool
+	}{
+		{"10.1.2.3", "Bearer secret", true},
+		{"192.168.1.1", "Bearer secret", true},
+		{"::1", "Bearer secret", true},
+		{"192.168.1.2", "Bearer secret", false},
+		{"10.1.2.3", "Bearer wrong", false},
+		{"10.1.2.3", "secret", false},
+		{"10.1.2.3", "", false},
+		{"invalid", "Bearer secret", false},
+	}
+	for _, test := range tests {
+		if result := config.Authorized(test.ip, test.authorization); result != test.want {
+			t.Errorf("Authorized(%q, %q) = %v, want %v", test.ip, test.authorization, result, test.want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Package config
+package config
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	"os"
+	"time"
+)
+
+type OAuthConfig struct {
+	Enabled                    bool            `json:"enabled"`
+	AuthorizeUrl               string          `json:"authorize_url"` // 前端授权页面地址, 为空则使用server_address/oauth/authorize
+	CodeExpiresTime            string          `json:"code_expires_time"`
+	CodeExpiresDuration        time.Duration   `json:"-"`
+	AccessTokenExpiresTime     string          `json:"access_token_expires_time"`
+	AccessTokenExpiresDuration time.Duration   `json:"-"`
+	SigningKeyFile             string          `json:"signing_key_file"` // ID令牌签名私钥, 不存在时自动生成
+	SigningKey                 *rsa.PrivateKey `json:"-"`
+	SigningKeyId               string          `json:"-"`
+}
+
+func defaultOAuthConfig() *OAuthConfig {
+	return &OAuthConfig{
+		Enabled:                false,
+		AuthorizeUrl:           "",
+		CodeExpiresTime:        "5m",
+		AccessTokenExpiresTime: "1h",
+		SigningKeyFile:         "oauth_signing_key.pem",
+	}
+}
+
+func (config *OAuthConfig) checkValid(logger log.LoggerInterface, serverAddress string) *ValidResult {
+	if !config.Enabled {
+		return ValidPass()
+	}
+	if duration, err := time.ParseDuration(config.CodeExpiresTime); err != nil {
+		return ValidFailWith(errors.New("invalid json field http_server.oauth.code_expires_time"), err)
+	} else {
+		config.CodeExpiresDuration = duration
+	}
+	if duration, err := time.ParseDuration(config.AccessTokenExpiresTime); err != nil {
+		return ValidFailWith(errors.New("invalid json field http_server.oauth.access_token_expires_time"), err)
+	} else {
+		config.AccessTokenExpiresDuration = duration
+	}
+	if err := config.loadSigningKey(logger); err != nil {
+		return ValidFailWith(errors.New("fail to load http_server.oauth.signing_key_file"), err)
+	}
+	if config.AuthorizeUrl == "" {
+		config.AuthorizeUrl = serverAddress + "/oauth/authorize"
+		logger.WarnF("http_server.oauth.authorize_url is empty, use %s", config.AuthorizeUrl)
+	}
+	return ValidPass()
+}
+
+// loadSigningKey 读取ID令牌签名私钥, 文件不存在时生成新的RSA私钥并保存
+func (config *OAuthConfig) loadSigningKey(logger log.LoggerInterface) error {
+	content, err := os.ReadFile(config.SigningKeyFile)
+	if errors.Is(err, os.ErrNotExist) {
+		logger.WarnF("%s not found, generating new oauth signing key", config.SigningKeyFile)
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return err
+		}
+		content = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+		if err := os.WriteFile(config.SigningKeyFile, content, 0600); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return errors.New("invalid pem content")
+	}
+	// 同时支持PKCS#1与PKCS#8格式的RSA私钥
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		parsed, pkcs8Err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if pkcs8Err != nil {
+			return err
+		}
+		rsaKey, ok := parsed.(*rsa.PrivateKey)
+		if !ok {
+			return errors.New("signing key must be an rsa private key")
+		}
+		key = rsaKey
+	}
+	sum := sha256.Sum256(x509.MarshalPKCS1PublicKey(&key.PublicKey))
+	config.SigningKey = key
+	config.SigningKeyId = base64.RawURLEncoding.EncodeToString(sum[:8])
+	return nil
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadSigningKey(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "oauth_signing_key.pem")
+	config := &OAuthConfig{SigningKeyFile: file}
+	if err := config.loadSigningKey(nopLogger{}); err != nil {
+		t.Fatal(err)
+	}
+	if config.SigningKey == nil || config.SigningKeyId == "" {
+		t.Fatal("signing key should be generated")
+	}
+	if info, err := os.Stat(file); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("signing key file should be saved with mode 0600, got %v", err)
+	}
+
+	// 再次加载时使用已保存的私钥
+	reloaded := &OAuthConfig{SigningKeyFile: file}
+	if err := reloaded.loadSigningKey(nopLogger{}); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.SigningKeyId != config.SigningKeyId || !reloaded.SigningKey.Equal(config.SigningKey) {
+		t.Error("reloaded signing key should match the saved one")
+	}
+
+	if err := os.WriteFile(file, []byte("invalid"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&OAuthConfig{SigningKeyFile: file}).loadSigningKey(nopLogger{}); err == nil {
+		t.Error("expected error for invalid signing key file")
+	}
+}
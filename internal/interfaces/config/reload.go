@@ -0,0 +1,148 @@
+// Package config
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// hotReloadFields 可以在运行时直接替换的配置项, 子路径同样视为可热重载
+var hotReloadFields = []string{
+	"rating",
+	"server.fsd_server.motd",
+	"server.fsd_server.first_motd_line",
+	"server.fsd_server.endorsed_positions",
+	"server.http_server.limits.rate_limit",
+	"server.http_server.limits.rate_limit_window",
+	"server.http_server.jwt.expires_time",
+	"server.http_server.jwt.refresh_time",
+	"server.http_server.email.template",
+}
+
+// ReloadResult 配置重载结果
+type ReloadResult struct {
+	Applied         []string `json:"applied"`          // 已经生效的配置项
+	RestartRequired []string `json:"restart_required"` // 发生变化但需要重启才能生效的配置项
+}
+
+func isHotReloadField(path string) bool {
+	for _, field := range hotReloadFields {
+		if path == field || strings.HasPrefix(path, field+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// toJsonTree 将配置转换为通用的JSON树, 用于比较差异
+func toJsonTree(config *Config) (map[string]interface{}, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	tree := make(map[string]interface{})
+	err = json.Unmarshal(data, &tree)
+	return tree, err
+}
+
+// diffJsonTree 比较两棵JSON树, 返回所有发生变化的叶子路径
+func diffJsonTree(prefix string, oldValue, newValue interface{}, result *[]string) {
+	oldMap, oldOk := oldValue.(map[string]interface{})
+	newMap, newOk := newValue.(map[string]interface{})
+	if !oldOk || !newOk {
+		if !reflect.DeepEqual(oldValue, newValue) {
+			*result = append(*result, prefix)
+		}
+		return
+	}
+	keys := make(map[string]struct{}, len(oldMap)+len(newMap))
+	for key := range oldMap {
+		keys[key] = struct{}{}
+	}
+	for key := range newMap {
+		keys[key] = struct{}{}
+	}
+	for key := range keys {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		diffJsonTree(path, oldMap[key], newMap[key], result)
+	}
+}
+
+// DiffConfig 返回两份配置之间发生变化的配置项路径
+func DiffConfig(oldConfig, newConfig *Config) ([]string, error) {
+	oldTree, err := toJsonTree(oldConfig)
+	if err != nil {
+		return nil, err
+	}
+	newTree, err := toJsonTree(newConfig)
+	if err != nil {
+		return nil, err
+	}
+	changed := make([]string, 0)
+	diffJsonTree("", oldTree, newTree, &changed)
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// ApplyHotReload 将 newConfig 中可热重载的配置项应用到当前配置, newConfig 必须已经通过 CheckValid
+// 运行时的读取方通过原子访问器获取新值, 导出字段的替换在写锁下进行
+func (c *Config) ApplyHotReload(newConfig *Config) (*ReloadResult, error) {
+	httpConfig, newHttpConfig := c.Server.HttpServer, newConfig.Server.HttpServer
+	// 两次都使用随机生成的JWT密钥时沿用当前密钥, 否则会被误报为需要重启
+	if httpConfig.JWT.generatedSecret && newHttpConfig.JWT.generatedSecret {
+		newHttpConfig.JWT.Secret = httpConfig.JWT.Secret
+	}
+
+	changed, err := DiffConfig(c, newConfig)
+	if err != nil {
+		return nil, err
+	}
+	result := &ReloadResult{Applied: make([]string, 0), RestartRequired: make([]string, 0)}
+	for _, path := range changed {
+		// http服务器未启用时其配置项不会被替换, 只能在重启后生效
+		if !newHttpConfig.Enabled && strings.HasPrefix(path, "server.http_server.") {
+			result.RestartRequired = append(result.RestartRequired, path)
+			continue
+		}
+		if isHotReloadField(path) {
+			result.Applied = append(result.Applied, path)
+		} else {
+			result.RestartRequired = append(result.RestartRequired, path)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Rating = newConfig.Rating
+
+	fsdConfig, newFsdConfig := c.Server.FSDServer, newConfig.Server.FSDServer
+	fsdConfig.FirstMotdLine = newFsdConfig.FirstMotdLine
+	fsdConfig.Motd = newFsdConfig.Motd
+	fsdConfig.motdLines.Store(newFsdConfig.motdLines.Load())
+	fsdConfig.EndorsedPositions = newFsdConfig.EndorsedPositions
+	fsdConfig.endorsedPositions.Store(newFsdConfig.endorsedPositions.Load())
+
+	// http服务器未启用时不会进行校验, 此时不替换
+	if newHttpConfig.Enabled {
+		httpConfig.Limits.RateLimit = newHttpConfig.Limits.RateLimit
+		httpConfig.Limits.RateLimitWindow = newHttpConfig.Limits.RateLimitWindow
+		httpConfig.Limits.RateLimitDuration = newHttpConfig.Limits.RateLimitDuration
+		httpConfig.Limits.rateLimit.Store(newHttpConfig.Limits.rateLimit.Load())
+
+		httpConfig.JWT.ExpiresTime = newHttpConfig.JWT.ExpiresTime
+		httpConfig.JWT.RefreshTime = newHttpConfig.JWT.RefreshTime
+		httpConfig.JWT.expires.Store(newHttpConfig.JWT.expires.Load())
+		httpConfig.JWT.refresh.Store(newHttpConfig.JWT.refresh.Load())
+
+		httpConfig.Email.Template = newHttpConfig.Email.Template
+		httpConfig.Email.templates.Store(newHttpConfig.Email.Template)
+	}
+
+	return result, nil
+}
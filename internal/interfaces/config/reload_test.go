@@ -0,0 +1,115 @@
+// Package config
+package config
+
+import (
+	"encoding/json"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// nopLogger 只实现校验过程中用到的方法
+type nopLogger struct {
+	log.LoggerInterface
+}
+
+func (nopLogger) DebugF(string, ...interface{}) {}
+func (nopLogger) InfoF(string, ...interface{})  {}
+func (nopLogger) WarnF(string, ...interface{})  {}
+
+// newReloadConfig 生成已校验热重载相关字段的配置, JWT密钥为空时使用随机密钥
+func newReloadConfig(t *testing.T, secret string) *Config {
+	config := DefaultConfig()
+	config.Server.HttpServer.Enabled = true
+	config.Server.HttpServer.JWT.Secret = secret
+	if result := config.Server.HttpServer.JWT.checkValid(nopLogger{}); result.IsFail() {
+		t.Fatal(result.Error())
+	}
+	if result := config.Server.HttpServer.Limits.checkValid(nopLogger{}); result.IsFail() {
+		t.Fatal(result.Error())
+	}
+	// fsd配置的完整校验需要加载机场数据, 这里只设置热重载读取的字段
+	fsdConfig := config.Server.FSDServer
+	fsdConfig.motdLines.Store(&fsdConfig.Motd)
+	fsdConfig.endorsedPositions.Store(&fsdConfig.EndorsedPositions)
+	return config
+}
+
+func TestApplyHotReloadGeneratedSecret(t *testing.T) {
+	config := newReloadConfig(t, "")
+	result, err := config.ApplyHotReload(newReloadConfig(t, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slices.Contains(result.RestartRequired, "server.http_server.jwt.secret") {
+		t.Errorf("generated jwt secret reported as changed: %v", result.RestartRequired)
+	}
+
+	result, err = config.ApplyHotReload(newReloadConfig(t, "configured"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(result.RestartRequired, "server.http_server.jwt.secret") {
+		t.Errorf("configured jwt secret not reported as changed: %v", result.RestartRequired)
+	}
+}
+
+func TestApplyHotReloadConcurrentRead(t *testing.T) {
+	config := newReloadConfig(t, "secret")
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			newConfig := newReloadConfig(t, "secret")
+			newConfig.Server.HttpServer.Limits.RateLimit = 100 + i
+			if result := newConfig.Server.HttpServer.Limits.checkValid(nopLogger{}); result.IsFail() {
+				t.Error(result.Error())
+				return
+			}
+			if _, err := config.ApplyHotReload(newConfig); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			_ = config.Server.HttpServer.Limits.CurrentRateLimit()
+			_ = config.Server.HttpServer.JWT.Expires()
+			_ = config.Server.FSDServer.MotdLines()
+			if _, err := json.Marshal(config); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+	if rule := config.Server.HttpServer.Limits.CurrentRateLimit(); rule.Limit != 199 {
+		t.Errorf("rate limit not reloaded, got %d", rule.Limit)
+	}
+}
+
+func TestApplyHotReloadHttpDisabled(t *testing.T) {
+	config := newReloadConfig(t, "secret")
+	newConfig := newReloadConfig(t, "secret")
+	newConfig.Server.HttpServer.Enabled = false
+	newConfig.Server.HttpServer.JWT.ExpiresTime = "1h"
+	newConfig.Server.FSDServer.Motd = []string{"reloaded"}
+	result, err := config.ApplyHotReload(newConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slices.Contains(result.Applied, "server.http_server.jwt.expires_time") ||
+		!slices.Contains(result.RestartRequired, "server.http_server.jwt.expires_time") {
+		t.Errorf("jwt expires_time of disabled http server reported as applied: %+v", result)
+	}
+	if !slices.Contains(result.Applied, "server.fsd_server.motd") {
+		t.Errorf("motd not reported as applied: %+v", result)
+	}
+	if config.Server.HttpServer.JWT.ExpiresTime == "1h" {
+		t.Error("jwt expires_time of disabled http server replaced")
+	}
+}
@@ -0,0 +1,88 @@
+// Package config
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// SectorPolygon 扇区多边形, 第一个环为外边界, 其余为内部挖空区域, 坐标点格式为GeoJSON标准的[经度, 纬度]
+type SectorPolygon [][][2]float64
+
+type SectorData struct {
+	Callsign string          // 扇区对应的管制席位呼号, 比如ZSHA_CTR
+	Name     string          // 扇区名称
+	Polygons []SectorPolygon // 扇区边界
+}
+
+type geoJsonGeometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+type geoJsonFeature struct {
+	Properties struct {
+		Callsign string `json:"callsign"`
+		Name     string `json:"name"`
+	} `json:"properties"`
+	Geometry *geoJsonGeometry `json:"geometry"`
+}
+
+type geoJsonFeatureCollection struct {
+	Type     string            `json:"type"`
+	Features []*geoJsonFeature `json:"features"`
+}
+
+// parseSectorData 解析GeoJSON格式的扇区数据, 仅支持Polygon和MultiPolygon
+func parseSectorData(content []byte) ([]*SectorData, error) {
+	collection := &geoJsonFeatureCollection{}
+	if err := json.Unmarshal(content, collection); err != nil {
+		return nil, fmt.Errorf("invalid geojson, %v", err)
+	}
+	if collection.Type != "FeatureCollection" {
+		return nil, fmt.Errorf("unsupported geojson type %s, only support FeatureCollection", collection.Type)
+	}
+	sectors := make([]*SectorData, 0, len(collection.Features))
+	for index, feature := range collection.Features {
+		if feature == nil {
+			return nil, fmt.Errorf("feature %d is null", index)
+		}
+		if feature.Properties.Callsign == "" {
+			return nil, fmt.Errorf("feature %d lack of property callsign", index)
+		}
+		if feature.Geometry == nil {
+			return nil, fmt.Errorf("feature %d(%s) lack of geometry", index, feature.Properties.Callsign)
+		}
+		sector := &SectorData{
+			Callsign: strings.ToUpper(feature.Properties.Callsign),
+			Name:     feature.Properties.Name,
+		}
+		switch feature.Geometry.Type {
+		case "Polygon":
+			polygon := SectorPolygon{}
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &polygon); err != nil {
+				return nil, fmt.Errorf("feature %d(%s) has invalid coordinates, %v", index, sector.Callsign, err)
+			}
+			sector.Polygons = []SectorPolygon{polygon}
+		case "MultiPolygon":
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &sector.Polygons); err != nil {
+				return nil, fmt.Errorf("feature %d(%s) has invalid coordinates, %v", index, sector.Callsign, err)
+			}
+		default:
+			return nil, fmt.Errorf("feature %d(%s) has unsupported geometry type %s", index, sector.Callsign, feature.Geometry.Type)
+		}
+		for polygonIndex, polygon := range sector.Polygons {
+			if len(polygon) == 0 {
+				return nil, fmt.Errorf("feature %d(%s) polygon %d is empty", index, sector.Callsign, polygonIndex)
+			}
+			for ringIndex, ring := range polygon {
+				if len(ring) < 3 {
+					return nil, fmt.Errorf("feature %d(%s) polygon %d ring %d must have at least 3 points", index, sector.Callsign, polygonIndex, ringIndex)
+				}
+			}
+		}
+		sectors = append(sectors, sector)
+	}
+	return sectors, nil
+}
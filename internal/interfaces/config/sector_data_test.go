@@ -0,0 +1,66 @@
+// Package config
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSectorData(t *testing.T) {
+	content := `{
+		"type": "FeatureCollection",
+		"features": [
+			{
+				"properties": {"callsign": "zsha_ctr", "name": "Shanghai"},
+				"geometry": {"type": "Polygon", "coordinates": [[[120, 30], [124, 30], [124, 34], [120, 30]]]}
+			},
+			{
+				"properties": {"callsign": "ZBPE_CTR"},
+				"geometry": {"type": "MultiPolygon", "coordinates": [
+					[[[110, 38], [114, 38], [114, 42], [110, 38]], [[111, 39], [112, 39], [112, 40], [111, 39]]],
+					[[[100, 38], [102, 38], [102, 40], [100, 38]]]
+				]}
+			}
+		]
+	}`
+	sectors, err := parseSectorData([]byte(content))
+	if err != nil {
+		t.Fatalf("parseSectorData returned error: %v", err)
+	}
+	if len(sectors) != 2 {
+		t.Fatalf("got %d sectors, want 2", len(sectors))
+	}
+	if sectors[0].Callsign != "ZSHA_CTR" || sectors[0].Name != "Shanghai" || len(sectors[0].Polygons) != 1 {
+		t.Errorf("unexpected first sector %+v", sectors[0])
+	}
+	if len(sectors[1].Polygons) != 2 || len(sectors[1].Polygons[0]) != 2 {
+		t.Errorf("unexpected second sector polygons %v", sectors[1].Polygons)
+	}
+}
+
+func TestParseSectorDataError(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"invalid json", `{`, "invalid geojson"},
+		{"not collection", `{"type": "Feature"}`, "unsupported geojson type"},
+		{"lack callsign", `{"type": "FeatureCollection", "features": [{"properties": {}, "geometry": null}]}`, "feature 0 lack of property callsign"},
+		{"lack geometry", `{"type": "FeatureCollection", "features": [{"properties": {"callsign": "A_CTR"}}]}`, "feature 0(A_CTR) lack of geometry"},
+		{"unsupported geometry", `{"type": "FeatureCollection", "features": [{"properties": {"callsign": "A_CTR"}, "geometry": {"type": "Point", "coordinates": [1, 2]}}]}`,
+			"feature 0(A_CTR) has unsupported geometry type Point"},
+		{"invalid coordinates", `{"type": "FeatureCollection", "features": [{"properties": {"callsign": "A_CTR"}, "geometry": {"type": "Polygon", "coordinates": [1]}}]}`,
+			"feature 0(A_CTR) has invalid coordinates"},
+		{"short hole", `{"type": "FeatureCollection", "features": [
+			{"properties": {"callsign": "A_CTR"}, "geometry": {"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1]]]}},
+			{"properties": {"callsign": "B_CTR"}, "geometry": {"type": "MultiPolygon", "coordinates": [[[[0, 0], [1, 0], [1, 1]]], [[[0, 0], [1, 0], [1, 1]], [[0, 0], [1, 1]]]]}}
+		]}`, "feature 1(B_CTR) polygon 1 ring 1 must have at least 3 points"},
+	}
+	for _, test := range tests {
+		_, err := parseSectorData([]byte(test.content))
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got error %v, want containing %q", test.name, err, test.want)
+		}
+	}
+}
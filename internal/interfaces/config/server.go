@@ -4,18 +4,20 @@ package config
 import "github.com/half-nothing/simple-fsd/internal/interfaces/log"
 
 type ServerConfig struct {
-	General    *GeneralConfig    `json:"general"`
-	FSDServer  *FSDServerConfig  `json:"fsd_server"`
-	HttpServer *HttpServerConfig `json:"http_server"`
-	GRPCServer *GRPCServerConfig `json:"grpc_server"`
+	General       *GeneralConfig       `json:"general"`
+	FSDServer     *FSDServerConfig     `json:"fsd_server"`
+	HttpServer    *HttpServerConfig    `json:"http_server"`
+	GRPCServer    *GRPCServerConfig    `json:"grpc_server"`
+	MetricsServer *MetricsServerConfig `json:"metrics_server"`
 }
 
 func defaultServerConfig() *ServerConfig {
 	return &ServerConfig{
-		General:    defaultOtherConfig(),
-		FSDServer:  defaultFSDServerConfig(),
-		HttpServer: defaultHttpServerConfig(),
-		GRPCServer: defaultGRPCServerConfig(),
+		General:       defaultOtherConfig(),
+		FSDServer:     defaultFSDServerConfig(),
+		HttpServer:    defaultHttpServerConfig(),
+		GRPCServer:    defaultGRPCServerConfig(),
+		MetricsServer: defaultMetricsServerConfig(),
 	}
 }
 
@@ -32,5 +34,8 @@ func (config *ServerConfig) checkValid(logger log.LoggerInterface) *ValidResult
 	if result := config.GRPCServer.checkValid(logger); result.IsFail() {
 		return result
 	}
+	if result := config.MetricsServer.checkValid(logger); result.IsFail() {
+		return result
+	}
 	return ValidPass()
 }
@@ -0,0 +1,24 @@
+// Package config
+package config
+
+import "github.com/half-nothing/simple-fsd/internal/interfaces/log"
+
+type TotpConfig struct {
+	Issuer          string `json:"issuer"`            // 身份验证器中显示的发行者名称
+	RequireForStaff bool   `json:"require_for_staff"` // 是否要求拥有任意管理权限的用户启用两步验证
+}
+
+func defaultTotpConfig() *TotpConfig {
+	return &TotpConfig{
+		Issuer:          "SimpleFSD",
+		RequireForStaff: false,
+	}
+}
+
+func (config *TotpConfig) checkValid(logger log.LoggerInterface) *ValidResult {
+	if config.Issuer == "" {
+		logger.Warn("totp issuer is empty, use default issuer SimpleFSD")
+		config.Issuer = "SimpleFSD"
+	}
+	return ValidPass()
+}
@@ -2,8 +2,11 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	simplefsd "github.com/half-nothing/simple-fsd"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/global"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/log"
 	"github.com/half-nothing/simple-fsd/internal/utils"
@@ -12,6 +15,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 )
 
 var (
@@ -28,16 +33,14 @@ func createFileWithContent(filePath string, content []byte) error {
 	return os.WriteFile(filePath, content, global.DefaultFilePermissions)
 }
 
-func cachedContent(logger log.LoggerInterface, filePath, url string) ([]byte, error) {
-	if content, err := os.ReadFile(filePath); err == nil {
-		return content, nil
-	} else if !os.IsNotExist(err) {
-		return nil, fmt.Errorf("file read error: %w", err)
-	}
+// downloadTimeout 下载默认文件的超时时间, 避免在无法访问外网的环境中长时间阻塞启动
+const downloadTimeout = 10 * time.Second
 
-	logger.InfoF("%s not found, downloading from %s", filePath, url)
+func downloadContent(logger log.LoggerInterface, filePath, url string) ([]byte, error) {
+	logger.InfoF("Downloading %s from %s", filePath, url)
 
-	resp, err := http.Get(url)
+	client := &http.Client{Timeout: downloadTimeout}
+	resp, err := client.Get(url)
 	if err != nil {
 		return nil, fmt.Errorf("HTTP request failed: %w", err)
 	}
@@ -58,11 +61,158 @@ func cachedContent(logger log.LoggerInterface, filePath, url string) ([]byte, er
 	}
 
 	logger.InfoF("%s successfully downloaded, %d bytes", filePath, len(content))
+	return content, nil
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// checksumFile 校验文件路径, 内容为"写入时文件的sha256 写入时内置文件的sha256"
+func checksumFile(filePath string) string {
+	return filePath + ".sha256"
+}
+
+func readChecksum(filePath string) (fileSum, bundledSum string, ok bool) {
+	data, err := os.ReadFile(checksumFile(filePath))
+	if err != nil {
+		return "", "", false
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 {
+		return "", "", false
+	}
+	return fields[0], fields[1], true
+}
 
+// writeContent 将内容写入磁盘, 存在内置版本时同时记录校验信息
+func writeContent(filePath string, content, bundled []byte) error {
 	if err := createFileWithContent(filePath, content); err != nil {
-		return nil, fmt.Errorf("file write error: %w", err)
+		return err
+	}
+	if bundled == nil {
+		return nil
+	}
+	return os.WriteFile(checksumFile(filePath), []byte(checksum(content)+" "+checksum(bundled)+"\n"), global.DefaultFilePermissions)
+}
+
+// checkBundledUpdate 若磁盘上的文件是由服务器写入且未被修改, 而可执行文件内置了不同版本, 则更新为内置版本
+func checkBundledUpdate(logger log.LoggerInterface, filePath string, content, bundled []byte) []byte {
+	if bundled == nil {
+		return content
+	}
+	fileSum, bundledSum, ok := readChecksum(filePath)
+	if !ok || fileSum != checksum(content) || bundledSum == checksum(bundled) {
+		return content
+	}
+	if err := writeContent(filePath, bundled, bundled); err != nil {
+		logger.WarnF("fail to update %s to bundled version, %v", filePath, err)
+		return content
+	}
+	logger.InfoF("%s updated to bundled version %s", filePath, checksum(bundled)[:12])
+	return bundled
+}
+
+// backgroundRefresh 等待后台刷新完成, 仅用于测试
+var backgroundRefresh sync.WaitGroup
+
+// replaceContent 先写入临时文件, 确认磁盘上的文件仍与expected一致后再替换, 避免覆盖期间被修改的文件
+func replaceContent(filePath string, expected, content, bundled []byte) (bool, error) {
+	temp, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = os.Remove(temp.Name()) }()
+	if _, err := temp.Write(content); err != nil {
+		_ = temp.Close()
+		return false, err
+	}
+	if err := temp.Close(); err != nil {
+		return false, err
+	}
+	if err := os.Chmod(temp.Name(), global.DefaultFilePermissions); err != nil {
+		return false, err
+	}
+	if current, err := os.ReadFile(filePath); err != nil || checksum(current) != checksum(expected) {
+		return false, nil
+	}
+	if err := os.Rename(temp.Name(), filePath); err != nil {
+		return false, err
+	}
+	return true, os.WriteFile(checksumFile(filePath), []byte(checksum(content)+" "+checksum(bundled)+"\n"), global.DefaultFilePermissions)
+}
+
+// refreshContent 在后台从url下载与当前版本对应的文件, 仅当磁盘上的文件仍为未修改的内置版本时替换, 下次加载配置时生效
+func refreshContent(logger log.LoggerInterface, filePath, url string, bundled []byte) {
+	defer backgroundRefresh.Done()
+	current, err := os.ReadFile(filePath)
+	if err != nil {
+		return
+	}
+	if fileSum, _, ok := readChecksum(filePath); !ok || fileSum != checksum(current) {
+		return
+	}
+	content, err := downloadContent(logger, filePath, url)
+	if err != nil {
+		logger.WarnF("%s background refresh failed, keep using bundled version, %v", filePath, err)
+		return
+	}
+	if checksum(content) == checksum(current) {
+		return
+	}
+	replaced, err := replaceContent(filePath, current, content, bundled)
+	if err != nil {
+		logger.WarnF("fail to write refreshed %s, %v", filePath, err)
+		return
+	}
+	if !replaced {
+		logger.InfoF("%s was modified during background refresh, keep the modified version", filePath)
+		return
+	}
+	logger.InfoF("%s refreshed from %s, will take effect on next load", filePath, url)
+}
+
+// cachedContent 读取本地文件, 文件不存在时优先使用内置在可执行文件中的版本并在后台从url下载更新,
+// 没有内置版本时才同步下载
+// bundledName 为 simplefsd.Assets 中的路径, 为空表示没有内置版本
+func cachedContent(logger log.LoggerInterface, filePath, url, bundledName string) ([]byte, error) {
+	var bundled []byte
+	if bundledName != "" {
+		if data, err := simplefsd.Assets.ReadFile(bundledName); err == nil {
+			bundled = data
+		}
 	}
 
+	if content, err := os.ReadFile(filePath); err == nil {
+		content = checkBundledUpdate(logger, filePath, content, bundled)
+		// 上次后台下载失败时文件仍为内置版本, 再次尝试刷新
+		if fileSum, bundledSum, ok := readChecksum(filePath); ok && bundled != nil &&
+			fileSum == checksum(content) && bundledSum == fileSum {
+			backgroundRefresh.Add(1)
+			go refreshContent(logger, filePath, url, bundled)
+		}
+		return content, nil
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("file read error: %w", err)
+	}
+
+	if bundled != nil {
+		if err := writeContent(filePath, bundled, bundled); err != nil {
+			return nil, fmt.Errorf("file write error: %w", err)
+		}
+		backgroundRefresh.Add(1)
+		go refreshContent(logger, filePath, url, bundled)
+		return bundled, nil
+	}
+
+	content, err := downloadContent(logger, filePath, url)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeContent(filePath, content, nil); err != nil {
+		return nil, fmt.Errorf("file write error: %w", err)
+	}
 	return content, nil
 }
 
@@ -0,0 +1,151 @@
+// Package config
+package config
+
+import (
+	"bytes"
+	simplefsd "github.com/half-nothing/simple-fsd"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/global"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newContentServer(t *testing.T, content []byte) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(content)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func readFile(t *testing.T, filePath string) []byte {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func TestCachedContentBundledFirst(t *testing.T) {
+	bundled, err := simplefsd.Assets.ReadFile(global.HelpRequestTemplateBundledFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote := []byte("remote template")
+	server := newContentServer(t, remote)
+	filePath := filepath.Join(t.TempDir(), "help_request.template")
+
+	content, err := cachedContent(nopLogger{}, filePath, server.URL, global.HelpRequestTemplateBundledFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(content, bundled) {
+		t.Error("expected bundled content on first load")
+	}
+
+	backgroundRefresh.Wait()
+	if !bytes.Equal(readFile(t, filePath), remote) {
+		t.Error("expected background refresh to write remote content")
+	}
+
+	content, err = cachedContent(nopLogger{}, filePath, server.URL, global.HelpRequestTemplateBundledFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backgroundRefresh.Wait()
+	if !bytes.Equal(content, remote) {
+		t.Error("expected refreshed content on next load")
+	}
+}
+
+func TestCachedContentDownloadFailed(t *testing.T) {
+	bundled, err := simplefsd.Assets.ReadFile(global.HelpRequestTemplateBundledFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	filePath := filepath.Join(t.TempDir(), "help_request.template")
+
+	content, err := cachedContent(nopLogger{}, filePath, server.URL, global.HelpRequestTemplateBundledFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backgroundRefresh.Wait()
+	if !bytes.Equal(content, bundled) || !bytes.Equal(readFile(t, filePath), bundled) {
+		t.Error("expected bundled content to be kept when download failed")
+	}
+}
+
+func TestCachedContentKeepModified(t *testing.T) {
+	// 下载在文件被修改之后才返回, 刷新时必须发现文件已被修改
+	modifiedCh := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		<-modifiedCh
+		_, _ = w.Write([]byte("remote template"))
+	}))
+	t.Cleanup(server.Close)
+	filePath := filepath.Join(t.TempDir(), "help_request.template")
+
+	if _, err := cachedContent(nopLogger{}, filePath, server.URL, global.HelpRequestTemplateBundledFile); err != nil {
+		t.Fatal(err)
+	}
+	modified := []byte("modified template")
+	if err := os.WriteFile(filePath, modified, global.DefaultFilePermissions); err != nil {
+		t.Fatal(err)
+	}
+	close(modifiedCh)
+	backgroundRefresh.Wait()
+	if !bytes.Equal(readFile(t, filePath), modified) {
+		t.Fatal("expected modified file to be kept by background refresh")
+	}
+
+	content, err := cachedContent(nopLogger{}, filePath, server.URL, global.HelpRequestTemplateBundledFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backgroundRefresh.Wait()
+	if !bytes.Equal(content, modified) || !bytes.Equal(readFile(t, filePath), modified) {
+		t.Error("expected modified file to be kept")
+	}
+}
+
+func TestReplaceContentChanged(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "help_request.template")
+	if err := os.WriteFile(filePath, []byte("edited"), global.DefaultFilePermissions); err != nil {
+		t.Fatal(err)
+	}
+	replaced, err := replaceContent(filePath, []byte("bundled"), []byte("remote"), []byte("bundled"))
+	if err != nil || replaced {
+		t.Errorf("replaceContent = %v, %v; expected false, nil", replaced, err)
+	}
+	if !bytes.Equal(readFile(t, filePath), []byte("edited")) {
+		t.Error("expected edited file to be kept")
+	}
+	if matches, _ := filepath.Glob(filePath + ".*.tmp"); len(matches) != 0 {
+		t.Errorf("temporary files left: %v", matches)
+	}
+}
+
+func TestReleaseFileUrl(t *testing.T) {
+	if !strings.Contains(global.AirportDataFileUrl, "/refs/tags/v"+global.AppVersion+"/") {
+		t.Errorf("expected url pinned to release tag, got %s", global.AirportDataFileUrl)
+	}
+}
+
+func TestCachedContentWithoutBundled(t *testing.T) {
+	remote := []byte("remote data")
+	server := newContentServer(t, remote)
+	filePath := filepath.Join(t.TempDir(), "data.json")
+
+	content, err := cachedContent(nopLogger{}, filePath, server.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(content, remote) || !bytes.Equal(readFile(t, filePath), remote) {
+		t.Error("expected downloaded content without bundled version")
+	}
+}
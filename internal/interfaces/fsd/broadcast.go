@@ -70,3 +70,14 @@ func CombineBroadcastFilter(filters ...BroadcastFilter) BroadcastFilter {
 		return true
 	}
 }
+
+func AnyBroadcastFilter(filters ...BroadcastFilter) BroadcastFilter {
+	return func(toClient, fromClient ClientInterface) bool {
+		for _, f := range filters {
+			if f != nil && f(toClient, fromClient) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -48,4 +48,6 @@ type ClientInterface interface {
 	GroundSpeed() int
 	Heading() int
 	Paths() []*PilotPath
+	SendQueueDepth() int
+	SendQueueDropped() uint64
 }
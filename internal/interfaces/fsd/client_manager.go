@@ -4,15 +4,22 @@ package fsd
 import (
 	"context"
 	"errors"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"time"
 )
 
 var (
-	ErrCallsignNotFound = errors.New("callsign not found")
+	ErrCallsignNotFound   = errors.New("callsign not found")
+	ErrDrainInProgress    = errors.New("drain already in progress")
+	ErrServerShuttingDown = errors.New("server is shutting down")
 )
 
 type ClientManagerInterface interface {
 	PutSlice(clients []ClientInterface)
 	Shutdown(ctx context.Context) error
+	ShuttingDown() bool
+	Drain(duration time.Duration) (deadline time.Time, err error)
+	Draining() bool
 	GetClientSnapshot() []ClientInterface
 	AddClient(client ClientInterface) error
 	GetClient(callsign string) (ClientInterface, bool)
@@ -20,5 +27,12 @@ type ClientManagerInterface interface {
 	SendMessageTo(callsign string, message []byte) error
 	SendRawMessageTo(from int, to string, message string) error
 	BroadcastMessage(message []byte, fromClient ClientInterface, filter BroadcastFilter)
+	BroadcastMessageInRange(message []byte, fromClient ClientInterface, filter BroadcastFilter)
+	UpdateClientPosition(client ClientInterface)
+	Sectors() *SectorMap
+	SupervisorOnline() bool
+	NotifyHelpRequest(request *operation.HelpRequest) (supervisorOnline bool)
+	// AcquireHelpRequest 检查cid是否可以发起求助请求并记录本次请求, 不能发起时返回需要等待的时间
+	AcquireHelpRequest(cid int) (wait time.Duration, ok bool)
 	NewClient(callsign string, rating Rating, protocol int, realName string, socket SessionInterface, isAtc bool) ClientInterface
 }
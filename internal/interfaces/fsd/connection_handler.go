@@ -16,4 +16,8 @@ type SessionInterface interface {
 	ConnId() string
 	Conn() net.Conn
 	SetDisconnected(disconnect bool)
+	Send(line []byte)
+	Close()
+	SendQueueDepth() int
+	SendQueueDropped() uint64
 }
@@ -15,11 +15,13 @@ const (
 	InvalidProtocolVision
 	RequestLevelTooHigh
 	UserBaned
+	ServerShuttingDown
 )
 
 var clientErrorsString = []string{"No error", "callsign in use", "Invalid callsign",
 	"Syntax error", "Invalid source callsign", "Invalid CID/password", "No such callsign", "No flightplan",
-	"Invalid protocol revision", "Requested level too high", "CID/PID was suspended"}
+	"Invalid protocol revision", "Requested level too high", "CID/PID was suspended",
+	"Server is shutting down"}
 
 func (e ClientError) String() string {
 	return clientErrorsString[e]
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
 	"github.com/half-nothing/simple-fsd/internal/utils"
+	"strings"
+	"sync/atomic"
 )
 
 type FacilityModel struct {
@@ -39,8 +41,19 @@ var Facilities = []FacilityModel{
 
 var facilitiesIndex = map[Facility]int{Pilot: 0, OBS: 1, DEL: 2, GND: 3, TWR: 4, APP: 5, CTR: 6, FSS: 7}
 
+var callsignSuffixFacility = map[string]Facility{
+	"OBS": OBS,
+	"DEL": DEL,
+	"GND": GND,
+	"TWR": TWR,
+	"APP": APP,
+	"DEP": APP,
+	"CTR": CTR,
+	"FSS": FSS,
+}
+
 func (f Facility) String() string {
-	return Facilities[f].ShortName
+	return Facilities[f.Index()].ShortName
 }
 
 func (f Facility) Index() int {
@@ -51,20 +64,39 @@ func (f Facility) CheckFacility(facility Facility) bool {
 	return f&facility != 0
 }
 
+// FacilityFromCallsign 通过管制席位呼号的后缀获取席位, 比如ZSSS_APP对应APP
+func FacilityFromCallsign(callsign string) (Facility, bool) {
+	index := strings.LastIndex(callsign, "_")
+	if index < 0 || index == len(callsign)-1 {
+		return 0, false
+	}
+	facility, ok := callsignSuffixFacility[strings.ToUpper(callsign[index+1:])]
+	return facility, ok
+}
+
+// ratingFacilities 当前生效的权限席位对照表, 由 SyncRatingConfig 原子替换
+var ratingFacilities atomic.Pointer[map[Rating]Facility]
+
 func (r Rating) CheckRatingFacility(facility Facility) bool {
+	if facilities := ratingFacilities.Load(); facilities != nil {
+		return (*facilities)[r].CheckFacility(facility)
+	}
 	return RatingFacilityMap[r].CheckFacility(facility)
 }
 
+// SyncRatingConfig 以 RatingFacilityMap 为默认值合并配置文件中的覆盖项, 可重复调用以热重载
 func SyncRatingConfig(config *config.Config) error {
-	if len(config.Rating) == 0 {
-		return nil
+	facilities := make(map[Rating]Facility, len(RatingFacilityMap))
+	for rating, facility := range RatingFacilityMap {
+		facilities[rating] = facility
 	}
 	for rating, facility := range config.Rating {
 		r := utils.StrToInt(rating, int(Ban)-1)
 		if r < int(Ban) || r > int(Administrator) {
 			return fmt.Errorf("illegal permission value %s", rating)
 		}
-		RatingFacilityMap[Rating(r)] = Facility(facility)
+		facilities[Rating(r)] = Facility(facility)
 	}
+	ratingFacilities.Store(&facilities)
 	return nil
 }
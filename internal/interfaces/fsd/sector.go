@@ -0,0 +1,154 @@
+// Package fsd
+package fsd
+
+import (
+	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
+	"math"
+	"strings"
+)
+
+type Sector struct {
+	Callsign string
+	Name     string
+	polygons []config.SectorPolygon
+	// 外接矩形, 用于快速排除
+	minLat, maxLat, minLon, maxLon float64
+}
+
+func newSector(data *config.SectorData) *Sector {
+	sector := &Sector{
+		Callsign: data.Callsign,
+		Name:     data.Name,
+		polygons: data.Polygons,
+		minLat:   math.MaxFloat64,
+		maxLat:   -math.MaxFloat64,
+		minLon:   math.MaxFloat64,
+		maxLon:   -math.MaxFloat64,
+	}
+	for _, polygon := range data.Polygons {
+		for _, point := range polygon[0] {
+			sector.minLon = math.Min(sector.minLon, point[0])
+			sector.maxLon = math.Max(sector.maxLon, point[0])
+			sector.minLat = math.Min(sector.minLat, point[1])
+			sector.maxLat = math.Max(sector.maxLat, point[1])
+		}
+	}
+	return sector
+}
+
+// onRingEdge 判断点是否恰好位于环的某条边上
+func onRingEdge(ring [][2]float64, lat, lon float64) bool {
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (lon-xi)*(yj-yi) != (xj-xi)*(lat-yi) {
+			continue
+		}
+		if lon >= math.Min(xi, xj) && lon <= math.Max(xi, xj) && lat >= math.Min(yi, yj) && lat <= math.Max(yi, yj) {
+			return true
+		}
+	}
+	return false
+}
+
+// ringContains 射线法判断点是否在环内部, 不包含边界
+func ringContains(ring [][2]float64, lat, lon float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside && !onRingEdge(ring, lat, lon)
+}
+
+// Contains 判断坐标是否在扇区内, 扇区边界(包括挖空区域的边界)属于扇区
+func (sector *Sector) Contains(position Position) bool {
+	if !position.PositionValid() {
+		return false
+	}
+	lat, lon := position.Latitude, position.Longitude
+	if lat < sector.minLat || lat > sector.maxLat || lon < sector.minLon || lon > sector.maxLon {
+		return false
+	}
+	for _, polygon := range sector.polygons {
+		if !onRingEdge(polygon[0], lat, lon) && !ringContains(polygon[0], lat, lon) {
+			continue
+		}
+		inHole := false
+		for _, hole := range polygon[1:] {
+			if ringContains(hole, lat, lon) {
+				inHole = true
+				break
+			}
+		}
+		if !inHole {
+			return true
+		}
+	}
+	return false
+}
+
+// OwnedBy 判断管制员呼号是否负责该扇区
+// 呼号完全一致, 或者去掉中间部分后一致, 比如ZSHA_1_CTR与ZSHA_N_CTR都负责ZSHA_CTR扇区
+func (sector *Sector) OwnedBy(callsign string) bool {
+	if callsign == sector.Callsign {
+		return true
+	}
+	first := strings.Index(callsign, "_")
+	last := strings.LastIndex(callsign, "_")
+	if first < 0 || first == last {
+		return false
+	}
+	return callsign[:first]+callsign[last:] == sector.Callsign
+}
+
+type SectorMap struct {
+	sectors []*Sector
+}
+
+func NewSectorMap(data []*config.SectorData) *SectorMap {
+	sectorMap := &SectorMap{sectors: make([]*Sector, 0, len(data))}
+	for _, sector := range data {
+		sectorMap.sectors = append(sectorMap.sectors, newSector(sector))
+	}
+	return sectorMap
+}
+
+// Sectors 获取所有扇区
+func (sectorMap *SectorMap) Sectors() []*Sector {
+	return sectorMap.sectors
+}
+
+// FindSectors 获取包含指定坐标的所有扇区
+func (sectorMap *SectorMap) FindSectors(position Position) []*Sector {
+	result := make([]*Sector, 0)
+	for _, sector := range sectorMap.sectors {
+		if sector.Contains(position) {
+			result = append(result, sector)
+		}
+	}
+	return result
+}
+
+// Covers 判断管制员是否负责包含指定坐标的扇区
+func (sectorMap *SectorMap) Covers(callsign string, position Position) bool {
+	for _, sector := range sectorMap.sectors {
+		if sector.OwnedBy(callsign) && sector.Contains(position) {
+			return true
+		}
+	}
+	return false
+}
+
+// BroadcastToSectorOwner 生成发送给负责target所在扇区的管制员的过滤器
+func (sectorMap *SectorMap) BroadcastToSectorOwner(target ClientInterface) BroadcastFilter {
+	return func(toClient, _ ClientInterface) bool {
+		if !toClient.IsAtc() || target == nil {
+			return false
+		}
+		return sectorMap.Covers(toClient.Callsign(), target.Position()[0])
+	}
+}
@@ -0,0 +1,80 @@
+// Package fsd
+package fsd
+
+import (
+	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
+	"testing"
+)
+
+// square 生成以(lon, lat)为左下角, 边长为size的正方形环
+func square(lon, lat, size float64) [][2]float64 {
+	return [][2]float64{{lon, lat}, {lon + size, lat}, {lon + size, lat + size}, {lon, lat + size}, {lon, lat}}
+}
+
+func TestSectorContains(t *testing.T) {
+	sector := newSector(&config.SectorData{
+		Callsign: "ZSHA_CTR",
+		Polygons: []config.SectorPolygon{
+			{square(120, 30, 4), square(121, 31, 1)},
+			{square(130, 30, 2)},
+		},
+	})
+	tests := []struct {
+		name     string
+		position Position
+		want     bool
+	}{
+		{"inside", Position{Latitude: 30.5, Longitude: 120.5}, true},
+		{"outside", Position{Latitude: 29, Longitude: 120.5}, false},
+		{"on outer edge", Position{Latitude: 30, Longitude: 122}, true},
+		{"on outer vertex", Position{Latitude: 34, Longitude: 124}, true},
+		{"inside hole", Position{Latitude: 31.5, Longitude: 121.5}, false},
+		{"on hole edge", Position{Latitude: 31, Longitude: 121.5}, true},
+		{"second polygon", Position{Latitude: 31, Longitude: 131}, true},
+		{"between polygons", Position{Latitude: 31, Longitude: 127}, false},
+		{"invalid position", Position{}, false},
+	}
+	for _, test := range tests {
+		if result := sector.Contains(test.position); result != test.want {
+			t.Errorf("%s: Contains(%v) = %v, want %v", test.name, test.position, result, test.want)
+		}
+	}
+}
+
+func TestSectorOwnedBy(t *testing.T) {
+	sector := &Sector{Callsign: "ZSHA_CTR"}
+	tests := []struct {
+		callsign string
+		want     bool
+	}{
+		{"ZSHA_CTR", true},
+		{"ZSHA_1_CTR", true},
+		{"ZSHA_N_1_CTR", true},
+		{"ZSHA_APP", false},
+		{"ZSHA_1_APP", false},
+		{"ZSPD_CTR", false},
+		{"ZSHACTR", false},
+	}
+	for _, test := range tests {
+		if result := sector.OwnedBy(test.callsign); result != test.want {
+			t.Errorf("OwnedBy(%q) = %v, want %v", test.callsign, result, test.want)
+		}
+	}
+}
+
+func TestSectorMapCovers(t *testing.T) {
+	sectorMap := NewSectorMap([]*config.SectorData{
+		{Callsign: "ZSHA_CTR", Polygons: []config.SectorPolygon{{square(120, 30, 4)}}},
+		{Callsign: "ZBPE_CTR", Polygons: []config.SectorPolygon{{square(110, 38, 4)}}},
+	})
+	position := Position{Latitude: 31, Longitude: 121}
+	if !sectorMap.Covers("ZSHA_N_CTR", position) {
+		t.Error("ZSHA_N_CTR should cover position inside ZSHA_CTR")
+	}
+	if sectorMap.Covers("ZBPE_CTR", position) {
+		t.Error("ZBPE_CTR should not cover position inside ZSHA_CTR")
+	}
+	if sectors := sectorMap.FindSectors(position); len(sectors) != 1 || sectors[0].Callsign != "ZSHA_CTR" {
+		t.Errorf("FindSectors returned %v, want only ZSHA_CTR", sectors)
+	}
+}
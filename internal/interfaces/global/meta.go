@@ -19,11 +19,29 @@ const (
 	DefaultFilePermissions     = 0644
 	DefaultDirectoryPermission = 0755
 
-	AirportDataFileUrl              = "https://raw.githubusercontent.com/Flyleague-Collection/SimpleFSD/refs/heads/main/data/airport.json"
-	EmailVerifyTemplateFileUrl      = "https://raw.githubusercontent.com/Flyleague-Collection/SimpleFSD/refs/heads/main/template/email_verify.template"
-	ATCRatingChangeTemplateFileUrl  = "https://raw.githubusercontent.com/Flyleague-Collection/SimpleFSD/refs/heads/main/template/atc_rating_change.template"
-	PermissionChangeTemplateFileUrl = "https://raw.githubusercontent.com/Flyleague-Collection/SimpleFSD/refs/heads/main/template/permission_change.template"
-	KickedFromServerTemplateFileUrl = "https://raw.githubusercontent.com/Flyleague-Collection/SimpleFSD/refs/heads/main/template/kicked_from_server.template"
+	// 默认文件从与当前版本对应的发布标签下载, 避免主分支上的新版本文件与可执行文件不兼容
+	releaseFileUrl = "https://raw.githubusercontent.com/Flyleague-Collection/SimpleFSD/refs/tags/v" + AppVersion + "/"
+
+	AirportDataFileUrl              = releaseFileUrl + "data/airport.json"
+	EmailVerifyTemplateFileUrl      = releaseFileUrl + "template/email_verify.template"
+	ATCRatingChangeTemplateFileUrl  = releaseFileUrl + "template/atc_rating_change.template"
+	PermissionChangeTemplateFileUrl = releaseFileUrl + "template/permission_change.template"
+	KickedFromServerTemplateFileUrl = releaseFileUrl + "template/kicked_from_server.template"
+	HelpRequestTemplateFileUrl      = releaseFileUrl + "template/help_request.template"
+	TicketUpdateTemplateFileUrl     = releaseFileUrl + "template/ticket_update.template"
+	PasswordResetTemplateFileUrl    = releaseFileUrl + "template/password_reset.template"
+	InactivityTemplateFileUrl       = releaseFileUrl + "template/inactivity.template"
+
+	// 内置在可执行文件中的默认文件路径
+	AirportDataBundledFile              = "data/airport.json"
+	EmailVerifyTemplateBundledFile      = "template/email_verify.template"
+	ATCRatingChangeTemplateBundledFile  = "template/atc_rating_change.template"
+	PermissionChangeTemplateBundledFile = "template/permission_change.template"
+	KickedFromServerTemplateBundledFile = "template/kicked_from_server.template"
+	HelpRequestTemplateBundledFile      = "template/help_request.template"
+	TicketUpdateTemplateBundledFile     = "template/ticket_update.template"
+	PasswordResetTemplateBundledFile    = "template/password_reset.template"
+	InactivityTemplateBundledFile       = "template/inactivity.template"
 
 	FSDServerName      = "SERVER"
 	FSDDisconnectDelay = time.Minute
@@ -0,0 +1,31 @@
+// Package operation
+package operation
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrApiKeyNotFound = errors.New("api key not found")
+)
+
+// ApiKeyOperationInterface API密钥操作接口定义
+type ApiKeyOperationInterface interface {
+	// NewApiKey 为用户创建新的API密钥(只是创建, 没有写入数据库), plain为密钥明文, 只在创建时返回一次
+	NewApiKey(user *User, name string, permission PermissionSet, rateLimit int, expiresAt *time.Time) (key *ApiKey, plain string)
+	// AddApiKey 写入API密钥, 当err为nil时写入成功
+	AddApiKey(key *ApiKey) (err error)
+	// GetApiKeyById 通过主键Id获取API密钥, 当err为nil时返回值key有效
+	GetApiKeyById(id uint) (key *ApiKey, err error)
+	// GetApiKeyByPlain 通过密钥明文获取API密钥, 当err为nil时返回值key有效
+	GetApiKeyByPlain(plain string) (key *ApiKey, err error)
+	// GetApiKeys 获取API密钥, cid为0时获取所有用户的密钥, 当err为nil时返回值keys有效
+	GetApiKeys(cid int) (keys []*ApiKey, err error)
+	// CountActiveApiKeys 统计用户未撤销且未过期的密钥数量
+	CountActiveApiKeys(cid int) (count int64, err error)
+	// TouchApiKey 更新密钥的最后使用时间与地址, 当err为nil时更新成功
+	TouchApiKey(key *ApiKey, ip string) (err error)
+	// RevokeApiKey 撤销API密钥, 当err为nil时撤销成功
+	RevokeApiKey(key *ApiKey) (err error)
+}
@@ -0,0 +1,30 @@
+// Package operation
+package operation
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrAtcBookingNotFound    = errors.New("atc booking not found")
+	ErrAtcBookingTimeInvalid = errors.New("atc booking time invalid")
+	ErrAtcBookingOverlap     = errors.New("facility already booked in this time range")
+	ErrAtcBookingUserOverlap = errors.New("you already have a booking in this time range")
+)
+
+// AtcBookingOperationInterface 管制席位预约操作接口定义
+type AtcBookingOperationInterface interface {
+	// NewAtcBooking 创建新的管制席位预约(只是创建, 没有写入数据库)
+	NewAtcBooking(user *User, callsign string, startTime, endTime time.Time) (booking *AtcBooking)
+	// AddAtcBooking 写入管制席位预约, 写入前会检查同席位和同用户的时间冲突, 当err为nil时写入成功
+	AddAtcBooking(booking *AtcBooking) (err error)
+	// GetAtcBookingById 通过预约Id获取预约, 当err为nil时返回值booking有效
+	GetAtcBookingById(id uint) (booking *AtcBooking, err error)
+	// GetAtcBookings 获取与指定时间段有重叠的所有预约, 当err为nil时返回值bookings有效
+	GetAtcBookings(startTime, endTime time.Time) (bookings []*AtcBooking, err error)
+	// GetActiveAtcBooking 获取指定席位在指定时刻生效的预约, 当err为nil时返回值booking有效
+	GetActiveAtcBooking(callsign string, moment time.Time) (booking *AtcBooking, err error)
+	// DeleteAtcBooking 删除预约, 当err为nil时删除成功
+	DeleteAtcBooking(booking *AtcBooking) (err error)
+}
@@ -8,13 +8,44 @@ const (
 	UserPermissionGrant  EventType = "UserPermissionGrant"
 	UserPermissionRevoke EventType = "UserPermissionRevoke"
 	UserRatingChange     EventType = "UserRatingChange"
+	UserPasswordReset    EventType = "UserPasswordReset"
+	UserTotpEnabled      EventType = "UserTotpEnabled"
+	UserTotpDisabled     EventType = "UserTotpDisabled"
 	ActivityCreated      EventType = "ActivityCreated"
 	ActivityDeleted      EventType = "ActivityDeleted"
 	ActivityUpdated      EventType = "ActivityUpdated"
 	TicketCreated        EventType = "TicketCreated"
 	TicketReply          EventType = "TicketReply"
+	TicketAssigned       EventType = "TicketAssigned"
+	TicketClosed         EventType = "TicketClosed"
+	OAuthClientCreated   EventType = "OAuthClientCreated"
+	OAuthClientDeleted   EventType = "OAuthClientDeleted"
+	RoleCreated          EventType = "RoleCreated"
+	RoleUpdated          EventType = "RoleUpdated"
+	RoleDeleted          EventType = "RoleDeleted"
+	UserRoleAssigned     EventType = "UserRoleAssigned"
+	UserRoleRevoked      EventType = "UserRoleRevoked"
+	ApiKeyCreated        EventType = "ApiKeyCreated"
+	ApiKeyRevoked        EventType = "ApiKeyRevoked"
+	TrainingRequested    EventType = "TrainingRequested"
+	TrainingAssigned     EventType = "TrainingAssigned"
+	TrainingReported     EventType = "TrainingReported"
+	TrainingClosed       EventType = "TrainingClosed"
+	SoloGranted          EventType = "SoloGranted"
+	SoloRevoked          EventType = "SoloRevoked"
+	EndorsementGranted   EventType = "EndorsementGranted"
+	EndorsementRevoked   EventType = "EndorsementRevoked"
+	ControllerWarned     EventType = "ControllerWarned"
+	ControllerInactive   EventType = "ControllerInactive"
 	ClientKicked         EventType = "ClientKicked"
 	ClientMessage        EventType = "ClientMessage"
+	AtcBookingCreated    EventType = "AtcBookingCreated"
+	AtcBookingDeleted    EventType = "AtcBookingDeleted"
+	ServerConfigReloaded EventType = "ServerConfigReloaded"
+	ServerDrainStarted   EventType = "ServerDrainStarted"
+	UserBanned           EventType = "UserBanned"
+	UserUnbanned         EventType = "UserUnbanned"
+	SupervisorQuery      EventType = "SupervisorQuery"
 )
 
 type AuditLogOperationInterface interface {
@@ -0,0 +1,34 @@
+// Package operation
+package operation
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	InactivityStatusWarned   = iota // 已发出警告, 处于宽限期内
+	InactivityStatusResolved        // 宽限期内已达到管制时长要求或不再需要考核
+	InactivityStatusInactive        // 宽限期结束仍未达标, 已标记为不活跃
+	InactivityStatusDemoted         // 宽限期结束仍未达标, 已降级
+)
+
+var (
+	ErrControllerInactivityExists  = errors.New("controller inactivity already exists")
+	ErrControllerInactivityChanged = errors.New("controller inactivity status has been changed")
+)
+
+// ControllerInactivityOperationInterface 管制员活跃度考核记录操作接口定义
+type ControllerInactivityOperationInterface interface {
+	// NewControllerInactivity 创建新的不活跃记录(只是创建, 没有写入数据库)
+	NewControllerInactivity(user *User, periodStart, periodEnd time.Time, atcTime int, graceUntil time.Time) (inactivity *ControllerInactivity)
+	// AddControllerInactivity 写入不活跃记录, 同一用户同一考核周期已有记录时返回 ErrControllerInactivityExists, 当err为nil时写入成功
+	AddControllerInactivity(inactivity *ControllerInactivity) (err error)
+	// GetControllerInactivities 获取指定考核周期的全部不活跃记录, 当err为nil时返回值inactivities有效
+	GetControllerInactivities(periodStart time.Time) (inactivities []*ControllerInactivity, err error)
+	// GetWarnedControllerInactivities 获取所有仍处于宽限期的不活跃记录, 当err为nil时返回值inactivities有效
+	GetWarnedControllerInactivities() (inactivities []*ControllerInactivity, err error)
+	// UpdateControllerInactivityStatus 更新不活跃记录的状态并记录处理时间, 数据库中的状态已不是inactivity.Status时返回 ErrControllerInactivityChanged,
+	// 多个实例同时处理同一记录时只有一个能够成功, 当err为nil时更新成功
+	UpdateControllerInactivityStatus(inactivity *ControllerInactivity, status int) (err error)
+}
@@ -0,0 +1,18 @@
+// Package operation
+package operation
+
+import (
+	"errors"
+)
+
+var (
+	ErrExternalIdentityNotFound = errors.New("external identity not found")
+)
+
+// ExternalIdentityOperationInterface 外部身份关联操作接口定义
+type ExternalIdentityOperationInterface interface {
+	// GetExternalIdentity 通过上游身份提供者与上游用户标识获取关联, 当err为nil时返回值identity有效
+	GetExternalIdentity(issuer string, subject string) (identity *ExternalIdentity, err error)
+	// AddExternalIdentity 将上游用户关联到本地用户, 当err为nil时写入成功
+	AddExternalIdentity(issuer string, subject string, user *User) (identity *ExternalIdentity, err error)
+}
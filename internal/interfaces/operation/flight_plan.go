@@ -23,6 +23,8 @@ type FlightPlanOperationInterface interface {
 	UpdateFlightPlanData(flightPlan *FlightPlan, flightPlanData []string)
 	// UpdateFlightPlan 更新飞行计划(提交数据库), 当err为nil时更新成功
 	UpdateFlightPlan(flightPlan *FlightPlan, flightPlanData []string, atcEdit bool) (err error)
+	// SaveFlightPlan 将内存中的飞行计划提交数据库, 当err为nil时保存成功
+	SaveFlightPlan(flightPlan *FlightPlan) (err error)
 	// UpdateCruiseAltitude 更新巡航高度, 当err为nil时更新成功
 	UpdateCruiseAltitude(flightPlan *FlightPlan, cruiseAltitude string) (err error)
 	// ToString 将飞行计划转换为ES和Swift可识别的形式
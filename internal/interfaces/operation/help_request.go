@@ -0,0 +1,34 @@
+// Package operation
+package operation
+
+import (
+	"errors"
+)
+
+const (
+	HelpRequestOpen    = iota // 等待管理员处理
+	HelpRequestClaimed        // 已被管理员认领
+	HelpRequestClosed         // 已关闭
+)
+
+var (
+	ErrHelpRequestNotFound = errors.New("help request not found")
+	ErrHelpRequestClaimed  = errors.New("help request already claimed")
+	ErrHelpRequestClosed   = errors.New("help request already closed")
+)
+
+// HelpRequestOperationInterface 求助请求操作接口定义
+type HelpRequestOperationInterface interface {
+	// NewHelpRequest 创建新的求助请求(只是创建, 没有写入数据库)
+	NewHelpRequest(cid int, callsign string, message string) (request *HelpRequest)
+	// AddHelpRequest 写入求助请求, 当err为nil时写入成功
+	AddHelpRequest(request *HelpRequest) (err error)
+	// GetHelpRequestById 通过Id获取求助请求, 当err为nil时返回值request有效
+	GetHelpRequestById(id uint) (request *HelpRequest, err error)
+	// GetUnclosedHelpRequests 获取所有未关闭的求助请求, 当err为nil时返回值requests有效
+	GetUnclosedHelpRequests() (requests []*HelpRequest, err error)
+	// ClaimHelpRequest 认领求助请求, 已被认领或已关闭时返回错误, 当err为nil时认领成功
+	ClaimHelpRequest(request *HelpRequest, cid int) (err error)
+	// CloseHelpRequest 关闭求助请求, 已关闭时返回错误, 当err为nil时关闭成功
+	CloseHelpRequest(request *HelpRequest) (err error)
+}
@@ -1,6 +1,13 @@
 // Package operation
 package operation
 
+import (
+	"errors"
+	"time"
+)
+
+var ErrHistoryNotFound = errors.New("history not found")
+
 // HistoryOperationInterface 联飞记录操作接口定义
 type HistoryOperationInterface interface {
 	// NewHistory 创建新联飞记录
@@ -9,8 +16,12 @@ type HistoryOperationInterface interface {
 	SaveHistory(history *History) (err error)
 	// EndRecordAndSaveHistory 结束联飞记录并保存到数据库, 当err为nil时保存成功
 	EndRecordAndSaveHistory(history *History) (err error)
+	// GetHistoryById 通过Id获取联飞记录, 当err为nil时返回值history有效
+	GetHistoryById(id uint) (history *History, err error)
 	// GetUserHistory 获取用户最近十次的连线记录, 当err为nil时返回值userHistory有效
 	GetUserHistory(cid int) (userHistory *UserHistory, err error)
+	// GetAtcTimeBetween 统计与[start, end)有重叠的管制联飞记录, 只计入落在该区间内的部分, 返回以cid为键的管制时长(秒), 当err为nil时返回值atcTimes有效
+	GetAtcTimeBetween(start, end time.Time) (atcTimes map[int]int, err error)
 }
 
 type UserHistory struct {
@@ -17,14 +17,40 @@ type User struct {
 	Permission      int64            `gorm:"default:0" json:"permission"`
 	TotalPilotTime  int              `gorm:"default:0" json:"total_pilot_time"`
 	TotalAtcTime    int              `gorm:"default:0" json:"total_atc_time"`
+	BannedUntil     *time.Time       `gorm:"default:null" json:"banned_until"`
+	BanReason       string           `gorm:"size:128;not null;default:''" json:"ban_reason"`
+	TotpSecret      string           `gorm:"size:64;not null;default:''" json:"-"`
+	TotpEnabled     bool             `gorm:"not null;default:false" json:"totp_enabled"`
+	TotpLastCounter int64            `gorm:"not null;default:0" json:"-"`
+	RecoveryCodes   string           `gorm:"type:text" json:"-"`
 	FlightPlans     []*FlightPlan    `gorm:"foreignKey:Cid;references:Cid" json:"-"`
 	OnlineHistories []*History       `gorm:"foreignKey:Cid;references:Cid" json:"-"`
 	ActivityAtc     []*ActivityATC   `gorm:"foreignKey:Cid;references:Cid" json:"-"`
 	ActivityPilot   []*ActivityPilot `gorm:"foreignKey:Cid;references:Cid" json:"-"`
+	AtcBookings     []*AtcBooking    `gorm:"foreignKey:Cid;references:Cid" json:"-"`
+	Tickets         []*Ticket        `gorm:"foreignKey:Cid;references:Cid" json:"-"`
+	Roles           []*UserRole      `gorm:"foreignKey:Cid;references:Cid" json:"roles,omitempty"`
 	CreatedAt       time.Time        `json:"-"`
 	UpdatedAt       time.Time        `json:"-"`
 }
 
+// Banned 用户是否处于临时封禁期间
+func (user *User) Banned() bool {
+	return user.BannedUntil != nil && user.BannedUntil.After(time.Now())
+}
+
+// EffectivePermission 用户的有效权限, 即直接授予的权限与所有未过期角色权限的并集
+// 需要查询用户时预加载了角色(Roles)才能得到完整结果
+func (user *User) EffectivePermission() PermissionSet {
+	permission := NewPermissionSet(user.Permission)
+	for _, userRole := range user.Roles {
+		if userRole.Active() && userRole.Role != nil {
+			permission.Merge(userRole.Role.PermissionSet())
+		}
+	}
+	return permission
+}
+
 type FlightPlan struct {
 	ID               uint      `gorm:"primarykey" json:"id"`
 	Cid              int       `gorm:"index;not null" json:"cid"`
@@ -51,7 +77,7 @@ type FlightPlan struct {
 }
 
 type History struct {
-	ID         uint      `gorm:"primarykey" json:"-"`
+	ID         uint      `gorm:"primarykey" json:"id"`
 	Cid        int       `gorm:"index;not null" json:"-"`
 	Callsign   string    `gorm:"size:16;index;not null" json:"callsign"`
 	StartTime  time.Time `gorm:"not null" json:"start_time"`
@@ -62,6 +88,20 @@ type History struct {
 	UpdatedAt  time.Time `json:"-"`
 }
 
+// TimeBetween 返回连线记录落在[start, end)之间的时长(秒), end晚于EndTime时以EndTime为准
+func (history *History) TimeBetween(start, end time.Time) int {
+	if history.StartTime.After(start) {
+		start = history.StartTime
+	}
+	if history.EndTime.Before(end) {
+		end = history.EndTime
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return int(end.Sub(start).Seconds())
+}
+
 type Activity struct {
 	ID               uint                `gorm:"primarykey" json:"id"`
 	Publisher        int                 `gorm:"index;not null" json:"publisher"`
@@ -200,3 +240,228 @@ type ChangeDetail struct {
 	OldValue string `json:"old_value"`
 	NewValue string `json:"new_value"`
 }
+
+type AtcBooking struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	Cid       int       `gorm:"index;not null" json:"cid"`
+	Callsign  string    `gorm:"size:16;index;not null" json:"callsign"`
+	StartTime time.Time `gorm:"index;not null" json:"start_time"`
+	EndTime   time.Time `gorm:"index;not null" json:"end_time"`
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"-"`
+}
+
+// AtcBookingLock 席位预约锁, 写入预约前锁定对应席位的行, 串行化同一席位的并发预约
+type AtcBookingLock struct {
+	ID       uint   `gorm:"primarykey" json:"id"`
+	Callsign string `gorm:"size:16;uniqueIndex;not null" json:"callsign"`
+}
+
+type HelpRequest struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	Cid       int        `gorm:"index;not null" json:"cid"`
+	Callsign  string     `gorm:"size:16;not null" json:"callsign"`
+	Message   string     `gorm:"size:512;not null" json:"message"`
+	Status    int        `gorm:"index;not null;default:0" json:"status"`
+	ClaimedBy int        `gorm:"default:0" json:"claimed_by"`
+	ClaimedAt *time.Time `json:"claimed_at"`
+	ClosedAt  *time.Time `json:"closed_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"-"`
+}
+
+type Ticket struct {
+	ID         uint             `gorm:"primarykey" json:"id"`
+	Cid        int              `gorm:"index;not null" json:"cid"`
+	Title      string           `gorm:"size:128;not null" json:"title"`
+	Content    string           `gorm:"type:text;not null" json:"content"`
+	Status     int              `gorm:"index;not null;default:0" json:"status"`
+	AssignedTo int              `gorm:"index;default:0" json:"assigned_to"`
+	ClosedAt   *time.Time       `json:"closed_at"`
+	Replies    []*TicketMessage `gorm:"foreignKey:TicketId;references:ID" json:"replies,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+	UpdatedAt  time.Time        `json:"updated_at"`
+}
+
+type TicketMessage struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	TicketId  uint      `gorm:"index;not null" json:"ticket_id"`
+	Cid       int       `gorm:"index;not null" json:"cid"`
+	Content   string    `gorm:"type:text;not null" json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName 工单回复表, 类型名避免与审计事件 TicketReply 冲突
+func (TicketMessage) TableName() string {
+	return "ticket_replies"
+}
+
+type UserSession struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	Jti         string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	Cid         int        `gorm:"index;not null" json:"cid"`
+	Ip          string     `gorm:"size:64;not null" json:"ip"`
+	UserAgent   string     `gorm:"size:256;not null" json:"user_agent"`
+	LastUsedAt  time.Time  `gorm:"not null" json:"last_used_at"`
+	ExpiresAt   time.Time  `gorm:"index;not null" json:"expires_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	TotpPending bool       `gorm:"not null;default:false" json:"totp_pending"` // 需要启用两步验证但尚未启用, 只能访问两步验证相关接口
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Active 会话是否仍然有效
+func (session *UserSession) Active() bool {
+	return session.RevokedAt == nil && session.ExpiresAt.After(time.Now())
+}
+
+type OAuthClient struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	ClientId     string    `gorm:"size:64;uniqueIndex;not null" json:"client_id"`
+	ClientSecret string    `gorm:"size:64;not null;default:''" json:"-"`
+	Name         string    `gorm:"size:64;not null" json:"name"`
+	RedirectUris string    `gorm:"type:text;not null" json:"redirect_uris"` // 允许的回调地址, 以空格分隔
+	Scopes       string    `gorm:"size:256;not null" json:"scopes"`         // 允许申请的权限范围, 以空格分隔
+	Confidential bool      `gorm:"not null;default:true" json:"confidential"`
+	CreatedBy    int       `gorm:"not null" json:"created_by"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type ExternalIdentity struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	Issuer    string    `gorm:"size:191;uniqueIndex:idx_external_identity;not null" json:"issuer"`
+	Subject   string    `gorm:"size:191;uniqueIndex:idx_external_identity;not null" json:"subject"`
+	Cid       int       `gorm:"index;not null" json:"cid"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Role struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	Name        string    `gorm:"size:64;uniqueIndex;not null" json:"name"`
+	Description string    `gorm:"size:256;not null;default:''" json:"description"`
+	Permissions string    `gorm:"type:text;not null" json:"permissions"` // 权限节点名称, 以逗号分隔
+	CreatedBy   int       `gorm:"not null" json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// PermissionSet 角色包含的权限节点, 已经不存在的节点会被忽略
+func (role *Role) PermissionSet() PermissionSet {
+	permission, _ := ParsePermissionNodes(role.Permissions, true)
+	return permission
+}
+
+type UserRole struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	Cid       int        `gorm:"uniqueIndex:idx_user_role;not null" json:"cid"`
+	RoleId    uint       `gorm:"uniqueIndex:idx_user_role;not null" json:"role_id"`
+	Role      *Role      `gorm:"foreignKey:RoleId;references:ID" json:"role,omitempty"`
+	ExpiresAt *time.Time `gorm:"index" json:"expires_at"` // 为空表示永久有效
+	GrantedBy int        `gorm:"not null" json:"granted_by"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Active 角色授予是否仍然有效
+func (userRole *UserRole) Active() bool {
+	return userRole.ExpiresAt == nil || userRole.ExpiresAt.After(time.Now())
+}
+
+type ApiKey struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	Cid         int        `gorm:"index;not null" json:"cid"`
+	Name        string     `gorm:"size:64;not null" json:"name"`
+	Prefix      string     `gorm:"size:16;not null" json:"prefix"` // 密钥明文的前几位, 用于识别密钥
+	KeyHash     string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	Permissions string     `gorm:"type:text;not null" json:"permissions"` // 权限范围, 权限节点名称以逗号分隔
+	RateLimit   int        `gorm:"not null;default:0" json:"rate_limit"`  // 每个限流窗口内允许的请求数, 0表示使用默认值
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	LastUsedIp  string     `gorm:"size:64;not null;default:''" json:"last_used_ip"`
+	ExpiresAt   *time.Time `json:"expires_at"` // 为空表示永不过期
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Active 密钥是否仍然有效
+func (key *ApiKey) Active() bool {
+	return key.RevokedAt == nil && (key.ExpiresAt == nil || key.ExpiresAt.After(time.Now()))
+}
+
+// PermissionSet 密钥的权限范围, 已经不存在的节点会被忽略
+func (key *ApiKey) PermissionSet() PermissionSet {
+	permission, _ := ParsePermissionNodes(key.Permissions, true)
+	return permission
+}
+
+type TrainingRequest struct {
+	ID           uint               `gorm:"primarykey" json:"id"`
+	Cid          int                `gorm:"index;not null" json:"cid"`
+	TargetRating int                `gorm:"not null" json:"target_rating"`
+	Remark       string             `gorm:"type:text;not null" json:"remark"`
+	Status       int                `gorm:"index;not null;default:0" json:"status"`
+	MentorCid    int                `gorm:"index;not null;default:0" json:"mentor_cid"`
+	ClosedAt     *time.Time         `json:"closed_at"`
+	Reports      []*TrainingReport  `gorm:"foreignKey:TrainingId;references:ID" json:"reports,omitempty"`
+	Solos        []*SoloEndorsement `gorm:"foreignKey:TrainingId;references:ID" json:"solos,omitempty"`
+	CreatedAt    time.Time          `json:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at"`
+}
+
+// Closed 培训申请是否已经结束
+func (request *TrainingRequest) Closed() bool {
+	return request.Status == TrainingStatusCompleted || request.Status == TrainingStatusCancelled
+}
+
+type TrainingReport struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	TrainingId uint      `gorm:"index;not null" json:"training_id"`
+	MentorCid  int       `gorm:"index;not null" json:"mentor_cid"`
+	HistoryId  uint      `gorm:"index;not null" json:"history_id"`
+	History    *History  `gorm:"foreignKey:HistoryId;references:ID" json:"history,omitempty"`
+	Content    string    `gorm:"type:text;not null" json:"content"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type SoloEndorsement struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	TrainingId uint       `gorm:"index;not null" json:"training_id"`
+	Cid        int        `gorm:"index;not null" json:"cid"`
+	Facility   int        `gorm:"not null" json:"facility"` // 允许登录的席位编号, 与连线协议中的席位编号一致
+	ExpiresAt  time.Time  `gorm:"index;not null" json:"expires_at"`
+	GrantedBy  int        `gorm:"not null" json:"granted_by"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Active 单飞授权是否仍然有效
+func (solo *SoloEndorsement) Active() bool {
+	return solo.RevokedAt == nil && solo.ExpiresAt.After(time.Now())
+}
+
+type PositionEndorsement struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	Cid       int        `gorm:"uniqueIndex:idx_position_endorsement;not null" json:"cid"`
+	Pattern   string     `gorm:"size:32;uniqueIndex:idx_position_endorsement;not null" json:"pattern"` // 允许登录的席位呼号模式, 比如ZBAA_*TWR
+	ExpiresAt *time.Time `gorm:"index" json:"expires_at"`                                              // 为空表示永久有效
+	GrantedBy int        `gorm:"not null" json:"granted_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Active 席位授权是否仍然有效
+func (endorsement *PositionEndorsement) Active() bool {
+	return endorsement.ExpiresAt == nil || endorsement.ExpiresAt.After(time.Now())
+}
+
+type ControllerInactivity struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	Cid         int        `gorm:"uniqueIndex:idx_controller_inactivity;not null" json:"cid"`
+	PeriodStart time.Time  `gorm:"uniqueIndex:idx_controller_inactivity;not null" json:"period_start"`
+	PeriodEnd   time.Time  `gorm:"not null" json:"period_end"`
+	AtcTime     int        `gorm:"not null" json:"atc_time"` // 考核周期内的管制时长, 单位为秒
+	Status      int        `gorm:"index;not null" json:"status"`
+	GraceUntil  time.Time  `gorm:"not null" json:"grace_until"` // 宽限期结束时间
+	OldRating   int        `gorm:"not null" json:"old_rating"`  // 发出警告时的管制权限
+	HandledAt   *time.Time `json:"handled_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,29 @@
+// Package operation
+package operation
+
+import (
+	"errors"
+)
+
+var (
+	ErrOAuthClientNotFound = errors.New("oauth client not found")
+)
+
+// OAuthClientOperationInterface 第三方应用操作接口定义
+type OAuthClientOperationInterface interface {
+	// NewOAuthClient 创建新的第三方应用并生成客户端Id与客户端密钥(只是创建, 没有写入数据库)
+	// confidential为false时不生成客户端密钥, secret为客户端密钥明文, 只在创建时返回一次
+	NewOAuthClient(user *User, name string, redirectUris string, scopes string, confidential bool) (client *OAuthClient, secret string)
+	// AddOAuthClient 写入第三方应用, 当err为nil时写入成功
+	AddOAuthClient(client *OAuthClient) (err error)
+	// GetOAuthClientById 通过主键Id获取第三方应用, 当err为nil时返回值client有效
+	GetOAuthClientById(id uint) (client *OAuthClient, err error)
+	// GetOAuthClientByClientId 通过客户端Id获取第三方应用, 当err为nil时返回值client有效
+	GetOAuthClientByClientId(clientId string) (client *OAuthClient, err error)
+	// GetOAuthClients 获取所有第三方应用, 当err为nil时返回值clients有效
+	GetOAuthClients() (clients []*OAuthClient, err error)
+	// DeleteOAuthClient 删除第三方应用, 当err为nil时删除成功
+	DeleteOAuthClient(client *OAuthClient) (err error)
+	// VerifyOAuthClientSecret 验证客户端密钥是否正确, pass为true表示验证通过
+	VerifyOAuthClientSecret(client *OAuthClient, secret string) (pass bool)
+}
@@ -2,11 +2,22 @@
 package operation
 
 type DatabaseOperations struct {
-	userOperation       UserOperationInterface
-	flightPlanOperation FlightPlanOperationInterface
-	historyOperation    HistoryOperationInterface
-	activityOperation   ActivityOperationInterface
-	auditLogOperation   AuditLogOperationInterface
+	userOperation             UserOperationInterface
+	flightPlanOperation       FlightPlanOperationInterface
+	historyOperation          HistoryOperationInterface
+	activityOperation         ActivityOperationInterface
+	auditLogOperation         AuditLogOperationInterface
+	atcBookingOperation       AtcBookingOperationInterface
+	helpRequestOperation      HelpRequestOperationInterface
+	ticketOperation           TicketOperationInterface
+	userSessionOperation      UserSessionOperationInterface
+	oauthClientOperation      OAuthClientOperationInterface
+	externalIdentityOperation ExternalIdentityOperationInterface
+	roleOperation             RoleOperationInterface
+	apiKeyOperation           ApiKeyOperationInterface
+	trainingOperation         TrainingOperationInterface
+	endorsementOperation      PositionEndorsementOperationInterface
+	inactivityOperation       ControllerInactivityOperationInterface
 }
 
 func NewDatabaseOperations(
@@ -15,13 +26,35 @@ func NewDatabaseOperations(
 	historyOperation HistoryOperationInterface,
 	activityOperation ActivityOperationInterface,
 	auditLogOperation AuditLogOperationInterface,
+	atcBookingOperation AtcBookingOperationInterface,
+	helpRequestOperation HelpRequestOperationInterface,
+	ticketOperation TicketOperationInterface,
+	userSessionOperation UserSessionOperationInterface,
+	oauthClientOperation OAuthClientOperationInterface,
+	externalIdentityOperation ExternalIdentityOperationInterface,
+	roleOperation RoleOperationInterface,
+	apiKeyOperation ApiKeyOperationInterface,
+	trainingOperation TrainingOperationInterface,
+	endorsementOperation PositionEndorsementOperationInterface,
+	inactivityOperation ControllerInactivityOperationInterface,
 ) *DatabaseOperations {
 	return &DatabaseOperations{
-		userOperation:       userOperation,
-		flightPlanOperation: flightPlanOperation,
-		historyOperation:    historyOperation,
-		activityOperation:   activityOperation,
-		auditLogOperation:   auditLogOperation,
+		userOperation:             userOperation,
+		flightPlanOperation:       flightPlanOperation,
+		historyOperation:          historyOperation,
+		activityOperation:         activityOperation,
+		auditLogOperation:         auditLogOperation,
+		atcBookingOperation:       atcBookingOperation,
+		helpRequestOperation:      helpRequestOperation,
+		ticketOperation:           ticketOperation,
+		userSessionOperation:      userSessionOperation,
+		oauthClientOperation:      oauthClientOperation,
+		externalIdentityOperation: externalIdentityOperation,
+		roleOperation:             roleOperation,
+		apiKeyOperation:           apiKeyOperation,
+		trainingOperation:         trainingOperation,
+		endorsementOperation:      endorsementOperation,
+		inactivityOperation:       inactivityOperation,
 	}
 }
 
@@ -44,3 +77,47 @@ func (db *DatabaseOperations) ActivityOperation() ActivityOperationInterface {
 func (db *DatabaseOperations) AuditLogOperation() AuditLogOperationInterface {
 	return db.auditLogOperation
 }
+
+func (db *DatabaseOperations) AtcBookingOperation() AtcBookingOperationInterface {
+	return db.atcBookingOperation
+}
+
+func (db *DatabaseOperations) HelpRequestOperation() HelpRequestOperationInterface {
+	return db.helpRequestOperation
+}
+
+func (db *DatabaseOperations) TicketOperation() TicketOperationInterface {
+	return db.ticketOperation
+}
+
+func (db *DatabaseOperations) UserSessionOperation() UserSessionOperationInterface {
+	return db.userSessionOperation
+}
+
+func (db *DatabaseOperations) OAuthClientOperation() OAuthClientOperationInterface {
+	return db.oauthClientOperation
+}
+
+func (db *DatabaseOperations) ExternalIdentityOperation() ExternalIdentityOperationInterface {
+	return db.externalIdentityOperation
+}
+
+func (db *DatabaseOperations) RoleOperation() RoleOperationInterface {
+	return db.roleOperation
+}
+
+func (db *DatabaseOperations) ApiKeyOperation() ApiKeyOperationInterface {
+	return db.apiKeyOperation
+}
+
+func (db *DatabaseOperations) TrainingOperation() TrainingOperationInterface {
+	return db.trainingOperation
+}
+
+func (db *DatabaseOperations) PositionEndorsementOperation() PositionEndorsementOperationInterface {
+	return db.endorsementOperation
+}
+
+func (db *DatabaseOperations) ControllerInactivityOperation() ControllerInactivityOperationInterface {
+	return db.inactivityOperation
+}
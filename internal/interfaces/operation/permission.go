@@ -1,11 +1,20 @@
 // Package operation
 package operation
 
-type Permission int64
+import (
+	"errors"
+	"math/bits"
+	"strings"
+)
+
+// ErrPermissionNodeNotExists 权限节点不存在
+var ErrPermissionNodeNotExists = errors.New("permission node does not exist")
+
+// Permission 权限节点编号, 节点数量不受64的限制
+type Permission uint
 
-// 权限节点上限是64, 超过64需要使用切片
 const (
-	AdminEntry Permission = 1 << iota
+	AdminEntry Permission = iota
 	UserShowList
 	UserGetProfile
 	UserSetPassword
@@ -21,12 +30,32 @@ const (
 	AuditLogShow
 	ClientSendMessage
 	ClientKill
+	AtcBookingManage
+	ServerConfigReload
+	ServerDrain
+	TicketShowList
+	TicketRespond
+	TicketManage
+	OAuthClientManage
+	RoleShowList
+	RoleManage
+	RoleAssign
+	ApiKeyCreate
+	ApiKeyManage
+	TrainingManage
+	EndorsementManage
+	ControllerActivityShow
+	permissionNodeCount // 节点总数, 新节点请添加在此之前
 )
 
+// LegacyPermissionNodes 用户表中 permission 位图字段能够表示的节点数量
+const LegacyPermissionNodes = 64
+
 var PermissionMap = map[string]Permission{
 	"AdminEntry":             AdminEntry,
 	"UserShowList":           UserShowList,
 	"UserGetProfile":         UserGetProfile,
+	"UserSetPassword":        UserSetPassword,
 	"UserEditBaseInfo":       UserEditBaseInfo,
 	"UserEditPermission":     UserEditPermission,
 	"UserEditRating":         UserEditRating,
@@ -36,23 +65,179 @@ var PermissionMap = map[string]Permission{
 	"ActivityEditState":      ActivityEditState,
 	"ActivityEditPilotState": ActivityEditPilotState,
 	"ActivityDelete":         ActivityDelete,
+	"AuditLogShow":           AuditLogShow,
 	"ClientSendMessage":      ClientSendMessage,
 	"ClientKill":             ClientKill,
+	"AtcBookingManage":       AtcBookingManage,
+	"ServerConfigReload":     ServerConfigReload,
+	"ServerDrain":            ServerDrain,
+	"TicketShowList":         TicketShowList,
+	"TicketRespond":          TicketRespond,
+	"TicketManage":           TicketManage,
+	"OAuthClientManage":      OAuthClientManage,
+	"RoleShowList":           RoleShowList,
+	"RoleManage":             RoleManage,
+	"RoleAssign":             RoleAssign,
+	"ApiKeyCreate":           ApiKeyCreate,
+	"ApiKeyManage":           ApiKeyManage,
+	"TrainingManage":         TrainingManage,
+	"EndorsementManage":      EndorsementManage,
+	"ControllerActivityShow": ControllerActivityShow,
+}
+
+var permissionNames = func() []string {
+	names := make([]string, permissionNodeCount)
+	for name, perm := range PermissionMap {
+		names[perm] = name
+	}
+	return names
+}()
+
+func (p Permission) IsValid() bool {
+	return p < permissionNodeCount
+}
+
+// Name 权限节点名称
+func (p Permission) Name() string {
+	if !p.IsValid() {
+		return ""
+	}
+	return permissionNames[p]
+}
+
+// PermissionSet 权限节点集合, 第i个节点对应第i/64个元素的第i%64位
+type PermissionSet []uint64
+
+// NewPermissionSet 从用户表中的权限位图创建权限集合
+func NewPermissionSet(legacy int64) PermissionSet {
+	if legacy == 0 {
+		return nil
+	}
+	return PermissionSet{uint64(legacy)}
+}
+
+// AllPermissions 包含所有权限节点的集合
+func AllPermissions() PermissionSet {
+	var set PermissionSet
+	for perm := Permission(0); perm < permissionNodeCount; perm++ {
+		set.Grant(perm)
+	}
+	return set
+}
+
+// ParsePermissionNodes 解析以逗号分隔的权限节点名称列表
+// 当 ignoreUnknown 为 true 时忽略无法识别的节点, 否则返回 ErrPermissionNodeNotExists
+func ParsePermissionNodes(nodes string, ignoreUnknown bool) (PermissionSet, error) {
+	var set PermissionSet
+	for _, name := range strings.Split(nodes, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		perm, ok := PermissionMap[name]
+		if !ok {
+			if ignoreUnknown {
+				continue
+			}
+			return nil, ErrPermissionNodeNotExists
+		}
+		set.Grant(perm)
+	}
+	return set, nil
+}
+
+// IsStaff 是否拥有任意管理权限
+func (s PermissionSet) IsStaff() bool {
+	for _, word := range s {
+		if word != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (s PermissionSet) HasPermission(perm Permission) bool {
+	index := int(perm / 64)
+	return index < len(s) && s[index]&(1<<(perm%64)) != 0
+}
+
+// Contains 当前集合是否包含 other 中的全部节点
+func (s PermissionSet) Contains(other PermissionSet) bool {
+	for i, word := range other {
+		var own uint64
+		if i < len(s) {
+			own = s[i]
+		}
+		if word&^own != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *PermissionSet) Grant(perm Permission) {
+	index := int(perm / 64)
+	for len(*s) <= index {
+		*s = append(*s, 0)
+	}
+	(*s)[index] |= 1 << (perm % 64)
+}
+
+func (s *PermissionSet) Revoke(perm Permission) {
+	index := int(perm / 64)
+	if index < len(*s) {
+		(*s)[index] &^= 1 << (perm % 64)
+	}
+}
+
+// Merge 将 other 中的节点合并到当前集合
+func (s *PermissionSet) Merge(other PermissionSet) {
+	for len(*s) < len(other) {
+		*s = append(*s, 0)
+	}
+	for i, word := range other {
+		(*s)[i] |= word
+	}
 }
 
-func (p *Permission) IsValid() bool {
-	maxPerm := ClientKill<<1 - 1 // 计算最大有效位
-	return *p >= 0 && *p <= maxPerm
+// Intersect 返回两个集合的交集
+func (s PermissionSet) Intersect(other PermissionSet) PermissionSet {
+	length := min(len(s), len(other))
+	result := make(PermissionSet, length)
+	for i := 0; i < length; i++ {
+		result[i] = s[i] & other[i]
+	}
+	return result
 }
 
-func (p *Permission) HasPermission(perm Permission) bool {
-	return *p&perm != 0
+// Legacy 转换为用户表中的权限位图, 存在编号不小于64的节点时 ok 为 false
+func (s PermissionSet) Legacy() (legacy int64, ok bool) {
+	for i := 1; i < len(s); i++ {
+		if s[i] != 0 {
+			return 0, false
+		}
+	}
+	if len(s) == 0 {
+		return 0, true
+	}
+	return int64(s[0]), true
 }
 
-func (p *Permission) Grant(perm Permission) {
-	*p |= perm
+// Nodes 按节点编号顺序返回集合中的权限节点名称
+func (s PermissionSet) Nodes() []string {
+	nodes := make([]string, 0)
+	for i, word := range s {
+		for word != 0 {
+			perm := Permission(i*64 + bits.TrailingZeros64(word))
+			if name := perm.Name(); name != "" {
+				nodes = append(nodes, name)
+			}
+			word &= word - 1
+		}
+	}
+	return nodes
 }
 
-func (p *Permission) Revoke(perm Permission) {
-	*p &^= perm
+func (s PermissionSet) String() string {
+	return strings.Join(s.Nodes(), ",")
 }
@@ -0,0 +1,25 @@
+// Package operation
+package operation
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrPositionEndorsementNotFound = errors.New("position endorsement not found")
+
+// PositionEndorsementOperationInterface 席位授权操作接口定义
+type PositionEndorsementOperationInterface interface {
+	// NewPositionEndorsement 创建新的席位授权(只是创建, 没有写入数据库)
+	NewPositionEndorsement(user *User, pattern string, expiresAt *time.Time, operator *User) (endorsement *PositionEndorsement)
+	// SavePositionEndorsement 写入席位授权, 用户已有相同模式的授权时更新过期时间, 当err为nil时写入成功
+	SavePositionEndorsement(endorsement *PositionEndorsement) (err error)
+	// GetPositionEndorsementById 通过Id获取席位授权, 当err为nil时返回值endorsement有效
+	GetPositionEndorsementById(id uint) (endorsement *PositionEndorsement, err error)
+	// GetPositionEndorsements 分页获取席位授权, cid为0时获取所有用户的授权, 当err为nil时返回值endorsements有效
+	GetPositionEndorsements(cid int, page, pageSize int) (endorsements []*PositionEndorsement, total int64, err error)
+	// GetActivePositionEndorsements 获取用户当前有效的全部席位授权, 当err为nil时返回值endorsements有效
+	GetActivePositionEndorsements(cid int) (endorsements []*PositionEndorsement, err error)
+	// DeletePositionEndorsement 删除席位授权, 当err为nil时删除成功
+	DeletePositionEndorsement(endorsement *PositionEndorsement) (err error)
+}
@@ -0,0 +1,39 @@
+// Package operation
+package operation
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrRoleNotFound     = errors.New("role not found")
+	ErrRoleNameTaken    = errors.New("role name has been used")
+	ErrUserRoleNotFound = errors.New("user role not found")
+)
+
+// RoleOperationInterface 角色操作接口定义
+type RoleOperationInterface interface {
+	// NewRole 创建新角色(只是创建, 没有写入数据库)
+	NewRole(user *User, name string, description string, permission PermissionSet) (role *Role)
+	// AddRole 写入角色, 角色名重复时返回 ErrRoleNameTaken, 当err为nil时写入成功
+	AddRole(role *Role) (err error)
+	// GetRoleById 通过主键Id获取角色, 当err为nil时返回值role有效
+	GetRoleById(id uint) (role *Role, err error)
+	// GetRoles 获取所有角色, 当err为nil时返回值roles有效
+	GetRoles() (roles []*Role, err error)
+	// UpdateRole 更新角色信息, 角色名重复时返回 ErrRoleNameTaken, 当err为nil时更新成功
+	UpdateRole(role *Role, updates map[string]interface{}) (err error)
+	// DeleteRole 删除角色及其所有授予记录, 当err为nil时删除成功
+	DeleteRole(role *Role) (err error)
+	// GetRoleHolders 获取持有指定角色且未过期的用户Cid, 当err为nil时返回值cids有效
+	GetRoleHolders(role *Role) (cids []int, err error)
+	// GetUserRoles 获取用户被授予的所有角色(包括已过期的), 当err为nil时返回值roles有效
+	GetUserRoles(cid int) (roles []*UserRole, err error)
+	// GetUserRole 获取用户的指定角色授予记录, 当err为nil时返回值userRole有效
+	GetUserRole(cid int, roleId uint) (userRole *UserRole, err error)
+	// AssignRole 为用户授予角色, 已经授予过时更新过期时间, expiresAt为nil表示永久有效, 当err为nil时返回值userRole有效
+	AssignRole(user *User, role *Role, expiresAt *time.Time, operator *User) (userRole *UserRole, err error)
+	// RevokeRole 撤销用户的角色, 当err为nil时撤销成功
+	RevokeRole(userRole *UserRole) (err error)
+}
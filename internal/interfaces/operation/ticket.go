@@ -0,0 +1,38 @@
+// Package operation
+package operation
+
+import (
+	"errors"
+)
+
+const (
+	TicketStatusOpen   = iota // 等待处理
+	TicketStatusClosed        // 已关闭
+)
+
+var (
+	ErrTicketNotFound = errors.New("ticket not found")
+	ErrTicketClosed   = errors.New("ticket already closed")
+)
+
+// TicketOperationInterface 工单操作接口定义
+type TicketOperationInterface interface {
+	// NewTicket 创建新的工单(只是创建, 没有写入数据库)
+	NewTicket(user *User, title string, content string) (ticket *Ticket)
+	// AddTicket 写入工单, 当err为nil时写入成功
+	AddTicket(ticket *Ticket) (err error)
+	// GetTicketById 通过工单Id获取工单与全部回复, 当err为nil时返回值ticket有效
+	GetTicketById(id uint) (ticket *Ticket, err error)
+	// GetTickets 分页获取所有工单, 不包含回复, 当err为nil时返回值tickets有效
+	GetTickets(page, pageSize int) (tickets []*Ticket, total int64, err error)
+	// GetUserTickets 分页获取指定用户创建的工单, 不包含回复, 当err为nil时返回值tickets有效
+	GetUserTickets(cid int, page, pageSize int) (tickets []*Ticket, total int64, err error)
+	// NewTicketReply 创建新的工单回复(只是创建, 没有写入数据库)
+	NewTicketReply(ticket *Ticket, user *User, content string) (reply *TicketMessage)
+	// AddTicketReply 写入工单回复, 工单已关闭时返回错误, 当err为nil时写入成功
+	AddTicketReply(ticket *Ticket, reply *TicketMessage) (err error)
+	// AssignTicket 将工单指派给指定用户, 工单已关闭时返回错误, 当err为nil时指派成功
+	AssignTicket(ticket *Ticket, cid int) (err error)
+	// CloseTicket 关闭工单, 工单已关闭时返回错误, 当err为nil时关闭成功
+	CloseTicket(ticket *Ticket) (err error)
+}
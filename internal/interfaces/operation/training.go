@@ -0,0 +1,53 @@
+// Package operation
+package operation
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	TrainingStatusPending    = iota // 等待分配教员
+	TrainingStatusInProgress        // 培训中
+	TrainingStatusCompleted         // 已完成并升级
+	TrainingStatusCancelled         // 已取消
+)
+
+var (
+	ErrTrainingNotFound        = errors.New("training request not found")
+	ErrTrainingClosed          = errors.New("training request already closed")
+	ErrTrainingExists          = errors.New("user already has an open training request")
+	ErrSoloEndorsementNotFound = errors.New("solo endorsement not found")
+)
+
+// TrainingOperationInterface 管制培训操作接口定义
+type TrainingOperationInterface interface {
+	// NewTrainingRequest 创建新的培训申请(只是创建, 没有写入数据库)
+	NewTrainingRequest(user *User, targetRating int, remark string) (request *TrainingRequest)
+	// AddTrainingRequest 写入培训申请, 用户已有未结束的申请时返回错误, 当err为nil时写入成功
+	AddTrainingRequest(request *TrainingRequest) (err error)
+	// GetTrainingRequestById 通过Id获取培训申请与全部培训报告和单飞授权, 当err为nil时返回值request有效
+	GetTrainingRequestById(id uint) (request *TrainingRequest, err error)
+	// GetTrainingRequests 分页获取所有培训申请, 不包含报告与单飞授权, 当err为nil时返回值requests有效
+	GetTrainingRequests(page, pageSize int) (requests []*TrainingRequest, total int64, err error)
+	// GetUserTrainingRequests 分页获取指定用户作为学员或教员参与的培训申请, 当err为nil时返回值requests有效
+	GetUserTrainingRequests(cid int, page, pageSize int) (requests []*TrainingRequest, total int64, err error)
+	// AssignMentor 为培训申请指派教员, 申请已结束时返回错误, 当err为nil时指派成功
+	AssignMentor(request *TrainingRequest, mentorCid int) (err error)
+	// CloseTrainingRequest 以指定状态结束培训申请并撤销其全部单飞授权, 申请已结束时返回错误, 当err为nil时结束成功
+	CloseTrainingRequest(request *TrainingRequest, status int) (err error)
+	// CancelUserTrainingRequests 取消用户所有未结束的培训申请并撤销其单飞授权, 用于封禁用户, 当err为nil时取消成功
+	CancelUserTrainingRequests(cid int) (err error)
+	// NewTrainingReport 创建新的培训报告(只是创建, 没有写入数据库)
+	NewTrainingReport(request *TrainingRequest, mentor *User, history *History, content string) (report *TrainingReport)
+	// AddTrainingReport 写入培训报告, 申请已结束时返回错误, 当err为nil时写入成功
+	AddTrainingReport(request *TrainingRequest, report *TrainingReport) (err error)
+	// NewSoloEndorsement 创建新的单飞授权(只是创建, 没有写入数据库)
+	NewSoloEndorsement(request *TrainingRequest, mentor *User, facility int, expiresAt time.Time) (solo *SoloEndorsement)
+	// AddSoloEndorsement 写入单飞授权, 申请已结束时返回错误, 当err为nil时写入成功
+	AddSoloEndorsement(request *TrainingRequest, solo *SoloEndorsement) (err error)
+	// GetActiveSoloEndorsement 获取用户在指定席位上当前有效的单飞授权, 当err为nil时返回值solo有效
+	GetActiveSoloEndorsement(cid int, facility int) (solo *SoloEndorsement, err error)
+	// RevokeSoloEndorsement 撤销单飞授权, 当err为nil时撤销成功
+	RevokeSoloEndorsement(solo *SoloEndorsement) (err error)
+}
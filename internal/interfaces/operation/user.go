@@ -5,6 +5,7 @@ import (
 	"errors"
 	"github.com/half-nothing/simple-fsd/internal/utils"
 	"gorm.io/gorm"
+	"time"
 )
 
 var (
@@ -18,6 +19,8 @@ var (
 	ErrPasswordEncode = errors.New("password encode error")
 	// ErrOldPassword 原密码错误
 	ErrOldPassword = errors.New("old password error")
+	// ErrNoUnusedCid 指定范围内没有可用的cid
+	ErrNoUnusedCid = errors.New("no unused cid in range")
 )
 
 type UserId interface {
@@ -57,6 +60,8 @@ type UserOperationInterface interface {
 	GetUserByUsernameOrEmail(ident string) (user *User, err error)
 	// GetUsers 获取分页用户数据, 当err为nil时返回值users有效, total表示数据总数目
 	GetUsers(page, pageSize int) (users []*User, total int64, err error)
+	// GetControllersByRating 获取管制权限在[minRating, maxRating]之间的全部用户, 当err为nil时返回值users有效
+	GetControllersByRating(minRating, maxRating int) (users []*User, err error)
 	// NewUser 创建一个新用户(只是创建, 没有写入数据库), 当err为nil时返回值user有效
 	NewUser(username string, email string, cid int, password string) (user *User, err error)
 	// AddUser 创建一个新用户(写入数据库), 在写入之前会调用 [UserOperationInterface.IsUserIdentifierTaken] 检查一致性约束, 当err为nil时表示创建成功
@@ -67,8 +72,12 @@ type UserOperationInterface interface {
 	UpdateUserPilotTime(user *User, seconds int) (err error)
 	// UpdateUserRating 更新用户管制权限, 当err为nil时表示更新成功
 	UpdateUserRating(user *User, rating int) (err error)
-	// UpdateUserPermission 更新用户飞控权限, 当err为nil时表示更新成功
-	UpdateUserPermission(user *User, permission Permission) (err error)
+	// BanUser 临时封禁用户直到until, 当err为nil时表示更新成功
+	BanUser(user *User, until time.Time, reason string) (err error)
+	// UnbanUser 解除用户的临时封禁, 当err为nil时表示更新成功
+	UnbanUser(user *User) (err error)
+	// UpdateUserPermission 更新用户直接授予的权限, 只能包含编号小于64的节点, 否则返回 ErrPermissionNodeNotExists, 当err为nil时表示更新成功
+	UpdateUserPermission(user *User, permission PermissionSet) (err error)
 	// UpdateUserInfo 批量更新用户信息, 当err为nil时表示更新成功
 	UpdateUserInfo(user *User, info map[string]interface{}) (err error)
 	// UpdateUserPassword 更新用户密码(不写入数据库, 仅验证), 当err为nil时返回值encodePassword有效
@@ -79,6 +88,8 @@ type UserOperationInterface interface {
 	VerifyUserPassword(user *User, password string) (pass bool)
 	// IsUserIdentifierTaken 检查给定用户三元组的一致性约束, err为nil且taken为true时表示一致性约束检查通过
 	IsUserIdentifierTaken(tx *gorm.DB, cid int, username, email string) (taken bool, err error)
+	// GetUnusedCid 获取[minCid, maxCid]范围内最小的未被使用的cid, 范围内已全部被使用时返回 ErrNoUnusedCid
+	GetUnusedCid(minCid, maxCid int) (cid int, err error)
 	GetTotalUsers() (total int64, err error)
 	GetTotalControllers() (total int64, err error)
 	GetControllers(page, pageSize int) (users []*User, total int64, err error)
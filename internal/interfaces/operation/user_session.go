@@ -0,0 +1,35 @@
+// Package operation
+package operation
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrUserSessionNotFound = errors.New("user session not found")
+)
+
+// UserSessionOperationInterface 用户登录会话操作接口定义
+type UserSessionOperationInterface interface {
+	// NewUserSession 创建新的会话并生成随机的令牌Id(只是创建, 没有写入数据库)
+	NewUserSession(user *User, ip string, userAgent string, expiresAt time.Time) (session *UserSession)
+	// AddUserSession 写入会话, 当err为nil时写入成功
+	AddUserSession(session *UserSession) (err error)
+	// GetUserSessionByJti 通过令牌Id获取会话, 当err为nil时返回值session有效
+	GetUserSessionByJti(jti string) (session *UserSession, err error)
+	// GetUserSessionById 通过会话Id获取会话, 当err为nil时返回值session有效
+	GetUserSessionById(id uint) (session *UserSession, err error)
+	// GetActiveUserSessions 获取用户所有未撤销且未过期的会话, 当err为nil时返回值sessions有效
+	GetActiveUserSessions(cid int) (sessions []*UserSession, err error)
+	// TouchUserSession 更新会话的最后使用时间与IP, expiresAt不为零值时同时更新过期时间, 当err为nil时更新成功
+	TouchUserSession(session *UserSession, ip string, expiresAt time.Time) (err error)
+	// RevokeUserSession 撤销会话, 当err为nil时撤销成功
+	RevokeUserSession(session *UserSession) (err error)
+	// RevokeUserSessions 撤销用户除exceptJti外的所有会话, exceptJti为空时撤销全部, 当err为nil时撤销成功
+	RevokeUserSessions(cid int, exceptJti string) (err error)
+	// ClearUserSessionsTotpPending 解除用户所有会话的两步验证限制, 当err为nil时更新成功
+	ClearUserSessionsTotpPending(cid int) (err error)
+	// DeleteExpiredUserSessions 删除在指定时间之前过期的会话, 当err为nil时删除成功
+	DeleteExpiredUserSessions(before time.Time) (err error)
+}
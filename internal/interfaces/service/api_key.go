@@ -0,0 +1,45 @@
+// Package service
+package service
+
+import (
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"time"
+)
+
+type ApiKeyServiceInterface interface {
+	GetApiKeys(req *RequestGetApiKeys) *ApiResponse[ResponseGetApiKeys]
+	CreateApiKey(req *RequestCreateApiKey) *ApiResponse[ResponseCreateApiKey]
+	RevokeApiKey(req *RequestRevokeApiKey) *ApiResponse[ResponseRevokeApiKey]
+}
+
+type RequestGetApiKeys struct {
+	JwtHeader
+	Cid int
+	All bool `query:"all"` // 获取所有用户的密钥, 需要ApiKeyManage权限
+}
+
+type ResponseGetApiKeys []*operation.ApiKey
+
+type RequestCreateApiKey struct {
+	JwtHeader
+	EchoContentHeader
+	Cid         int
+	Name        string     `json:"name"`
+	Permissions []string   `json:"permissions"`
+	RateLimit   int        `json:"rate_limit"` // 0表示使用默认值
+	ExpiresAt   *time.Time `json:"expires_at"` // 为null时永不过期
+}
+
+type ResponseCreateApiKey struct {
+	Key    *operation.ApiKey `json:"key"`
+	ApiKey string            `json:"api_key"`
+}
+
+type RequestRevokeApiKey struct {
+	JwtHeader
+	EchoContentHeader
+	Cid   int
+	KeyId uint `param:"id"`
+}
+
+type ResponseRevokeApiKey bool
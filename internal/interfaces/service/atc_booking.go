@@ -0,0 +1,43 @@
+// Package service
+package service
+
+import (
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"time"
+)
+
+type AtcBookingServiceInterface interface {
+	GetAtcBookings(req *RequestGetAtcBookings) *ApiResponse[ResponseGetAtcBookings]
+	GetAtcBookingCalendar() []byte
+	AddAtcBooking(req *RequestAddAtcBooking) *ApiResponse[ResponseAddAtcBooking]
+	DeleteAtcBooking(req *RequestDeleteAtcBooking) *ApiResponse[ResponseDeleteAtcBooking]
+}
+
+type RequestGetAtcBookings struct {
+	StartTime string `query:"start_time"`
+	EndTime   string `query:"end_time"`
+}
+
+type ResponseGetAtcBookings struct {
+	Items []*operation.AtcBooking `json:"items"`
+}
+
+type RequestAddAtcBooking struct {
+	JwtHeader
+	EchoContentHeader
+	Cid       int
+	Callsign  string    `json:"callsign"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+}
+
+type ResponseAddAtcBooking operation.AtcBooking
+
+type RequestDeleteAtcBooking struct {
+	JwtHeader
+	EchoContentHeader
+	Cid       int
+	BookingId uint `param:"id"`
+}
+
+type ResponseDeleteAtcBooking bool
@@ -11,6 +11,7 @@ type ClientServiceInterface interface {
 	SendMessageToClient(req *RequestSendMessageToClient) *ApiResponse[ResponseSendMessageToClient]
 	KillClient(req *RequestKillClient) *ApiResponse[ResponseKillClient]
 	GetClientPath(req *RequestClientPath) *ApiResponse[ResponseClientPath]
+	GetSectorCoverage(req *RequestSectorCoverage) *ApiResponse[ResponseSectorCoverage]
 }
 
 type OnlineGeneral struct {
@@ -79,3 +80,16 @@ type RequestClientPath struct {
 }
 
 type ResponseClientPath []*fsd.PilotPath
+
+type SectorCoverage struct {
+	Callsign    string   `json:"callsign"`
+	Name        string   `json:"name"`
+	Controllers []string `json:"controllers"`
+	Pilots      []string `json:"pilots"`
+}
+
+type RequestSectorCoverage struct {
+	Callsign string `query:"callsign"`
+}
+
+type ResponseSectorCoverage []*SectorCoverage
@@ -0,0 +1,32 @@
+// Package service
+package service
+
+import (
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"time"
+)
+
+type ControllerActivityServiceInterface interface {
+	CheckControllerActivity(now time.Time)
+	GetControllerActivity(req *RequestGetControllerActivity) *ApiResponse[ResponseGetControllerActivity]
+}
+
+type RequestGetControllerActivity struct {
+	JwtHeader
+	Offset int `query:"offset"` // 向前偏移的考核周期数, 0为当前周期
+}
+
+type ControllerActivity struct {
+	Cid        int                             `json:"cid"`
+	Rating     int                             `json:"rating"`
+	AtcTime    int                             `json:"atc_time"` // 考核周期内的管制时长, 单位为秒
+	Satisfied  bool                            `json:"satisfied"`
+	Inactivity *operation.ControllerInactivity `json:"inactivity"` // 该考核周期的不活跃记录, 没有时为null
+}
+
+type ResponseGetControllerActivity struct {
+	PeriodStart time.Time             `json:"period_start"`
+	PeriodEnd   time.Time             `json:"period_end"`
+	MinimumTime int                   `json:"minimum_time"` // 要求的最少管制时长, 单位为秒
+	Items       []*ControllerActivity `json:"items"`
+}
@@ -2,6 +2,7 @@
 package service
 
 import (
+	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
 	"html/template"
@@ -11,10 +12,16 @@ type EmailServiceInterface interface {
 	RenderTemplate(template *template.Template, data interface{}) (string, error)
 	VerifyCode(email string, code int, cid int) error
 	SendEmailCode(email string, cid int) error
+	// SendPasswordResetCode 向email发送重置密码验证码, user为nil表示邮箱未注册, 此时只记录发送间隔
+	SendPasswordResetCode(email string, user *operation.User) error
+	VerifyPasswordResetCode(email string, code int, cid int) error
 	SendEmailVerifyCode(req *RequestEmailVerifyCode) *ApiResponse[ResponseEmailVerifyCode]
 	SendPermissionChangeEmail(user *operation.User, operator *operation.User) error
 	SendRatingChangeEmail(user *operation.User, operator *operation.User, oldRating, newRating fsd.Rating) error
 	SendKickedFromServerEmail(user *operation.User, operator *operation.User, reason string) error
+	SendHelpRequestEmail(emails []string, request *operation.HelpRequest) error
+	SendTicketUpdateEmail(user *operation.User, operator *operation.User, ticket *operation.Ticket, event string, content string) error
+	SendInactivityEmail(user *operation.User, inactivity *operation.ControllerInactivity, config *config.InactivityConfig) error
 }
 
 type RequestEmailVerifyCode struct {
@@ -0,0 +1,62 @@
+// Package service
+package service
+
+import (
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"time"
+)
+
+type EndorsementServiceInterface interface {
+	GetEndorsedPositions(req *RequestGetEndorsedPositions) *ApiResponse[ResponseGetEndorsedPositions]
+	GetEndorsements(req *RequestGetEndorsements) *ApiResponse[ResponseGetEndorsements]
+	GetEndorsementsPage(req *RequestGetEndorsementsPage) *ApiResponse[ResponseGetEndorsementsPage]
+	AddEndorsement(req *RequestAddEndorsement) *ApiResponse[ResponseAddEndorsement]
+	DeleteEndorsement(req *RequestDeleteEndorsement) *ApiResponse[ResponseDeleteEndorsement]
+}
+
+type RequestGetEndorsedPositions struct {
+	JwtHeader
+}
+
+type ResponseGetEndorsedPositions []string
+
+type RequestGetEndorsements struct {
+	JwtHeader
+	Cid int
+}
+
+type ResponseGetEndorsements []*operation.PositionEndorsement
+
+type RequestGetEndorsementsPage struct {
+	JwtHeader
+	TargetCid int `query:"cid"` // 为0时获取所有用户的席位授权
+	Page      int `query:"page_number"`
+	PageSize  int `query:"page_size"`
+}
+
+type ResponseGetEndorsementsPage struct {
+	Items    []*operation.PositionEndorsement `json:"items"`
+	Page     int                              `json:"page"`
+	PageSize int                              `json:"page_size"`
+	Total    int64                            `json:"total"`
+}
+
+type RequestAddEndorsement struct {
+	JwtHeader
+	EchoContentHeader
+	Cid       int
+	TargetCid int        `json:"cid"`
+	Pattern   string     `json:"pattern"`
+	ExpiresAt *time.Time `json:"expires_at"` // 为null时永久有效
+}
+
+type ResponseAddEndorsement operation.PositionEndorsement
+
+type RequestDeleteEndorsement struct {
+	JwtHeader
+	EchoContentHeader
+	Cid           int
+	EndorsementId uint `param:"id"`
+}
+
+type ResponseDeleteEndorsement bool
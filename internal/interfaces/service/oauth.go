@@ -0,0 +1,153 @@
+// Package service
+package service
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+)
+
+// 第三方应用可以申请的权限范围
+const (
+	OAuthScopeOpenId     = "openid"     // 签发ID令牌
+	OAuthScopeProfile    = "profile"    // 用户名与头像
+	OAuthScopeEmail      = "email"      // 邮箱
+	OAuthScopeRating     = "rating"     // 管制权限
+	OAuthScopePermission = "permission" // 飞控权限
+)
+
+var OAuthScopes = []string{OAuthScopeOpenId, OAuthScopeProfile, OAuthScopeEmail, OAuthScopeRating, OAuthScopePermission}
+
+type OAuthServiceInterface interface {
+	GetAuthorizeInfo(req *RequestOAuthAuthorize) *ApiResponse[ResponseOAuthAuthorizeInfo]
+	Authorize(req *RequestOAuthAuthorize) *ApiResponse[ResponseOAuthAuthorize]
+	Token(req *RequestOAuthToken) (*ResponseOAuthToken, *OAuthError)
+	UserInfo(accessToken string) (ResponseOAuthUserInfo, *OAuthError)
+	Discovery() *ResponseOAuthDiscovery
+	Jwks() *ResponseOAuthJwks
+	GetClients(req *RequestGetOAuthClients) *ApiResponse[ResponseGetOAuthClients]
+	CreateClient(req *RequestCreateOAuthClient) *ApiResponse[ResponseCreateOAuthClient]
+	DeleteClient(req *RequestDeleteOAuthClient) *ApiResponse[ResponseDeleteOAuthClient]
+}
+
+// OAuthClaims 第三方应用访问令牌声明
+type OAuthClaims struct {
+	ClientId string `json:"client_id"`
+	Scope    string `json:"scope"`
+	jwt.RegisteredClaims
+}
+
+// OAuthIdTokenClaims OIDC ID令牌声明
+type OAuthIdTokenClaims struct {
+	Nonce    string `json:"nonce,omitempty"`
+	AuthTime int64  `json:"auth_time"`
+	jwt.RegisteredClaims
+}
+
+// OAuthError 按照RFC 6749返回的错误
+type OAuthError struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description"`
+	HttpCode    int    `json:"-"`
+}
+
+type RequestOAuthAuthorize struct {
+	JwtHeader
+	ResponseType        string `query:"response_type" json:"response_type"`
+	ClientId            string `query:"client_id" json:"client_id"`
+	RedirectUri         string `query:"redirect_uri" json:"redirect_uri"`
+	Scope               string `query:"scope" json:"scope"`
+	State               string `query:"state" json:"state"`
+	Nonce               string `query:"nonce" json:"nonce"`
+	CodeChallenge       string `query:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `query:"code_challenge_method" json:"code_challenge_method"`
+}
+
+type ResponseOAuthAuthorizeInfo struct {
+	ClientId    string   `json:"client_id"`
+	Name        string   `json:"name"`
+	RedirectUri string   `json:"redirect_uri"`
+	Scopes      []string `json:"scopes"`
+}
+
+type ResponseOAuthAuthorize struct {
+	RedirectUri string `json:"redirect_uri"`
+}
+
+type RequestOAuthToken struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectUri  string `form:"redirect_uri"`
+	ClientId     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+	CodeVerifier string `form:"code_verifier"`
+}
+
+type ResponseOAuthToken struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope"`
+	IdToken     string `json:"id_token,omitempty"`
+}
+
+type ResponseOAuthUserInfo map[string]interface{}
+
+type ResponseOAuthDiscovery struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JwksUri                           string   `json:"jwks_uri"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IdTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// OAuthJwk ID令牌签名公钥(RFC 7517)
+type OAuthJwk struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyId     string `json:"kid"`
+	Modulus   string `json:"n"`
+	Exponent  string `json:"e"`
+}
+
+type ResponseOAuthJwks struct {
+	Keys []OAuthJwk `json:"keys"`
+}
+
+type RequestGetOAuthClients struct {
+	JwtHeader
+}
+
+type ResponseGetOAuthClients []*operation.OAuthClient
+
+type RequestCreateOAuthClient struct {
+	JwtHeader
+	EchoContentHeader
+	Cid          int
+	Name         string   `json:"name"`
+	RedirectUris []string `json:"redirect_uris"`
+	Scopes       []string `json:"scopes"`
+	Confidential bool     `json:"confidential"`
+}
+
+type ResponseCreateOAuthClient struct {
+	Client       *operation.OAuthClient `json:"client"`
+	ClientSecret string                 `json:"client_secret"`
+}
+
+type RequestDeleteOAuthClient struct {
+	JwtHeader
+	EchoContentHeader
+	Cid      int
+	ClientId uint `param:"id"`
+}
+
+type ResponseDeleteOAuthClient bool
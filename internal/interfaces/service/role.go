@@ -0,0 +1,90 @@
+// Package service
+package service
+
+import (
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"time"
+)
+
+type RoleServiceInterface interface {
+	GetPermissionNodes(req *RequestGetPermissionNodes) *ApiResponse[ResponseGetPermissionNodes]
+	GetRoles(req *RequestGetRoles) *ApiResponse[ResponseGetRoles]
+	CreateRole(req *RequestCreateRole) *ApiResponse[ResponseCreateRole]
+	EditRole(req *RequestEditRole) *ApiResponse[ResponseEditRole]
+	DeleteRole(req *RequestDeleteRole) *ApiResponse[ResponseDeleteRole]
+	GetUserRoles(req *RequestGetUserRoles) *ApiResponse[ResponseGetUserRoles]
+	AssignUserRole(req *RequestAssignUserRole) *ApiResponse[ResponseAssignUserRole]
+	RevokeUserRole(req *RequestRevokeUserRole) *ApiResponse[ResponseRevokeUserRole]
+}
+
+type RequestGetPermissionNodes struct {
+	JwtHeader
+}
+
+type ResponseGetPermissionNodes []string
+
+type RequestGetRoles struct {
+	JwtHeader
+}
+
+type ResponseGetRoles []*operation.Role
+
+type RequestCreateRole struct {
+	JwtHeader
+	EchoContentHeader
+	Cid         int
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type ResponseCreateRole operation.Role
+
+type RequestEditRole struct {
+	JwtHeader
+	EchoContentHeader
+	Cid         int
+	RoleId      uint     `param:"id"`
+	Name        string   `json:"name"`
+	Description *string  `json:"description"`
+	Permissions []string `json:"permissions"` // 为null时不修改
+}
+
+type ResponseEditRole operation.Role
+
+type RequestDeleteRole struct {
+	JwtHeader
+	EchoContentHeader
+	Cid    int
+	RoleId uint `param:"id"`
+}
+
+type ResponseDeleteRole bool
+
+type RequestGetUserRoles struct {
+	JwtHeader
+	TargetUid uint `param:"uid"`
+}
+
+type ResponseGetUserRoles []*operation.UserRole
+
+type RequestAssignUserRole struct {
+	JwtHeader
+	EchoContentHeader
+	Cid       int
+	TargetUid uint       `param:"uid"`
+	RoleId    uint       `json:"role_id"`
+	ExpiresAt *time.Time `json:"expires_at"` // 为null时永久有效
+}
+
+type ResponseAssignUserRole operation.UserRole
+
+type RequestRevokeUserRole struct {
+	JwtHeader
+	EchoContentHeader
+	Cid       int
+	TargetUid uint `param:"uid"`
+	RoleId    uint `param:"id"`
+}
+
+type ResponseRevokeUserRole bool
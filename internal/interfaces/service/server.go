@@ -2,13 +2,17 @@
 package service
 
 import (
+	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
 	. "github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
+	"time"
 )
 
 type ServerServiceInterface interface {
 	GetServerConfig() *ApiResponse[ResponseGetServerConfig]
 	GetServerInfo() *ApiResponse[ResponseGetServerInfo]
 	GetTimeRating() *ApiResponse[ResponseGetTimeRating]
+	ReloadConfig(req *RequestReloadConfig) *ApiResponse[ResponseReloadConfig]
+	DrainServer(req *RequestDrainServer) *ApiResponse[ResponseDrainServer]
 }
 
 type ServerLimits struct {
@@ -44,3 +48,22 @@ type ResponseGetTimeRating struct {
 	Pilots      []OnlineTime `json:"pilots"`
 	Controllers []OnlineTime `json:"controllers"`
 }
+
+type RequestReloadConfig struct {
+	JwtHeader
+	EchoContentHeader
+	Cid int
+}
+
+type ResponseReloadConfig config.ReloadResult
+
+type RequestDrainServer struct {
+	JwtHeader
+	EchoContentHeader
+	Cid      int
+	Duration string `json:"duration"`
+}
+
+type ResponseDrainServer struct {
+	Deadline time.Time `json:"deadline"`
+}
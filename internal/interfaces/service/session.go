@@ -0,0 +1,37 @@
+// Package service
+package service
+
+import "github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+
+type SessionServiceInterface interface {
+	GetSessions(req *RequestGetSessions) *ApiResponse[ResponseGetSessions]
+	RevokeSession(req *RequestRevokeSession) *ApiResponse[ResponseRevokeSession]
+	RevokeOtherSessions(req *RequestRevokeOtherSessions) *ApiResponse[ResponseRevokeOtherSessions]
+}
+
+type SessionInfo struct {
+	*operation.UserSession
+	Current bool `json:"current"`
+}
+
+type RequestGetSessions struct {
+	Cid       int
+	SessionId string
+}
+
+type ResponseGetSessions []*SessionInfo
+
+type RequestRevokeSession struct {
+	Cid       int
+	SessionId string
+	TargetId  uint `param:"id"`
+}
+
+type ResponseRevokeSession bool
+
+type RequestRevokeOtherSessions struct {
+	Cid       int
+	SessionId string
+}
+
+type ResponseRevokeOtherSessions bool
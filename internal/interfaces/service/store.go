@@ -2,6 +2,7 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
 	"mime/multipart"
@@ -85,6 +86,7 @@ type StoreServiceInterface interface {
 	SaveImageFile(file *multipart.FileHeader) (*StoreInfo, *ApiStatus)
 	DeleteImageFile(file string) (*StoreInfo, error)
 	SaveUploadImages(req *RequestUploadFile) *ApiResponse[ResponseUploadFile]
+	Ping(ctx context.Context) error
 }
 
 type RequestUploadFile struct {
@@ -2,6 +2,7 @@
 package service
 
 import (
+	"encoding/json"
 	"errors"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
@@ -21,6 +22,7 @@ const (
 	NotFound            HttpCode = 404
 	Conflict            HttpCode = 409
 	ServerInternalError HttpCode = 500
+	BadGateway          HttpCode = 502
 )
 
 func (hc HttpCode) Code() int {
@@ -41,16 +43,37 @@ type ApiResponse[T any] struct {
 }
 
 type Claims struct {
-	Uid        uint   `json:"uid"`
-	Cid        int    `json:"cid"`
-	Username   string `json:"username"`
-	Permission int64  `json:"permission"`
-	Rating     int    `json:"rating"`
-	FlushToken bool   `json:"flushToken"`
-	config     *config.JWTConfig
+	Uid              uint                    `json:"uid"`
+	Cid              int                     `json:"cid"`
+	Username         string                  `json:"username"`
+	Permission       operation.PermissionSet `json:"permissions"` // 有效权限, 包含角色授予的权限
+	LegacyPermission int64                   `json:"permission"`  // 旧版权限位图, 只包含编号小于64的节点, 兼容旧版客户端
+	Rating           int                     `json:"rating"`
+	FlushToken       bool                    `json:"flushToken"`
+	config           *config.JWTConfig
 	jwt.RegisteredClaims
 }
 
+// UnmarshalJSON 升级前签发的令牌只有旧版权限位图, 从位图恢复有效权限
+func (claim *Claims) UnmarshalJSON(data []byte) error {
+	type claims Claims
+	if err := json.Unmarshal(data, (*claims)(claim)); err != nil {
+		return err
+	}
+	if claim.Permission == nil {
+		claim.Permission = operation.NewPermissionSet(claim.LegacyPermission)
+	}
+	return nil
+}
+
+// legacyPermission 权限集合中编号小于64的节点组成的位图
+func legacyPermission(permission operation.PermissionSet) int64 {
+	if len(permission) == 0 {
+		return 0
+	}
+	return int64(permission[0])
+}
+
 type EchoContentHeader struct {
 	Ip        string
 	UserAgent string
@@ -58,28 +81,64 @@ type EchoContentHeader struct {
 
 type JwtHeader struct {
 	Uid        uint
-	Permission int64
+	Permission operation.PermissionSet
 }
 
-func NewClaims(config *config.JWTConfig, user *operation.User, flushToken bool) *Claims {
-	expiredDuration := config.ExpiresDuration
+// RealtimePermission 令牌中的权限与数据库中实时权限的交集, 敏感操作使用
+// 实时权限不包含已过期的角色, 因此角色过期后立即失去对应的敏感操作权限
+// 使用API密钥时令牌中的权限受密钥的权限范围限制, 因此不能只检查实时权限
+func (header *JwtHeader) RealtimePermission(user *operation.User) operation.PermissionSet {
+	return user.EffectivePermission().Intersect(header.Permission)
+}
+
+// NewClaims 创建令牌声明, sessionId 为服务端会话记录的令牌Id(jti)
+// 用户的角色在访问令牌有效期内过期时, 访问令牌在角色过期时失效, 刷新后重新计算有效权限
+func NewClaims(config *config.JWTConfig, user *operation.User, flushToken bool, sessionId string) *Claims {
+	now := time.Now()
+	expiresAt := now.Add(config.Expires())
 	if flushToken {
-		expiredDuration += config.RefreshDuration
+		expiresAt = expiresAt.Add(config.Refresh())
+	} else {
+		for _, userRole := range user.Roles {
+			if userRole.Active() && userRole.ExpiresAt != nil && userRole.ExpiresAt.Before(expiresAt) {
+				expiresAt = *userRole.ExpiresAt
+			}
+		}
 	}
+	permission := user.EffectivePermission()
+	return &Claims{
+		Uid:              user.ID,
+		Cid:              user.Cid,
+		Username:         user.Username,
+		Permission:       permission,
+		LegacyPermission: legacyPermission(permission),
+		Rating:           user.Rating,
+		FlushToken:       flushToken,
+		config:           config,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        sessionId,
+			Issuer:    "FsdHttpServer",
+			Subject:   user.Username,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+}
+
+// NewApiKeyClaims 使用API密钥认证时的令牌声明, 只在请求内使用, 不会被签发
+// 权限为密钥的权限范围与持有人当前有效权限的交集
+func NewApiKeyClaims(user *operation.User, key *operation.ApiKey) *Claims {
 	return &Claims{
 		Uid:        user.ID,
 		Cid:        user.Cid,
 		Username:   user.Username,
-		Permission: user.Permission,
+		Permission: key.PermissionSet().Intersect(user.EffectivePermission()),
 		Rating:     user.Rating,
-		FlushToken: flushToken,
-		config:     config,
+		FlushToken: false,
 		RegisteredClaims: jwt.RegisteredClaims{
-			Issuer:    "FsdHttpServer",
-			Subject:   user.Username,
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiredDuration)),
+			Issuer:  "FsdHttpServer",
+			Subject: user.Username,
 		},
 	}
 }
@@ -102,10 +161,25 @@ var (
 	ErrUserNotFound          = ApiStatus{"USER_NOT_FOUND", "指定用户不存在", NotFound}
 	ErrActivityNotFound      = ApiStatus{"ACTIVITY_NOT_FOUND", "活动不存在", NotFound}
 	ErrFacilityNotFound      = ApiStatus{"FACILITY_NOT_FOUND", "管制席位不存在", NotFound}
+	ErrAtcBookingNotFound    = ApiStatus{"ATC_BOOKING_NOT_FOUND", "席位预约不存在", NotFound}
+	ErrTicketNotFound        = ApiStatus{"TICKET_NOT_FOUND", "工单不存在", NotFound}
+	ErrUserSessionNotFound   = ApiStatus{"SESSION_NOT_FOUND", "登录会话不存在", NotFound}
+	ErrOAuthClientNotFound   = ApiStatus{"OAUTH_CLIENT_NOT_FOUND", "第三方应用不存在", NotFound}
+	ErrRoleNotFound          = ApiStatus{"ROLE_NOT_FOUND", "角色不存在", NotFound}
+	ErrRoleNameTaken         = ApiStatus{"ROLE_NAME_TAKEN", "角色名称已存在", Conflict}
+	ErrUserRoleNotFound      = ApiStatus{"USER_ROLE_NOT_FOUND", "用户未被授予该角色", NotFound}
+	ErrApiKeyNotFound        = ApiStatus{"API_KEY_NOT_FOUND", "API密钥不存在", NotFound}
+	ErrHistoryNotFound       = ApiStatus{"HISTORY_NOT_FOUND", "联飞记录不存在", NotFound}
+	ErrTrainingNotFound      = ApiStatus{"TRAINING_NOT_FOUND", "培训申请不存在", NotFound}
+	ErrEndorsementNotFound   = ApiStatus{"ENDORSEMENT_NOT_FOUND", "席位授权不存在", NotFound}
 	ErrRegisterFail          = ApiStatus{"REGISTER_FAIL", "注册失败", ServerInternalError}
 	ErrIdentifierTaken       = ApiStatus{"USER_EXISTS", "用户已存在", BadRequest}
 	ErrMissingOrMalformedJwt = ApiStatus{"MISSING_OR_MALFORMED_JWT", "缺少JWT令牌或者令牌格式错误", BadRequest}
 	ErrInvalidOrExpiredJwt   = ApiStatus{"INVALID_OR_EXPIRED_JWT", "无效或过期的JWT令牌", Unauthorized}
+	ErrSessionRevoked        = ApiStatus{"SESSION_REVOKED", "登录会话已失效, 请重新登录", Unauthorized}
+	ErrTotpEnrolmentRequired = ApiStatus{"TOTP_ENROLMENT_REQUIRED", "请先启用两步验证", PermissionDenied}
+	ErrApiKeyInvalid         = ApiStatus{"API_KEY_INVALID", "无效或已撤销的API密钥", Unauthorized}
+	ErrApiKeyNotAllowed      = ApiStatus{"API_KEY_NOT_ALLOWED", "该接口不允许使用API密钥访问", PermissionDenied}
 	ErrUnknown               = ApiStatus{"UNKNOWN_JWT_ERROR", "未知的JWT解析错误", ServerInternalError}
 )
 
@@ -142,6 +216,28 @@ func CallDBFuncAndCheckError[R any, T any](fc func() (*R, error)) (*R, *ApiRespo
 		return nil, NewApiResponse[T](&ErrActivityNotFound, Unsatisfied, nil)
 	case errors.Is(err, operation.ErrFacilityNotFound):
 		return nil, NewApiResponse[T](&ErrFacilityNotFound, Unsatisfied, nil)
+	case errors.Is(err, operation.ErrAtcBookingNotFound):
+		return nil, NewApiResponse[T](&ErrAtcBookingNotFound, Unsatisfied, nil)
+	case errors.Is(err, operation.ErrTicketNotFound):
+		return nil, NewApiResponse[T](&ErrTicketNotFound, Unsatisfied, nil)
+	case errors.Is(err, operation.ErrUserSessionNotFound):
+		return nil, NewApiResponse[T](&ErrUserSessionNotFound, Unsatisfied, nil)
+	case errors.Is(err, operation.ErrOAuthClientNotFound):
+		return nil, NewApiResponse[T](&ErrOAuthClientNotFound, Unsatisfied, nil)
+	case errors.Is(err, operation.ErrRoleNotFound):
+		return nil, NewApiResponse[T](&ErrRoleNotFound, Unsatisfied, nil)
+	case errors.Is(err, operation.ErrRoleNameTaken):
+		return nil, NewApiResponse[T](&ErrRoleNameTaken, Unsatisfied, nil)
+	case errors.Is(err, operation.ErrUserRoleNotFound):
+		return nil, NewApiResponse[T](&ErrUserRoleNotFound, Unsatisfied, nil)
+	case errors.Is(err, operation.ErrApiKeyNotFound):
+		return nil, NewApiResponse[T](&ErrApiKeyNotFound, Unsatisfied, nil)
+	case errors.Is(err, operation.ErrHistoryNotFound):
+		return nil, NewApiResponse[T](&ErrHistoryNotFound, Unsatisfied, nil)
+	case errors.Is(err, operation.ErrTrainingNotFound):
+		return nil, NewApiResponse[T](&ErrTrainingNotFound, Unsatisfied, nil)
+	case errors.Is(err, operation.ErrPositionEndorsementNotFound):
+		return nil, NewApiResponse[T](&ErrEndorsementNotFound, Unsatisfied, nil)
 	case err != nil:
 		return nil, NewApiResponse[T](&ErrDatabaseFail, Unsatisfied, nil)
 	default:
@@ -150,14 +246,13 @@ func CallDBFuncAndCheckError[R any, T any](fc func() (*R, error)) (*R, *ApiRespo
 }
 
 // GetUsersAndCheckPermission 从数据库获取用户数据并检查权限
-func GetUsersAndCheckPermission[T any](userOperation operation.UserOperationInterface, uid, targetUid uint, perm operation.Permission) (*operation.User, *operation.User, *ApiResponse[T]) {
+func GetUsersAndCheckPermission[T any](userOperation operation.UserOperationInterface, header *JwtHeader, targetUid uint, perm operation.Permission) (*operation.User, *operation.User, *ApiResponse[T]) {
 	// 敏感操作获取实时数据
-	user, res := CallDBFuncAndCheckError[operation.User, T](func() (*operation.User, error) { return userOperation.GetUserByUid(uid) })
+	user, res := CallDBFuncAndCheckError[operation.User, T](func() (*operation.User, error) { return userOperation.GetUserByUid(header.Uid) })
 	if res != nil {
 		return nil, nil, res
 	}
-	permission := operation.Permission(user.Permission)
-	if !permission.HasPermission(perm) {
+	if !header.RealtimePermission(user).HasPermission(perm) {
 		return nil, nil, NewApiResponse[T](&ErrNoPermission, Unsatisfied, nil)
 	}
 	targetUser, res := CallDBFuncAndCheckError[operation.User, T](func() (*operation.User, error) { return userOperation.GetUserByUid(targetUid) })
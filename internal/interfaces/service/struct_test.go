@@ -0,0 +1,54 @@
+package service
+
+import (
+	"encoding/json"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"testing"
+)
+
+func TestClaimsLegacyPermission(t *testing.T) {
+	var permission operation.PermissionSet
+	permission.Grant(operation.UserEditRating)
+	permission.Grant(operation.Permission(70))
+	legacy, _ := operation.PermissionSet{permission[0]}.Legacy()
+
+	claims := NewClaims(&config.JWTConfig{Secret: "secret"}, &operation.User{Cid: 1000}, false, "jti")
+	claims.Permission = permission
+	claims.LegacyPermission = legacyPermission(permission)
+	data, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := make(map[string]interface{})
+	_ = json.Unmarshal(data, &fields)
+	// 旧版客户端读取的permission仍然是数字
+	if value, ok := fields["permission"].(float64); !ok || int64(value) != legacy {
+		t.Errorf("expected legacy permission %d, got %v", legacy, fields["permission"])
+	}
+
+	parsed := &Claims{}
+	if err := json.Unmarshal(data, parsed); err != nil {
+		t.Fatal(err)
+	}
+	if !parsed.Permission.HasPermission(operation.Permission(70)) || !parsed.Permission.HasPermission(operation.UserEditRating) {
+		t.Errorf("permissions claim should keep all nodes, got %v", parsed.Permission)
+	}
+
+	// 升级前签发的令牌只有旧版权限位图
+	old := &Claims{}
+	if err := json.Unmarshal([]byte(`{"uid":1,"cid":1000,"permission":`+string(mustMarshal(t, legacy))+`}`), old); err != nil {
+		t.Fatal(err)
+	}
+	if !old.Permission.HasPermission(operation.UserEditRating) {
+		t.Errorf("legacy token should restore permission, got %v", old.Permission)
+	}
+}
+
+func mustMarshal(t *testing.T, value interface{}) []byte {
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
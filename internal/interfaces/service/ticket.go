@@ -0,0 +1,81 @@
+// Package service
+package service
+
+import "github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+
+type TicketServiceInterface interface {
+	GetTickets(req *RequestGetTickets) *ApiResponse[ResponseGetTickets]
+	GetTicketsPage(req *RequestGetTicketsPage) *ApiResponse[ResponseGetTickets]
+	GetTicket(req *RequestGetTicket) *ApiResponse[ResponseGetTicket]
+	AddTicket(req *RequestAddTicket) *ApiResponse[ResponseAddTicket]
+	ReplyTicket(req *RequestReplyTicket) *ApiResponse[ResponseReplyTicket]
+	AssignTicket(req *RequestAssignTicket) *ApiResponse[ResponseAssignTicket]
+	CloseTicket(req *RequestCloseTicket) *ApiResponse[ResponseCloseTicket]
+}
+
+type RequestGetTickets struct {
+	JwtHeader
+	Cid      int
+	Page     int `query:"page_number"`
+	PageSize int `query:"page_size"`
+}
+
+type RequestGetTicketsPage struct {
+	JwtHeader
+	Page     int `query:"page_number"`
+	PageSize int `query:"page_size"`
+}
+
+type ResponseGetTickets struct {
+	Items    []*operation.Ticket `json:"items"`
+	Page     int                 `json:"page"`
+	PageSize int                 `json:"page_size"`
+	Total    int64               `json:"total"`
+}
+
+type RequestGetTicket struct {
+	JwtHeader
+	Cid      int
+	TicketId uint `param:"id"`
+}
+
+type ResponseGetTicket operation.Ticket
+
+type RequestAddTicket struct {
+	JwtHeader
+	EchoContentHeader
+	Cid     int
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+type ResponseAddTicket operation.Ticket
+
+type RequestReplyTicket struct {
+	JwtHeader
+	EchoContentHeader
+	Cid      int
+	TicketId uint   `param:"id"`
+	Content  string `json:"content"`
+}
+
+type ResponseReplyTicket operation.TicketMessage
+
+type RequestAssignTicket struct {
+	JwtHeader
+	EchoContentHeader
+	Cid       int
+	TicketId  uint `param:"id"`
+	TargetCid int  `json:"cid"`
+}
+
+type ResponseAssignTicket bool
+
+type RequestCloseTicket struct {
+	JwtHeader
+	EchoContentHeader
+	Cid      int
+	TicketId uint `param:"id"`
+}
+
+type ResponseCloseTicket bool
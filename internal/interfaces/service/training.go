@@ -0,0 +1,121 @@
+// Package service
+package service
+
+import (
+	"github.com/half-nothing/simple-fsd/internal/interfaces/operation"
+	"time"
+)
+
+type TrainingServiceInterface interface {
+	GetTrainings(req *RequestGetTrainings) *ApiResponse[ResponseGetTrainings]
+	GetTrainingsPage(req *RequestGetTrainingsPage) *ApiResponse[ResponseGetTrainings]
+	GetTraining(req *RequestGetTraining) *ApiResponse[ResponseGetTraining]
+	GetTrainingHistory(req *RequestGetTraining) *ApiResponse[ResponseGetTrainingHistory]
+	AddTraining(req *RequestAddTraining) *ApiResponse[ResponseAddTraining]
+	AssignMentor(req *RequestAssignMentor) *ApiResponse[ResponseAssignMentor]
+	AddTrainingReport(req *RequestAddTrainingReport) *ApiResponse[ResponseAddTrainingReport]
+	GrantSolo(req *RequestGrantSolo) *ApiResponse[ResponseGrantSolo]
+	RevokeSolo(req *RequestRevokeSolo) *ApiResponse[ResponseRevokeSolo]
+	CompleteTraining(req *RequestCompleteTraining) *ApiResponse[ResponseCompleteTraining]
+	CancelTraining(req *RequestCancelTraining) *ApiResponse[ResponseCancelTraining]
+}
+
+type RequestGetTrainings struct {
+	JwtHeader
+	Cid      int
+	Page     int `query:"page_number"`
+	PageSize int `query:"page_size"`
+}
+
+type RequestGetTrainingsPage struct {
+	JwtHeader
+	Page     int `query:"page_number"`
+	PageSize int `query:"page_size"`
+}
+
+type ResponseGetTrainings struct {
+	Items    []*operation.TrainingRequest `json:"items"`
+	Page     int                          `json:"page"`
+	PageSize int                          `json:"page_size"`
+	Total    int64                        `json:"total"`
+}
+
+type RequestGetTraining struct {
+	JwtHeader
+	Cid        int
+	TrainingId uint `param:"id"`
+}
+
+type ResponseGetTraining operation.TrainingRequest
+
+type ResponseGetTrainingHistory []operation.History
+
+type RequestAddTraining struct {
+	JwtHeader
+	EchoContentHeader
+	Cid          int
+	TargetRating int    `json:"target_rating"`
+	Remark       string `json:"remark"`
+}
+
+type ResponseAddTraining operation.TrainingRequest
+
+type RequestAssignMentor struct {
+	JwtHeader
+	EchoContentHeader
+	Cid        int
+	TrainingId uint `param:"id"`
+	MentorCid  int  `json:"cid"`
+}
+
+type ResponseAssignMentor bool
+
+type RequestAddTrainingReport struct {
+	JwtHeader
+	EchoContentHeader
+	Cid        int
+	TrainingId uint   `param:"id"`
+	HistoryId  uint   `json:"history_id"`
+	Content    string `json:"content"`
+}
+
+type ResponseAddTrainingReport operation.TrainingReport
+
+type RequestGrantSolo struct {
+	JwtHeader
+	EchoContentHeader
+	Cid        int
+	TrainingId uint      `param:"id"`
+	Facility   int       `json:"facility"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type ResponseGrantSolo operation.SoloEndorsement
+
+type RequestRevokeSolo struct {
+	JwtHeader
+	EchoContentHeader
+	Cid        int
+	TrainingId uint `param:"id"`
+	SoloId     uint `param:"solo_id"`
+}
+
+type ResponseRevokeSolo bool
+
+type RequestCompleteTraining struct {
+	JwtHeader
+	EchoContentHeader
+	Cid        int
+	TrainingId uint `param:"id"`
+}
+
+type ResponseCompleteTraining operation.TrainingRequest
+
+type RequestCancelTraining struct {
+	JwtHeader
+	EchoContentHeader
+	Cid        int
+	TrainingId uint `param:"id"`
+}
+
+type ResponseCancelTraining bool
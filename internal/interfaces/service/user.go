@@ -20,9 +20,18 @@ type UserServiceInterface interface {
 	EditUserRating(req *RequestUserEditRating) *ApiResponse[ResponseUserEditRating]
 	GetUserHistory(req *RequestGetUserHistory) *ApiResponse[ResponseGetUserHistory]
 	GetTokenWithFlushToken(req *RequestGetToken) *ApiResponse[ResponseGetToken]
+	SendPasswordResetCode(req *RequestPasswordResetCode) *ApiResponse[ResponsePasswordResetCode]
+	ResetPassword(req *RequestResetPassword) *ApiResponse[ResponseResetPassword]
+	SetupTotp(req *RequestSetupTotp) *ApiResponse[ResponseSetupTotp]
+	ConfirmTotp(req *RequestConfirmTotp) *ApiResponse[ResponseConfirmTotp]
+	DisableTotp(req *RequestDisableTotp) *ApiResponse[ResponseDisableTotp]
+	RegenerateRecoveryCodes(req *RequestRegenerateRecoveryCodes) *ApiResponse[ResponseRegenerateRecoveryCodes]
+	GetExternalLoginUrl(req *RequestExternalLoginUrl) *ApiResponse[ResponseExternalLoginUrl]
+	ExternalLogin(req *RequestExternalLogin) *ApiResponse[ResponseUserLogin]
 }
 
 type RequestUserRegister struct {
+	EchoContentHeader
 	Username  string `json:"username"`
 	Email     string `json:"email"`
 	Password  string `json:"password"`
@@ -37,14 +46,17 @@ type ResponseUserRegister struct {
 }
 
 type RequestUserLogin struct {
+	EchoContentHeader
 	Username string `json:"username"`
 	Password string `json:"password"`
+	TotpCode string `json:"totp_code"`
 }
 
 type ResponseUserLogin struct {
-	User       *operation.User `json:"user"`
-	Token      string          `json:"token"`
-	FlushToken string          `json:"flush_token"`
+	User                  *operation.User `json:"user"`
+	Token                 string          `json:"token"`
+	FlushToken            string          `json:"flush_token"`
+	TotpEnrolmentRequired bool            `json:"totp_enrolment_required"`
 }
 
 type RequestUserAvailability struct {
@@ -71,6 +83,7 @@ type RequestUserEditCurrentProfile struct {
 	QQ             int    `json:"qq"`
 	OriginPassword string `json:"origin_password"`
 	NewPassword    string `json:"new_password"`
+	SessionId      string `json:"-"`
 }
 
 type ResponseUserEditCurrentProfile operation.User
@@ -149,6 +162,7 @@ type ResponseGetUserHistory struct {
 }
 
 type RequestGetToken struct {
+	EchoContentHeader
 	*Claims
 }
 
@@ -157,3 +171,75 @@ type ResponseGetToken struct {
 	Token      string          `json:"token"`
 	FlushToken string          `json:"flush_token"`
 }
+
+type RequestPasswordResetCode struct {
+	Email string `json:"email"`
+}
+
+type ResponsePasswordResetCode struct {
+	Email string `json:"email"`
+}
+
+type RequestResetPassword struct {
+	EchoContentHeader
+	Email       string `json:"email"`
+	EmailCode   int    `json:"email_code"`
+	NewPassword string `json:"new_password"`
+}
+
+type ResponseResetPassword bool
+
+type RequestSetupTotp struct {
+	JwtHeader
+}
+
+type ResponseSetupTotp struct {
+	Secret          string `json:"secret"`
+	ProvisioningUri string `json:"provisioning_uri"`
+}
+
+type RequestConfirmTotp struct {
+	JwtHeader
+	EchoContentHeader
+	Cid  int
+	Code string `json:"code"`
+}
+
+type ResponseConfirmTotp struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type RequestDisableTotp struct {
+	JwtHeader
+	EchoContentHeader
+	Cid  int
+	Code string `json:"code"`
+}
+
+type ResponseDisableTotp bool
+
+type RequestRegenerateRecoveryCodes struct {
+	JwtHeader
+	Code string `json:"code"`
+}
+
+type ResponseRegenerateRecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type RequestExternalLoginUrl struct{}
+
+type ResponseExternalLoginUrl struct {
+	Name    string `json:"name"`
+	Url     string `json:"url"`
+	State   string `json:"state"`
+	Binding string `json:"-"` // 由控制器写入Cookie, 不返回给前端
+}
+
+type RequestExternalLogin struct {
+	EchoContentHeader
+	Code     string `json:"code"`
+	State    string `json:"state"`
+	TotpCode string `json:"totp_code"`
+	Binding  string `json:"-"`
+}
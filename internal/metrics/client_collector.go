@@ -0,0 +1,55 @@
+// Package metrics
+package metrics
+
+import (
+	"github.com/half-nothing/simple-fsd/internal/interfaces/fsd"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	onlineClientsDesc  = prometheus.NewDesc("fsd_online_clients", "Number of connected fsd clients, by type", []string{"type"}, nil)
+	sendQueueDepthDesc = prometheus.NewDesc("fsd_send_queue_depth", "Total number of packets waiting in client send queues", nil, nil)
+	sendQueueMaxDesc   = prometheus.NewDesc("fsd_send_queue_max_depth", "Largest send queue depth among connected clients", nil, nil)
+)
+
+// clientCollector 在采集时从客户端管理器获取实时数据
+type clientCollector struct {
+	clientManager fsd.ClientManagerInterface
+}
+
+// RegisterClientCollector 注册在线客户端相关指标, 只应调用一次
+func RegisterClientCollector(clientManager fsd.ClientManagerInterface) {
+	Registry.MustRegister(&clientCollector{clientManager: clientManager})
+}
+
+func (collector *clientCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- onlineClientsDesc
+	ch <- sendQueueDepthDesc
+	ch <- sendQueueMaxDesc
+}
+
+func (collector *clientCollector) Collect(ch chan<- prometheus.Metric) {
+	clients := collector.clientManager.GetClientSnapshot()
+	defer collector.clientManager.PutSlice(clients)
+
+	pilots, controllers := 0, 0
+	totalDepth, maxDepth := 0, 0
+	for _, client := range clients {
+		if client == nil || client.Disconnected() {
+			continue
+		}
+		if client.IsAtc() {
+			controllers++
+		} else {
+			pilots++
+		}
+		depth := client.SendQueueDepth()
+		totalDepth += depth
+		maxDepth = max(maxDepth, depth)
+	}
+
+	ch <- prometheus.MustNewConstMetric(onlineClientsDesc, prometheus.GaugeValue, float64(pilots), "pilot")
+	ch <- prometheus.MustNewConstMetric(onlineClientsDesc, prometheus.GaugeValue, float64(controllers), "atc")
+	ch <- prometheus.MustNewConstMetric(sendQueueDepthDesc, prometheus.GaugeValue, float64(totalDepth))
+	ch <- prometheus.MustNewConstMetric(sendQueueMaxDesc, prometheus.GaugeValue, float64(maxDepth))
+}
@@ -0,0 +1,111 @@
+// Package metrics Prometheus指标定义
+package metrics
+
+import (
+	"github.com/half-nothing/simple-fsd/internal/interfaces/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net"
+	"net/http"
+)
+
+var Registry = prometheus.NewRegistry()
+
+var (
+	PacketsReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "fsd_packets_received_total",
+		Help: "Total number of packets received from fsd clients, by command",
+	}, []string{"command"})
+
+	PacketsSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "fsd_packets_sent_total",
+		Help: "Total number of packets queued to fsd clients, by command",
+	}, []string{"command"})
+
+	ErrorsSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "fsd_errors_sent_total",
+		Help: "Total number of $ER packets sent to fsd clients, by error",
+	}, []string{"error"})
+
+	BroadcastDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "fsd_broadcast_duration_seconds",
+		Help:    "Time spent fanning out a broadcast message to all recipients",
+		Buckets: []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1},
+	})
+
+	SendQueueDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "fsd_send_queue_dropped_total",
+		Help: "Total number of packets dropped because a client send queue was full",
+	})
+
+	DatabaseQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "database_query_duration_seconds",
+		Help:    "Database query latency, by operation and table",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"operation", "table"})
+
+	HttpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Total number of http requests, by method, route and status code",
+	}, []string{"method", "route", "code"})
+
+	HttpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Http request latency, by method and route",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	RateLimitRejected = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "http_rate_limit_rejected_total",
+		Help: "Total number of http requests rejected by the rate limiter",
+	})
+
+	EmailSendFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "email_send_failures_total",
+		Help: "Total number of emails failed to send, by email type",
+	}, []string{"type"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		PacketsReceived,
+		PacketsSent,
+		ErrorsSent,
+		BroadcastDuration,
+		SendQueueDropped,
+		DatabaseQueryDuration,
+		HttpRequests,
+		HttpRequestDuration,
+		RateLimitRejected,
+		EmailSendFailures,
+	)
+}
+
+// Handler 返回暴露所有指标的http处理器
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ProtectedHandler 只允许通过 [config.MetricsServerConfig.Authorized] 校验的请求访问指标, 使用直连来源地址校验IP
+func ProtectedHandler(config *config.MetricsServerConfig) http.Handler {
+	handler := Handler()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !config.Authorized(remoteIp(r), r.Header.Get("Authorization")) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// remoteIp 获取请求的直连来源地址
+func remoteIp(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
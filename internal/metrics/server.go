@@ -0,0 +1,48 @@
+// Package metrics
+package metrics
+
+import (
+	"context"
+	"errors"
+	"github.com/half-nothing/simple-fsd/internal/health"
+	. "github.com/half-nothing/simple-fsd/internal/interfaces"
+	"net/http"
+	"time"
+)
+
+type MetricsServerShutdownCallback struct {
+	server *http.Server
+}
+
+func NewMetricsServerShutdownCallback(server *http.Server) *MetricsServerShutdownCallback {
+	return &MetricsServerShutdownCallback{server: server}
+}
+
+func (mc *MetricsServerShutdownCallback) Invoke(ctx context.Context) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return mc.server.Shutdown(timeoutCtx)
+}
+
+// StartMetricsServer 在独立端口上启动指标服务器
+func StartMetricsServer(applicationContent *ApplicationContent) {
+	config := applicationContent.ConfigManager().Config().Server.MetricsServer
+	logger := applicationContent.Logger()
+
+	mux := http.NewServeMux()
+	mux.Handle(config.Path, ProtectedHandler(config))
+	mux.Handle("/healthz", health.LivenessHandler())
+	mux.Handle("/readyz", health.ReadinessHandler())
+	server := &http.Server{
+		Addr:              config.Address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	applicationContent.Cleaner().Add(NewMetricsServerShutdownCallback(server))
+
+	logger.InfoF("Starting metrics server on %s%s", config.Address, config.Path)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.ErrorF("Metrics server error: %v", err)
+	}
+}
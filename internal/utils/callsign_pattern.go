@@ -0,0 +1,49 @@
+// Package utils
+package utils
+
+import (
+	"strings"
+)
+
+// maxCallsignPatternLength 呼号模式的最大长度, 与数据库字段长度一致
+const maxCallsignPatternLength = 32
+
+// CallsignPatternValid 校验呼号模式是否合法, 只允许大写字母, 数字, 下划线与通配符*和?
+func CallsignPatternValid(pattern string) bool {
+	if pattern == "" || len(pattern) > maxCallsignPatternLength {
+		return false
+	}
+	for _, c := range pattern {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '_' && c != '*' && c != '?' {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchCallsignPattern 判断呼号是否匹配模式, *匹配任意个字符, ?匹配单个字符, 不区分大小写
+// 比如ZBAA_*TWR可以匹配ZBAA_TWR与ZBAA_N_TWR, 与path.Match不同, *与?同样匹配/
+func MatchCallsignPattern(pattern string, callsign string) bool {
+	p, c := []rune(strings.ToUpper(pattern)), []rune(strings.ToUpper(callsign))
+	// star为最近一个*在模式中的位置, mark为该*开始匹配时呼号中的位置, 匹配失败时回溯到这里让*多匹配一个字符
+	pi, ci, star, mark := 0, 0, -1, 0
+	for ci < len(c) {
+		switch {
+		case pi < len(p) && (p[pi] == '?' || p[pi] == c[ci]):
+			pi++
+			ci++
+		case pi < len(p) && p[pi] == '*':
+			star, mark = pi, ci
+			pi++
+		case star >= 0:
+			mark++
+			pi, ci = star+1, mark
+		default:
+			return false
+		}
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
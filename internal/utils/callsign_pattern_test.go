@@ -0,0 +1,57 @@
+// Package utils
+package utils
+
+import "testing"
+
+func TestCallsignPatternValid(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    bool
+	}{
+		{"ZBAA_TWR", true},
+		{"ZBAA_*TWR", true},
+		{"ZSPD_?_APP", true},
+		{"", false},
+		{"zbaa_twr", false},
+		{"ZBAA_[NS]_TWR", false},
+		{"ZBAA/TWR", false},
+		{"ZBAA_TWR_ZBAA_TWR_ZBAA_TWR_ZBAA_TWR", false},
+	}
+	for _, test := range tests {
+		if result := CallsignPatternValid(test.pattern); result != test.want {
+			t.Errorf("CallsignPatternValid(%q) = %v, want %v", test.pattern, result, test.want)
+		}
+	}
+}
+
+func TestMatchCallsignPattern(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		callsign string
+		want     bool
+	}{
+		{"ZBAA_TWR", "ZBAA_TWR", true},
+		{"ZBAA_TWR", "zbaa_twr", true},
+		{"ZBAA_*TWR", "ZBAA_TWR", true},
+		{"ZBAA_*TWR", "ZBAA_N_TWR", true},
+		{"ZBAA_*TWR", "ZBAA_GND", false},
+		{"ZBAA_*", "ZBAA_DEL", true},
+		{"ZSPD_?_APP", "ZSPD_1_APP", true},
+		{"ZSPD_?_APP", "ZSPD_APP", false},
+		{"ZBAA_TWR", "ZBAD_TWR", false},
+		{"ZBAA_*TWR", "ZBAA_/_TWR", true},
+		{"ZBAA_?_TWR", "ZBAA_/_TWR", true},
+		{"ZBAA_*", "ZBAA_N/TWR", true},
+		{"*_TWR", "ZBAA_TWR", true},
+		{"*", "", true},
+		{"ZBAA_*_*_TWR", "ZBAA_N_1_TWR", true},
+		{"ZBAA_*_*_TWR", "ZBAA_N_TWR", false},
+		{"ZBAA_[N]_TWR", "ZBAA_N_TWR", false},
+		{"ZBAA_[N]_TWR", "ZBAA_[N]_TWR", true},
+	}
+	for _, test := range tests {
+		if result := MatchCallsignPattern(test.pattern, test.callsign); result != test.want {
+			t.Errorf("MatchCallsignPattern(%q, %q) = %v, want %v", test.pattern, test.callsign, result, test.want)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+// Package utils
+package utils
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+)
+
+// PkceChallenge 按照RFC 7636的S256方法计算code_verifier对应的code_challenge
+func PkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// VerifyPkce 校验code_verifier是否与S256方法生成的code_challenge匹配
+// code_verifier长度必须在43到128之间
+func VerifyPkce(verifier string, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 || challenge == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(PkceChallenge(verifier)), []byte(challenge)) == 1
+}
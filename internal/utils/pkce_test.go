@@ -0,0 +1,22 @@
+// Package utils
+package utils
+
+import "testing"
+
+// RFC 7636 附录B中的测试向量
+func TestVerifyPkce(t *testing.T) {
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	if result := PkceChallenge(verifier); result != challenge {
+		t.Errorf("PkceChallenge = %s, want %s", result, challenge)
+	}
+	if !VerifyPkce(verifier, challenge) {
+		t.Errorf("VerifyPkce rejected a valid verifier")
+	}
+	if VerifyPkce(verifier[1:]+"a", challenge) {
+		t.Errorf("VerifyPkce accepted a wrong verifier")
+	}
+	if VerifyPkce("short", PkceChallenge("short")) {
+		t.Errorf("VerifyPkce accepted a verifier shorter than 43 characters")
+	}
+}
@@ -0,0 +1,16 @@
+package utils
+
+// TruncateRunes 将字符串截断为最多maxRunes个字符, 不会截断多字节字符
+func TruncateRunes(str string, maxRunes int) string {
+	if maxRunes <= 0 {
+		return ""
+	}
+	count := 0
+	for index := range str {
+		if count == maxRunes {
+			return str[:index]
+		}
+		count++
+	}
+	return str
+}
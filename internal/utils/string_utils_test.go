@@ -0,0 +1,23 @@
+package utils
+
+import "testing"
+
+func TestTruncateRunes(t *testing.T) {
+	tests := []struct {
+		input    string
+		maxRunes int
+		expected string
+	}{
+		{"hello", 10, "hello"},
+		{"hello", 5, "hello"},
+		{"hello", 3, "hel"},
+		{"需要帮助", 2, "需要"},
+		{"a需要", 2, "a需"},
+		{"hello", 0, ""},
+	}
+	for _, test := range tests {
+		if actual := TruncateRunes(test.input, test.maxRunes); actual != test.expected {
+			t.Errorf("TruncateRunes(%q, %d) = %q, want %q", test.input, test.maxRunes, actual, test.expected)
+		}
+	}
+}
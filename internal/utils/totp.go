@@ -0,0 +1,89 @@
+// Package utils
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	TotpPeriod = 30 // 时间步长, 单位秒
+	TotpDigits = 6  // 验证码位数
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTotpSecret 生成随机的TOTP密钥, 使用无填充的Base32编码
+func GenerateTotpSecret() string {
+	secret := make([]byte, 20)
+	_, _ = rand.Read(secret)
+	return totpEncoding.EncodeToString(secret)
+}
+
+// TotpCounter 返回给定时间对应的时间步
+func TotpCounter(t time.Time) int64 {
+	return t.Unix() / TotpPeriod
+}
+
+// TotpCode 计算指定时间步的验证码(RFC 6238, HMAC-SHA1)
+func TotpCode(secret string, counter int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", err
+	}
+	message := make([]byte, 8)
+	binary.BigEndian.PutUint64(message, uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < TotpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", TotpDigits, value%mod), nil
+}
+
+// VerifyTotp 校验验证码, 允许前后skew个时间步的误差
+// 只接受大于lastCounter的时间步以防止验证码被重放, 校验通过时返回匹配的时间步
+func VerifyTotp(secret string, code string, now time.Time, skew int64, lastCounter int64) (counter int64, ok bool) {
+	if len(code) != TotpDigits {
+		return 0, false
+	}
+	current := TotpCounter(now)
+	for i := -skew; i <= skew; i++ {
+		counter = current + i
+		if counter <= lastCounter {
+			continue
+		}
+		expected, err := TotpCode(secret, counter)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return counter, true
+		}
+	}
+	return 0, false
+}
+
+// TotpProvisioningUri 生成供身份验证器扫描的otpauth链接
+func TotpProvisioningUri(issuer string, account string, secret string) string {
+	label := url.PathEscape(issuer + ":" + account)
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(TotpDigits))
+	query.Set("period", fmt.Sprint(TotpPeriod))
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
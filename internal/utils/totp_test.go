@@ -0,0 +1,59 @@
+// Package utils
+package utils
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 附录B中的SHA1测试向量, 取后6位
+func TestTotpCode(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	tests := []struct {
+		unix     int64
+		expected string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, test := range tests {
+		code, err := TotpCode(secret, TotpCounter(time.Unix(test.unix, 0)))
+		if err != nil {
+			t.Fatalf("TotpCode error: %v", err)
+		}
+		if code != test.expected {
+			t.Errorf("TotpCode(%d) = %s, want %s", test.unix, code, test.expected)
+		}
+	}
+}
+
+func TestVerifyTotp(t *testing.T) {
+	secret := GenerateTotpSecret()
+	now := time.Now()
+	code, _ := TotpCode(secret, TotpCounter(now)-1)
+	counter, ok := VerifyTotp(secret, code, now, 1, 0)
+	if !ok || counter != TotpCounter(now)-1 {
+		t.Fatalf("VerifyTotp rejected previous step code")
+	}
+	if _, ok := VerifyTotp(secret, code, now, 1, counter); ok {
+		t.Errorf("VerifyTotp accepted a replayed code")
+	}
+	if _, ok := VerifyTotp(secret, code, now, 0, 0); ok {
+		t.Errorf("VerifyTotp accepted a code outside the skew")
+	}
+	if _, ok := VerifyTotp(secret, "12345", now, 1, 0); ok {
+		t.Errorf("VerifyTotp accepted a code with wrong length")
+	}
+}
+
+func TestTotpProvisioningUri(t *testing.T) {
+	uri := TotpProvisioningUri("Simple FSD", "user", "ABC")
+	if !strings.HasPrefix(uri, "otpauth://totp/Simple%20FSD:user?") || !strings.Contains(uri, "secret=ABC") {
+		t.Errorf("unexpected provisioning uri %s", uri)
+	}
+}